@@ -3,13 +3,124 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"DevOps_Oct2023_TeamB_Assignment/microservices/account" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/auth"    //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/config"  //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/gateway" //change here
 	"DevOps_Oct2023_TeamB_Assignment/microservices/record"  //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/web"     //change here
+	"DevOps_Oct2023_TeamB_Assignment/static"                //change here
 )
 
 func main() {
-	go account.InitHTTPServer()
-	go record.InitHTTPServer()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	level, _ := cfg.SlogLevel()
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	if cfg.Auth.Secret != "" {
+		auth.SetSecret([]byte(cfg.Auth.Secret))
+	}
+	auth.SetTokenTTL(cfg.Auth.TokenTTL)
+
+	// "console migrate ..." manages the schema instead of starting the services
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(cfg, os.Args[2:]))
+	}
+
+	// "console purge" empties the trash of items past their retention
+	if len(os.Args) > 1 && os.Args[1] == "purge" {
+		os.Exit(runPurge(cfg, os.Args[2:]))
+	}
+
+	// "console report" writes the catalogue of one academic year
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		os.Exit(runReport(cfg, os.Args[2:]))
+	}
+
+	account.SetConfig(cfg)
+	record.SetConfig(cfg)
+
+	// Without a mode both services run separately on their own ports
+	mode := "all"
+	if len(os.Args) > 1 {
+		mode = os.Args[1]
+	}
+
+	var services []func(context.Context) error
+	switch mode {
+	case "all":
+		services = []func(context.Context) error{account.Run, record.Run}
+	case "account":
+		services = []func(context.Context) error{account.Run}
+	case "record":
+		services = []func(context.Context) error{record.Run}
+	case "gateway":
+		site, err := siteHandler(cfg, os.Args[2:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		services = []func(context.Context) error{func(ctx context.Context) error {
+			return gateway.Run(ctx, cfg, site)
+		}}
+	default:
+		fmt.Fprintln(os.Stderr, "usage: console [all|account|record|gateway [--dev] [--static dir]|migrate|purge [--retention d]|report --year y [--format html|pdf] [--out file]]")
+		os.Exit(2)
+	}
+
+	// Stop the services on Ctrl+C or when the orchestrator sends SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, len(services))
+	for _, run := range services {
+		go func(run func(context.Context) error) {
+			errs <- run(ctx)
+		}(run)
+	}
+
+	// If one service fails, shut the others down too
+	failed := false
+	for range services {
+		if err := <-errs; err != nil {
+			slog.Error("service stopped", "err", err)
+			failed = true
+			stop()
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// siteHandler serves the frontend for gateway mode, from the binary or, with
+// --dev, straight from the static directory so edits show up on reload
+func siteHandler(cfg config.Config, args []string) (http.Handler, error) {
+	flags := flag.NewFlagSet("gateway", flag.ContinueOnError)
+	dev := flags.Bool("dev", false, "serve the frontend from disk without caching")
+	dir := flags.String("static", "static", "frontend directory used with --dev")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
 
-	select {}
+	var files fs.FS = static.Files
+	if *dev {
+		files = os.DirFS(*dir)
+	}
+	return web.New(files, cfg.Web.APIBase, *dev), nil
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/config"  //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/migrate" //change here
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+const migrateUsage = `usage: console migrate <command>
+
+commands:
+  up [version]   apply pending migrations, up to version if given
+  down [steps]   roll back the last steps migrations (default 1)
+  baseline <version>
+                 record migrations up to version as applied without running them
+  status         list migrations and whether they are applied`
+
+// runMigrate handles "console migrate ..." and returns the exit code
+func runMigrate(cfg config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	n := 0
+	if len(args) > 1 {
+		v, err := strconv.Atoi(args[1])
+		if err != nil || v < 1 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		n = v
+	}
+
+	migrations, err := migrate.Embedded()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	db, err := sql.Open("mysql", cfg.Database.DSN)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	m := migrate.New(db, migrations)
+	switch args[0] {
+	case "up":
+		count, err := m.Up(n)
+		fmt.Println("Applied", count, "migrations")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		count, err := m.Down(n)
+		fmt.Println("Rolled back", count, "migrations")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	case "baseline":
+		if n == 0 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		count, err := m.Baseline(n)
+		fmt.Println("Recorded", count, "migrations as applied")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, state)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/account" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit"   //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/config"  //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/record"  //change here
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// runPurge handles "console purge [--retention d]", permanently removing
+// accounts and records that have been in the trash longer than the
+// retention period, and returns the exit code
+func runPurge(cfg config.Config, args []string) int {
+	flags := flag.NewFlagSet("purge", flag.ContinueOnError)
+	retention := flags.Duration("retention", cfg.Trash.Retention, "how long deleted items stay in the trash")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *retention <= 0 {
+		fmt.Fprintln(os.Stderr, "retention must be positive")
+		return 2
+	}
+
+	db, err := sql.Open("mysql", cfg.Database.DSN)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	// The purge runs unattended, so it is logged without an actor
+	before := time.Now().Add(-*retention)
+
+	accounts, err := account.NewMySQLStore(db).Purge(before, audit.Actor{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println("Purged", accounts, "accounts")
+
+	records, err := record.NewMySQLStore(db).Purge(before, audit.Actor{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println("Purged", records, "records")
+	return 0
+}
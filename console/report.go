@@ -0,0 +1,77 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/config" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/record" //change here
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// runReport handles "console report --year y [--format html|pdf] [--out
+// file]", writing the catalogue of an academic year to a file named after
+// the year, or to standard output with --out -, and returns the exit code
+func runReport(cfg config.Config, args []string) int {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	year := flags.String("year", "", "academic year to report on, such as 2023/2024")
+	format := flags.String("format", "pdf", "html or pdf")
+	out := flags.String("out", "", "file to write, or - for standard output")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if errs := record.ValidateAcadYr(*year); errs != nil {
+		fmt.Fprintln(os.Stderr, "year", errs[0].Message)
+		return 2
+	}
+	render, ok := record.ReportFormats[*format]
+	if !ok {
+		fmt.Fprintln(os.Stderr, "format must be html or pdf")
+		return 2
+	}
+
+	db, err := sql.Open("mysql", cfg.Database.DSN)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	c, err := record.Catalogue(record.NewMySQLStore(db), *year, time.Now().UTC())
+	if errors.Is(err, record.ErrNoRecords) {
+		fmt.Fprintln(os.Stderr, "No records for", *year)
+		return 1
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *out == "" {
+		*out = c.FileName(*format)
+	}
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := render(w, c); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *out != "-" {
+		fmt.Println("Wrote", c.Count(), "projects to", *out)
+	}
+	return 0
+}
@@ -1,267 +1,510 @@
 package account
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
 	"strconv"
 
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"        //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit"      //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/auth"       //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/config"     //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/middleware" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/patch"      //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/query"      //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/service"    //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/stats"      //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/validate"   //change here
+
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 )
 
+// Account fields are limited to what the Account table can hold. Password is
+// checked before hashing, against the 72 bytes bcrypt accepts. Version counts
+// the changes to an account, starting at 1, and is sent as its ETag.
+// MustChangePassword is set for a generated password, which only lasts until
+// the first login; clients never see or set it.
 type Account struct {
-	AccID     int    `json:"accId"`
-	Username  string `json:"username"`
-	Password  string `json:"password"`
-	AccType   string `json:"accType"`
-	AccStatus string `json:"accStatus"`
+	AccID              int    `json:"accId"`
+	Username           string `json:"username" validate:"required,max=50"`
+	Password           string `json:"password,omitempty" validate:"required,maxbytes=72"`
+	AccType            string `json:"accType" validate:"required,oneof=Admin User"`
+	AccStatus          string `json:"accStatus" validate:"required,oneof=Created Pending"`
+	Version            int    `json:"version,omitempty"`
+	MustChangePassword bool   `json:"-"`
 }
 
-var (
-	db  *sql.DB
-	err error
-)
+// accountUpdate is the part of an account that UpdateAccHandler may change
+type accountUpdate struct {
+	Username string `json:"username" validate:"required,max=50"`
+	AccType  string `json:"accType" validate:"required,oneof=Admin User"`
+}
+
+var cfg = config.Default()
 
-func SetDB(database *sql.DB) {
-	db = database
+func SetConfig(c config.Config) {
+	cfg = c
 }
 
-func DB() {
-	db, err = sql.Open("mysql", "record_system:dopasgpwd@tcp(127.0.0.1:3306)/record_db")
+// DB opens the database configured for the service
+func DB() (*sql.DB, error) {
+	db, err := sql.Open("mysql", cfg.Database.DSN)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	cfg.Database.ApplyPool(db)
 
 	if err := db.Ping(); err != nil {
-		log.Fatal(err)
+		db.Close()
+		return nil, err
 	}
 
 	fmt.Println("Connected to the database")
+	return db, nil
+}
+
+// Server serves the account API from an AccountStore
+type Server struct {
+	store AccountStore
+	stats *stats.Cache
+}
+
+// NewServer returns a server for store that caches statistics for the
+// configured time
+func NewServer(store AccountStore) *Server {
+	return &Server{store: store, stats: stats.NewCache(store.Stats(), cfg.Stats.CacheTTL)}
+}
+
+// Run serves the account API until ctx is cancelled, then drains in-flight
+// requests and closes the database pool
+func Run(ctx context.Context) error {
+	db, err := DB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Share logouts with every service through the database
+	auth.SetRevocationStore(auth.NewSQLRevocationStore(db))
+
+	router := NewServer(NewMySQLStore(db)).Router()
+
+	server := &http.Server{
+		Addr:         cfg.Account.Addr(),
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		Handler:      service.CORS(cfg.HTTP)(api.RequestID(router)),
+	}
+
+	fmt.Println("Listening at port", cfg.Account.Port)
+	return service.Serve(ctx, server, cfg.HTTP.ShutdownTimeout)
 }
 
-func InitHTTPServer() {
-	DB()
+// access policy for every account route
+var routePolicies = middleware.Policies{
+	"GET /healthz":                                middleware.Public,
+	"GET /readyz":                                 middleware.Public,
+	"POST /api/v1/auth/login":                     middleware.Public,
+	"POST /api/v1/auth/refresh":                   middleware.Public,
+	"POST /api/v1/auth/logout":                    middleware.Public,
+	"POST /api/v1/accounts":                       middleware.Public,
+	"GET /api/v1/accounts/all":                    middleware.AdminOnly,
+	"POST /api/v1/admin/accounts":                 middleware.AdminOnly,
+	"POST /api/v1/accounts/approve":               middleware.AdminOnly,
+	"POST /api/v1/accounts/{accID}/approve":       middleware.AdminOnly,
+	"DELETE /api/v1/accounts/delete":              middleware.AdminOnly,
+	"DELETE /api/v1/accounts/{accID}":             middleware.AdminOnly,
+	"GET /api/v1/accounts/get":                    middleware.AdminOnly,
+	"GET /api/v1/accounts/{accID}":                middleware.AdminOnly,
+	"PUT /api/v1/accounts/{accID}":                middleware.AdminOnly,
+	"PATCH /api/v1/accounts/{accID}":              middleware.AdminOnly,
+	"GET /api/v1/accounts/trash":                  middleware.AdminOnly,
+	"POST /api/v1/accounts/trash/{accID}/restore": middleware.AdminOnly,
+	"GET /api/v1/audit":                           middleware.AdminOnly,
+	"GET /api/v1/stats":                           middleware.AdminOnly,
+}
 
+// Router returns the account routes behind the authorization middleware
+func (s *Server) Router() *mux.Router {
 	router := mux.NewRouter()
-	router.HandleFunc("/api/v1/accounts", CreateAccHandler).Methods("POST")
-	router.HandleFunc("/api/v1/accounts", GetAccHandler).Methods("GET")
-	router.HandleFunc("/api/v1/accounts/all", ListAllAccsHandler).Methods("GET")
-	router.HandleFunc("/api/v1/accounts/approve", ApproveAccHandler).Methods("POST")
-	router.HandleFunc("/api/v1/accounts", AdminCreateAccHandler).Methods("POST")
-	router.HandleFunc("/api/v1/accounts/delete", DeleteAccHandler).Methods("DELETE")
-	router.HandleFunc("/api/v1/accounts/get", GetSpecificAccHandler).Methods("GET")
-	router.HandleFunc("/api/v1/accounts/{accID}", UpdateAccHandler).Methods("PUT")
-
-	fmt.Println("Listening at port 5001")
-	http.ListenAndServe(":5001",
-		handlers.CORS(
-			handlers.AllowedOrigins([]string{"*"}),
-			handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
-			handlers.AllowedHeaders([]string{"Origin", "X-Api-Key", "X-Requested-With", "Content-Type", "Accept", "Authorization"}),
-			handlers.AllowCredentials(),
-		)(router))
+	router.NotFoundHandler = http.HandlerFunc(api.NotFound)
+	router.MethodNotAllowedHandler = http.HandlerFunc(api.MethodNotAllowed)
+	router.Use(middleware.Authorize(routePolicies, s.resolveIdentity))
+
+	router.HandleFunc("/healthz", service.Healthz).Methods("GET")
+	router.HandleFunc("/readyz", service.Readyz(s.store, cfg.HTTP.ReadyTimeout)).Methods("GET")
+
+	router.HandleFunc("/api/v1/auth/login", s.LoginHandler).Methods("POST")
+	router.HandleFunc("/api/v1/auth/refresh", s.RefreshHandler).Methods("POST")
+	router.HandleFunc("/api/v1/auth/logout", s.LogoutHandler).Methods("POST")
+	router.HandleFunc("/api/v1/accounts", s.CreateAccHandler).Methods("POST")
+	router.HandleFunc("/api/v1/accounts/all", s.ListAllAccsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/accounts/approve", s.ApproveAccHandler).Methods("POST")
+	router.HandleFunc("/api/v1/admin/accounts", s.AdminCreateAccHandler).Methods("POST")
+	router.HandleFunc("/api/v1/accounts/delete", s.DeleteAccHandler).Methods("DELETE")
+	router.HandleFunc("/api/v1/accounts/get", s.GetSpecificAccHandler).Methods("GET")
+	router.HandleFunc("/api/v1/accounts/{accID}", s.UpdateAccHandler).Methods("PUT")
+	router.HandleFunc("/api/v1/accounts/{accID}", s.PatchAccHandler).Methods("PATCH")
+	router.HandleFunc("/api/v1/accounts/trash", s.ListTrashHandler).Methods("GET")
+	router.HandleFunc("/api/v1/accounts/trash/{accID}/restore", s.RestoreAccHandler).Methods("POST")
+	router.HandleFunc("/api/v1/audit", s.ListAuditHandler).Methods("GET")
+	router.HandleFunc("/api/v1/stats", s.StatsHandler).Methods("GET")
+	// After the fixed paths so they do not shadow them
+	router.HandleFunc("/api/v1/accounts/{accID}", s.GetSpecificAccHandler).Methods("GET")
+	router.HandleFunc("/api/v1/accounts/{accID}", s.DeleteAccHandler).Methods("DELETE")
+	router.HandleFunc("/api/v1/accounts/{accID}/approve", s.ApproveAccHandler).Methods("POST")
+
+	return router
+}
+
+// resolveIdentity loads the caller's current type and status from the store
+func (s *Server) resolveIdentity(accID int) (middleware.Identity, error) {
+	acc, err := s.store.Get(accID)
+	if err == ErrNotFound {
+		return middleware.Identity{}, middleware.ErrUnknownAccount
+	} else if err != nil {
+		return middleware.Identity{}, err
+	}
+	return middleware.Identity{AccID: acc.AccID, AccType: acc.AccType, AccStatus: acc.AccStatus}, nil
 }
 
-func CreateAccHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateAccHandler(w http.ResponseWriter, r *http.Request) {
 	var newAcc Account
 	err := json.NewDecoder(r.Body).Decode(&newAcc)
 	if err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		api.InvalidPayload(w, r)
 		return
 	}
 
-	// Insert the new account into the database
-	stmt, err := db.Prepare("INSERT INTO Account (Username, Password, AccType, AccStatus) VALUES (?, ?, ?, ?)")
+	// Self-signup always creates a user account awaiting admin approval
+	newAcc.AccType = "User"
+	newAcc.AccStatus = "Pending"
+
+	if errs := validate.Struct(newAcc); errs != nil {
+		api.Invalid(w, r, errs)
+		return
+	}
+
+	// Hash the password before it is stored
+	hashedPwd, err := hashPassword(newAcc.Password)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		api.Internal(w, r)
 		return
 	}
-	defer stmt.Close()
 
-	_, err = stmt.Exec(newAcc.Username, newAcc.Password, newAcc.AccType, newAcc.AccStatus)
+	// Insert the new account into the database
+	newAcc.Password = hashedPwd
+	newAcc.AccID, err = s.store.Create(newAcc, audit.ActorFrom(r))
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		api.Internal(w, r)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	fmt.Fprintln(w, "Account created successfully")
+	// Respond with the new account, never its password hash
+	newAcc.Password, newAcc.Version = "", 1
+	api.SetETag(w, newAcc.Version)
+	api.Created(w, accountLocation(newAcc.AccID), newAcc)
 }
 
-func GetAccHandler(w http.ResponseWriter, r *http.Request) {
-	username := r.URL.Query().Get("username")
-	password := r.URL.Query().Get("password")
+// list options accepted by ListAllAccsHandler
+var accountListSpec = query.Spec{
+	Table:   "Account",
+	Columns: []string{"AccID", "Username", "AccType", "AccStatus", "Version"},
+	Where:   "DeletedAt IS NULL",
+	Key:     "AccID",
+	Sortable: map[string]string{
+		"accId":     "AccID",
+		"username":  "Username",
+		"accType":   "AccType",
+		"accStatus": "AccStatus",
+	},
+	Filterable: map[string]string{
+		"username":  "Username",
+		"accType":   "AccType",
+		"accStatus": "AccStatus",
+	},
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
 
-	if username == "" || password == "" {
-		http.Error(w, "Username and Password parameters are required", http.StatusBadRequest)
-		return
+func scanAccount(rows *sql.Rows) (Account, error) {
+	var acc Account
+	err := rows.Scan(&acc.AccID, &acc.Username, &acc.AccType, &acc.AccStatus, &acc.Version)
+	return acc, err
+}
+
+// accountValue returns the value of a sortable column for building cursors
+func accountValue(acc Account, column string) interface{} {
+	switch column {
+	case "AccID":
+		return acc.AccID
+	case "Username":
+		return acc.Username
+	case "AccType":
+		return acc.AccType
+	case "AccStatus":
+		return acc.AccStatus
+	case "Version":
+		return acc.Version
 	}
+	return nil
+}
 
-	var acc Account
-	err := db.QueryRow("SELECT AccID, Username, Password, AccType, AccStatus FROM Account WHERE Username = ? AND Password = ?", username, password).Scan(&acc.AccID, &acc.Username, &acc.Password, &acc.AccType, &acc.AccStatus)
-	if err == sql.ErrNoRows {
-		http.Error(w, "Anvalid Username or Password", http.StatusNotFound)
+func (s *Server) ListAllAccsHandler(w http.ResponseWriter, r *http.Request) {
+	params, err := accountListSpec.Parse(r.URL.Query())
+	if err != nil {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, err.Error())
 		return
-	} else if err != nil {
-		http.Error(w, "Bnternal server error", http.StatusInternalServerError)
+	}
+
+	page, err := s.store.List(params)
+	if err != nil {
+		api.Internal(w, r)
 		return
 	}
 
-	// Respond with user information
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(acc)
+	// Respond with the page of users
+	api.JSON(w, http.StatusOK, page)
 }
 
-func ListAllAccsHandler(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query("SELECT AccID, Username, AccType, AccStatus FROM Account")
-	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+func (s *Server) ApproveAccHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the account ID from the request parameters
+	accID, ok := accIDParam(w, r)
+	if !ok {
 		return
 	}
-	defer rows.Close()
 
-	var accs []Account
-	for rows.Next() {
-		var acc Account
-		err := rows.Scan(&acc.AccID, &acc.Username, &acc.AccType, &acc.AccStatus)
-		if err != nil {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		accs = append(accs, acc)
+	// Update the account status in the database
+	err := s.store.Approve(accID, audit.ActorFrom(r))
+	if err == ErrNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Account not found")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
 	}
 
-	// Respond with the list of users
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(accs)
+	api.Message(w, http.StatusOK, "Account approved successfully")
 }
 
-func ApproveAccHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) DeleteAccHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse the account ID from the request parameters
-	accID := r.URL.Query().Get("accID")
-	if accID == "" {
-		http.Error(w, "Account ID parameter is required", http.StatusBadRequest)
+	accID, ok := accIDParam(w, r)
+	if !ok {
 		return
 	}
 
-	// Update the account status in the database
-	stmt, err := db.Prepare("UPDATE Account SET AccStatus = 'Created' WHERE AccID = ?")
-	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	// Delete the account from the database
+	err := s.store.Delete(accID, audit.ActorFrom(r))
+	if err == ErrNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Account not found")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
 		return
 	}
-	defer stmt.Close()
 
-	_, err = stmt.Exec(accID)
+	api.Message(w, http.StatusOK, "Account deleted successfully")
+}
+
+// accountLocation is the path of an account's resource
+func accountLocation(accID int) string {
+	return fmt.Sprintf("/api/v1/accounts/%d", accID)
+}
+
+// accIDParam reads the account ID from the path, or from the accID query
+// parameter of the older routes, writing a 400 response when it is missing
+// or not a number
+func accIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v, ok := mux.Vars(r)["accID"]
+	if !ok {
+		v = r.URL.Query().Get("accID")
+	}
+	if v == "" {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Account ID parameter is required")
+		return 0, false
+	}
+	accID, err := strconv.Atoi(v)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid Account ID")
+		return 0, false
+	}
+	return accID, true
+}
+
+func (s *Server) GetSpecificAccHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the account ID from the request parameters
+	accID, ok := accIDParam(w, r)
+	if !ok {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintln(w, "Account approved successfully")
+	// get the account from the database
+	acc, err := s.store.Get(accID)
+	if err == ErrNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Account not found")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	api.SetETag(w, acc.Version)
+	api.JSON(w, http.StatusOK, acc)
 }
 
-func AdminCreateAccHandler(w http.ResponseWriter, r *http.Request) {
-	var newAcc Account
-	err := json.NewDecoder(r.Body).Decode(&newAcc)
+func (s *Server) UpdateAccHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the user ID from the request URL
+	vars := mux.Vars(r)
+	accID, err := strconv.Atoi(vars["accID"])
 	if err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid Account ID")
 		return
 	}
 
-	// Insert the new account into the database
-	stmt, err := db.Prepare("INSERT INTO Account (Username, Password, AccType, AccStatus) VALUES (?, ?, ?, ?)")
+	var update accountUpdate
+	err = json.NewDecoder(r.Body).Decode(&update)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		api.InvalidPayload(w, r)
 		return
 	}
-	defer stmt.Close()
 
-	_, err = stmt.Exec(newAcc.Username, newAcc.Password, newAcc.AccType, newAcc.AccStatus)
-	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	if errs := validate.Struct(update); errs != nil {
+		api.Invalid(w, r, errs)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	fmt.Fprintln(w, "Account created successfully")
-}
+	// Only the version the client last read may be replaced
+	version, ok := api.IfMatch(w, r)
+	if !ok {
+		return
+	}
 
-func DeleteAccHandler(w http.ResponseWriter, r *http.Request) {
-	// Parse the account ID from the request parameters
-	accID := r.URL.Query().Get("accID")
-	if accID == "" {
-		http.Error(w, "Account ID parameter is required", http.StatusBadRequest)
+	// Update the user's information in the database
+	updatedAcc := Account{AccID: accID, Username: update.Username, AccType: update.AccType, Version: version}
+	err = s.store.Update(updatedAcc, audit.ActorFrom(r))
+	if err == ErrNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Account not found")
+		return
+	} else if err == ErrVersionConflict {
+		api.PreconditionFailed(w, r, "Account was changed by someone else; reload it and try again")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
 		return
 	}
 
-	// Delete the account from the database
-	stmt, err := db.Prepare("DELETE FROM Account WHERE AccID = ?")
+	if version > 0 {
+		api.SetETag(w, version+1)
+	}
+	api.Message(w, http.StatusAccepted, "Account updated successfully!")
+}
+
+// PatchAccHandler changes the username or type of an account as a JSON merge
+// patch names them and responds with the account
+func (s *Server) PatchAccHandler(w http.ResponseWriter, r *http.Request) {
+	accID, err := strconv.Atoi(mux.Vars(r)["accID"])
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid Account ID")
 		return
 	}
-	defer stmt.Close()
 
-	_, err = stmt.Exec(accID)
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		api.InvalidPayload(w, r)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintln(w, "Account deleted successfully")
-}
+	current, err := s.store.Get(accID)
+	if err == ErrNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Account not found")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
 
-func GetSpecificAccHandler(w http.ResponseWriter, r *http.Request) {
-	// Parse the account ID from the request parameters
-	accID := r.URL.Query().Get("accID")
+	// Apply the patch and check only the fields it supplies. Passwords and
+	// approval have their own routes.
+	patched := current
+	fields, err := patch.Apply(&patched, body, "accId", "password", "accStatus", "version")
+	if errs, ok := err.(validate.Errors); ok {
+		api.Invalid(w, r, errs)
+		return
+	} else if err != nil {
+		api.InvalidPayload(w, r)
+		return
+	}
+	if errs := validate.Fields(patched, fields...); errs != nil {
+		api.Invalid(w, r, errs)
+		return
+	}
 
-	// get the account from the database
-	var acc Account
-	db.QueryRow("Select * FROM Account WHERE AccID = ?", accID).Scan(&acc.AccID, &acc.Username, &acc.Password, &acc.AccType, &acc.AccStatus)
+	// If-Match is optional, as the patch leaves the other fields alone
+	if version := api.MatchVersion(r); version != 0 && version != current.Version {
+		api.PreconditionFailed(w, r, "Account was changed by someone else; reload it and try again")
+		return
+	}
+
+	// Write only the columns that changed; an empty patch writes nothing
+	if columns := patch.Changed(current, patched); columns != nil {
+		err = s.store.Patch(patched, columns, audit.ActorFrom(r))
+		if err == ErrNotFound {
+			api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Account not found")
+			return
+		} else if err == ErrVersionConflict {
+			api.PreconditionFailed(w, r, "Account was changed by someone else; reload it and try again")
+			return
+		} else if err != nil {
+			api.Internal(w, r)
+			return
+		}
+		patched.Version++
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	fmt.Println(acc)
-	json.NewEncoder(w).Encode(acc)
+	api.SetETag(w, patched.Version)
+	api.JSON(w, http.StatusOK, patched)
 }
 
-func UpdateAccHandler(w http.ResponseWriter, r *http.Request) {
-	// Parse the user ID from the request URL
-	vars := mux.Vars(r)
-	accID, err := strconv.Atoi(vars["accID"])
+// ListAuditHandler lists the audit log of account and record changes,
+// newest first, filtered by actor, action, entity, entityId and a from/to
+// time range
+func (s *Server) ListAuditHandler(w http.ResponseWriter, r *http.Request) {
+	params, err := audit.Parse(r.URL.Query())
 	if err != nil {
-		http.Error(w, "Invalid Account ID", http.StatusBadRequest)
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, err.Error())
 		return
 	}
 
-	var updatedAcc Account
-	err = json.NewDecoder(r.Body).Decode(&updatedAcc)
+	page, err := s.store.AuditLog().List(params)
 	if err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		api.Internal(w, r)
 		return
 	}
 
-	// Update the user's information in the database
-	stmt, err := db.Prepare("UPDATE Account SET Username=?, AccType=? WHERE AccID=?")
-	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	api.JSON(w, http.StatusOK, page)
+}
+
+// StatsHandler summarises the records and accounts for the admin overview.
+// Records can be limited to the academic years from and to, inclusive. A
+// summary is reused for the cache TTL, so it may be a little behind.
+func (s *Server) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	rng, errs := stats.Parse(r.URL.Query())
+	if errs != nil {
+		api.ErrorDetails(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "from and to must be academic years such as 2023/2024", errs)
 		return
 	}
-	defer stmt.Close()
 
-	_, err = stmt.Exec(updatedAcc.Username, updatedAcc.AccType, accID)
+	summary, err := s.stats.Summary(rng)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		api.Internal(w, r)
 		return
 	}
 
-	w.WriteHeader(http.StatusAccepted)
-	fmt.Fprintln(w, "Account updated successfully!")
+	api.JSON(w, http.StatusOK, summary)
 }
@@ -3,6 +3,7 @@ package account
 
 import (
 	"bytes"
+	"database/sql/driver"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,67 +13,83 @@ import (
 	"strings"
 	"testing"
 
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"   //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/auth"  //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/query" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/stats" //change here
+
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
 )
 
-func TestCreateAccHandler(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
-
-	// Set up expected database query and result
-	mock.ExpectPrepare("INSERT INTO Account").
-		ExpectExec().
-		WithArgs("testacc", "testpwd", "User", "Pending").
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	newAcc := Account{
-		Username:  "testacc",
-		Password:  "testpwd",
-		AccType:   "User",
-		AccStatus: "Pending",
-	}
-	payload, err := json.Marshal(newAcc)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	req, err := http.NewRequest("POST", "/api/v1/accounts", bytes.NewBuffer(payload))
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Create a ResponseRecorder to record the response
-	rr := httptest.NewRecorder()
-
-	// Call the handler directly
-	CreateAccHandler(rr, req)
-
-	// Check the status code
-	if status := rr.Code; status != http.StatusCreated {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusCreated)
-	}
-
-	// Check the response body
-	expected := "Account created successfully\n"
-	if rr.Body.String() != expected {
-		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
-	}
+// hashOf matches a bcrypt hash of the given plaintext password
+type hashOf string
 
-	// Verify that the expectations were met
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Errorf("there were unfulfilled expectations: %s", err)
+func (h hashOf) Match(v driver.Value) bool {
+	hash, ok := v.(string)
+	if !ok {
+		return false
 	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(h)) == nil
+}
 
-	defer mock.ExpectationsWereMet() // Ensure expectations are checked even if the test fails early
+func TestCreateAccHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		// Set up expected database query and result
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectPrepare("INSERT INTO Account").
+				ExpectExec().
+				WithArgs("testacc", hashOf("testpwd"), "User", "Pending", false).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectAudit(mock, audit.ActionCreate, 1, nil)
+			mock.ExpectCommit()
+		})
+
+		newAcc := Account{
+			Username:  "testacc",
+			Password:  "testpwd",
+			AccType:   "User",
+			AccStatus: "Pending",
+		}
+		payload, err := json.Marshal(newAcc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest("POST", "/api/v1/accounts", bytes.NewBuffer(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Create a ResponseRecorder to record the response
+		rr := httptest.NewRecorder()
+
+		// Call the handler directly
+		f.server().CreateAccHandler(rr, req)
+
+		// Check the status code
+		if status := rr.Code; status != http.StatusCreated {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+		}
+
+		// Check the response body
+		expected := `{"accId":1,"username":"testacc","accType":"User","accStatus":"Pending","version":1}` + "\n"
+		if rr.Body.String() != expected {
+			t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+		}
+		if location := rr.Header().Get("Location"); location != "/api/v1/accounts/1" {
+			t.Errorf("Handler returned wrong Location: got %v want %v", location, "/api/v1/accounts/1")
+		}
+
+		// The password is stored hashed
+		if acc, ok := f.stored(1); ok && !hashOf("testpwd").Match(acc.Password) {
+			t.Errorf("Handler stored an unhashed password: %v", acc.Password)
+		}
+	})
 }
 
 func TestCreateAccHandler_Unmarhal(t *testing.T) {
@@ -91,12 +108,13 @@ func TestCreateAccHandler_Unmarhal(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call the handler directly
-	CreateAccHandler(rr, req)
+	NewServer(NewMemoryStore()).CreateAccHandler(rr, req)
 
 	// Check the status code
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
+	expectErrorCode(t, rr, api.CodeInvalidPayload)
 }
 
 func TestCreateAccHandler_Prepare(t *testing.T) {
@@ -116,15 +134,7 @@ func TestCreateAccHandler_Prepare(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
+	server, mock := mysqlServer(t)
 
 	// Create a mock MySQL error
 	mockError := &mysql.MySQLError{
@@ -133,30 +143,25 @@ func TestCreateAccHandler_Prepare(t *testing.T) {
 	}
 
 	// Mock Prepare to return the mock MySQL error
+	mock.ExpectBegin()
 	mock.ExpectPrepare("INSERT INTO Account").WillReturnError(mockError)
+	mock.ExpectRollback()
 
 	// Create a ResponseRecorder to record the response
 	rr := httptest.NewRecorder()
 
 	// Call the handler directly
-	CreateAccHandler(rr, req)
+	server.CreateAccHandler(rr, req)
 
 	// Check the status code
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
+	expectErrorCode(t, rr, api.CodeInternal)
 }
 
 func TestCreateAccHandler_Exec(t *testing.T) {
-	// Create a new instance of sqlmock
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
-
-	// Replace your existing db with the mocked one
-	SetDB(db)
+	server, mock := mysqlServer(t)
 
 	// Create a mock MySQL error
 	mockError := &mysql.MySQLError{
@@ -165,9 +170,11 @@ func TestCreateAccHandler_Exec(t *testing.T) {
 	}
 
 	// Set up expectations for your query
+	mock.ExpectBegin()
 	mock.ExpectPrepare("INSERT INTO Account").ExpectExec().
-		WithArgs("test_username", "test_password", "test_type", "test_status").
+		WithArgs("test_username", hashOf("test_password"), "User", "Pending", false).
 		WillReturnError(mockError)
+	mock.ExpectRollback()
 
 	// Create a request with the required payload (JSON encoded)
 	reqBody := `{"username": "test_username", "password": "test_password", "accType": "test_type", "accStatus": "test_status"}`
@@ -180,7 +187,7 @@ func TestCreateAccHandler_Exec(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call your handler function with the mocked database
-	CreateAccHandler(rr, req)
+	server.CreateAccHandler(rr, req)
 
 	// Check the response status code
 	if status := rr.Code; status != http.StatusInternalServerError {
@@ -188,257 +195,148 @@ func TestCreateAccHandler_Exec(t *testing.T) {
 			status, http.StatusInternalServerError)
 	}
 
-	// Check the response body
-	expectedBody := "Internal server error\n"
-	if rr.Body.String() != expectedBody {
-		t.Errorf("handler returned unexpected body: got %v want %v",
-			rr.Body.String(), expectedBody)
-	}
+	// Check the error code
+	expectErrorCode(t, rr, api.CodeInternal)
 }
 
-func TestGetAccHandler(t *testing.T) {
-	username := "testacc"
-	password := "testpwd"
-
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
-
-	// Set up expectations for the query and scan to return sql.ErrNoRows
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, Password, AccType, AccStatus FROM Account WHERE Username = ? AND Password = ?")).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
-		WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "Password", "AccType", "AccStatus"}).
-			AddRow(1, "testacc", "testpwd", "user", "active")) // Simulating a successful row
-
-	req, err := http.NewRequest("GET", fmt.Sprintf("/api/v1/accounts?username=%s&password=%s", username, password), nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	rr := httptest.NewRecorder()
-
-	GetAccHandler(rr, req)
-
-	if status := rr.Code; status != http.StatusOK {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
-	}
-
-	// Unmarshal the response body into an Account struct
-	var acc Account
-	err = json.NewDecoder(rr.Body).Decode(&acc)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Check the retrieved account information
-	expectedUsername := "testacc"
-	if acc.Username != expectedUsername {
-		t.Errorf("Handler returned unexpected username: got %v want %v", acc.Username, expectedUsername)
-	}
-
-	// Verify that the expectations were met
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Errorf("there were unfulfilled expectations: %s", err)
-	}
+func TestCreateAccHandler_ForcesUserPending(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		// Self-signup cannot choose its own type or status
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectPrepare("INSERT INTO Account").
+				ExpectExec().
+				WithArgs("sneaky", hashOf("sneakypwd"), "User", "Pending", false).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectAudit(mock, audit.ActionCreate, 1, nil)
+			mock.ExpectCommit()
+		})
+
+		reqBody := `{"username": "sneaky", "password": "sneakypwd", "accType": "Admin", "accStatus": "Created"}`
+		req, err := http.NewRequest("POST", "/api/v1/accounts", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		f.server().CreateAccHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusCreated {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+		}
+
+		if acc, ok := f.stored(1); ok && (acc.AccType != "User" || acc.AccStatus != "Pending") {
+			t.Errorf("Handler stored unexpected type and status: %v %v", acc.AccType, acc.AccStatus)
+		}
+	})
 }
 
-func TestGetAccHandler_Password(t *testing.T) {
-	username := "testacc"
-	password := ""
-	// Create a request with query parameters
-	req, err := http.NewRequest("GET", fmt.Sprintf("/api/v1/accounts?username=%s&password=%s", username, password), nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Create a ResponseRecorder to record the response
-	rr := httptest.NewRecorder()
-
-	// Call your handler function with the request
-	GetAccHandler(rr, req)
-
-	// Check the response status code
-	if status := rr.Code; status != http.StatusBadRequest {
-		t.Errorf("handler returned wrong status code: got %v want %v",
-			status, http.StatusBadRequest)
-	}
-
-	// Check the response body
-	expectedBody := "Username and Password parameters are required\n"
-	if rr.Body.String() != expectedBody {
-		t.Errorf("handler returned unexpected body: got %v want %v",
-			rr.Body.String(), expectedBody)
-	}
-}
-
-func TestGetAccHandler_Norows(t *testing.T) {
-	username := "testacc"
-	password := "testpwd"
-
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
-
-	// Set up the mock expectation for QueryRow to return an empty result set
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, Password, AccType, AccStatus FROM Account WHERE Username = ? AND Password = ?")).
-		WithArgs(username, password).
-		WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "Password", "AccType", "AccStatus"}))
-
-	req, err := http.NewRequest("GET", fmt.Sprintf("/api/v1/accounts?username=%s&password=%s", username, password), nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	rr := httptest.NewRecorder()
-
-	GetAccHandler(rr, req)
-
-	// Check the response status code
-	if status := rr.Code; status != http.StatusNotFound {
-		t.Errorf("handler returned wrong status code: got %v want %v",
-			status, http.StatusNotFound)
-	}
-}
-
-func TestGetAccHandler_OtherErr(t *testing.T) {
-	username := "testacc"
-	password := "testpwd"
-
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
-
-	// Create a mock MySQL error
-	mockError := &mysql.MySQLError{
-		Number:  1062,                                      // MySQL error number (example)
-		Message: "Duplicate entry 'xyz' for key 'PRIMARY'", // MySQL error message (example)
-	}
-
-	mock.ExpectQuery("SELECT AccID, Username, Password, AccType, AccStatus FROM Account WHERE Username = ? AND Password = ?").
-		WithArgs(username, password).
-		WillReturnError(mockError)
-
-	req, err := http.NewRequest("GET", fmt.Sprintf("/api/v1/accounts?username=%s&password=%s", username, password), nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	rr := httptest.NewRecorder()
-
-	GetAccHandler(rr, req)
-
-	// Check the response status code
-	if status := rr.Code; status != http.StatusInternalServerError {
-		t.Errorf("handler returned wrong status code: got %v want %v",
-			status, http.StatusInternalServerError)
+func TestCreateAccHandler_Validation(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{"missing fields", `{}`, `{"code":"validation_failed","message":"Request validation failed","details":[{"field":"username","message":"is required"},{"field":"password","message":"is required"}],"requestId":""}`},
+		{"long username", `{"username": "` + strings.Repeat("u", 51) + `", "password": "pwd"}`, `{"code":"validation_failed","message":"Request validation failed","details":[{"field":"username","message":"must be at most 50 characters"}],"requestId":""}`},
+		{"long password", `{"username": "user", "password": "` + strings.Repeat("p", 73) + `"}`, `{"code":"validation_failed","message":"Request validation failed","details":[{"field":"password","message":"must be at most 72 bytes"}],"requestId":""}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Invalid payloads are rejected before any SQL is run
+			server, mock := mysqlServer(t)
+
+			req, err := http.NewRequest("POST", "/api/v1/accounts", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			server.CreateAccHandler(rr, req)
+
+			if status := rr.Code; status != http.StatusUnprocessableEntity {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
+			}
+			if rr.Body.String() != tt.expected+"\n" {
+				t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), tt.expected)
+			}
+
+			// Verify that the expectations were met
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
 	}
 }
 
 func TestApproveAccHandler(t *testing.T) {
 	// accID follows the existing acc with pending status in record_db for testing approval
-	accID := "2004"
-
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
-
-	// Set up expected database query and result
-	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET AccStatus = 'Created' WHERE AccID = ?")).
-		ExpectExec().
-		WithArgs(accID).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	req, err := http.NewRequest("POST", fmt.Sprintf("/api/v1/accounts/approve?accID=%s", accID), nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	rr := httptest.NewRecorder()
-
-	ApproveAccHandler(rr, req)
-
-	if status := rr.Code; status != http.StatusOK {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
-	}
-
-	// Check the response body
-	expected := "Account approved successfully\n"
-	if rr.Body.String() != expected {
-		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
-	}
+	accID := 2004
+
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Account{AccID: accID, Username: "testapprove", AccType: "User", AccStatus: "Pending"})
+
+		// Set up expected database query and result
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectLock(mock, Account{AccID: accID, Username: "testapprove", AccType: "User", AccStatus: "Pending"})
+			mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET AccStatus = 'Created', Version = Version + 1 WHERE AccID = ?")).
+				ExpectExec().
+				WithArgs(accID).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectAudit(mock, audit.ActionApprove, accID, &Account{AccID: accID, Username: "testapprove", AccType: "User", AccStatus: "Created"})
+			mock.ExpectCommit()
+		})
+
+		req, err := http.NewRequest("POST", fmt.Sprintf("/api/v1/accounts/approve?accID=%d", accID), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		f.server().ApproveAccHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		// Check the response body
+		expected := `{"message":"Account approved successfully"}` + "\n"
+		if rr.Body.String() != expected {
+			t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+		}
+
+		if acc, ok := f.stored(accID); ok && acc.AccStatus != "Created" {
+			t.Errorf("Handler did not approve the account: %v", acc.AccStatus)
+		}
+	})
 }
 
 func TestApproveAccHandler_Empty(t *testing.T) {
-	// accID follows the existing acc with pending status in record_db for testing approval
-	accID := ""
-
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	for _, accID := range []string{"", "abc"} {
+		req, err := http.NewRequest("POST", fmt.Sprintf("/api/v1/accounts/approve?accID=%s", accID), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
 
-	// Replace the actual database connection with the mock
-	SetDB(db)
+		rr := httptest.NewRecorder()
 
-	// Set up expected database query and result
-	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET AccStatus = 'Created' WHERE AccID = ?")).
-		ExpectExec().
-		WithArgs(accID).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		NewServer(NewMemoryStore()).ApproveAccHandler(rr, req)
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("/api/v1/accounts/approve?accID=%s", accID), nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	rr := httptest.NewRecorder()
-
-	ApproveAccHandler(rr, req)
-
-	if status := rr.Code; status != http.StatusBadRequest {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Handler returned wrong status code for %q: got %v want %v", accID, status, http.StatusBadRequest)
+		}
+		expectErrorCode(t, rr, api.CodeInvalidParameter)
 	}
 }
 
 func TestApproveAccHandler_Prepare(t *testing.T) {
 	// accID follows the existing acc with pending status in record_db for testing approval
-	accID := "2004"
-
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	accID := 2004
 
-	// Replace the actual database connection with the mock
-	SetDB(db)
+	server, mock := mysqlServer(t)
 
 	// Create a mock MySQL error
 	mockError := &mysql.MySQLError{
@@ -447,36 +345,31 @@ func TestApproveAccHandler_Prepare(t *testing.T) {
 	}
 
 	// Mock Prepare to return the mock MySQL error
-	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET AccStatus = 'Created' WHERE AccID = ?")).
+	expectLock(mock, Account{AccID: accID, Username: "testapprove", AccType: "User", AccStatus: "Pending"})
+	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET AccStatus = 'Created', Version = Version + 1 WHERE AccID = ?")).
 		WillReturnError(mockError)
+	mock.ExpectRollback()
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("/api/v1/accounts/approve?accID=%s", accID), nil)
+	req, err := http.NewRequest("POST", fmt.Sprintf("/api/v1/accounts/approve?accID=%d", accID), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	rr := httptest.NewRecorder()
 
-	ApproveAccHandler(rr, req)
+	server.ApproveAccHandler(rr, req)
 
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
+	expectErrorCode(t, rr, api.CodeInternal)
 }
 
 func TestApproveAccHandler_Exec(t *testing.T) {
 	// accID follows the existing acc with pending status in record_db for testing approval
-	accID := "2004"
-
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	accID := 2004
 
-	// Replace the actual database connection with the mock
-	SetDB(db)
+	server, mock := mysqlServer(t)
 
 	// Create a mock MySQL error
 	mockError := &mysql.MySQLError{
@@ -484,273 +377,172 @@ func TestApproveAccHandler_Exec(t *testing.T) {
 		Message: "Duplicate entry 'xyz' for key 'PRIMARY'", // MySQL error message (example)
 	}
 
-	// Mock Prepare to return the mock MySQL error
-	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET AccStatus = 'Created' WHERE AccID = ?")).
+	// Mock Exec to return the mock MySQL error
+	expectLock(mock, Account{AccID: accID, Username: "testapprove", AccType: "User", AccStatus: "Pending"})
+	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET AccStatus = 'Created', Version = Version + 1 WHERE AccID = ?")).
 		ExpectExec().
 		WithArgs(accID).
 		WillReturnError(mockError)
+	mock.ExpectRollback()
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("/api/v1/accounts/approve?accID=%s", accID), nil)
+	req, err := http.NewRequest("POST", fmt.Sprintf("/api/v1/accounts/approve?accID=%d", accID), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	rr := httptest.NewRecorder()
 
-	ApproveAccHandler(rr, req)
+	server.ApproveAccHandler(rr, req)
 
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
+	expectErrorCode(t, rr, api.CodeInternal)
 }
 
-func TestAdminCreateAccHandler(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
-
-	// Set up expected database query and result
-	mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO Account (Username, Password, AccType, AccStatus) VALUES (?, ?, ?, ?)")).
-		ExpectExec().
-		WithArgs("admincreatedacc", "admincreatedpwd", "User", "Created").
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	newAcc := Account{
-		Username:  "admincreatedacc",
-		Password:  "admincreatedpwd",
-		AccType:   "User",
-		AccStatus: "Created",
-	}
-	payload, err := json.Marshal(newAcc)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	req, err := http.NewRequest("POST", "/api/v1/accounts", bytes.NewBuffer(payload))
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Create a ResponseRecorder to record the response
-	rr := httptest.NewRecorder()
-
-	// Call the handler directly
-	AdminCreateAccHandler(rr, req)
-
-	// Check the status code
-	if status := rr.Code; status != http.StatusCreated {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusCreated)
-	}
-
-	// Check the response body
-	expected := "Account created successfully\n"
-	if rr.Body.String() != expected {
-		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
-	}
-}
-
-func TestAdminCreateAccHandler_Prepare(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
-
-	// Create a mock MySQL error
-	mockError := &mysql.MySQLError{
-		Number:  1062,                                      // MySQL error number (example)
-		Message: "Duplicate entry 'xyz' for key 'PRIMARY'", // MySQL error message (example)
-	}
-
-	// Mock Prepare to return the mock MySQL error
-	mock.ExpectPrepare("INSERT INTO Account").WillReturnError(mockError)
-
-	newAcc := Account{
-		Username:  "admincreatedacc",
-		Password:  "admincreatedpwd",
-		AccType:   "User",
-		AccStatus: "Created",
-	}
-	payload, err := json.Marshal(newAcc)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	req, err := http.NewRequest("POST", "/api/v1/accounts", bytes.NewBuffer(payload))
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Create a ResponseRecorder to record the response
-	rr := httptest.NewRecorder()
-
-	// Call the handler directly
-	AdminCreateAccHandler(rr, req)
-
-	// Check the status code
-	if status := rr.Code; status != http.StatusInternalServerError {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
-	}
+func TestDeleteAccHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Account{AccID: 2003, Username: "testdelete", AccType: "User", AccStatus: "Created"})
+
+		// Set up expected database query and result for success
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectLock(mock, Account{AccID: 2003, Username: "testdelete", AccType: "User", AccStatus: "Created"})
+			mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET DeletedAt = UTC_TIMESTAMP(), DeletedBy = ? WHERE AccID = ?")).
+				ExpectExec().
+				WithArgs(nil, 2003).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectAudit(mock, audit.ActionDelete, 2003, nil)
+			mock.ExpectCommit()
+		})
+
+		req, err := http.NewRequest("DELETE", "/api/v1/accounts/delete?accID=2003", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		// Call the handler
+		f.server().DeleteAccHandler(rr, req)
+
+		// Check the status code for success case
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		// Check the response body for success case
+		expected := `{"message":"Account deleted successfully"}` + "\n"
+		if rr.Body.String() != expected {
+			t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+		}
+
+		if f.memory != nil {
+			if _, ok := f.stored(2003); ok {
+				t.Errorf("Handler did not delete the account")
+			}
+		}
+	})
 }
 
-func TestAdminCreateAccHandler_Decode(t *testing.T) {
-
-	payload, err := json.Marshal("newAcc")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	req, err := http.NewRequest("POST", "/api/v1/accounts", bytes.NewBuffer(payload))
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Create a ResponseRecorder to record the response
-	rr := httptest.NewRecorder()
-
-	// Call the handler directly
-	AdminCreateAccHandler(rr, req)
-
-	// Check the status code
-	if status := rr.Code; status != http.StatusBadRequest {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
-	}
+func TestDeleteAccHandler_Path(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Account{AccID: 2003, Username: "testdelete", AccType: "User", AccStatus: "Created"})
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectLock(mock, Account{AccID: 2003, Username: "testdelete", AccType: "User", AccStatus: "Created"})
+			mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET DeletedAt = UTC_TIMESTAMP(), DeletedBy = ? WHERE AccID = ?")).
+				ExpectExec().
+				WithArgs(nil, 2003).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectAudit(mock, audit.ActionDelete, 2003, nil)
+			mock.ExpectCommit()
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/accounts/{accID}", f.server().DeleteAccHandler)
+
+		req, err := http.NewRequest("DELETE", "/api/v1/accounts/2003", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if f.memory != nil {
+			if _, ok := f.stored(2003); ok {
+				t.Errorf("Handler did not delete the account")
+			}
+		}
+	})
 }
 
-func TestAdminCreateAccHandler_Exec(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
-
-	// Create a mock MySQL error
-	mockError := &mysql.MySQLError{
-		Number:  1062,                                      // MySQL error number (example)
-		Message: "Duplicate entry 'xyz' for key 'PRIMARY'", // MySQL error message (example)
-	}
-
-	// Set up expected database query and result
-	mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO Account (Username, Password, AccType, AccStatus) VALUES (?, ?, ?, ?)")).
-		ExpectExec().
-		WithArgs("admincreatedacc", "admincreatedpwd", "User", "Created").
-		WillReturnError(mockError)
-
-	newAcc := Account{
-		Username:  "admincreatedacc",
-		Password:  "admincreatedpwd",
-		AccType:   "User",
-		AccStatus: "Created",
-	}
-	payload, err := json.Marshal(newAcc)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	req, err := http.NewRequest("POST", "/api/v1/accounts", bytes.NewBuffer(payload))
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Create a ResponseRecorder to record the response
-	rr := httptest.NewRecorder()
-
-	// Call the handler directly
-	AdminCreateAccHandler(rr, req)
-
-	// Check the status code
-	if status := rr.Code; status != http.StatusInternalServerError {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+func TestAccHandlers_NotFound(t *testing.T) {
+	tests := []struct {
+		method  string
+		path    string
+		handler func(*Server) http.HandlerFunc
+	}{
+		{"POST", "/api/v1/accounts/approve?accID=9999", func(s *Server) http.HandlerFunc { return s.ApproveAccHandler }},
+		{"DELETE", "/api/v1/accounts/delete?accID=9999", func(s *Server) http.HandlerFunc { return s.DeleteAccHandler }},
+	}
+
+	for _, tt := range tests {
+		forEachStore(t, func(t *testing.T, f *storeFixture) {
+			f.expectSQL(func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, Version FROM Account WHERE AccID = ? AND DeletedAt IS NULL FOR UPDATE")).
+					WithArgs(9999).
+					WillReturnRows(accountRows())
+				mock.ExpectRollback()
+			})
+
+			req, err := http.NewRequest(tt.method, tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			tt.handler(f.server())(rr, req)
+
+			if status := rr.Code; status != http.StatusNotFound {
+				t.Errorf("%s %s returned wrong status code: got %v want %v", tt.method, tt.path, status, http.StatusNotFound)
+			}
+			expectErrorCode(t, rr, api.CodeNotFound)
+		})
 	}
 }
 
-func TestDeleteAccHandler(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
-
-	// Set up expected database query and result for success
-	mock.ExpectPrepare(regexp.QuoteMeta("DELETE FROM Account WHERE AccID = ?")).
-		ExpectExec().
-		WithArgs("2003").
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	req, err := http.NewRequest("DELETE", "/api/v1/accounts/delete?accID=2003", nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	rr := httptest.NewRecorder()
-
-	// Call the handler
-	DeleteAccHandler(rr, req)
-
-	// Check the status code for success case
-	if status := rr.Code; status != http.StatusOK {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
-	}
-
-	// Check the response body for success case
-	expected := "Account deleted successfully\n"
-	if rr.Body.String() != expected {
-		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
-	}
-
-	// Verify that the expectations for success were met
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Errorf("there were unfulfilled expectations: %s", err)
-	}
+func TestDeleteAccHandler_Errors(t *testing.T) {
+	server, mock := mysqlServer(t)
 
 	// Set up expectations for error when accID is empty
-	req, err = http.NewRequest("DELETE", "/api/v1/accounts/delete", nil)
+	req, err := http.NewRequest("DELETE", "/api/v1/accounts/delete", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	rr = httptest.NewRecorder()
+	rr := httptest.NewRecorder()
 
 	// Call the handler with empty accID
-	DeleteAccHandler(rr, req)
+	server.DeleteAccHandler(rr, req)
 
 	// Check the status code for error case
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("Handler returned wrong status code for empty accID case: got %v want %v", status, http.StatusBadRequest)
 	}
 
-	// Check the response body for error case
-	expectedError := "Account ID parameter is required\n"
-	if rr.Body.String() != expectedError {
-		t.Errorf("Handler returned unexpected body for empty accID case: got %v want %v", rr.Body.String(), expectedError)
-	}
-
-	// Verify that the expectations for empty accID were met
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Errorf("there were unfulfilled expectations for empty accID case: %s", err)
-	}
+	// Check the error code for error case
+	expectErrorCode(t, rr, api.CodeInvalidParameter)
 
 	// Set up expectations for error when preparing SQL statement
-	mock.ExpectPrepare(regexp.QuoteMeta("DELETE FROM Account WHERE AccID = ?")).
+	expectLock(mock, Account{AccID: 2003, Username: "testdelete", AccType: "User", AccStatus: "Created"})
+	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET DeletedAt = UTC_TIMESTAMP(), DeletedBy = ? WHERE AccID = ?")).
 		WillReturnError(errors.New("sql: statement preparation failed"))
+	mock.ExpectRollback()
 
 	req, err = http.NewRequest("DELETE", "/api/v1/accounts/delete?accID=2003", nil)
 	if err != nil {
@@ -760,18 +552,15 @@ func TestDeleteAccHandler(t *testing.T) {
 	rr = httptest.NewRecorder()
 
 	// Call the handler with valid accID but with an error in preparing the SQL statement
-	DeleteAccHandler(rr, req)
+	server.DeleteAccHandler(rr, req)
 
 	// Check the status code for error case
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("Handler returned wrong status code for SQL statement preparation error case: got %v want %v", status, http.StatusInternalServerError)
 	}
 
-	// Check the response body for error case
-	expectedError = "Internal server error\n"
-	if rr.Body.String() != expectedError {
-		t.Errorf("Handler returned unexpected body for SQL statement preparation error case: got %v want %v", rr.Body.String(), expectedError)
-	}
+	// Check the error code for error case
+	expectErrorCode(t, rr, api.CodeInternal)
 
 	// Verify that the expectations for SQL statement preparation error were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -779,10 +568,12 @@ func TestDeleteAccHandler(t *testing.T) {
 	}
 
 	// Set up expectations for error when executing SQL statement
-	mock.ExpectPrepare(regexp.QuoteMeta("DELETE FROM Account WHERE AccID = ?")).
+	expectLock(mock, Account{AccID: 2003, Username: "testdelete", AccType: "User", AccStatus: "Created"})
+	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET DeletedAt = UTC_TIMESTAMP(), DeletedBy = ? WHERE AccID = ?")).
 		ExpectExec().
-		WithArgs("2003").
+		WithArgs(nil, 2003).
 		WillReturnError(errors.New("sql: execution failed"))
+	mock.ExpectRollback()
 
 	req, err = http.NewRequest("DELETE", "/api/v1/accounts/delete?accID=2003", nil)
 	if err != nil {
@@ -792,18 +583,15 @@ func TestDeleteAccHandler(t *testing.T) {
 	rr = httptest.NewRecorder()
 
 	// Call the handler with valid accID but with an error in executing the SQL statement
-	DeleteAccHandler(rr, req)
+	server.DeleteAccHandler(rr, req)
 
 	// Check the status code for error case
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("Handler returned wrong status code for SQL statement execution error case: got %v want %v", status, http.StatusInternalServerError)
 	}
 
-	// Check the response body for error case
-	expectedError = "Internal server error\n"
-	if rr.Body.String() != expectedError {
-		t.Errorf("Handler returned unexpected body for SQL statement execution error case: got %v want %v", rr.Body.String(), expectedError)
-	}
+	// Check the error code for error case
+	expectErrorCode(t, rr, api.CodeInternal)
 
 	// Verify that the expectations for SQL statement execution error were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -812,56 +600,155 @@ func TestDeleteAccHandler(t *testing.T) {
 }
 
 func TestListAllAccsHandler(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
-
-	// Set up expected database query and result for success
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus FROM Account")).
-		WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "AccType", "AccStatus"}).
-			AddRow(1, "user1", "Type1", "Status1").
-			AddRow(2, "user2", "Type2", "Status2"))
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(
+			Account{AccID: 1, Username: "user1", Password: "hash1", AccType: "Type1", AccStatus: "Status1"},
+			Account{AccID: 2, Username: "user2", Password: "hash2", AccType: "Type2", AccStatus: "Status2"},
+		)
+
+		// Set up expected database query and result for success
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, Version FROM Account")).
+				WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "AccType", "AccStatus", "Version"}).
+					AddRow(1, "user1", "Type1", "Status1", 1).
+					AddRow(2, "user2", "Type2", "Status2", 1))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM Account")).
+				WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(2))
+		})
+
+		req, err := http.NewRequest("GET", "/api/v1/accounts", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		// Call the handler
+		f.server().ListAllAccsHandler(rr, req)
+
+		// Check the status code for success case
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		// The password field must not appear in the response
+		if strings.Contains(rr.Body.String(), "password") {
+			t.Errorf("Handler returned the password field: %v", rr.Body.String())
+		}
+
+		// Check the response body
+		var page query.Page[Account]
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Items) != 2 || page.Total != 2 || page.NextCursor != "" {
+			t.Errorf("Handler returned unexpected page: %+v", page)
+		}
+	})
+}
 
-	req, err := http.NewRequest("GET", "/api/v1/accounts", nil)
-	if err != nil {
-		t.Fatal(err)
-	}
+func TestListAllAccsHandler_Paginated(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(
+			Account{AccID: 1, Username: "user1", AccType: "User", AccStatus: "Pending"},
+			Account{AccID: 2, Username: "user2", AccType: "User", AccStatus: "Pending"},
+			Account{AccID: 3, Username: "user3", AccType: "User", AccStatus: "Pending"},
+			Account{AccID: 4, Username: "user4", AccType: "User", AccStatus: "Created"},
+		)
+
+		// One row more than the limit is fetched so a cursor is returned
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, Version FROM Account WHERE DeletedAt IS NULL AND AccStatus = ? ORDER BY Username DESC, AccID ASC LIMIT ?")).
+				WithArgs("Pending", 3).
+				WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "AccType", "AccStatus", "Version"}).
+					AddRow(3, "user3", "User", "Pending", 1).
+					AddRow(2, "user2", "User", "Pending", 1).
+					AddRow(1, "user1", "User", "Pending", 1))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM Account WHERE DeletedAt IS NULL AND AccStatus = ?")).
+				WithArgs("Pending").
+				WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(3))
+		})
+
+		server := f.server()
+
+		req, err := http.NewRequest("GET", "/api/v1/accounts/all?limit=2&sort=-username&accStatus=Pending", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		server.ListAllAccsHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		var page query.Page[Account]
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Items) != 2 || page.Items[0].AccID != 3 || page.Total != 3 || page.NextCursor == "" {
+			t.Errorf("Handler returned unexpected page: %+v", page)
+		}
+
+		// The cursor continues after the last returned account
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, Version FROM Account WHERE DeletedAt IS NULL AND AccStatus = ? AND ((Username < ?) OR (Username = ? AND AccID > ?)) ORDER BY Username DESC, AccID ASC LIMIT ?")).
+				WithArgs("Pending", "user2", "user2", 2, 3).
+				WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "AccType", "AccStatus", "Version"}).
+					AddRow(1, "user1", "User", "Pending", 1))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM Account WHERE DeletedAt IS NULL AND AccStatus = ?")).
+				WithArgs("Pending").
+				WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(3))
+		})
+
+		req, err = http.NewRequest("GET", "/api/v1/accounts/all?limit=2&sort=-username&accStatus=Pending&cursor="+page.NextCursor, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr = httptest.NewRecorder()
+
+		server.ListAllAccsHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		page = query.Page[Account]{}
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Items) != 1 || page.Items[0].AccID != 1 || page.NextCursor != "" {
+			t.Errorf("Handler returned unexpected second page: %+v", page)
+		}
+	})
+}
 
-	rr := httptest.NewRecorder()
+func TestListAllAccsHandler_BadParams(t *testing.T) {
+	for _, rawQuery := range []string{"limit=0", "limit=abc", "sort=password", "cursor=notacursor"} {
+		req, err := http.NewRequest("GET", "/api/v1/accounts/all?"+rawQuery, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
 
-	// Call the handler
-	ListAllAccsHandler(rr, req)
+		rr := httptest.NewRecorder()
 
-	// Check the status code for success case
-	if status := rr.Code; status != http.StatusOK {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
-	}
+		NewServer(NewMemoryStore()).ListAllAccsHandler(rr, req)
 
-	// Verify that the expectations for success were met
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Errorf("there were unfulfilled expectations: %s", err)
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Handler returned wrong status code for %q: got %v want %v", rawQuery, status, http.StatusBadRequest)
+		}
+		expectErrorCode(t, rr, api.CodeInvalidParameter)
 	}
 }
 
 func TestListAllAccsHandler_Error(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
+	server, mock := mysqlServer(t)
 
 	// Set up expected database query and result for error
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus FROM Account")).
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, Version FROM Account")).
 		WillReturnError(errors.New("database error"))
 
 	req, err := http.NewRequest("GET", "/api/v1/accounts", nil)
@@ -872,12 +759,13 @@ func TestListAllAccsHandler_Error(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call the handler
-	ListAllAccsHandler(rr, req)
+	server.ListAllAccsHandler(rr, req)
 
 	// Check the status code for error case
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
+	expectErrorCode(t, rr, api.CodeInternal)
 
 	// Verify that the expectations for error were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -886,85 +774,302 @@ func TestListAllAccsHandler_Error(t *testing.T) {
 }
 
 func TestGetSpecificAccHandler(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
-
-	// Set up expected database query and result for success
-	mock.ExpectQuery("SELECT * FROM Account WHERE AccID = ?").
-		WithArgs("1").
-		WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "Password", "AccType", "AccStatus"}).
-			AddRow(1, "user1", "password1", "Type1", "Status1"))
-
-	req, err := http.NewRequest("GET", "/api/v1/accounts?accID=1", nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	rr := httptest.NewRecorder()
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Account{AccID: 1, Username: "user1", Password: "hash1", AccType: "Type1", AccStatus: "Status1"})
+
+		// Set up expected database query and result for success
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, Version FROM Account WHERE AccID = ?")).
+				WithArgs(1).
+				WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "AccType", "AccStatus", "Version"}).
+					AddRow(1, "user1", "Type1", "Status1", 1))
+		})
+
+		req, err := http.NewRequest("GET", "/api/v1/accounts?accID=1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		// Call the handler
+		f.server().GetSpecificAccHandler(rr, req)
+
+		// Check the status code for success case
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		// The password field must not appear in the response
+		if strings.Contains(rr.Body.String(), "password") {
+			t.Errorf("Handler returned the password field: %v", rr.Body.String())
+		}
+
+		var acc Account
+		if err := json.NewDecoder(rr.Body).Decode(&acc); err != nil {
+			t.Fatal(err)
+		}
+		if acc.Username != "user1" {
+			t.Errorf("Handler returned unexpected account: %+v", acc)
+		}
+		if etag := rr.Header().Get("ETag"); etag != `"1"` {
+			t.Errorf("Handler returned wrong ETag: got %v want %v", etag, `"1"`)
+		}
+	})
+}
 
-	// Call the handler
-	GetSpecificAccHandler(rr, req)
+func TestGetSpecificAccHandler_Path(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Account{AccID: 1, Username: "user1", AccType: "User", AccStatus: "Created"})
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, Version FROM Account WHERE AccID = ?")).
+				WithArgs(1).
+				WillReturnRows(accountRows(Account{AccID: 1, Username: "user1", AccType: "User", AccStatus: "Created"}))
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/accounts/{accID}", f.server().GetSpecificAccHandler)
+
+		req, err := http.NewRequest("GET", "/api/v1/accounts/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		var acc Account
+		if err := json.NewDecoder(rr.Body).Decode(&acc); err != nil {
+			t.Fatal(err)
+		}
+		if acc.AccID != 1 || acc.Username != "user1" {
+			t.Errorf("Handler returned unexpected account: %+v", acc)
+		}
+	})
+}
 
-	// Check the status code for success case
-	if status := rr.Code; status != http.StatusOK {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
-	}
+func TestGetSpecificAccHandler_NotFound(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, Version FROM Account WHERE AccID = ?")).
+				WithArgs(9999).
+				WillReturnRows(accountRows())
+		})
+
+		req, err := http.NewRequest("GET", "/api/v1/accounts/get?accID=9999", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		f.server().GetSpecificAccHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+		expectErrorCode(t, rr, api.CodeNotFound)
+	})
 }
 
 func TestUpdateAccHandler_Success(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Account{AccID: 123, Username: "oldUsername", AccType: "User", AccStatus: "Created"})
+
+		// Prepare mock for successful update
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectLock(mock, Account{AccID: 123, Username: "oldUsername", AccType: "User", AccStatus: "Created"})
+			mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET Username=?, AccType=?, Version=Version+1 WHERE AccID=?")).
+				ExpectExec().
+				WithArgs("newUsername", "Admin", 123).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectAudit(mock, audit.ActionUpdate, 123, &Account{AccID: 123, Username: "newUsername", AccType: "Admin", AccStatus: "Created"})
+			mock.ExpectCommit()
+		})
+
+		// Create a new mux router
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/accounts/{accID}", f.server().UpdateAccHandler)
+
+		// Prepare request with valid payload and account ID
+		reqBody := `{"Username": "newUsername", "AccType": "Admin"}`
+		req, err := http.NewRequest("PUT", "/api/v1/accounts/123", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-Match", `"1"`)
+
+		rr := httptest.NewRecorder()
+
+		// Serve the request using the router
+		router.ServeHTTP(rr, req)
+
+		// Check the response status code for success
+		if status := rr.Code; status != http.StatusAccepted {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusAccepted)
+		}
+
+		if etag := rr.Header().Get("ETag"); etag != `"2"` {
+			t.Errorf("Handler returned wrong ETag: got %v want %v", etag, `"2"`)
+		}
+
+		// Check the response body
+		expectedBody := `{"message":"Account updated successfully!"}` + "\n"
+		if rr.Body.String() != expectedBody {
+			t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expectedBody)
+		}
+
+		if acc, ok := f.stored(123); ok && (acc.Username != "newUsername" || acc.AccType != "Admin" || acc.AccStatus != "Created") {
+			t.Errorf("Handler stored unexpected account: %+v", acc)
+		}
+	})
+}
 
-	// Prepare mock for successful update
-	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET Username=?, AccType=? WHERE AccID=?")).
-		ExpectExec().
-		WithArgs("newUsername", "newAccType", 123).
-		WillReturnResult(sqlmock.NewResult(0, 1))
+func TestUpdateAccHandler_Conflict(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Account{AccID: 123, Username: "oldUsername", AccType: "User", AccStatus: "Created", Version: 2})
+
+		// The account moved on to version 2 since the client read version 1
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectLock(mock, Account{AccID: 123, Username: "oldUsername", AccType: "User", AccStatus: "Created", Version: 2})
+			mock.ExpectRollback()
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/accounts/{accID}", f.server().UpdateAccHandler)
+
+		reqBody := `{"Username": "newUsername", "AccType": "Admin"}`
+		req, err := http.NewRequest("PUT", "/api/v1/accounts/123", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-Match", `"1"`)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusPreconditionFailed {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusPreconditionFailed)
+		}
+		expectErrorCode(t, rr, api.CodePreconditionFailed)
+
+		if acc, ok := f.stored(123); ok && acc.Username != "oldUsername" {
+			t.Errorf("Handler updated the account despite the conflict: %+v", acc)
+		}
+	})
+}
 
-	// Create a new mux router
+func TestUpdateAccHandler_NoIfMatch(t *testing.T) {
 	router := mux.NewRouter()
-	router.HandleFunc("/api/v1/accounts/{accID}", UpdateAccHandler)
+	router.HandleFunc("/api/v1/accounts/{accID}", NewServer(NewMemoryStore(Account{AccID: 123, Username: "oldUsername", AccType: "User", AccStatus: "Created"})).UpdateAccHandler)
 
-	// Prepare request with valid payload and account ID
-	reqBody := `{"Username": "newUsername", "AccType": "newAccType"}`
+	reqBody := `{"Username": "newUsername", "AccType": "Admin"}`
 	req, err := http.NewRequest("PUT", "/api/v1/accounts/123", strings.NewReader(reqBody))
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	rr := httptest.NewRecorder()
-
-	// Serve the request using the router
 	router.ServeHTTP(rr, req)
 
-	// Check the response status code for success
-	if status := rr.Code; status != http.StatusAccepted {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusAccepted)
+	if status := rr.Code; status != http.StatusPreconditionRequired {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusPreconditionRequired)
 	}
+	expectErrorCode(t, rr, api.CodePreconditionRequired)
+}
 
-	// Check the response body
-	expectedBody := "Account updated successfully!\n"
-	if rr.Body.String() != expectedBody {
-		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expectedBody)
-	}
+func TestPatchAccHandler(t *testing.T) {
+	current := Account{AccID: 123, Username: "oldUsername", AccType: "User", AccStatus: "Created", Version: 1}
+	patched := Account{AccID: 123, Username: "oldUsername", AccType: "Admin", AccStatus: "Created", Version: 2}
+
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(current)
+
+		// Only the type changes, so only AccType is written
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, Version FROM Account WHERE AccID = ? AND DeletedAt IS NULL")).
+				WithArgs(123).
+				WillReturnRows(accountRows(current))
+			expectLock(mock, current)
+			mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET AccType=?, Version=Version+1 WHERE AccID=?")).
+				ExpectExec().
+				WithArgs("Admin", 123).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectAudit(mock, audit.ActionUpdate, 123, &patched)
+			mock.ExpectCommit()
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/accounts/{accID}", f.server().PatchAccHandler)
+
+		req, err := http.NewRequest("PATCH", "/api/v1/accounts/123", strings.NewReader(`{"accType": "Admin"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-Match", `"1"`)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if etag := rr.Header().Get("ETag"); etag != `"2"` {
+			t.Errorf("Handler returned wrong ETag: got %v want %v", etag, `"2"`)
+		}
+		expected := `{"accId":123,"username":"oldUsername","accType":"Admin","accStatus":"Created","version":2}` + "\n"
+		if rr.Body.String() != expected {
+			t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+		}
+
+		if acc, ok := f.stored(123); ok && acc != patched {
+			t.Errorf("Handler stored unexpected account: %+v", acc)
+		}
+	})
+}
 
-	// Verify that the expectations were met
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Errorf("there were unfulfilled expectations: %s", err)
+func TestPatchAccHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"invalid ID", "/api/v1/accounts/abc", `{}`, http.StatusBadRequest, api.CodeInvalidParameter},
+		{"missing account", "/api/v1/accounts/99", `{}`, http.StatusNotFound, api.CodeNotFound},
+		{"not an object", "/api/v1/accounts/123", `"Admin"`, http.StatusBadRequest, api.CodeInvalidPayload},
+		{"password", "/api/v1/accounts/123", `{"password": "newpwd"}`, http.StatusUnprocessableEntity, api.CodeValidationFailed},
+		{"status", "/api/v1/accounts/123", `{"accStatus": "Pending"}`, http.StatusUnprocessableEntity, api.CodeValidationFailed},
+		{"invalid type", "/api/v1/accounts/123", `{"accType": "Root"}`, http.StatusUnprocessableEntity, api.CodeValidationFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore(Account{AccID: 123, Username: "oldUsername", AccType: "User", AccStatus: "Created"})
+			router := mux.NewRouter()
+			router.HandleFunc("/api/v1/accounts/{accID}", NewServer(store).PatchAccHandler)
+
+			req, err := http.NewRequest("PATCH", tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.status {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, tt.status)
+			}
+			expectErrorCode(t, rr, tt.code)
+
+			if acc, _ := store.Get(123); acc.Version != 1 {
+				t.Errorf("Handler changed the account: %+v", acc)
+			}
+		})
 	}
 }
 
@@ -978,23 +1083,20 @@ func TestUpdateAccHandler_InvalidID(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call the handler with invalid ID
-	UpdateAccHandler(rr, req)
+	NewServer(NewMemoryStore()).UpdateAccHandler(rr, req)
 
 	// Check the response status code for invalid ID
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
-	// Check the response body for invalid ID
-	expectedBody := "Invalid Account ID\n"
-	if rr.Body.String() != expectedBody {
-		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expectedBody)
-	}
+	// Check the error code for invalid ID
+	expectErrorCode(t, rr, api.CodeInvalidParameter)
 }
 
 func TestUpdateAccHandler_InvalidPayload(t *testing.T) {
 	// Create a new mux router
 	router := mux.NewRouter()
-	router.HandleFunc("/api/v1/accounts/{accID}", UpdateAccHandler)
+	router.HandleFunc("/api/v1/accounts/{accID}", NewServer(NewMemoryStore()).UpdateAccHandler)
 
 	// Prepare request with invalid payload and account ID
 	payload, err := json.Marshal("updateAcc")
@@ -1015,20 +1117,43 @@ func TestUpdateAccHandler_InvalidPayload(t *testing.T) {
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
-	// Check the response body for invalid payload
-	expectedBody := "Invalid request payload\n"
+	// Check the error code for invalid payload
+	expectErrorCode(t, rr, api.CodeInvalidPayload)
+}
+
+func TestUpdateAccHandler_Validation(t *testing.T) {
+	// Invalid payloads are rejected before any SQL is run
+	server, mock := mysqlServer(t)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/accounts/{accID}", server.UpdateAccHandler)
+
+	reqBody := `{"username": "", "accType": "Superuser"}`
+	req, err := http.NewRequest("PUT", "/api/v1/accounts/123", strings.NewReader(reqBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-Match", `"1"`)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusUnprocessableEntity {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
+	}
+	expectedBody := `{"code":"validation_failed","message":"Request validation failed","details":[{"field":"username","message":"is required"},{"field":"accType","message":"must be one of Admin, User"}],"requestId":""}` + "\n"
 	if rr.Body.String() != expectedBody {
 		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expectedBody)
 	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
 
 func TestUpdateAccHandler_Prepare(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	server, mock := mysqlServer(t)
 
 	// Create a mock MySQL error
 	mockError := &mysql.MySQLError{
@@ -1036,22 +1161,22 @@ func TestUpdateAccHandler_Prepare(t *testing.T) {
 		Message: "Duplicate entry 'xyz' for key 'PRIMARY'", // MySQL error message (example)
 	}
 
-	// Replace the actual database connection with the mock
-	SetDB(db)
-
-	// Prepare mock for successful update
-	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET Username=?, AccType=? WHERE AccID=?")).WillReturnError(mockError)
+	// Prepare mock for a failed update
+	expectLock(mock, Account{AccID: 123, Username: "oldUsername", AccType: "User", AccStatus: "Created"})
+	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET Username=?, AccType=?, Version=Version+1 WHERE AccID=?")).WillReturnError(mockError)
+	mock.ExpectRollback()
 
 	// Create a new mux router
 	router := mux.NewRouter()
-	router.HandleFunc("/api/v1/accounts/{accID}", UpdateAccHandler)
+	router.HandleFunc("/api/v1/accounts/{accID}", server.UpdateAccHandler)
 
 	// Prepare request with valid payload and account ID
-	reqBody := `{"Username": "newUsername", "AccType": "newAccType"}`
+	reqBody := `{"Username": "newUsername", "AccType": "Admin"}`
 	req, err := http.NewRequest("PUT", "/api/v1/accounts/123", strings.NewReader(reqBody))
 	if err != nil {
 		t.Fatal(err)
 	}
+	req.Header.Set("If-Match", `"1"`)
 
 	rr := httptest.NewRecorder()
 
@@ -1062,18 +1187,11 @@ func TestUpdateAccHandler_Prepare(t *testing.T) {
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
+	expectErrorCode(t, rr, api.CodeInternal)
 }
 
 func TestUpdateAccHandler_Exec(t *testing.T) {
-	// Create a new instance of sqlmock
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
-
-	// Replace your existing db with the mocked one
-	SetDB(db)
+	server, mock := mysqlServer(t)
 
 	// Create a mock MySQL error
 	mockError := &mysql.MySQLError{
@@ -1082,20 +1200,23 @@ func TestUpdateAccHandler_Exec(t *testing.T) {
 	}
 
 	// Set up expectations for your query
-	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET Username=?, AccType=? WHERE AccID=?")).ExpectExec().
-		WithArgs("yiting", "Admin", 1).
+	expectLock(mock, Account{AccID: 123, Username: "oldUsername", AccType: "User", AccStatus: "Created"})
+	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET Username=?, AccType=?, Version=Version+1 WHERE AccID=?")).ExpectExec().
+		WithArgs("newUsername", "Admin", 123).
 		WillReturnError(mockError)
+	mock.ExpectRollback()
 
 	// Create a new mux router
 	router := mux.NewRouter()
-	router.HandleFunc("/api/v1/accounts/{accID}", UpdateAccHandler)
+	router.HandleFunc("/api/v1/accounts/{accID}", server.UpdateAccHandler)
 
 	// Prepare request with valid payload and account ID
-	reqBody := `{"Username": "newUsername", "AccType": "newAccType"}`
+	reqBody := `{"Username": "newUsername", "AccType": "Admin"}`
 	req, err := http.NewRequest("PUT", "/api/v1/accounts/123", strings.NewReader(reqBody))
 	if err != nil {
 		t.Fatal(err)
 	}
+	req.Header.Set("If-Match", `"1"`)
 
 	rr := httptest.NewRecorder()
 
@@ -1108,10 +1229,274 @@ func TestUpdateAccHandler_Exec(t *testing.T) {
 			status, http.StatusInternalServerError)
 	}
 
-	// Check the response body
-	expectedBody := "Internal server error\n"
-	if rr.Body.String() != expectedBody {
-		t.Errorf("handler returned unexpected body: got %v want %v",
-			rr.Body.String(), expectedBody)
+	// Check the error code
+	expectErrorCode(t, rr, api.CodeInternal)
+}
+
+func TestListAuditHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Account{AccID: 2004, Username: "testapprove", AccType: "User", AccStatus: "Pending"})
+
+		// An admin approved account 2004
+		if f.memory != nil {
+			if err := f.memory.Approve(2004, audit.Actor{AccID: 1001, IP: "192.0.2.1"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AuditID, ActorID, Action, Entity, EntityID, OldValue, NewValue, SourceIP, CreatedAt FROM AuditLog WHERE ActorID = ? AND Entity = ? AND CreatedAt >= ? ORDER BY CreatedAt DESC, AuditID DESC LIMIT ?")).
+				WithArgs("1001", "account", "2024-01-01 00:00:00", 51).
+				WillReturnRows(sqlmock.NewRows([]string{"AuditID", "ActorID", "Action", "Entity", "EntityID", "OldValue", "NewValue", "SourceIP", "CreatedAt"}).
+					AddRow(1, 1001, "approve", "account", 2004,
+						`{"accId":2004,"username":"testapprove","accType":"User","accStatus":"Pending","version":1}`,
+						`{"accId":2004,"username":"testapprove","accType":"User","accStatus":"Created","version":2}`,
+						"192.0.2.1", "2024-05-01 09:30:00"))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM AuditLog WHERE ActorID = ? AND Entity = ? AND CreatedAt >= ?")).
+				WithArgs("1001", "account", "2024-01-01 00:00:00").
+				WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+		})
+
+		req, err := http.NewRequest("GET", "/api/v1/audit?actor=1001&entity=account&from=2024-01-01", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		f.server().ListAuditHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		var page query.Page[audit.Entry]
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 1 || len(page.Items) != 1 {
+			t.Fatalf("Handler returned unexpected page: %+v", page)
+		}
+		e := page.Items[0]
+		if e.ActorID != 1001 || e.Action != audit.ActionApprove || e.EntityID != 2004 || e.SourceIP != "192.0.2.1" {
+			t.Errorf("Handler returned unexpected entry: %+v", e)
+		}
+		if string(e.Before) != `{"accId":2004,"username":"testapprove","accType":"User","accStatus":"Pending","version":1}` ||
+			string(e.After) != `{"accId":2004,"username":"testapprove","accType":"User","accStatus":"Created","version":2}` {
+			t.Errorf("Handler returned unexpected change: %s -> %s", e.Before, e.After)
+		}
+	})
+}
+
+func TestListAuditHandler_BadParams(t *testing.T) {
+	for _, q := range []string{"from=yesterday", "sort=action", "limit=0"} {
+		req, err := http.NewRequest("GET", "/api/v1/audit?"+q, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		NewServer(NewMemoryStore()).ListAuditHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Handler returned wrong status code for %q: got %v want %v", q, status, http.StatusBadRequest)
+		}
+		expectErrorCode(t, rr, api.CodeInvalidParameter)
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	s := NewServer(NewMemoryStore(
+		Account{AccID: 1001, Username: "admin", AccType: "Admin", AccStatus: "Created"},
+		Account{AccID: 2001, Username: "user", AccType: "User", AccStatus: "Created"},
+		Account{AccID: 2004, Username: "pending", AccType: "User", AccStatus: "Pending"},
+	))
+
+	req, err := http.NewRequest("GET", "/api/v1/stats?from=2022/2023", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	s.StatsHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var summary stats.Summary
+	if err := json.NewDecoder(rr.Body).Decode(&summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.From != "2022/2023" || summary.Accounts.Total != 3 || summary.Accounts.PendingApproval != 1 {
+		t.Errorf("Handler returned unexpected summary: %+v", summary)
+	}
+}
+
+func TestStatsHandler_BadParams(t *testing.T) {
+	for _, q := range []string{"from=2023", "to=yesterday", "from=2023/2024&to=2021/2022"} {
+		req, err := http.NewRequest("GET", "/api/v1/stats?"+q, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		NewServer(NewMemoryStore()).StatsHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Handler returned wrong status code for %q: got %v want %v", q, status, http.StatusBadRequest)
+		}
+		expectErrorCode(t, rr, api.CodeInvalidParameter)
+	}
+}
+
+func TestRoutePolicies(t *testing.T) {
+	callers := []struct {
+		name      string
+		accID     int
+		accType   string
+		accStatus string
+	}{
+		{"anonymous", 0, "", ""},
+		{"pending user", 2004, "User", "Pending"},
+		{"user", 2001, "User", "Created"},
+		{"admin", 1001, "Admin", "Created"},
+	}
+
+	const ok = http.StatusNoContent
+	const unauthorized = http.StatusUnauthorized
+	const forbidden = http.StatusForbidden
+
+	tests := []struct {
+		method string
+		path   string
+		public bool
+		want   [4]int // anonymous, pending user, user, admin
+	}{
+		{"GET", "/healthz", true, [4]int{ok, ok, ok, ok}},
+		{"GET", "/readyz", true, [4]int{ok, ok, ok, ok}},
+		{"POST", "/api/v1/auth/login", true, [4]int{ok, ok, ok, ok}},
+		{"POST", "/api/v1/auth/refresh", true, [4]int{ok, ok, ok, ok}},
+		{"POST", "/api/v1/auth/logout", true, [4]int{ok, ok, ok, ok}},
+		{"POST", "/api/v1/accounts", true, [4]int{ok, ok, ok, ok}},
+		{"GET", "/api/v1/accounts/all", false, [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"POST", "/api/v1/admin/accounts", false, [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"POST", "/api/v1/accounts/approve?accID=2004", false, [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"DELETE", "/api/v1/accounts/delete?accID=2003", false, [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"GET", "/api/v1/accounts/get?accID=2001", false, [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"GET", "/api/v1/accounts/2001", false, [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"DELETE", "/api/v1/accounts/2003", false, [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"POST", "/api/v1/accounts/2004/approve", false, [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"PUT", "/api/v1/accounts/2005", false, [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"PATCH", "/api/v1/accounts/2005", false, [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"GET", "/api/v1/accounts/trash", false, [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"POST", "/api/v1/accounts/trash/2003/restore", false, [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"GET", "/api/v1/audit", false, [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"GET", "/api/v1/stats", false, [4]int{unauthorized, forbidden, forbidden, ok}},
+	}
+
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		var seed []Account
+		for _, caller := range callers[1:] {
+			seed = append(seed, Account{AccID: caller.accID, Username: caller.name, AccType: caller.accType, AccStatus: caller.accStatus})
+		}
+		f.seed(seed...)
+		auth.SetRevocationStore(auth.NewMemoryRevocationStore())
+
+		// Replace every handler so only the authorization middleware is exercised
+		router := f.server().Router()
+		router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			route.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+			return nil
+		})
+
+		for _, tt := range tests {
+			for i, caller := range callers {
+				t.Run(tt.method+" "+tt.path+" as "+caller.name, func(t *testing.T) {
+					req, err := http.NewRequest(tt.method, tt.path, nil)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if caller.accID != 0 {
+						token, err := auth.Issue(caller.accID, caller.accType)
+						if err != nil {
+							t.Fatal(err)
+						}
+						req.Header.Set("Authorization", "Bearer "+token.Token)
+
+						// Protected routes look up the caller's current type and status
+						if !tt.public {
+							f.expectSQL(func(mock sqlmock.Sqlmock) {
+								mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, Version FROM Account WHERE AccID = ?")).
+									WithArgs(caller.accID).
+									WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "AccType", "AccStatus", "Version"}).
+										AddRow(caller.accID, caller.name, caller.accType, caller.accStatus, 1))
+							})
+						}
+					}
+
+					rr := httptest.NewRecorder()
+					router.ServeHTTP(rr, req)
+
+					if status := rr.Code; status != tt.want[i] {
+						t.Errorf("Handler returned wrong status code: got %v want %v", status, tt.want[i])
+					}
+				})
+			}
+		}
+	})
+}
+
+func TestRoutePolicies_Complete(t *testing.T) {
+	// Every registered route needs an entry in the policy table
+	NewServer(NewMemoryStore()).Router().Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			if _, ok := routePolicies[method+" "+tmpl]; !ok {
+				t.Errorf("No access policy for %s %s", method, tmpl)
+			}
+		}
+		return nil
+	})
+}
+
+func TestReadyz(t *testing.T) {
+	// Create a new mock database connection that checks pings
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	router := NewServer(NewMySQLStore(db)).Router()
+
+	mock.ExpectPing()
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	for _, expected := range []int{http.StatusOK, http.StatusServiceUnavailable} {
+		req, err := http.NewRequest("GET", "/readyz", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != expected {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, expected)
+		}
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
@@ -0,0 +1,205 @@
+package account
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"      //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit"    //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/validate" //change here
+)
+
+// provisionRequest is one account to create through the admin API
+type provisionRequest struct {
+	Username         string `json:"username" validate:"required,max=50"`
+	Password         string `json:"password" validate:"omitempty,maxbytes=72"`
+	AccType          string `json:"accType" validate:"required,oneof=Admin User"`
+	AccStatus        string `json:"accStatus" validate:"required,oneof=Created Pending"`
+	GeneratePassword bool   `json:"generatePassword"`
+}
+
+// provisionResult reports the outcome for one requested account. A generated
+// password is only ever returned here, once, and must be replaced at the
+// first login. Errors lists the rejected fields
+// when the request itself was invalid.
+type provisionResult struct {
+	Index             int             `json:"index"`
+	AccID             int             `json:"accId,omitempty"`
+	Username          string          `json:"username"`
+	AccType           string          `json:"accType"`
+	AccStatus         string          `json:"accStatus"`
+	GeneratedPassword string          `json:"generatedPassword,omitempty"`
+	Error             string          `json:"error,omitempty"`
+	Errors            validate.Errors `json:"errors,omitempty"`
+}
+
+// create one account, or many from a JSON array in a single transaction
+func (s *Server) AdminCreateAccHandler(w http.ResponseWriter, r *http.Request) {
+	var body json.RawMessage
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		api.InvalidPayload(w, r)
+		return
+	}
+
+	// Accept either a single account object or an array of them
+	bulk := len(bytes.TrimSpace(body)) > 0 && bytes.TrimSpace(body)[0] == '['
+	var reqs []provisionRequest
+	if bulk {
+		err = json.Unmarshal(body, &reqs)
+	} else {
+		var single provisionRequest
+		err = json.Unmarshal(body, &single)
+		reqs = []provisionRequest{single}
+	}
+	if err != nil || len(reqs) == 0 {
+		api.InvalidPayload(w, r)
+		return
+	}
+
+	results, status := s.provisionAccounts(reqs, audit.ActorFrom(r))
+
+	var out interface{} = results
+	if !bulk {
+		out = results[0]
+	}
+	switch status {
+	case http.StatusCreated:
+		// A single account can be located; a batch has one per result
+		if !bulk {
+			w.Header().Set("Location", accountLocation(results[0].AccID))
+		}
+		api.JSON(w, status, out)
+	case http.StatusUnprocessableEntity:
+		api.ErrorDetails(w, r, status, api.CodeValidationFailed, "No accounts were created because some were invalid", out)
+	default:
+		api.ErrorDetails(w, r, status, api.CodeInternal, "No accounts were created", out)
+	}
+}
+
+// provisionAccounts validates every request before inserting them all in one
+// transaction; if any item fails nothing is created
+func (s *Server) provisionAccounts(reqs []provisionRequest, by audit.Actor) ([]provisionResult, int) {
+	results := make([]provisionResult, len(reqs))
+	passwords := make([]string, len(reqs))
+	failed := false
+
+	for i, req := range reqs {
+		// Admin-provisioned accounts default to approved users
+		if req.AccType == "" {
+			req.AccType = "User"
+		}
+		if req.AccStatus == "" {
+			req.AccStatus = "Created"
+		}
+		res := provisionResult{Index: i, Username: req.Username, AccType: req.AccType, AccStatus: req.AccStatus}
+
+		res.Errors = validate.Struct(req)
+		switch {
+		case req.GeneratePassword && req.Password != "":
+			res.Errors = append(res.Errors, validate.FieldError{Field: "password", Message: "must be empty when generatePassword is set"})
+		case !req.GeneratePassword && req.Password == "":
+			res.Errors = append(res.Errors, validate.FieldError{Field: "password", Message: "is required unless generatePassword is set"})
+		}
+		if repeatedUsername(req.Username, reqs[:i]) {
+			res.Errors = append(res.Errors, validate.FieldError{Field: "username", Message: "is repeated in the request"})
+		}
+		if res.Errors != nil {
+			res.Error = res.Errors.Error()
+		}
+
+		if res.Error == "" {
+			passwords[i] = req.Password
+			if req.GeneratePassword {
+				pwd, err := generatePassword()
+				if err != nil {
+					return markAll(results, "internal server error"), http.StatusInternalServerError
+				}
+				passwords[i] = pwd
+				res.GeneratedPassword = pwd
+			}
+		} else {
+			failed = true
+		}
+		results[i] = res
+	}
+
+	if failed {
+		return clearGenerated(results), http.StatusUnprocessableEntity
+	}
+
+	// Logins look accounts up by username, so it must not be in use already
+	for i, res := range results {
+		_, err := s.store.GetByUsername(res.Username)
+		if err == nil {
+			results[i].Errors = validate.Errors{{Field: "username", Message: "is already in use"}}
+			results[i].Error = results[i].Errors.Error()
+			failed = true
+		} else if err != ErrNotFound {
+			return markAll(results, "internal server error"), http.StatusInternalServerError
+		}
+	}
+	if failed {
+		return clearGenerated(results), http.StatusUnprocessableEntity
+	}
+
+	accs := make([]Account, len(results))
+	for i, res := range results {
+		hashedPwd, err := hashPassword(passwords[i])
+		if err != nil {
+			results[i].Error = "internal server error"
+			return clearGenerated(results), http.StatusInternalServerError
+		}
+		// A generated password has been seen by the admin, so it only lasts
+		// until the account's first login
+		accs[i] = Account{Username: res.Username, Password: hashedPwd, AccType: res.AccType, AccStatus: res.AccStatus, MustChangePassword: res.GeneratedPassword != ""}
+	}
+
+	ids, err := s.store.CreateMany(accs, by)
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		results[batchErr.Index].Error = "could not create account"
+		return clearGenerated(results), http.StatusInternalServerError
+	} else if err != nil {
+		return markAll(results, "internal server error"), http.StatusInternalServerError
+	}
+
+	for i, id := range ids {
+		results[i].AccID = id
+	}
+	return results, http.StatusCreated
+}
+
+// repeatedUsername reports whether an earlier item of the request has the
+// same username, ignoring case like MySQL's collation
+func repeatedUsername(username string, earlier []provisionRequest) bool {
+	for _, other := range earlier {
+		if username != "" && strings.EqualFold(other.Username, username) {
+			return true
+		}
+	}
+	return false
+}
+
+// clearGenerated drops generated passwords and ids when the batch was not
+// committed, and notes that the remaining items were not created
+func clearGenerated(results []provisionResult) []provisionResult {
+	for i := range results {
+		results[i].GeneratedPassword = ""
+		results[i].AccID = 0
+		if results[i].Error == "" {
+			results[i].Error = "not created because another account in the request failed"
+		}
+	}
+	return results
+}
+
+func markAll(results []provisionResult, msg string) []provisionResult {
+	for i := range results {
+		results[i].Error = msg
+	}
+	return clearGenerated(results)
+}
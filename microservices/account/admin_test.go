@@ -0,0 +1,437 @@
+// admin_test.go
+package account
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"      //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit"    //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/query"    //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/validate" //change here
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestAdminCreateAccHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Account{AccID: 2009, Username: "existing", AccType: "User", AccStatus: "Created"})
+
+		// Set up expected database query and result
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectUsernameFree(mock, "admincreatedacc")
+			mock.ExpectBegin()
+			mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO Account (Username, Password, AccType, AccStatus, MustChangePassword) VALUES (?, ?, ?, ?, ?)")).
+				ExpectExec().
+				WithArgs("admincreatedacc", hashOf("admincreatedpwd"), "Admin", "Created", false).
+				WillReturnResult(sqlmock.NewResult(2010, 1))
+			expectAudit(mock, audit.ActionCreate, 2010, nil)
+			mock.ExpectCommit()
+		})
+
+		newAcc := Account{
+			Username:  "admincreatedacc",
+			Password:  "admincreatedpwd",
+			AccType:   "Admin",
+			AccStatus: "Created",
+		}
+		payload, err := json.Marshal(newAcc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest("POST", "/api/v1/admin/accounts", bytes.NewBuffer(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Create a ResponseRecorder to record the response
+		rr := httptest.NewRecorder()
+
+		// Call the handler directly
+		f.server().AdminCreateAccHandler(rr, req)
+
+		// Check the status code
+		if status := rr.Code; status != http.StatusCreated {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+		}
+
+		// Check the response body
+		var result provisionResult
+		if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		if result.AccID != 2010 || result.Error != "" || result.GeneratedPassword != "" {
+			t.Errorf("Handler returned unexpected result: %+v", result)
+		}
+		if location := rr.Header().Get("Location"); location != "/api/v1/accounts/2010" {
+			t.Errorf("Handler returned wrong Location: got %v want %v", location, "/api/v1/accounts/2010")
+		}
+
+		if acc, ok := f.stored(2010); ok && (acc.AccType != "Admin" || !hashOf("admincreatedpwd").Match(acc.Password)) {
+			t.Errorf("Handler stored unexpected account: %+v", acc)
+		}
+	})
+}
+
+func TestAdminCreateAccHandler_GeneratePassword(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Account{AccID: 2010, Username: "existing", AccType: "User", AccStatus: "Created"})
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectUsernameFree(mock, "newstaff")
+			mock.ExpectBegin()
+			mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO Account (Username, Password, AccType, AccStatus, MustChangePassword) VALUES (?, ?, ?, ?, ?)")).
+				ExpectExec().
+				WithArgs("newstaff", sqlmock.AnyArg(), "User", "Created", true).
+				WillReturnResult(sqlmock.NewResult(2011, 1))
+			expectAudit(mock, audit.ActionCreate, 2011, nil)
+			mock.ExpectCommit()
+		})
+
+		reqBody := `{"username": "newstaff", "generatePassword": true}`
+		req, err := http.NewRequest("POST", "/api/v1/admin/accounts", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		f.server().AdminCreateAccHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusCreated {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+		}
+
+		// The generated password is returned once
+		var result provisionResult
+		if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result.GeneratedPassword) < 12 || result.AccID != 2011 {
+			t.Errorf("Handler returned unexpected result: %+v", result)
+		}
+
+		if acc, ok := f.stored(2011); ok && !hashOf(result.GeneratedPassword).Match(acc.Password) {
+			t.Errorf("Handler did not store the generated password")
+		} else if ok && !acc.MustChangePassword {
+			t.Errorf("Handler did not require the generated password to be changed")
+		}
+	})
+}
+
+func TestAdminCreateAccHandler_Bulk(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Account{AccID: 2019, Username: "existing", AccType: "User", AccStatus: "Created"})
+
+		// Both accounts are inserted in the same transaction
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectUsernameFree(mock, "bulk1", "bulk2")
+			mock.ExpectBegin()
+			prep := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO Account (Username, Password, AccType, AccStatus, MustChangePassword) VALUES (?, ?, ?, ?, ?)"))
+			prep.ExpectExec().
+				WithArgs("bulk1", hashOf("bulkpwd1"), "User", "Created", false).
+				WillReturnResult(sqlmock.NewResult(2020, 1))
+			expectAudit(mock, audit.ActionCreate, 2020, nil)
+			prep.ExpectExec().
+				WithArgs("bulk2", hashOf("bulkpwd2"), "Admin", "Pending", false).
+				WillReturnResult(sqlmock.NewResult(2021, 1))
+			expectAudit(mock, audit.ActionCreate, 2021, nil)
+			mock.ExpectCommit()
+		})
+
+		reqBody := `[{"username": "bulk1", "password": "bulkpwd1"},
+					{"username": "bulk2", "password": "bulkpwd2", "accType": "Admin", "accStatus": "Pending"}]`
+		req, err := http.NewRequest("POST", "/api/v1/admin/accounts", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		f.server().AdminCreateAccHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusCreated {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+		}
+
+		var results []provisionResult
+		if err := json.NewDecoder(rr.Body).Decode(&results); err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[0].AccID != 2020 || results[1].AccID != 2021 {
+			t.Errorf("Handler returned unexpected results: %+v", results)
+		}
+	})
+}
+
+func TestAdminCreateAccHandler_BulkInvalid(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		// The second item is invalid so the database is never touched
+		reqBody := `[{"username": "bulk1", "password": "bulkpwd1"},
+					{"username": "bulk2", "password": "bulkpwd2", "accType": "Superuser"},
+					{"username": "", "password": "bulkpwd3"}]`
+		req, err := http.NewRequest("POST", "/api/v1/admin/accounts", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		f.server().AdminCreateAccHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusUnprocessableEntity {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
+		}
+
+		// The per-account results are the details of the error envelope
+		var body struct {
+			Code    string            `json:"code"`
+			Details []provisionResult `json:"details"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Code != api.CodeValidationFailed {
+			t.Errorf("Handler returned wrong error code: got %v want %v", body.Code, api.CodeValidationFailed)
+		}
+		results := body.Details
+		expectedErrors := []string{
+			"not created because another account in the request failed",
+			"accType must be one of Admin, User",
+			"username is required",
+		}
+		for i, expected := range expectedErrors {
+			if results[i].Error != expected {
+				t.Errorf("Handler returned unexpected error for item %d: got %v want %v", i, results[i].Error, expected)
+			}
+		}
+		if len(results[2].Errors) != 1 || results[2].Errors[0] != (validate.FieldError{Field: "username", Message: "is required"}) {
+			t.Errorf("Handler returned unexpected field errors: %+v", results[2].Errors)
+		}
+
+		if f.memory != nil {
+			if page, _ := f.store.List(query.Params{Limit: 10}); page.Total != 0 {
+				t.Errorf("Handler created accounts from an invalid request: %+v", page.Items)
+			}
+		}
+	})
+}
+
+func TestAdminCreateAccHandler_RepeatedUsername(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		// The batch names the same account twice, so nothing is looked up
+		reqBody := `[{"username": "bulk1", "password": "bulkpwd1"},
+					{"username": "BULK1", "password": "bulkpwd2"}]`
+		req, err := http.NewRequest("POST", "/api/v1/admin/accounts", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		f.server().AdminCreateAccHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusUnprocessableEntity {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
+		}
+
+		var body struct {
+			Details []provisionResult `json:"details"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Details) != 2 || len(body.Details[1].Errors) != 1 || body.Details[1].Errors[0] != (validate.FieldError{Field: "username", Message: "is repeated in the request"}) {
+			t.Errorf("Handler returned unexpected results: %+v", body.Details)
+		}
+	})
+}
+
+func TestAdminCreateAccHandler_UsernameInUse(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Account{AccID: 2009, Username: "existing", AccType: "User", AccStatus: "Created"})
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectUsernameFree(mock, "bulk1")
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, Password, AccType, AccStatus, MustChangePassword FROM Account WHERE Username = ?")).
+				WithArgs("existing").
+				WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "Password", "AccType", "AccStatus", "MustChangePassword"}).
+					AddRow(2009, "existing", "", "User", "Created", false))
+		})
+
+		reqBody := `[{"username": "bulk1", "password": "bulkpwd1"},
+					{"username": "existing", "password": "bulkpwd2"}]`
+		req, err := http.NewRequest("POST", "/api/v1/admin/accounts", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		f.server().AdminCreateAccHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusUnprocessableEntity {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
+		}
+
+		var body struct {
+			Details []provisionResult `json:"details"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Details) != 2 || body.Details[0].Error != "not created because another account in the request failed" || body.Details[1].Error != "username is already in use" {
+			t.Errorf("Handler returned unexpected results: %+v", body.Details)
+		}
+
+		if f.memory != nil {
+			if page, _ := f.store.List(query.Params{Limit: 10}); page.Total != 1 {
+				t.Errorf("Handler created accounts from an invalid request: %+v", page.Items)
+			}
+		}
+	})
+}
+
+// expectUsernameFree registers the lookups that find no account with any of
+// the usernames
+func expectUsernameFree(mock sqlmock.Sqlmock, usernames ...string) {
+	for _, username := range usernames {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, Password, AccType, AccStatus, MustChangePassword FROM Account WHERE Username = ?")).
+			WithArgs(username).
+			WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "Password", "AccType", "AccStatus", "MustChangePassword"}))
+	}
+}
+
+func TestAdminCreateAccHandler_Prepare(t *testing.T) {
+	server, mock := mysqlServer(t)
+
+	// Create a mock MySQL error
+	mockError := &mysql.MySQLError{
+		Number:  1062,                                      // MySQL error number (example)
+		Message: "Duplicate entry 'xyz' for key 'PRIMARY'", // MySQL error message (example)
+	}
+
+	// Mock Prepare to return the mock MySQL error
+	expectUsernameFree(mock, "admincreatedacc")
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO Account").WillReturnError(mockError)
+	mock.ExpectRollback()
+
+	newAcc := Account{
+		Username:  "admincreatedacc",
+		Password:  "admincreatedpwd",
+		AccType:   "User",
+		AccStatus: "Created",
+	}
+	payload, err := json.Marshal(newAcc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", "/api/v1/admin/accounts", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler directly
+	server.AdminCreateAccHandler(rr, req)
+
+	// Check the status code
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+	}
+	expectErrorCode(t, rr, api.CodeInternal)
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAdminCreateAccHandler_Decode(t *testing.T) {
+	server := NewServer(NewMemoryStore())
+
+	payload, err := json.Marshal("newAcc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", "/api/v1/admin/accounts", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler directly
+	server.AdminCreateAccHandler(rr, req)
+
+	// Check the status code
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	expectErrorCode(t, rr, api.CodeInvalidPayload)
+}
+
+func TestAdminCreateAccHandler_Exec(t *testing.T) {
+	server, mock := mysqlServer(t)
+
+	// Create a mock MySQL error
+	mockError := &mysql.MySQLError{
+		Number:  1062,                                      // MySQL error number (example)
+		Message: "Duplicate entry 'xyz' for key 'PRIMARY'", // MySQL error message (example)
+	}
+
+	// Set up expected database query and result
+	expectUsernameFree(mock, "admincreatedacc")
+	mock.ExpectBegin()
+	mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO Account (Username, Password, AccType, AccStatus, MustChangePassword) VALUES (?, ?, ?, ?, ?)")).
+		ExpectExec().
+		WithArgs("admincreatedacc", hashOf("admincreatedpwd"), "User", "Created", false).
+		WillReturnError(mockError)
+	mock.ExpectRollback()
+
+	newAcc := Account{
+		Username:  "admincreatedacc",
+		Password:  "admincreatedpwd",
+		AccType:   "User",
+		AccStatus: "Created",
+	}
+	payload, err := json.Marshal(newAcc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", "/api/v1/admin/accounts", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler directly
+	server.AdminCreateAccHandler(rr, req)
+
+	// Check the status code
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+	}
+	expectErrorCode(t, rr, api.CodeInternal)
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,55 @@
+package account
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt cost used when hashing new passwords
+var passwordCost = bcrypt.DefaultCost
+
+// hashPassword returns the bcrypt hash (salt included) of a plaintext password
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// dummyHash is compared against when a login names no account, so that takes
+// as long as a wrong password and does not reveal which usernames exist
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a password"), passwordCost)
+	return hash
+})
+
+// isPasswordHash reports whether a stored password is already a bcrypt hash
+func isPasswordHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// checkPassword compares a login attempt against the stored password.
+// Rows created before hashing was introduced still hold plaintext, so those
+// are compared directly and reported as needing an upgrade.
+func checkPassword(stored, password string) (ok bool, needsUpgrade bool) {
+	if isPasswordHash(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil, false
+	}
+	ok = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+	return ok, ok
+}
+
+// generatePassword returns a random password for admin-provisioned accounts
+func generatePassword() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
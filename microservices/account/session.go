@@ -0,0 +1,171 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"      //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit"    //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/auth"     //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/validate" //change here
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// loginRequest holds the credentials of a login. NewPassword replaces the
+// password once it is verified, which is required for a generated one.
+type loginRequest struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	NewPassword string `json:"newPassword" validate:"omitempty,maxbytes=72"`
+}
+
+var errInvalidCredentials = errors.New("invalid username or password")
+
+// authenticate looks up the account and verifies its password; from is the
+// address of the login request
+func (s *Server) authenticate(username, password string, from audit.Actor) (Account, error) {
+	acc, err := s.store.GetByUsername(username)
+	if err == ErrNotFound {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return Account{}, errInvalidCredentials
+	} else if err != nil {
+		return Account{}, err
+	}
+
+	ok, needsUpgrade := checkPassword(acc.Password, password)
+	if !ok {
+		return Account{}, errInvalidCredentials
+	}
+
+	// Replace a legacy plaintext password with its hash now that it is verified
+	if needsUpgrade {
+		// The account upgrades its own password, so it is the actor
+		s.upgradePassword(acc.AccID, password, audit.Actor{AccID: acc.AccID, IP: from.IP})
+	}
+	acc.Password = ""
+
+	return acc, nil
+}
+
+// upgradePassword rehashes a legacy plaintext password; a failure only means
+// the upgrade is retried on the next successful login
+func (s *Server) upgradePassword(accID int, password string, by audit.Actor) {
+	hashedPwd, err := hashPassword(password)
+	if err != nil {
+		log.Printf("Error hashing password for account %d: %v", accID, err)
+		return
+	}
+
+	if err := s.store.SetPassword(accID, hashedPwd, by); err != nil {
+		log.Printf("Error upgrading password for account %d: %v", accID, err)
+	}
+}
+
+// login with a JSON body and receive a signed session token
+func (s *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var creds loginRequest
+	err := json.NewDecoder(r.Body).Decode(&creds)
+	if err != nil {
+		api.InvalidPayload(w, r)
+		return
+	}
+
+	if creds.Username == "" || creds.Password == "" {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidPayload, "Username and Password are required")
+		return
+	}
+
+	if errs := validate.Struct(creds); errs != nil {
+		api.Invalid(w, r, errs)
+		return
+	}
+
+	from := audit.ActorFrom(r)
+	acc, err := s.authenticate(creds.Username, creds.Password, from)
+	if err == errInvalidCredentials {
+		api.Error(w, r, http.StatusUnauthorized, api.CodeInvalidCredentials, "Invalid Username or Password")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	if acc.MustChangePassword && creds.NewPassword == "" {
+		api.Error(w, r, http.StatusForbidden, api.CodePasswordChange, "The password must be changed: log in again with newPassword")
+		return
+	} else if creds.NewPassword != "" && creds.NewPassword == creds.Password {
+		api.Invalid(w, r, validate.Errors{{Field: "newPassword", Message: "must differ from the current password"}})
+		return
+	}
+	if creds.NewPassword != "" {
+		hashedPwd, err := hashPassword(creds.NewPassword)
+		if err != nil {
+			api.Internal(w, r)
+			return
+		}
+		// The account changes its own password, so it is the actor
+		if err := s.store.SetPassword(acc.AccID, hashedPwd, audit.Actor{AccID: acc.AccID, IP: from.IP}); err != nil {
+			api.Internal(w, r)
+			return
+		}
+	}
+
+	token, err := auth.Issue(acc.AccID, acc.AccType)
+	if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	api.JSON(w, http.StatusOK, token)
+}
+
+// exchange a valid token for a new one, revoking the old token
+func (s *Server) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := auth.FromRequest(r)
+	if err != nil {
+		api.Error(w, r, http.StatusUnauthorized, api.CodeUnauthorized, "Invalid or expired token")
+		return
+	}
+
+	// Reload the account so type changes and deletions take effect
+	acc, err := s.store.Get(claims.AccID)
+	if err == ErrNotFound {
+		api.Error(w, r, http.StatusUnauthorized, api.CodeUnauthorized, "Invalid or expired token")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	if err := auth.Revoke(claims); err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	token, err := auth.Issue(acc.AccID, acc.AccType)
+	if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	api.JSON(w, http.StatusOK, token)
+}
+
+// revoke the token sent with the request
+func (s *Server) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := auth.FromRequest(r)
+	if err != nil {
+		api.Error(w, r, http.StatusUnauthorized, api.CodeUnauthorized, "Invalid or expired token")
+		return
+	}
+
+	if err := auth.Revoke(claims); err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	api.Message(w, http.StatusOK, "Logged out successfully")
+}
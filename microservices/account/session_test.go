@@ -0,0 +1,450 @@
+// session_test.go
+package account
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"   //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/auth"  //change here
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginHandler(t *testing.T) {
+	hashedPwd, err := bcrypt.GenerateFromPassword([]byte("testpwd"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Account{AccID: 1, Username: "testacc", Password: string(hashedPwd), AccType: "User", AccStatus: "Created"})
+
+		// Set up expectations for the query to return a row holding the hashed password
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, Password, AccType, AccStatus, MustChangePassword FROM Account WHERE Username = ?")).
+				WithArgs("testacc").
+				WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "Password", "AccType", "AccStatus", "MustChangePassword"}).
+					AddRow(1, "testacc", string(hashedPwd), "User", "Created", false))
+		})
+
+		reqBody := `{"username": "testacc", "password": "testpwd"}`
+		req, err := http.NewRequest("POST", "/api/v1/auth/login", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		f.server().LoginHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		// The token must verify and carry the account details
+		var token auth.Token
+		if err := json.NewDecoder(rr.Body).Decode(&token); err != nil {
+			t.Fatal(err)
+		}
+		claims, err := auth.Verify(token.Token)
+		if err != nil {
+			t.Fatalf("Handler returned an invalid token: %v", err)
+		}
+		if claims.AccID != 1 || claims.AccType != "User" {
+			t.Errorf("Handler returned unexpected claims: got %v %v want 1 User", claims.AccID, claims.AccType)
+		}
+	})
+}
+
+func TestLoginHandler_Missing(t *testing.T) {
+	reqBody := `{"username": "testacc", "password": ""}`
+	req, err := http.NewRequest("POST", "/api/v1/auth/login", strings.NewReader(reqBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+
+	NewServer(NewMemoryStore()).LoginHandler(rr, req)
+
+	// Check the response status code
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusBadRequest)
+	}
+
+	// Check the error code
+	expectErrorCode(t, rr, api.CodeInvalidPayload)
+}
+
+func TestLoginHandler_InvalidPayload(t *testing.T) {
+	req, err := http.NewRequest("POST", "/api/v1/auth/login", strings.NewReader("invalid json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	NewServer(NewMemoryStore()).LoginHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusBadRequest)
+	}
+	expectErrorCode(t, rr, api.CodeInvalidPayload)
+}
+
+func TestLoginHandler_Norows(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		// Set up the mock expectation for QueryRow to return an empty result set
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, Password, AccType, AccStatus, MustChangePassword FROM Account WHERE Username = ?")).
+				WithArgs("testacc").
+				WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "Password", "AccType", "AccStatus", "MustChangePassword"}))
+		})
+
+		reqBody := `{"username": "testacc", "password": "testpwd"}`
+		req, err := http.NewRequest("POST", "/api/v1/auth/login", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		f.server().LoginHandler(rr, req)
+
+		// Check the response status code
+		if status := rr.Code; status != http.StatusUnauthorized {
+			t.Errorf("handler returned wrong status code: got %v want %v",
+				status, http.StatusUnauthorized)
+		}
+		expectErrorCode(t, rr, api.CodeInvalidCredentials)
+	})
+}
+
+func TestDummyHash(t *testing.T) {
+	// An unknown username costs as much as a wrong password
+	if cost, err := bcrypt.Cost(dummyHash()); err != nil || cost != passwordCost {
+		t.Errorf("dummyHash has cost %d, want %d: %v", cost, passwordCost, err)
+	}
+}
+
+func TestLoginHandler_WrongPassword(t *testing.T) {
+	hashedPwd, err := bcrypt.GenerateFromPassword([]byte("testpwd"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Account{AccID: 1, Username: "testacc", Password: string(hashedPwd), AccType: "User", AccStatus: "Created"})
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, Password, AccType, AccStatus, MustChangePassword FROM Account WHERE Username = ?")).
+				WithArgs("testacc").
+				WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "Password", "AccType", "AccStatus", "MustChangePassword"}).
+					AddRow(1, "testacc", string(hashedPwd), "User", "Created", false))
+		})
+
+		reqBody := `{"username": "testacc", "password": "wrongpwd"}`
+		req, err := http.NewRequest("POST", "/api/v1/auth/login", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		f.server().LoginHandler(rr, req)
+
+		// Check the response status code
+		if status := rr.Code; status != http.StatusUnauthorized {
+			t.Errorf("handler returned wrong status code: got %v want %v",
+				status, http.StatusUnauthorized)
+		}
+		expectErrorCode(t, rr, api.CodeInvalidCredentials)
+	})
+}
+
+func TestLoginHandler_LegacyPassword(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		// A row created before hashing still holds the plaintext password
+		f.seed(Account{AccID: 2001, Username: "testacc", Password: "testpwd", AccType: "User", AccStatus: "Created"})
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, Password, AccType, AccStatus, MustChangePassword FROM Account WHERE Username = ?")).
+				WithArgs("testacc").
+				WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "Password", "AccType", "AccStatus", "MustChangePassword"}).
+					AddRow(2001, "testacc", "testpwd", "User", "Created", false))
+
+			// The password is rehashed after the successful login
+			expectLock(mock, Account{AccID: 2001, Username: "testacc", AccType: "User", AccStatus: "Created"})
+			mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET Password = ?, MustChangePassword = FALSE WHERE AccID = ?")).
+				ExpectExec().
+				WithArgs(hashOf("testpwd"), 2001).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectAudit(mock, audit.ActionSetPassword, 2001, &Account{AccID: 2001, Username: "testacc", AccType: "User", AccStatus: "Created"})
+			mock.ExpectCommit()
+		})
+
+		reqBody := `{"username": "testacc", "password": "testpwd"}`
+		req, err := http.NewRequest("POST", "/api/v1/auth/login", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		f.server().LoginHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		// The password must never be echoed back
+		if strings.Contains(rr.Body.String(), "testpwd") {
+			t.Errorf("Handler returned the password: %v", rr.Body.String())
+		}
+
+		if acc, ok := f.stored(2001); ok && !hashOf("testpwd").Match(acc.Password) {
+			t.Errorf("Handler did not upgrade the password: %v", acc.Password)
+		}
+	})
+}
+
+func TestLoginHandler_MustChangePassword(t *testing.T) {
+	hashedPwd, err := bcrypt.GenerateFromPassword([]byte("generatedpwd"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Account{AccID: 2001, Username: "testacc", Password: string(hashedPwd), AccType: "User", AccStatus: "Created", MustChangePassword: true})
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, Password, AccType, AccStatus, MustChangePassword FROM Account WHERE Username = ?")).
+				WithArgs("testacc").
+				WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "Password", "AccType", "AccStatus", "MustChangePassword"}).
+					AddRow(2001, "testacc", string(hashedPwd), "User", "Created", true))
+		})
+
+		// A generated password alone does not get a token
+		reqBody := `{"username": "testacc", "password": "generatedpwd"}`
+		req, err := http.NewRequest("POST", "/api/v1/auth/login", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		f.server().LoginHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusForbidden {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusForbidden)
+		}
+		expectErrorCode(t, rr, api.CodePasswordChange)
+	})
+}
+
+func TestLoginHandler_NewPassword(t *testing.T) {
+	hashedPwd, err := bcrypt.GenerateFromPassword([]byte("generatedpwd"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Account{AccID: 2001, Username: "testacc", Password: string(hashedPwd), AccType: "User", AccStatus: "Created", MustChangePassword: true})
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, Password, AccType, AccStatus, MustChangePassword FROM Account WHERE Username = ?")).
+				WithArgs("testacc").
+				WillReturnRows(sqlmock.NewRows([]string{"AccID", "Username", "Password", "AccType", "AccStatus", "MustChangePassword"}).
+					AddRow(2001, "testacc", string(hashedPwd), "User", "Created", true))
+
+			// The new password replaces the generated one and clears the flag
+			expectLock(mock, Account{AccID: 2001, Username: "testacc", AccType: "User", AccStatus: "Created"})
+			mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Account SET Password = ?, MustChangePassword = FALSE WHERE AccID = ?")).
+				ExpectExec().
+				WithArgs(hashOf("chosenpwd"), 2001).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectAudit(mock, audit.ActionSetPassword, 2001, &Account{AccID: 2001, Username: "testacc", AccType: "User", AccStatus: "Created"})
+			mock.ExpectCommit()
+		})
+
+		reqBody := `{"username": "testacc", "password": "generatedpwd", "newPassword": "chosenpwd"}`
+		req, err := http.NewRequest("POST", "/api/v1/auth/login", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		f.server().LoginHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		if acc, ok := f.stored(2001); ok && (!hashOf("chosenpwd").Match(acc.Password) || acc.MustChangePassword) {
+			t.Errorf("Handler did not replace the generated password: %+v", acc)
+		}
+	})
+}
+
+func TestLoginHandler_SamePassword(t *testing.T) {
+	hashedPwd, err := bcrypt.GenerateFromPassword([]byte("generatedpwd"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(NewMemoryStore(Account{AccID: 2001, Username: "testacc", Password: string(hashedPwd), AccType: "User", AccStatus: "Created", MustChangePassword: true}))
+
+	reqBody := `{"username": "testacc", "password": "generatedpwd", "newPassword": "generatedpwd"}`
+	req, err := http.NewRequest("POST", "/api/v1/auth/login", strings.NewReader(reqBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	server.LoginHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusUnprocessableEntity {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
+	}
+	expectErrorCode(t, rr, api.CodeValidationFailed)
+}
+
+func TestLoginHandler_OtherErr(t *testing.T) {
+	server, mock := mysqlServer(t)
+
+	// Create a mock MySQL error
+	mockError := &mysql.MySQLError{
+		Number:  1062,                                      // MySQL error number (example)
+		Message: "Duplicate entry 'xyz' for key 'PRIMARY'", // MySQL error message (example)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, Password, AccType, AccStatus, MustChangePassword FROM Account WHERE Username = ?")).
+		WithArgs("testacc").
+		WillReturnError(mockError)
+
+	reqBody := `{"username": "testacc", "password": "testpwd"}`
+	req, err := http.NewRequest("POST", "/api/v1/auth/login", strings.NewReader(reqBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	server.LoginHandler(rr, req)
+
+	// Check the response status code
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusInternalServerError)
+	}
+	expectErrorCode(t, rr, api.CodeInternal)
+}
+
+func TestRefreshHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		auth.SetRevocationStore(auth.NewMemoryRevocationStore())
+
+		oldToken, err := auth.Issue(2001, "User")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The account was promoted since the token was issued
+		f.seed(Account{AccID: 2001, Username: "ziyi", AccType: "Admin", AccStatus: "Created"})
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, Version FROM Account WHERE AccID = ?")).
+				WithArgs(2001).
+				WillReturnRows(accountRows(Account{AccID: 2001, Username: "ziyi", AccType: "Admin", AccStatus: "Created"}))
+		})
+
+		req, err := http.NewRequest("POST", "/api/v1/auth/refresh", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+oldToken.Token)
+
+		rr := httptest.NewRecorder()
+
+		f.server().RefreshHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		var newToken auth.Token
+		if err := json.NewDecoder(rr.Body).Decode(&newToken); err != nil {
+			t.Fatal(err)
+		}
+		if newToken.AccType != "Admin" {
+			t.Errorf("Handler returned unexpected account type: got %v want %v", newToken.AccType, "Admin")
+		}
+
+		// The old token is revoked once it has been refreshed
+		if _, err := auth.Verify(oldToken.Token); err != auth.ErrRevokedToken {
+			t.Errorf("Old token was not revoked: got %v", err)
+		}
+	})
+}
+
+func TestRefreshHandler_NoToken(t *testing.T) {
+	req, err := http.NewRequest("POST", "/api/v1/auth/refresh", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	NewServer(NewMemoryStore()).RefreshHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+	expectErrorCode(t, rr, api.CodeUnauthorized)
+}
+
+func TestLogoutHandler(t *testing.T) {
+	auth.SetRevocationStore(auth.NewMemoryRevocationStore())
+	server := NewServer(NewMemoryStore())
+
+	token, err := auth.Issue(2001, "User")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", "/api/v1/auth/logout", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+
+	rr := httptest.NewRecorder()
+
+	server.LogoutHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	// A second logout with the same token is rejected
+	rr = httptest.NewRecorder()
+
+	server.LogoutHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+	expectErrorCode(t, rr, api.CodeUnauthorized)
+}
@@ -0,0 +1,571 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/query" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/stats" //change here
+)
+
+// AccountStore persists accounts. Passwords are bcrypt hashes by the time
+// they reach the store. Every change is written to the audit log together
+// with the change itself, attributed to by. Changing a missing account
+// returns ErrNotFound. Deleted accounts move to the trash, where only Trash,
+// Restore and Purge see them.
+type AccountStore interface {
+	Create(acc Account, by audit.Actor) (int, error)
+	// CreateMany creates every account or none of them
+	CreateMany(accs []Account, by audit.Actor) ([]int, error)
+	// Get returns an account without its password, or ErrNotFound
+	Get(accID int) (Account, error)
+	// GetByUsername returns an account including its password hash and
+	// MustChangePassword
+	GetByUsername(username string) (Account, error)
+	List(p query.Params) (query.Page[Account], error)
+	// Update changes the username and type of the account at acc.Version, or
+	// at any version when it is 0, and returns ErrVersionConflict when the
+	// account has moved on
+	Update(acc Account, by audit.Actor) error
+	// Patch is Update for only the named columns, which are set to their
+	// values in acc; the other columns keep whatever they hold
+	Patch(acc Account, columns []string, by audit.Actor) error
+	Approve(accID int, by audit.Actor) error
+	// SetPassword replaces the password hash and clears MustChangePassword
+	SetPassword(accID int, hash string, by audit.Actor) error
+	Delete(accID int, by audit.Actor) error
+	Trash(p query.Params) (query.Page[DeletedAccount], error)
+	// Restore takes an account out of the trash, or returns ErrNotFound
+	Restore(accID int, by audit.Actor) error
+	// Purge permanently removes accounts deleted before the given time and
+	// returns how many there were
+	Purge(before time.Time, by audit.Actor) (int, error)
+	// AuditLog reads the entries written by this store and any other
+	// sharing its database
+	AuditLog() audit.Store
+	// Stats summarises the records and accounts in the database the store
+	// shares
+	Stats() stats.Store
+	// Ping reports whether the backing database is reachable
+	Ping(ctx context.Context) error
+}
+
+var ErrNotFound = errors.New("account not found")
+
+// ErrVersionConflict is returned when an account changed since the caller read it
+var ErrVersionConflict = errors.New("account version conflict")
+
+// BatchError reports which account of a CreateMany call could not be created
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("account %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// MySQLStore keeps accounts in the Account table
+type MySQLStore struct {
+	db *sql.DB
+}
+
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+func (s *MySQLStore) Create(acc Account, by audit.Actor) (int, error) {
+	ids, err := s.CreateMany([]Account{acc}, by)
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		return 0, batchErr.Err
+	} else if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+func (s *MySQLStore) CreateMany(accs []Account, by audit.Actor) ([]int, error) {
+	ids := make([]int, len(accs))
+	err := audit.InTx(s.db, func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare("INSERT INTO Account (Username, Password, AccType, AccStatus, MustChangePassword) VALUES (?, ?, ?, ?, ?)")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for i, acc := range accs {
+			res, err := stmt.Exec(acc.Username, acc.Password, acc.AccType, acc.AccStatus, acc.MustChangePassword)
+			if err != nil {
+				return &BatchError{Index: i, Err: err}
+			}
+
+			id, err := res.LastInsertId()
+			if err != nil {
+				return &BatchError{Index: i, Err: err}
+			}
+			ids[i] = int(id)
+
+			acc.AccID, acc.Password, acc.Version = ids[i], "", 1
+			if err := audit.Write(tx, by, audit.Change{Action: audit.ActionCreate, Entity: audit.EntityAccount, EntityID: acc.AccID, After: acc}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// getAccount reads an account that is not in the trash, without its
+// password; lock is appended to the query, e.g. " FOR UPDATE"
+func getAccount(q queryer, accID int, lock string) (Account, error) {
+	var acc Account
+	err := q.QueryRow("SELECT AccID, Username, AccType, AccStatus, Version FROM Account WHERE AccID = ? AND DeletedAt IS NULL"+lock, accID).Scan(&acc.AccID, &acc.Username, &acc.AccType, &acc.AccStatus, &acc.Version)
+	if err == sql.ErrNoRows {
+		return Account{}, ErrNotFound
+	}
+	return acc, err
+}
+
+func (s *MySQLStore) Get(accID int) (Account, error) {
+	return getAccount(s.db, accID, "")
+}
+
+func (s *MySQLStore) GetByUsername(username string) (Account, error) {
+	var acc Account
+	err := s.db.QueryRow("SELECT AccID, Username, Password, AccType, AccStatus, MustChangePassword FROM Account WHERE Username = ? AND DeletedAt IS NULL", username).
+		Scan(&acc.AccID, &acc.Username, &acc.Password, &acc.AccType, &acc.AccStatus, &acc.MustChangePassword)
+	if err == sql.ErrNoRows {
+		return Account{}, ErrNotFound
+	}
+	return acc, err
+}
+
+func (s *MySQLStore) List(p query.Params) (query.Page[Account], error) {
+	return query.List(s.db, accountListSpec, p, scanAccount, accountValue)
+}
+
+func (s *MySQLStore) Update(acc Account, by audit.Actor) error {
+	return s.change(acc.AccID, audit.ActionUpdate, by, acc.Version, "UPDATE Account SET Username=?, AccType=?, Version=Version+1 WHERE AccID=?", acc.Username, acc.AccType, acc.AccID)
+}
+
+func (s *MySQLStore) Patch(acc Account, columns []string, by audit.Actor) error {
+	args := make([]interface{}, 0, len(columns)+1)
+	for _, column := range columns {
+		args = append(args, accountValue(acc, column))
+	}
+	q := "UPDATE Account SET " + strings.Join(columns, "=?, ") + "=?, Version=Version+1 WHERE AccID=?"
+	return s.change(acc.AccID, audit.ActionUpdate, by, acc.Version, q, append(args, acc.AccID)...)
+}
+
+func (s *MySQLStore) Approve(accID int, by audit.Actor) error {
+	return s.change(accID, audit.ActionApprove, by, 0, "UPDATE Account SET AccStatus = 'Created', Version = Version + 1 WHERE AccID = ?", accID)
+}
+
+func (s *MySQLStore) SetPassword(accID int, hash string, by audit.Actor) error {
+	return s.change(accID, audit.ActionSetPassword, by, 0, "UPDATE Account SET Password = ?, MustChangePassword = FALSE WHERE AccID = ?", hash, accID)
+}
+
+func (s *MySQLStore) Delete(accID int, by audit.Actor) error {
+	return s.change(accID, audit.ActionDelete, by, 0, "UPDATE Account SET DeletedAt = UTC_TIMESTAMP(), DeletedBy = ? WHERE AccID = ?", by.ID(), accID)
+}
+
+func (s *MySQLStore) Trash(p query.Params) (query.Page[DeletedAccount], error) {
+	return query.List(s.db, accountTrashSpec, p, scanDeletedAccount, deletedAccountValue)
+}
+
+func (s *MySQLStore) Restore(accID int, by audit.Actor) error {
+	return audit.InTx(s.db, func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT "+strings.Join(accountTrashSpec.Columns, ", ")+" FROM Account WHERE AccID = ? AND DeletedAt IS NOT NULL FOR UPDATE", accID)
+		if err != nil {
+			return err
+		}
+		before, err := scanAll(rows, scanDeletedAccount)
+		if err != nil {
+			return err
+		}
+		if len(before) == 0 {
+			return ErrNotFound
+		}
+
+		if _, err := tx.Exec("UPDATE Account SET DeletedAt = NULL, DeletedBy = NULL WHERE AccID = ?", accID); err != nil {
+			return err
+		}
+
+		after, err := getAccount(tx, accID, "")
+		if err != nil {
+			return err
+		}
+		return audit.Write(tx, by, audit.Change{Action: audit.ActionRestore, Entity: audit.EntityAccount, EntityID: accID, Before: before[0], After: after})
+	})
+}
+
+func (s *MySQLStore) Purge(before time.Time, by audit.Actor) (int, error) {
+	cutoff := before.UTC().Format(audit.TimeFormat)
+
+	var purged []DeletedAccount
+	err := audit.InTx(s.db, func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT "+strings.Join(accountTrashSpec.Columns, ", ")+" FROM Account WHERE DeletedAt < ? FOR UPDATE", cutoff)
+		if err != nil {
+			return err
+		}
+		if purged, err = scanAll(rows, scanDeletedAccount); err != nil || len(purged) == 0 {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM Account WHERE DeletedAt < ?", cutoff); err != nil {
+			return err
+		}
+
+		for _, acc := range purged {
+			if err := audit.Write(tx, by, audit.Change{Action: audit.ActionPurge, Entity: audit.EntityAccount, EntityID: acc.AccID, Before: acc}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(purged), nil
+}
+
+// scanAll reads every row and closes rows
+func scanAll[T any](rows *sql.Rows, scan func(*sql.Rows) (T, error)) ([]T, error) {
+	defer rows.Close()
+
+	var items []T
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *MySQLStore) AuditLog() audit.Store {
+	return audit.NewMySQLStore(s.db)
+}
+
+func (s *MySQLStore) Stats() stats.Store {
+	return stats.NewMySQLStore(s.db)
+}
+
+func (s *MySQLStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// change runs one statement against an account and logs the account as it
+// was before and after, all in one transaction. It returns ErrNotFound for a
+// missing account. A version other than 0 must match the account's current
+// version.
+func (s *MySQLStore) change(accID int, action string, by audit.Actor, version int, q string, args ...interface{}) error {
+	return audit.InTx(s.db, func(tx *sql.Tx) error {
+		before, err := getAccount(tx, accID, " FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if version != 0 && version != before.Version {
+			return ErrVersionConflict
+		}
+
+		stmt, err := tx.Prepare(q)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		if _, err := stmt.Exec(args...); err != nil {
+			return err
+		}
+
+		c := audit.Change{Action: action, Entity: audit.EntityAccount, EntityID: accID, Before: before}
+		if action != audit.ActionDelete {
+			after, err := getAccount(tx, accID, "")
+			if err != nil {
+				return err
+			}
+			c.After = after
+		}
+		return audit.Write(tx, by, c)
+	})
+}
+
+// MemoryStore keeps accounts in memory for tests and local development
+type MemoryStore struct {
+	mu       sync.Mutex
+	accounts map[int]Account
+	deleted  map[int]DeletedAccount
+	nextID   int
+	// Log receives an entry for every change
+	Log *audit.MemoryLog
+}
+
+// NewMemoryStore returns a store holding the given accounts, which keep their ids
+func NewMemoryStore(accounts ...Account) *MemoryStore {
+	s := &MemoryStore{accounts: make(map[int]Account), deleted: make(map[int]DeletedAccount), nextID: 1, Log: audit.NewMemoryLog()}
+	for _, acc := range accounts {
+		acc.Version = max(acc.Version, 1)
+		s.accounts[acc.AccID] = acc
+		if acc.AccID >= s.nextID {
+			s.nextID = acc.AccID + 1
+		}
+	}
+	return s
+}
+
+func (s *MemoryStore) Create(acc Account, by audit.Actor) (int, error) {
+	ids, err := s.CreateMany([]Account{acc}, by)
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+func (s *MemoryStore) CreateMany(accs []Account, by audit.Actor) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]int, len(accs))
+	for i, acc := range accs {
+		acc.AccID, acc.Version = s.nextID, 1
+		s.nextID++
+		s.accounts[acc.AccID] = acc
+		ids[i] = acc.AccID
+
+		acc.Password = ""
+		if err := s.Log.Write(by, audit.Change{Action: audit.ActionCreate, Entity: audit.EntityAccount, EntityID: acc.AccID, After: acc}); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+func (s *MemoryStore) Get(accID int) (Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[accID]
+	if !ok {
+		return Account{}, ErrNotFound
+	}
+	acc.Password = ""
+	return acc, nil
+}
+
+func (s *MemoryStore) GetByUsername(username string) (Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Pick the lowest id like the unordered SQL query does in practice
+	var found *Account
+	for _, acc := range s.accounts {
+		if acc.Username == username && (found == nil || acc.AccID < found.AccID) {
+			acc := acc
+			found = &acc
+		}
+	}
+	if found == nil {
+		return Account{}, ErrNotFound
+	}
+	return *found, nil
+}
+
+func (s *MemoryStore) List(p query.Params) (query.Page[Account], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accs := make([]Account, 0, len(s.accounts))
+	for _, acc := range s.accounts {
+		acc.Password = ""
+		accs = append(accs, acc)
+	}
+	sort.Slice(accs, func(i, j int) bool { return accs[i].AccID < accs[j].AccID })
+
+	return query.ListSlice(accs, p, accountValue), nil
+}
+
+func (s *MemoryStore) Update(acc Account, by audit.Actor) error {
+	return s.modify(acc.AccID, audit.ActionUpdate, by, acc.Version, func(stored *Account) {
+		stored.Username = acc.Username
+		stored.AccType = acc.AccType
+		stored.Version++
+	})
+}
+
+func (s *MemoryStore) Patch(acc Account, columns []string, by audit.Actor) error {
+	return s.modify(acc.AccID, audit.ActionUpdate, by, acc.Version, func(stored *Account) {
+		dst, src := reflect.ValueOf(stored).Elem(), reflect.ValueOf(acc)
+		for _, column := range columns {
+			dst.FieldByName(column).Set(src.FieldByName(column))
+		}
+		stored.Version++
+	})
+}
+
+func (s *MemoryStore) Approve(accID int, by audit.Actor) error {
+	return s.modify(accID, audit.ActionApprove, by, 0, func(stored *Account) {
+		stored.AccStatus = "Created"
+		stored.Version++
+	})
+}
+
+func (s *MemoryStore) SetPassword(accID int, hash string, by audit.Actor) error {
+	return s.modify(accID, audit.ActionSetPassword, by, 0, func(stored *Account) {
+		stored.Password, stored.MustChangePassword = hash, false
+	})
+}
+
+func (s *MemoryStore) Delete(accID int, by audit.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[accID]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.accounts, accID)
+	s.deleted[accID] = DeletedAccount{Account: acc, DeletedAt: time.Now().UTC().Format(audit.TimeFormat), DeletedBy: by.AccID}
+
+	acc.Password = ""
+	return s.Log.Write(by, audit.Change{Action: audit.ActionDelete, Entity: audit.EntityAccount, EntityID: accID, Before: acc})
+}
+
+func (s *MemoryStore) Trash(p query.Params) (query.Page[DeletedAccount], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accs := make([]DeletedAccount, 0, len(s.deleted))
+	for _, acc := range s.deleted {
+		acc.Password = ""
+		accs = append(accs, acc)
+	}
+	sort.Slice(accs, func(i, j int) bool { return accs[i].AccID < accs[j].AccID })
+
+	return query.ListSlice(accs, p, deletedAccountValue), nil
+}
+
+func (s *MemoryStore) Restore(accID int, by audit.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.deleted[accID]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.deleted, accID)
+	s.accounts[accID] = before.Account
+
+	after := before.Account
+	before.Password, after.Password = "", ""
+	return s.Log.Write(by, audit.Change{Action: audit.ActionRestore, Entity: audit.EntityAccount, EntityID: accID, Before: before, After: after})
+}
+
+func (s *MemoryStore) Purge(before time.Time, by audit.Actor) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := before.UTC().Format(audit.TimeFormat)
+	var expired []int
+	for accID, acc := range s.deleted {
+		if acc.DeletedAt < cutoff {
+			expired = append(expired, accID)
+		}
+	}
+	sort.Ints(expired)
+
+	for count, accID := range expired {
+		acc := s.deleted[accID]
+		delete(s.deleted, accID)
+
+		acc.Password = ""
+		if err := s.Log.Write(by, audit.Change{Action: audit.ActionPurge, Entity: audit.EntityAccount, EntityID: accID, Before: acc}); err != nil {
+			return count + 1, err
+		}
+	}
+	return len(expired), nil
+}
+
+func (s *MemoryStore) AuditLog() audit.Store {
+	return s.Log
+}
+
+// Stats summarises the accounts in the store; it holds no records, so
+// every record count is 0
+func (s *MemoryStore) Stats() stats.Store {
+	return memoryStats{s}
+}
+
+type memoryStats struct {
+	s *MemoryStore
+}
+
+func (m memoryStats) Summary(r stats.Range) (stats.Summary, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	counts := map[[2]string]int{}
+	for _, acc := range m.s.accounts {
+		counts[[2]string{acc.AccType, acc.AccStatus}]++
+	}
+	var accounts []stats.AccountCount
+	for key, n := range counts {
+		accounts = append(accounts, stats.AccountCount{AccType: key[0], AccStatus: key[1], Count: n})
+	}
+
+	return stats.Summary{
+		Range:       r,
+		Records:     stats.Records{ByAcadYr: []stats.Count{}, ByCompany: []stats.Count{}},
+		Accounts:    stats.NewAccounts(accounts),
+		GeneratedAt: time.Now().UTC().Format(audit.TimeFormat),
+	}, nil
+}
+
+// Ping always succeeds since there is no database to reach
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// modify applies fn to a stored account and logs the change. It returns
+// ErrNotFound for a missing account. A version other than 0 must match the
+// account's current version.
+func (s *MemoryStore) modify(accID int, action string, by audit.Actor, version int, fn func(*Account)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[accID]
+	if !ok {
+		return ErrNotFound
+	}
+	if version != 0 && version != acc.Version {
+		return ErrVersionConflict
+	}
+	before := acc
+	fn(&acc)
+	s.accounts[accID] = acc
+
+	before.Password, acc.Password = "", ""
+	return s.Log.Write(by, audit.Change{Action: action, Entity: audit.EntityAccount, EntityID: accID, Before: before, After: acc})
+}
@@ -0,0 +1,204 @@
+// store_test.go
+package account
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"   //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit" //change here
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// storeFixture is one AccountStore implementation under test. Handler tests
+// seed accounts for the memory store and set query expectations for the
+// MySQL store; each call only applies to its own implementation.
+type storeFixture struct {
+	store  AccountStore
+	mock   sqlmock.Sqlmock // nil for the memory store
+	memory *MemoryStore    // nil for the MySQL store
+}
+
+// seed puts accounts into the memory store
+func (f *storeFixture) seed(accs ...Account) {
+	if f.memory != nil {
+		f.memory = NewMemoryStore(accs...)
+		f.store = f.memory
+	}
+}
+
+// expectSQL registers query expectations on the MySQL store
+func (f *storeFixture) expectSQL(expect func(mock sqlmock.Sqlmock)) {
+	if f.mock != nil {
+		expect(f.mock)
+	}
+}
+
+// stored returns an account as held by the memory store, including its
+// password; ok is false for the MySQL store or a missing account
+func (f *storeFixture) stored(accID int) (acc Account, ok bool) {
+	if f.memory == nil {
+		return Account{}, false
+	}
+	f.memory.mu.Lock()
+	defer f.memory.mu.Unlock()
+	acc, ok = f.memory.accounts[accID]
+	return acc, ok
+}
+
+func (f *storeFixture) server() *Server {
+	return NewServer(f.store)
+}
+
+// forEachStore runs a test against the MySQL store backed by sqlmock and
+// against the memory store
+func forEachStore(t *testing.T, test func(t *testing.T, f *storeFixture)) {
+	t.Run("MySQL", func(t *testing.T) {
+		// Create a new mock database connection
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		test(t, &storeFixture{store: NewMySQLStore(db), mock: mock})
+
+		// Verify that the expectations were met
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Memory", func(t *testing.T) {
+		memory := NewMemoryStore()
+		test(t, &storeFixture{store: memory, memory: memory})
+	})
+}
+
+// mysqlServer returns a server on a sqlmock database for tests of database failures
+func mysqlServer(t *testing.T) (*Server, sqlmock.Sqlmock) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return NewServer(NewMySQLStore(db)), mock
+}
+
+// accountRows returns acc as the result of an account query; a zero version
+// is read as 1, the version every account starts at
+func accountRows(accs ...Account) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"AccID", "Username", "AccType", "AccStatus", "Version"})
+	for _, acc := range accs {
+		rows.AddRow(acc.AccID, acc.Username, acc.AccType, acc.AccStatus, max(acc.Version, 1))
+	}
+	return rows
+}
+
+// expectLock registers the read of an account's current state that starts
+// every change in the MySQL store
+func expectLock(mock sqlmock.Sqlmock, acc Account) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, Version FROM Account WHERE AccID = ? AND DeletedAt IS NULL FOR UPDATE")).
+		WithArgs(acc.AccID).
+		WillReturnRows(accountRows(acc))
+}
+
+// expectAudit registers the read of the changed account, unless it was
+// deleted, and the audit entry that end every change in the MySQL store
+func expectAudit(mock sqlmock.Sqlmock, action string, accID int, after *Account) {
+	if after != nil {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, Version FROM Account WHERE AccID = ? AND DeletedAt IS NULL")).
+			WithArgs(accID).
+			WillReturnRows(accountRows(*after))
+	}
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO AuditLog (ActorID, Action, Entity, EntityID, OldValue, NewValue, SourceIP, CreatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")).
+		WithArgs(sqlmock.AnyArg(), action, audit.EntityAccount, accID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(Account{AccID: 1001, Username: "admin", Password: "hash", AccType: "Admin", AccStatus: "Created"})
+	by := audit.Actor{AccID: 1001, IP: "192.0.2.1"}
+
+	// New accounts continue after the seeded ids
+	id, err := store.Create(Account{Username: "user", Password: "hash2", AccType: "User", AccStatus: "Pending"}, audit.Actor{})
+	if err != nil || id != 1002 {
+		t.Fatalf("Create returned unexpected id: %v %v", id, err)
+	}
+
+	// Get never returns the password, GetByUsername does
+	acc, err := store.Get(1001)
+	if err != nil || acc.Password != "" || acc.Username != "admin" {
+		t.Errorf("Get returned unexpected account: %+v %v", acc, err)
+	}
+	acc, err = store.GetByUsername("user")
+	if err != nil || acc.Password != "hash2" {
+		t.Errorf("GetByUsername returned unexpected account: %+v %v", acc, err)
+	}
+
+	if err := store.Approve(1002, by); err != nil {
+		t.Fatal(err)
+	}
+	if acc, _ := store.Get(1002); acc.AccStatus != "Created" {
+		t.Errorf("Approve did not change the status: %+v", acc)
+	}
+
+	// Changes to missing accounts fail and are not logged
+	if err := store.Approve(9999, by); err != ErrNotFound {
+		t.Errorf("Approve returned wrong error for a missing account: got %v want %v", err, ErrNotFound)
+	}
+
+	if err := store.Delete(1001, by); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(1001); err != ErrNotFound {
+		t.Errorf("Get returned wrong error for a deleted account: got %v want %v", err, ErrNotFound)
+	}
+
+	// Every change is logged, newest first, without passwords
+	params, err := audit.Parse(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := store.AuditLog().List(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range page.Items {
+		got = append(got, fmt.Sprintf("%d %s %d %s %s", e.ActorID, e.Action, e.EntityID, string(e.Before), string(e.After)))
+	}
+	expected := []string{
+		`1001 delete 1001 {"accId":1001,"username":"admin","accType":"Admin","accStatus":"Created","version":1} `,
+		`1001 approve 1002 {"accId":1002,"username":"user","accType":"User","accStatus":"Pending","version":1} {"accId":1002,"username":"user","accType":"User","accStatus":"Created","version":2}`,
+		`0 create 1002  {"accId":1002,"username":"user","accType":"User","accStatus":"Pending","version":1}`,
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("AuditLog returned unexpected entries:\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+// expectErrorCode checks that a response is the JSON error envelope with code
+func expectErrorCode(t *testing.T, rr *httptest.ResponseRecorder, code string) api.ErrorBody {
+	t.Helper()
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Handler returned wrong content type: got %v want application/json", ct)
+	}
+	var body api.ErrorBody
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Handler returned a body that is not an error envelope: %v", rr.Body.String())
+	}
+	if body.Code != code {
+		t.Errorf("Handler returned wrong error code: got %v want %v", body.Code, code)
+	}
+	return body
+}
@@ -0,0 +1,99 @@
+package account
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"   //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/query" //change here
+
+	"github.com/gorilla/mux"
+)
+
+// DeletedAccount is an account in the trash. DeletedBy is 0 when the
+// deleting account is unknown.
+type DeletedAccount struct {
+	Account
+	DeletedAt string `json:"deletedAt"`
+	DeletedBy int    `json:"deletedBy,omitempty"`
+}
+
+// list options accepted by ListTrashHandler; the most recently deleted
+// accounts come first by default
+var accountTrashSpec = query.Spec{
+	Table:   "Account",
+	Columns: []string{"AccID", "Username", "AccType", "AccStatus", "DeletedAt", "DeletedBy"},
+	Where:   "DeletedAt IS NOT NULL",
+	Key:     "AccID",
+	Sortable: map[string]string{
+		"accId":     "AccID",
+		"username":  "Username",
+		"deletedAt": "DeletedAt",
+	},
+	Filterable: map[string]string{
+		"username":  "Username",
+		"accType":   "AccType",
+		"deletedBy": "DeletedBy",
+	},
+	DefaultSort:  []query.SortField{{Column: "DeletedAt", Desc: true}},
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+func scanDeletedAccount(rows *sql.Rows) (DeletedAccount, error) {
+	var acc DeletedAccount
+	var deletedBy sql.NullInt64
+	err := rows.Scan(&acc.AccID, &acc.Username, &acc.AccType, &acc.AccStatus, &acc.DeletedAt, &deletedBy)
+	acc.DeletedBy = int(deletedBy.Int64)
+	return acc, err
+}
+
+// deletedAccountValue returns the value of a column for filtering, sorting and cursors
+func deletedAccountValue(acc DeletedAccount, column string) interface{} {
+	switch column {
+	case "DeletedAt":
+		return acc.DeletedAt
+	case "DeletedBy":
+		return acc.DeletedBy
+	}
+	return accountValue(acc.Account, column)
+}
+
+// ListTrashHandler lists deleted accounts that have not been purged yet
+func (s *Server) ListTrashHandler(w http.ResponseWriter, r *http.Request) {
+	params, err := accountTrashSpec.Parse(r.URL.Query())
+	if err != nil {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, err.Error())
+		return
+	}
+
+	page, err := s.store.Trash(params)
+	if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	api.JSON(w, http.StatusOK, page)
+}
+
+// RestoreAccHandler takes an account out of the trash
+func (s *Server) RestoreAccHandler(w http.ResponseWriter, r *http.Request) {
+	accID, err := strconv.Atoi(mux.Vars(r)["accID"])
+	if err != nil {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid Account ID")
+		return
+	}
+
+	err = s.store.Restore(accID, audit.ActorFrom(r))
+	if err == ErrNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Account is not in the trash")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	api.Message(w, http.StatusOK, "Account restored successfully")
+}
@@ -0,0 +1,188 @@
+// trash_test.go
+package account
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"   //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/query" //change here
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+var trashColumns = []string{"AccID", "Username", "AccType", "AccStatus", "DeletedAt", "DeletedBy"}
+
+// trashAccount seeds the memory store with an account that admin 1001 deleted
+func trashAccount(t *testing.T, f *storeFixture, acc Account) {
+	f.seed(acc)
+	if f.memory != nil {
+		if err := f.memory.Delete(acc.AccID, audit.Actor{AccID: 1001}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListTrashHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		trashAccount(t, f, Account{AccID: 2003, Username: "testdelete", Password: "hash", AccType: "User", AccStatus: "Created"})
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, DeletedAt, DeletedBy FROM Account WHERE DeletedAt IS NOT NULL ORDER BY DeletedAt DESC, AccID ASC LIMIT ?")).
+				WithArgs(51).
+				WillReturnRows(sqlmock.NewRows(trashColumns).AddRow(2003, "testdelete", "User", "Created", "2024-05-01 09:30:00", 1001))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM Account WHERE DeletedAt IS NOT NULL")).
+				WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+		})
+
+		req, err := http.NewRequest("GET", "/api/v1/accounts/trash", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		f.server().ListTrashHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if strings.Contains(rr.Body.String(), "password") {
+			t.Errorf("Handler returned a password: %v", rr.Body.String())
+		}
+
+		var page query.Page[DeletedAccount]
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 1 || page.Items[0].AccID != 2003 || page.Items[0].DeletedBy != 1001 || page.Items[0].DeletedAt == "" {
+			t.Errorf("Handler returned unexpected page: %+v", page)
+		}
+	})
+}
+
+func TestRestoreAccHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		trashAccount(t, f, Account{AccID: 2003, Username: "testdelete", AccType: "User", AccStatus: "Created"})
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, DeletedAt, DeletedBy FROM Account WHERE AccID = ? AND DeletedAt IS NOT NULL FOR UPDATE")).
+				WithArgs(2003).
+				WillReturnRows(sqlmock.NewRows(trashColumns).AddRow(2003, "testdelete", "User", "Created", "2024-05-01 09:30:00", 1001))
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE Account SET DeletedAt = NULL, DeletedBy = NULL WHERE AccID = ?")).
+				WithArgs(2003).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectAudit(mock, audit.ActionRestore, 2003, &Account{AccID: 2003, Username: "testdelete", AccType: "User", AccStatus: "Created"})
+			mock.ExpectCommit()
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/accounts/trash/{accID}/restore", f.server().RestoreAccHandler)
+
+		req, err := http.NewRequest("POST", "/api/v1/accounts/trash/2003/restore", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		expected := `{"message":"Account restored successfully"}` + "\n"
+		if rr.Body.String() != expected {
+			t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+		}
+
+		if f.memory != nil {
+			if acc, err := f.store.Get(2003); err != nil || acc.Username != "testdelete" {
+				t.Errorf("Account was not restored: %+v %v", acc, err)
+			}
+		}
+	})
+}
+
+func TestRestoreAccHandler_NotInTrash(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		// A live account is not in the trash either
+		f.seed(Account{AccID: 2003, Username: "testdelete", AccType: "User", AccStatus: "Created"})
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, DeletedAt, DeletedBy FROM Account WHERE AccID = ? AND DeletedAt IS NOT NULL FOR UPDATE")).
+				WithArgs(2003).
+				WillReturnRows(sqlmock.NewRows(trashColumns))
+			mock.ExpectRollback()
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/accounts/trash/{accID}/restore", f.server().RestoreAccHandler)
+
+		req, err := http.NewRequest("POST", "/api/v1/accounts/trash/2003/restore", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+		expectErrorCode(t, rr, api.CodeNotFound)
+	})
+}
+
+func TestPurge(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(
+			Account{AccID: 2003, Username: "old1", AccType: "User", AccStatus: "Created"},
+			Account{AccID: 2004, Username: "old2", AccType: "User", AccStatus: "Pending"},
+		)
+		if f.memory != nil {
+			f.memory.Delete(2003, audit.Actor{AccID: 1001})
+			f.memory.Delete(2004, audit.Actor{AccID: 1001})
+
+			// Accounts deleted after the cutoff are kept
+			if count, err := f.store.Purge(time.Now().Add(-time.Hour), audit.Actor{}); err != nil || count != 0 {
+				t.Errorf("Purge returned unexpected count: %v %v", count, err)
+			}
+		}
+
+		cutoff := time.Now().Add(time.Hour)
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT AccID, Username, AccType, AccStatus, DeletedAt, DeletedBy FROM Account WHERE DeletedAt < ? FOR UPDATE")).
+				WithArgs(cutoff.UTC().Format(audit.TimeFormat)).
+				WillReturnRows(sqlmock.NewRows(trashColumns).
+					AddRow(2003, "old1", "User", "Created", "2024-05-01 09:30:00", 1001).
+					AddRow(2004, "old2", "User", "Pending", "2024-05-01 09:31:00", nil))
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM Account WHERE DeletedAt < ?")).
+				WithArgs(cutoff.UTC().Format(audit.TimeFormat)).
+				WillReturnResult(sqlmock.NewResult(0, 2))
+			expectAudit(mock, audit.ActionPurge, 2003, nil)
+			expectAudit(mock, audit.ActionPurge, 2004, nil)
+			mock.ExpectCommit()
+		})
+
+		count, err := f.store.Purge(cutoff, audit.Actor{})
+		if err != nil || count != 2 {
+			t.Errorf("Purge returned unexpected count: %v %v", count, err)
+		}
+
+		if f.memory != nil {
+			// Purged accounts cannot be restored
+			if err := f.store.Restore(2003, audit.Actor{}); err != ErrNotFound {
+				t.Errorf("Restore returned wrong error for a purged account: got %v want %v", err, ErrNotFound)
+			}
+		}
+	})
+}
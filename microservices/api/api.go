@@ -0,0 +1,105 @@
+// Package api defines the JSON bodies the account and record services respond
+// with. Every failure uses the same envelope:
+//
+//	{"code": "not_found", "message": "Account not found", "details": ..., "requestId": "..."}
+//
+// Clients should branch on code; message is for people and may change.
+// details is only present for some codes, such as the per-field errors of
+// validation_failed. requestId matches the X-Request-ID response header.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/validate" //change here
+)
+
+// Error codes, with the status they are sent with
+const (
+	CodeInvalidPayload       = "invalid_payload"          // 400: the body is not the expected JSON
+	CodeInvalidParameter     = "invalid_parameter"        // 400: a path or query parameter is missing or malformed
+	CodeInvalidCredentials   = "invalid_credentials"      // 401: wrong username or password
+	CodeUnauthorized         = "unauthorized"             // 401: missing, invalid or expired token
+	CodeForbidden            = "forbidden"                // 403: the account may not use this route
+	CodePasswordChange       = "password_change_required" // 403: the login must set newPassword first
+	CodeNotFound             = "not_found"                // 404: no such route or resource
+	CodeMethodNotAllowed     = "method_not_allowed"       // 405: the route exists for other methods
+	CodeConflict             = "conflict"                 // 409: the change clashes with other data, such as a name in use
+	CodePreconditionFailed   = "precondition_failed"      // 412: the resource changed since the client read it
+	CodePayloadTooLarge      = "payload_too_large"        // 413: the body exceeds the route's size limit
+	CodeValidationFailed     = "validation_failed"        // 422: details lists the rejected fields
+	CodePreconditionRequired = "precondition_required"    // 428: an update is missing If-Match
+	CodeInternal             = "internal"                 // 500: unexpected server or database failure
+)
+
+// ErrorBody is the envelope written for every failed request
+type ErrorBody struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"requestId"`
+}
+
+// MessageBody is the success body of requests that return no resource
+type MessageBody struct {
+	Message string `json:"message"`
+}
+
+// JSON writes v as the response body with the given status
+func JSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Created answers a create with the new resource and its location
+func Created(w http.ResponseWriter, location string, v interface{}) {
+	w.Header().Set("Location", location)
+	JSON(w, http.StatusCreated, v)
+}
+
+// Message writes a success body carrying only a message
+func Message(w http.ResponseWriter, status int, message string) {
+	JSON(w, status, MessageBody{Message: message})
+}
+
+// Error writes the error envelope without details
+func Error(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	ErrorDetails(w, r, status, code, message, nil)
+}
+
+// ErrorDetails writes the error envelope with details
+func ErrorDetails(w http.ResponseWriter, r *http.Request, status int, code, message string, details interface{}) {
+	JSON(w, status, ErrorBody{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: RequestIDFromContext(r.Context()),
+	})
+}
+
+// Internal reports an unexpected failure without leaking its cause
+func Internal(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, http.StatusInternalServerError, CodeInternal, "Internal server error")
+}
+
+// InvalidPayload reports a body that could not be decoded
+func InvalidPayload(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, http.StatusBadRequest, CodeInvalidPayload, "Invalid request payload")
+}
+
+// Invalid reports the fields that failed validation
+func Invalid(w http.ResponseWriter, r *http.Request, errs validate.Errors) {
+	ErrorDetails(w, r, http.StatusUnprocessableEntity, CodeValidationFailed, "Request validation failed", errs)
+}
+
+// NotFound answers requests that match no route
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, http.StatusNotFound, CodeNotFound, "Not found")
+}
+
+// MethodNotAllowed answers requests whose path matches a route but not its method
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+}
@@ -0,0 +1,148 @@
+// api_test.go
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/validate" //change here
+)
+
+func TestErrorDetails(t *testing.T) {
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Invalid(w, r, validate.Errors{{Field: "name", Message: "is required"}})
+	}))
+
+	req, err := http.NewRequest("POST", "/api/v1/records", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(RequestIDHeader, "abc-123")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusUnprocessableEntity {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Handler returned wrong content type: got %v want application/json", ct)
+	}
+
+	expected := `{"code":"validation_failed","message":"Request validation failed","details":[{"field":"name","message":"is required"}],"requestId":"abc-123"}` + "\n"
+	if rr.Body.String() != expected {
+		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Message(rr, http.StatusAccepted, "Record updated successfully!")
+
+	if status := rr.Code; status != http.StatusAccepted {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusAccepted)
+	}
+	expected := `{"message":"Record updated successfully!"}` + "\n"
+	if rr.Body.String() != expected {
+		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+}
+
+func TestCreated(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Created(rr, "/api/v1/records/7", MessageBody{Message: "created"})
+
+	if status := rr.Code; status != http.StatusCreated {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	if location := rr.Header().Get("Location"); location != "/api/v1/records/7" {
+		t.Errorf("Handler returned wrong location: got %v want %v", location, "/api/v1/records/7")
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := RequestID(RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	})))
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"generated", "", false},
+		{"caller supplied", "trace-42", true},
+		{"too long", strings.Repeat("x", 129), false},
+		{"unprintable", "bad id\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			// The nested middleware keeps the outer ID
+			header := rr.Header().Get(RequestIDHeader)
+			if seen == "" || header != seen {
+				t.Errorf("Request ID mismatch: header %q, context %q", header, seen)
+			}
+			if kept := seen == tt.incoming; kept != tt.keep {
+				t.Errorf("Request ID %q kept = %v, want %v", tt.incoming, kept, tt.keep)
+			}
+		})
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		header  string
+		version int
+		ok      bool
+	}{
+		{"", 0, false},
+		{"*", 0, true},
+		{`"3"`, 3, true},
+		{`W/"3"`, -1, true},
+		{"3", -1, true},
+		{`"abc"`, -1, true},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest("PUT", "/api/v1/records/3", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.header != "" {
+			req.Header.Set("If-Match", tt.header)
+		}
+
+		rr := httptest.NewRecorder()
+		version, ok := IfMatch(rr, req)
+		if version != tt.version || ok != tt.ok {
+			t.Errorf("IfMatch(%q) = %v, %v; want %v, %v", tt.header, version, ok, tt.version, tt.ok)
+		}
+		if !ok && rr.Code != http.StatusPreconditionRequired {
+			t.Errorf("IfMatch(%q) returned wrong status code: got %v want %v", tt.header, rr.Code, http.StatusPreconditionRequired)
+		}
+
+		// MatchVersion reads the same header but lets it be left out
+		if version := MatchVersion(req); version != tt.version {
+			t.Errorf("MatchVersion(%q) = %v; want %v", tt.header, version, tt.version)
+		}
+	}
+
+	if tag := ETag(3); tag != `"3"` {
+		t.Errorf("ETag returned wrong tag: got %v want %v", tag, `"3"`)
+	}
+}
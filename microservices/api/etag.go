@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ETag returns the entity tag of a resource version
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// SetETag sends the entity tag of the resource version in the response
+func SetETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", ETag(version))
+}
+
+// IfMatch returns the resource version a conditional update expects: 0 for
+// "If-Match: *", which matches any version, and -1 for a tag no version has.
+// Without the header it answers 428 Precondition Required and returns false,
+// so updates cannot silently overwrite changes the client has not seen.
+func IfMatch(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" {
+		Error(w, r, http.StatusPreconditionRequired, CodePreconditionRequired, "If-Match header with the ETag of the resource is required")
+		return 0, false
+	}
+	return matchVersion(v), true
+}
+
+// MatchVersion is IfMatch for updates that may leave out the header, such as
+// merge patches that only touch the fields they name; without it any
+// version matches
+func MatchVersion(r *http.Request) int {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" {
+		return 0
+	}
+	return matchVersion(v)
+}
+
+func matchVersion(v string) int {
+	if v == "*" {
+		return 0
+	}
+
+	// Weak tags never match, as If-Match compares strongly
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(v, `"`), `"`))
+	if err != nil || version < 1 || !strings.HasPrefix(v, `"`) {
+		return -1
+	}
+	return version
+}
+
+// PreconditionFailed reports an update whose If-Match no longer matches the
+// resource because someone else changed it
+func PreconditionFailed(w http.ResponseWriter, r *http.Request, message string) {
+	Error(w, r, http.StatusPreconditionFailed, CodePreconditionFailed, message)
+}
@@ -0,0 +1,143 @@
+// Package audit keeps the trail of account and record changes. Stores write
+// an entry in the same transaction as the change it describes, so the log
+// never claims a change that was rolled back or misses one that committed.
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/middleware" //change here
+)
+
+// Entities and actions written to the log
+const (
+	EntityAccount = "account"
+	EntityRecord  = "record"
+	EntityCompany = "company"
+	EntityContact = "contact"
+
+	ActionCreate      = "create"
+	ActionUpdate      = "update"
+	ActionApprove     = "approve"
+	ActionDelete      = "delete"
+	ActionSetPassword = "set_password"
+	ActionRestore     = "restore"
+	ActionPurge       = "purge"
+	ActionRollback    = "rollback"
+)
+
+// TimeFormat is how CreatedAt is stored and compared, always in UTC
+const TimeFormat = "2006-01-02 15:04:05"
+
+// Entry is one logged change. Before is absent for creations and After for
+// deletions; neither ever holds a password.
+type Entry struct {
+	AuditID   int             `json:"auditId"`
+	ActorID   int             `json:"actorId,omitempty"`
+	Action    string          `json:"action"`
+	Entity    string          `json:"entity"`
+	EntityID  int             `json:"entityId"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	SourceIP  string          `json:"sourceIp"`
+	CreatedAt string          `json:"createdAt"`
+}
+
+// Actor is who made a change and from where. AccID is 0 for anonymous
+// requests such as self-signup.
+type Actor struct {
+	AccID int
+	IP    string
+}
+
+// ID returns the actor's account id for a nullable column, which is NULL
+// for anonymous actors
+func (a Actor) ID() sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(a.AccID), Valid: a.AccID != 0}
+}
+
+// ActorFrom returns the authorized caller of r and its remote address
+func ActorFrom(r *http.Request) Actor {
+	var by Actor
+	if id, ok := middleware.IdentityFromContext(r.Context()); ok {
+		by.AccID = id.AccID
+	}
+	by.IP = r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		by.IP = host
+	}
+	return by
+}
+
+// Change describes one mutation to log
+type Change struct {
+	Action   string
+	Entity   string
+	EntityID int
+	Before   interface{} // nil when the entity did not exist
+	After    interface{} // nil when the entity no longer exists
+}
+
+// now is replaced in tests
+var now = time.Now
+
+// entry builds the log entry for a change
+func entry(by Actor, c Change) (Entry, error) {
+	e := Entry{
+		ActorID:   by.AccID,
+		Action:    c.Action,
+		Entity:    c.Entity,
+		EntityID:  c.EntityID,
+		SourceIP:  by.IP,
+		CreatedAt: now().UTC().Format(TimeFormat),
+	}
+
+	var err error
+	if c.Before != nil {
+		if e.Before, err = json.Marshal(c.Before); err != nil {
+			return Entry{}, err
+		}
+	}
+	if c.After != nil {
+		if e.After, err = json.Marshal(c.After); err != nil {
+			return Entry{}, err
+		}
+	}
+	return e, nil
+}
+
+// Write logs a change as part of tx
+func Write(tx *sql.Tx, by Actor, c Change) error {
+	e, err := entry(by, c)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO AuditLog (ActorID, Action, Entity, EntityID, OldValue, NewValue, SourceIP, CreatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		by.ID(), e.Action, e.Entity, e.EntityID, nullJSON(e.Before), nullJSON(e.After), e.SourceIP, e.CreatedAt)
+	return err
+}
+
+// InTx runs fn in a transaction that is committed only if fn succeeds
+func InTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func nullJSON(v json.RawMessage) interface{} {
+	if v == nil {
+		return nil
+	}
+	return string(v)
+}
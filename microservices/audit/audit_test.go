@@ -0,0 +1,142 @@
+// audit_test.go
+package audit
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/middleware" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/query"      //change here
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func fixedNow(t *testing.T) {
+	now = func() time.Time { return time.Date(2024, 5, 1, 17, 30, 0, 0, time.FixedZone("SGT", 8*60*60)) }
+	t.Cleanup(func() { now = time.Now })
+}
+
+func TestWrite(t *testing.T) {
+	fixedNow(t)
+
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	insert := regexp.QuoteMeta("INSERT INTO AuditLog (ActorID, Action, Entity, EntityID, OldValue, NewValue, SourceIP, CreatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	mock.ExpectBegin()
+	// Anonymous actors and missing states are stored as NULL, times in UTC
+	mock.ExpectExec(insert).
+		WithArgs(nil, ActionCreate, EntityAccount, 7, nil, `{"name":"new"}`, "192.0.2.1", "2024-05-01 09:30:00").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(insert).
+		WithArgs(1001, ActionDelete, EntityRecord, 3, `{"name":"old"}`, nil, "192.0.2.2", "2024-05-01 09:30:00").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	err = InTx(db, func(tx *sql.Tx) error {
+		if err := Write(tx, Actor{IP: "192.0.2.1"}, Change{Action: ActionCreate, Entity: EntityAccount, EntityID: 7, After: map[string]string{"name": "new"}}); err != nil {
+			return err
+		}
+		return Write(tx, Actor{AccID: 1001, IP: "192.0.2.2"}, Change{Action: ActionDelete, Entity: EntityRecord, EntityID: 3, Before: map[string]string{"name": "old"}})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_Rollback(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	failure := errors.New("update failed")
+	if err := InTx(db, func(tx *sql.Tx) error { return failure }); err != failure {
+		t.Errorf("InTx returned wrong error: got %v want %v", err, failure)
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestActorFrom(t *testing.T) {
+	req, err := http.NewRequest("DELETE", "/api/v1/records/3", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.RemoteAddr = "192.0.2.1:54321"
+
+	if by := ActorFrom(req); by != (Actor{IP: "192.0.2.1"}) {
+		t.Errorf("ActorFrom returned unexpected actor for an anonymous request: %+v", by)
+	}
+
+	req = req.WithContext(middleware.WithIdentity(req.Context(), middleware.Identity{AccID: 1001, AccType: "Admin"}))
+	if by := ActorFrom(req); by != (Actor{AccID: 1001, IP: "192.0.2.1"}) {
+		t.Errorf("ActorFrom returned unexpected actor: %+v", by)
+	}
+}
+
+func TestParse(t *testing.T) {
+	p, err := Parse(url.Values{"entity": {"record"}, "from": {"2024-05-01"}, "to": {"2024-05-02T08:00:00+08:00"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []query.Filter{
+		{Column: "Entity", Value: "record"},
+		{Column: "CreatedAt", Op: ">=", Value: "2024-05-01 00:00:00"},
+		{Column: "CreatedAt", Op: "<", Value: "2024-05-02 00:00:00"},
+	}
+	if !reflect.DeepEqual(p.Filters, expected) {
+		t.Errorf("Parse returned wrong filters: got %+v want %+v", p.Filters, expected)
+	}
+
+	if _, err := Parse(url.Values{"to": {"May 2024"}}); err == nil {
+		t.Errorf("Parse accepted an invalid time")
+	}
+}
+
+func TestMemoryLog(t *testing.T) {
+	fixedNow(t)
+
+	log := NewMemoryLog()
+	log.Write(Actor{AccID: 1001}, Change{Action: ActionApprove, Entity: EntityAccount, EntityID: 2004, Before: 1, After: 2})
+	log.Write(Actor{AccID: 2001}, Change{Action: ActionUpdate, Entity: EntityRecord, EntityID: 3, Before: 1, After: 2})
+	log.Write(Actor{AccID: 1001}, Change{Action: ActionDelete, Entity: EntityRecord, EntityID: 3, Before: 2})
+
+	p, err := Parse(url.Values{"actor": {"1001"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := log.List(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries logged in the same second are still listed newest first
+	if page.Total != 2 || page.Items[0].AuditID != 3 || page.Items[1].AuditID != 1 {
+		t.Errorf("List returned unexpected page: %+v", page)
+	}
+}
@@ -0,0 +1,152 @@
+package audit
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/query" //change here
+)
+
+// Store reads the audit log
+type Store interface {
+	List(p query.Params) (query.Page[Entry], error)
+}
+
+// list options accepted by Parse; newest entries come first by default
+var listSpec = query.Spec{
+	Table:   "AuditLog",
+	Columns: []string{"AuditID", "ActorID", "Action", "Entity", "EntityID", "OldValue", "NewValue", "SourceIP", "CreatedAt"},
+	Key:     "AuditID",
+	Sortable: map[string]string{
+		"auditId":   "AuditID",
+		"createdAt": "CreatedAt",
+	},
+	Filterable: map[string]string{
+		"actor":    "ActorID",
+		"action":   "Action",
+		"entity":   "Entity",
+		"entityId": "EntityID",
+	},
+	Ranges: map[string]query.Range{
+		"from": {Column: "CreatedAt", Op: ">="},
+		"to":   {Column: "CreatedAt", Op: "<"},
+	},
+	DefaultSort:  []query.SortField{{Column: "CreatedAt", Desc: true}, {Column: "AuditID", Desc: true}},
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// Parse reads the list options of an audit query. from and to bound the
+// creation time and accept RFC 3339 timestamps or plain dates.
+func Parse(values url.Values) (query.Params, error) {
+	values = cloneValues(values)
+	for _, name := range []string{"from", "to"} {
+		if v := values.Get(name); v != "" {
+			t, err := parseTime(v)
+			if err != nil {
+				return query.Params{}, errors.New(name + " must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+			}
+			values.Set(name, t.UTC().Format(TimeFormat))
+		}
+	}
+	return listSpec.Parse(values)
+}
+
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+func cloneValues(values url.Values) url.Values {
+	c := make(url.Values, len(values))
+	for k, v := range values {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
+func scanEntry(rows *sql.Rows) (Entry, error) {
+	var e Entry
+	var actor sql.NullInt64
+	var before, after []byte
+	err := rows.Scan(&e.AuditID, &actor, &e.Action, &e.Entity, &e.EntityID, &before, &after, &e.SourceIP, &e.CreatedAt)
+	e.ActorID = int(actor.Int64)
+	if before != nil {
+		e.Before = before
+	}
+	if after != nil {
+		e.After = after
+	}
+	return e, err
+}
+
+// entryValue returns the value of a column for filtering, sorting and cursors
+func entryValue(e Entry, column string) interface{} {
+	switch column {
+	case "AuditID":
+		return e.AuditID
+	case "ActorID":
+		return e.ActorID
+	case "Action":
+		return e.Action
+	case "Entity":
+		return e.Entity
+	case "EntityID":
+		return e.EntityID
+	case "CreatedAt":
+		return e.CreatedAt
+	}
+	return nil
+}
+
+// MySQLStore reads the AuditLog table
+type MySQLStore struct {
+	db *sql.DB
+}
+
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+func (s *MySQLStore) List(p query.Params) (query.Page[Entry], error) {
+	return query.List(s.db, listSpec, p, scanEntry, entryValue)
+}
+
+// MemoryLog keeps the audit log in memory for the memory stores
+type MemoryLog struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func NewMemoryLog() *MemoryLog {
+	return &MemoryLog{}
+}
+
+// Write logs a change. Memory stores call it while holding their own lock so
+// the entry lands together with the change.
+func (l *MemoryLog) Write(by Actor, c Change) error {
+	e, err := entry(by, c)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.AuditID = len(l.entries) + 1
+	l.entries = append(l.entries, e)
+	return nil
+}
+
+func (l *MemoryLog) List(p query.Params) (query.Page[Entry], error) {
+	l.mu.Lock()
+	entries := append([]Entry(nil), l.entries...)
+	l.mu.Unlock()
+
+	return query.ListSlice(entries, p, entryValue), nil
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims carried by a session token
+type Claims struct {
+	AccID   int    `json:"accId"`
+	AccType string `json:"accType"`
+	jwt.RegisteredClaims
+}
+
+// Token is returned to the client after login or refresh
+type Token struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	AccID     int       `json:"accId"`
+	AccType   string    `json:"accType"`
+}
+
+var (
+	ErrMissingToken = errors.New("missing bearer token")
+	ErrInvalidToken = errors.New("invalid or expired token")
+	ErrRevokedToken = errors.New("token has been revoked")
+)
+
+var (
+	secret   []byte
+	tokenTTL = 1 * time.Hour
+
+	// Services running in one process each set the revocation store while
+	// others may already be verifying tokens, so it is read under a lock
+	revocationsMu sync.RWMutex
+	revocations   RevocationStore = NewMemoryRevocationStore()
+)
+
+func init() {
+	if s := os.Getenv("AUTH_SECRET"); s != "" {
+		secret = []byte(s)
+		return
+	}
+
+	// Without a configured secret tokens only stay valid for this process
+	secret = make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("AUTH_SECRET not set, using a random signing secret")
+}
+
+func SetSecret(s []byte) {
+	secret = s
+}
+
+func SetTokenTTL(ttl time.Duration) {
+	tokenTTL = ttl
+}
+
+func SetRevocationStore(store RevocationStore) {
+	revocationsMu.Lock()
+	defer revocationsMu.Unlock()
+	revocations = store
+}
+
+func revocationStore() RevocationStore {
+	revocationsMu.RLock()
+	defer revocationsMu.RUnlock()
+	return revocations
+}
+
+// Issue signs a new token for the given account
+func Issue(accID int, accType string) (Token, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return Token{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(tokenTTL)
+	claims := Claims{
+		AccID:   accID,
+		AccType: accType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.Itoa(accID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		return Token{}, err
+	}
+
+	return Token{Token: signed, ExpiresAt: expiresAt, AccID: accID, AccType: accType}, nil
+}
+
+// Verify checks the signature, expiry and revocation status of a token
+func Verify(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := revocationStore().IsRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrRevokedToken
+	}
+
+	return claims, nil
+}
+
+// Revoke invalidates a token before it expires
+func Revoke(claims *Claims) error {
+	return revocationStore().Revoke(claims.ID, claims.ExpiresAt.Time)
+}
+
+// TokenFromRequest reads the bearer token from the Authorization header
+func TokenFromRequest(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	tokenString, found := strings.CutPrefix(header, "Bearer ")
+	if !found || tokenString == "" {
+		return "", ErrMissingToken
+	}
+	return tokenString, nil
+}
+
+// FromRequest verifies the bearer token sent with a request
+func FromRequest(r *http.Request) (*Claims, error) {
+	tokenString, err := TokenFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	return Verify(tokenString)
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
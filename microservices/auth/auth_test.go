@@ -0,0 +1,177 @@
+// auth_test.go
+package auth
+
+import (
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestIssueAndVerify(t *testing.T) {
+	SetSecret([]byte("test-secret"))
+	SetRevocationStore(NewMemoryRevocationStore())
+
+	token, err := Issue(1001, "Admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := Verify(token.Token)
+	if err != nil {
+		t.Fatalf("Verify returned an error: %v", err)
+	}
+	if claims.AccID != 1001 || claims.AccType != "Admin" {
+		t.Errorf("Verify returned unexpected claims: got %v %v want 1001 Admin", claims.AccID, claims.AccType)
+	}
+}
+
+func TestVerify_WrongSecret(t *testing.T) {
+	SetSecret([]byte("test-secret"))
+	token, err := Issue(1001, "Admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetSecret([]byte("other-secret"))
+	defer SetSecret([]byte("test-secret"))
+
+	if _, err := Verify(token.Token); err != ErrInvalidToken {
+		t.Errorf("Verify returned wrong error: got %v want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestVerify_Expired(t *testing.T) {
+	SetSecret([]byte("test-secret"))
+	SetTokenTTL(-time.Minute)
+	defer SetTokenTTL(time.Hour)
+
+	token, err := Issue(1001, "Admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Verify(token.Token); err != ErrInvalidToken {
+		t.Errorf("Verify returned wrong error: got %v want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	SetSecret([]byte("test-secret"))
+	SetRevocationStore(NewMemoryRevocationStore())
+
+	token, err := Issue(2001, "User")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := Verify(token.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Revoke(claims); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Verify(token.Token); err != ErrRevokedToken {
+		t.Errorf("Verify returned wrong error: got %v want %v", err, ErrRevokedToken)
+	}
+}
+
+func TestSetRevocationStore_Concurrent(t *testing.T) {
+	SetSecret([]byte("test-secret"))
+	SetRevocationStore(NewMemoryRevocationStore())
+
+	token, err := Issue(2001, "User")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// In "all" mode each service sets the store while the other verifies
+	// tokens; go test -race reports any unguarded access
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetRevocationStore(NewMemoryRevocationStore())
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := Verify(token.Token); err != nil {
+				t.Errorf("Verify returned unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTokenFromRequest(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    string
+		wantErr error
+	}{
+		{"Bearer abc.def.ghi", "abc.def.ghi", nil},
+		{"", "", ErrMissingToken},
+		{"Basic dXNlcjpwd2Q=", "", ErrMissingToken},
+		{"Bearer ", "", ErrMissingToken},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+
+		got, err := TokenFromRequest(req)
+		if got != tt.want || err != tt.wantErr {
+			t.Errorf("TokenFromRequest(%q) = %q, %v want %q, %v", tt.header, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSQLRevocationStore(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	store := NewSQLRevocationStore(db)
+	expiresAt := time.Now().Add(time.Hour)
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM RevokedToken WHERE ExpiresAt < ?")).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO RevokedToken (TokenID, ExpiresAt) VALUES (?, ?)")).
+		WithArgs("abc", expiresAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM RevokedToken WHERE TokenID = ?")).
+		WithArgs("abc").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+
+	if err := store.Revoke("abc", expiresAt); err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := store.IsRevoked("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !revoked {
+		t.Errorf("IsRevoked returned false for a revoked token")
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// RevocationStore keeps track of tokens that were logged out or refreshed
+type RevocationStore interface {
+	Revoke(tokenID string, expiresAt time.Time) error
+	IsRevoked(tokenID string) (bool, error)
+}
+
+// MemoryRevocationStore is used when no database is configured and in tests
+type MemoryRevocationStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{revoked: make(map[string]time.Time)}
+}
+
+func (s *MemoryRevocationStore) Revoke(tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop entries for tokens that have expired anyway
+	now := time.Now()
+	for id, exp := range s.revoked {
+		if exp.Before(now) {
+			delete(s.revoked, id)
+		}
+	}
+
+	s.revoked[tokenID] = expiresAt
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsRevoked(tokenID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.revoked[tokenID]
+	return ok, nil
+}
+
+// SQLRevocationStore persists revoked tokens in the RevokedToken table so
+// every service instance sees the same logouts
+type SQLRevocationStore struct {
+	db *sql.DB
+}
+
+func NewSQLRevocationStore(db *sql.DB) *SQLRevocationStore {
+	return &SQLRevocationStore{db: db}
+}
+
+func (s *SQLRevocationStore) Revoke(tokenID string, expiresAt time.Time) error {
+	// Remove rows for tokens that can no longer be used
+	if _, err := s.db.Exec("DELETE FROM RevokedToken WHERE ExpiresAt < ?", time.Now()); err != nil {
+		return err
+	}
+
+	_, err := s.db.Exec("INSERT INTO RevokedToken (TokenID, ExpiresAt) VALUES (?, ?)", tokenID, expiresAt)
+	return err
+}
+
+func (s *SQLRevocationStore) IsRevoked(tokenID string) (bool, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM RevokedToken WHERE TokenID = ?", tokenID).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
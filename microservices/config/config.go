@@ -0,0 +1,280 @@
+package config
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting shared by the account and record services and
+// the gateway. Values come from Default, then an optional YAML file, then the
+// environment.
+type Config struct {
+	Database DatabaseConfig `yaml:"database"`
+	HTTP     HTTPConfig     `yaml:"http"`
+	Account  ServiceConfig  `yaml:"account"`
+	Record   ServiceConfig  `yaml:"record"`
+	Gateway  ServiceConfig  `yaml:"gateway"`
+	Web      WebConfig      `yaml:"web"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Trash    TrashConfig    `yaml:"trash"`
+	Stats    StatsConfig    `yaml:"stats"`
+	LogLevel string         `yaml:"logLevel"`
+}
+
+type DatabaseConfig struct {
+	DSN             string        `yaml:"dsn"`
+	MaxOpenConns    int           `yaml:"maxOpenConns"`
+	MaxIdleConns    int           `yaml:"maxIdleConns"`
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime"`
+}
+
+type HTTPConfig struct {
+	ReadTimeout    time.Duration `yaml:"readTimeout"`
+	WriteTimeout   time.Duration `yaml:"writeTimeout"`
+	AllowedOrigins []string      `yaml:"allowedOrigins"`
+	// ShutdownTimeout bounds how long in-flight requests may run after a stop signal
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	// ReadyTimeout bounds the database ping behind /readyz
+	ReadyTimeout time.Duration `yaml:"readyTimeout"`
+}
+
+type ServiceConfig struct {
+	Port int `yaml:"port"`
+}
+
+// WebConfig controls the frontend served by the gateway
+type WebConfig struct {
+	// APIBase is the origin the pages send API requests to; empty means the
+	// gateway that served them
+	APIBase string `yaml:"apiBase"`
+}
+
+type AuthConfig struct {
+	// Secret signs session tokens; when empty a random per-process secret is used
+	Secret   string        `yaml:"secret"`
+	TokenTTL time.Duration `yaml:"tokenTTL"`
+}
+
+// TrashConfig controls how long deleted accounts and records can be restored
+type TrashConfig struct {
+	// Retention is how long items stay in the trash before "console purge"
+	// removes them for good
+	Retention time.Duration `yaml:"retention"`
+}
+
+// StatsConfig controls the admin statistics
+type StatsConfig struct {
+	// CacheTTL is how long a summary is served before it is computed again;
+	// 0 computes it on every request
+	CacheTTL time.Duration `yaml:"cacheTTL"`
+}
+
+// Default returns the settings used for local development
+func Default() Config {
+	return Config{
+		Database: DatabaseConfig{
+			DSN:             "record_system:dopasgpwd@tcp(127.0.0.1:3306)/record_db",
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		HTTP: HTTPConfig{
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    15 * time.Second,
+			AllowedOrigins:  []string{"*"},
+			ShutdownTimeout: 10 * time.Second,
+			ReadyTimeout:    2 * time.Second,
+		},
+		Account:  ServiceConfig{Port: 5001},
+		Record:   ServiceConfig{Port: 5002},
+		Gateway:  ServiceConfig{Port: 5000},
+		Auth:     AuthConfig{TokenTTL: time.Hour},
+		Trash:    TrashConfig{Retention: 30 * 24 * time.Hour},
+		Stats:    StatsConfig{CacheTTL: time.Minute},
+		LogLevel: "info",
+	}
+}
+
+// Load builds the configuration from the file named by CONFIG_FILE (if any)
+// and the process environment
+func Load() (Config, error) {
+	return LoadFrom(os.Getenv("CONFIG_FILE"), os.LookupEnv)
+}
+
+// LoadFrom is Load with an explicit file path and environment lookup, so
+// tests can inject their own settings
+func LoadFrom(path string, lookup func(string) (string, bool)) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(&cfg, lookup); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides settings with any environment variables that are set
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	str := func(key string, dst *string) {
+		if v, ok := lookup(key); ok {
+			*dst = v
+		}
+	}
+	num := func(key string, dst *int) error {
+		if v, ok := lookup(key); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			*dst = n
+		}
+		return nil
+	}
+	dur := func(key string, dst *time.Duration) error {
+		if v, ok := lookup(key); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			*dst = d
+		}
+		return nil
+	}
+
+	str("DB_DSN", &cfg.Database.DSN)
+	str("LOG_LEVEL", &cfg.LogLevel)
+	str("AUTH_SECRET", &cfg.Auth.Secret)
+	str("WEB_API_BASE", &cfg.Web.APIBase)
+	if v, ok := lookup("ALLOWED_ORIGINS"); ok {
+		cfg.HTTP.AllowedOrigins = splitList(v)
+	}
+
+	return errors.Join(
+		num("DB_MAX_OPEN_CONNS", &cfg.Database.MaxOpenConns),
+		num("DB_MAX_IDLE_CONNS", &cfg.Database.MaxIdleConns),
+		dur("DB_CONN_MAX_LIFETIME", &cfg.Database.ConnMaxLifetime),
+		dur("HTTP_READ_TIMEOUT", &cfg.HTTP.ReadTimeout),
+		dur("HTTP_WRITE_TIMEOUT", &cfg.HTTP.WriteTimeout),
+		dur("HTTP_SHUTDOWN_TIMEOUT", &cfg.HTTP.ShutdownTimeout),
+		dur("HTTP_READY_TIMEOUT", &cfg.HTTP.ReadyTimeout),
+		num("ACCOUNT_PORT", &cfg.Account.Port),
+		num("RECORD_PORT", &cfg.Record.Port),
+		num("GATEWAY_PORT", &cfg.Gateway.Port),
+		dur("AUTH_TOKEN_TTL", &cfg.Auth.TokenTTL),
+		dur("TRASH_RETENTION", &cfg.Trash.Retention),
+		dur("STATS_CACHE_TTL", &cfg.Stats.CacheTTL),
+	)
+}
+
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Validate reports every invalid setting at once
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Database.DSN == "" {
+		errs = append(errs, errors.New("database.dsn is required"))
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("database pool sizes must not be negative"))
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("database.maxIdleConns must not exceed maxOpenConns"))
+	}
+	if c.HTTP.ReadTimeout <= 0 || c.HTTP.WriteTimeout <= 0 || c.HTTP.ShutdownTimeout <= 0 || c.HTTP.ReadyTimeout <= 0 {
+		errs = append(errs, errors.New("http timeouts must be positive"))
+	}
+	if len(c.HTTP.AllowedOrigins) == 0 {
+		errs = append(errs, errors.New("http.allowedOrigins must list at least one origin"))
+	}
+	if c.Account.Port <= 0 || c.Account.Port > 65535 {
+		errs = append(errs, errors.New("account.port must be between 1 and 65535"))
+	}
+	if c.Record.Port <= 0 || c.Record.Port > 65535 {
+		errs = append(errs, errors.New("record.port must be between 1 and 65535"))
+	}
+	if c.Gateway.Port <= 0 || c.Gateway.Port > 65535 {
+		errs = append(errs, errors.New("gateway.port must be between 1 and 65535"))
+	}
+	if c.Account.Port == c.Record.Port {
+		errs = append(errs, errors.New("account.port and record.port must differ"))
+	}
+	if c.Auth.TokenTTL <= 0 {
+		errs = append(errs, errors.New("auth.tokenTTL must be positive"))
+	}
+	if c.Trash.Retention <= 0 {
+		errs = append(errs, errors.New("trash.retention must be positive"))
+	}
+	if c.Stats.CacheTTL < 0 {
+		errs = append(errs, errors.New("stats.cacheTTL must not be negative"))
+	}
+	if _, err := c.SlogLevel(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// SlogLevel converts LogLevel for use with log/slog
+func (c Config) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return 0, errors.New("logLevel must be one of debug, info, warn or error")
+	}
+	return level, nil
+}
+
+// AllowOrigin returns the Access-Control-Allow-Origin value for a request
+// origin, or false when the origin is not allowed
+func (h HTTPConfig) AllowOrigin(origin string) (string, bool) {
+	for _, allowed := range h.AllowedOrigins {
+		if allowed == "*" {
+			return "*", true
+		}
+		if origin != "" && allowed == origin {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// ApplyPool sets the connection pool limits on an opened database
+func (d DatabaseConfig) ApplyPool(db *sql.DB) {
+	db.SetMaxOpenConns(d.MaxOpenConns)
+	db.SetMaxIdleConns(d.MaxIdleConns)
+	db.SetConnMaxLifetime(d.ConnMaxLifetime)
+}
+
+// Addr returns the listen address for a service port
+func (s ServiceConfig) Addr() string {
+	return ":" + strconv.Itoa(s.Port)
+}
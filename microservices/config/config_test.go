@@ -0,0 +1,133 @@
+// config_test.go
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// envFrom returns a lookup function backed by a map instead of the process environment
+func envFrom(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestLoadFrom_Defaults(t *testing.T) {
+	cfg, err := LoadFrom("", envFrom(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("LoadFrom returned unexpected config: got %+v want %+v", cfg, Default())
+	}
+}
+
+func TestLoadFrom_FileAndEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	file := `
+database:
+  dsn: "staging:pwd@tcp(db.staging:3306)/record_db"
+  maxOpenConns: 20
+http:
+  readTimeout: 5s
+  allowedOrigins: ["https://staging.example.com"]
+account:
+  port: 6001
+trash:
+  retention: 168h
+logLevel: debug
+`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The environment wins over the file
+	cfg, err := LoadFrom(path, envFrom(map[string]string{
+		"RECORD_PORT":     "6002",
+		"LOG_LEVEL":       "warn",
+		"ALLOWED_ORIGINS": "https://a.example.com, https://b.example.com",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Database.DSN != "staging:pwd@tcp(db.staging:3306)/record_db" {
+		t.Errorf("unexpected DSN: %v", cfg.Database.DSN)
+	}
+	if cfg.Database.MaxOpenConns != 20 || cfg.Database.MaxIdleConns != 5 {
+		t.Errorf("unexpected pool sizes: %v %v", cfg.Database.MaxOpenConns, cfg.Database.MaxIdleConns)
+	}
+	if cfg.HTTP.ReadTimeout != 5*time.Second || cfg.HTTP.WriteTimeout != 15*time.Second {
+		t.Errorf("unexpected timeouts: %v %v", cfg.HTTP.ReadTimeout, cfg.HTTP.WriteTimeout)
+	}
+	if cfg.Account.Port != 6001 || cfg.Record.Port != 6002 {
+		t.Errorf("unexpected ports: %v %v", cfg.Account.Port, cfg.Record.Port)
+	}
+	if cfg.Trash.Retention != 7*24*time.Hour {
+		t.Errorf("unexpected trash retention: %v", cfg.Trash.Retention)
+	}
+	if cfg.LogLevel != "warn" {
+		t.Errorf("unexpected log level: %v", cfg.LogLevel)
+	}
+	expectedOrigins := []string{"https://a.example.com", "https://b.example.com"}
+	if !reflect.DeepEqual(cfg.HTTP.AllowedOrigins, expectedOrigins) {
+		t.Errorf("unexpected origins: got %v want %v", cfg.HTTP.AllowedOrigins, expectedOrigins)
+	}
+}
+
+func TestLoadFrom_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"bad number", map[string]string{"ACCOUNT_PORT": "abc"}, "ACCOUNT_PORT"},
+		{"bad duration", map[string]string{"HTTP_READ_TIMEOUT": "soon"}, "HTTP_READ_TIMEOUT"},
+		{"empty dsn", map[string]string{"DB_DSN": ""}, "database.dsn is required"},
+		{"port out of range", map[string]string{"RECORD_PORT": "70000"}, "record.port"},
+		{"same ports", map[string]string{"RECORD_PORT": "5001"}, "must differ"},
+		{"idle above open", map[string]string{"DB_MAX_IDLE_CONNS": "50"}, "maxIdleConns"},
+		{"no origins", map[string]string{"ALLOWED_ORIGINS": " , "}, "allowedOrigins"},
+		{"bad log level", map[string]string{"LOG_LEVEL": "loud"}, "logLevel"},
+		{"zero ttl", map[string]string{"AUTH_TOKEN_TTL": "0s"}, "tokenTTL"},
+		{"negative retention", map[string]string{"TRASH_RETENTION": "-1h"}, "trash.retention"},
+		{"negative stats ttl", map[string]string{"STATS_CACHE_TTL": "-1s"}, "stats.cacheTTL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFrom("", envFrom(tt.env))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadFrom returned wrong error: got %v want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadFrom_MissingFile(t *testing.T) {
+	if _, err := LoadFrom(filepath.Join(t.TempDir(), "missing.yaml"), envFrom(nil)); err == nil {
+		t.Errorf("LoadFrom returned no error for a missing file")
+	}
+}
+
+func TestAllowOrigin(t *testing.T) {
+	restricted := HTTPConfig{AllowedOrigins: []string{"https://a.example.com"}}
+	if origin, ok := restricted.AllowOrigin("https://a.example.com"); !ok || origin != "https://a.example.com" {
+		t.Errorf("AllowOrigin rejected a listed origin: %v %v", origin, ok)
+	}
+	if _, ok := restricted.AllowOrigin("https://evil.example.com"); ok {
+		t.Errorf("AllowOrigin accepted an unlisted origin")
+	}
+
+	open := HTTPConfig{AllowedOrigins: []string{"*"}}
+	if origin, ok := open.AllowOrigin("https://any.example.com"); !ok || origin != "*" {
+		t.Errorf("AllowOrigin did not allow any origin: %v %v", origin, ok)
+	}
+}
@@ -0,0 +1,82 @@
+// Package gateway serves the account and record APIs from one listener so
+// the frontend only needs a single origin.
+package gateway
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/account"    //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"        //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/auth"       //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/config"     //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/middleware" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/record"     //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/service"    //change here
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// path prefixes served by each service; anything else is not found
+var (
+	accountPrefixes = []string{"/api/v1/auth", "/api/v1/accounts", "/api/v1/admin", "/api/v1/audit", "/api/v1/stats"}
+	recordPrefixes  = []string{"/api/v1/records", "/api/v1/companies", "/api/v1/contacts"}
+)
+
+// Handler routes requests to the account and record routers by path. Each
+// service still authorizes its own routes; CORS and request IDs are applied
+// once for both. Any other path is served by site, which may be nil.
+func Handler(accounts, records, site http.Handler, ready service.Pinger, h config.HTTPConfig) http.Handler {
+	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(api.NotFound)
+	router.HandleFunc("/healthz", service.Healthz).Methods("GET")
+	router.HandleFunc("/readyz", service.Readyz(ready, h.ReadyTimeout)).Methods("GET")
+
+	for _, prefix := range accountPrefixes {
+		router.PathPrefix(prefix).Handler(accounts)
+	}
+	for _, prefix := range recordPrefixes {
+		router.PathPrefix(prefix).Handler(records)
+	}
+	if site != nil {
+		router.PathPrefix("/").Handler(site)
+	}
+
+	return service.CORS(h)(api.RequestID(router))
+}
+
+// Run serves both APIs and the frontend in site on the gateway port until ctx
+// is cancelled, sharing one database pool between the APIs
+func Run(ctx context.Context, cfg config.Config, site http.Handler) error {
+	db, err := sql.Open("mysql", cfg.Database.DSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	cfg.Database.ApplyPool(db)
+
+	if err := db.Ping(); err != nil {
+		return err
+	}
+	fmt.Println("Connected to the database")
+
+	// Share logouts with separately deployed services through the database
+	auth.SetRevocationStore(auth.NewSQLRevocationStore(db))
+
+	accountStore := account.NewMySQLStore(db)
+	accounts := account.NewServer(accountStore).Router()
+	records := record.NewServer(record.NewMySQLStore(db), middleware.SQLResolver(db)).Router()
+
+	server := &http.Server{
+		Addr:         cfg.Gateway.Addr(),
+		Handler:      Handler(accounts, records, site, accountStore, cfg.HTTP),
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+	}
+
+	fmt.Println("Gateway listening at port", cfg.Gateway.Port)
+	return service.Serve(ctx, server, cfg.HTTP.ShutdownTimeout)
+}
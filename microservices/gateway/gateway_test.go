@@ -0,0 +1,240 @@
+// gateway_test.go
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/account"    //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"        //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/config"     //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/middleware" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/record"     //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/web"        //change here
+	"DevOps_Oct2023_TeamB_Assignment/static"                   //change here
+)
+
+type readyPinger struct{}
+
+func (readyPinger) Ping(ctx context.Context) error {
+	return nil
+}
+
+// named returns a handler that answers with its name so tests can see where a request went
+func named(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, name)
+	})
+}
+
+func TestHandler_Routing(t *testing.T) {
+	handler := Handler(named("account"), named("record"), nil, readyPinger{}, config.Default().HTTP)
+
+	tests := []struct {
+		method   string
+		path     string
+		status   int
+		expected string
+	}{
+		{"POST", "/api/v1/auth/login", http.StatusOK, "account"},
+		{"GET", "/api/v1/accounts/all", http.StatusOK, "account"},
+		{"POST", "/api/v1/admin/accounts", http.StatusOK, "account"},
+		{"GET", "/api/v1/audit", http.StatusOK, "account"},
+		{"GET", "/api/v1/stats", http.StatusOK, "account"},
+		{"GET", "/api/v1/records/search", http.StatusOK, "record"},
+		{"PUT", "/api/v1/records/3", http.StatusOK, "record"},
+		{"GET", "/api/v1/companies/2/contacts", http.StatusOK, "record"},
+		{"DELETE", "/api/v1/contacts/4", http.StatusOK, "record"},
+		{"GET", "/healthz", http.StatusOK, "{\"status\":\"ok\"}\n"},
+		{"GET", "/readyz", http.StatusOK, "{\"status\":\"ok\"}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.status {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, tt.status)
+			}
+			if rr.Body.String() != tt.expected {
+				t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestHandler_NotFound(t *testing.T) {
+	handler := Handler(named("account"), named("record"), nil, readyPinger{}, config.Default().HTTP)
+
+	req, err := http.NewRequest("GET", "/api/v1/unknown", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(api.RequestIDHeader, "trace-123")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+
+	// Unknown API paths get the error envelope tagged with the caller's request ID
+	expected := `{"code":"not_found","message":"Not found","requestId":"trace-123"}` + "\n"
+	if rr.Body.String() != expected {
+		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+	if id := rr.Header().Get(api.RequestIDHeader); id != "trace-123" {
+		t.Errorf("Handler returned wrong request ID header: got %v want trace-123", id)
+	}
+}
+
+func TestHandler_Site(t *testing.T) {
+	handler := Handler(named("account"), named("record"), named("site"), readyPinger{}, config.Default().HTTP)
+
+	// Paths outside the APIs go to the frontend
+	for _, path := range []string{"/", "/templates/signup_login.html", "/api/v1/unknown"} {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Body.String() != "site" {
+			t.Errorf("GET %s was not served by the site: got %v", path, rr.Body.String())
+		}
+	}
+}
+
+func TestHandler_CORS(t *testing.T) {
+	h := config.Default().HTTP
+	h.AllowedOrigins = []string{"https://app.example.com"}
+	handler := Handler(named("account"), named("record"), nil, readyPinger{}, h)
+
+	// Preflight requests are answered by the gateway for every service
+	req, err := http.NewRequest("OPTIONS", "/api/v1/records", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if origin := rr.Header().Get("Access-Control-Allow-Origin"); origin != "https://app.example.com" {
+		t.Errorf("Handler returned wrong allowed origin: got %v want %v", origin, "https://app.example.com")
+	}
+}
+
+func TestHandler_ServiceRouters(t *testing.T) {
+	resolve := func(accID int) (middleware.Identity, error) {
+		return middleware.Identity{}, middleware.ErrUnknownAccount
+	}
+	accounts := account.NewServer(account.NewMemoryStore()).Router()
+	records := record.NewServer(record.NewMemoryStore(), resolve).Router()
+	handler := Handler(accounts, records, nil, readyPinger{}, config.Default().HTTP)
+
+	// Each service still enforces its own access policies behind the gateway
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{"GET", "/api/v1/records/search?q=x", http.StatusUnauthorized},
+		{"GET", "/api/v1/accounts/all", http.StatusUnauthorized},
+		{"GET", "/api/v1/audit", http.StatusUnauthorized},
+		{"GET", "/api/v1/stats", http.StatusUnauthorized},
+		{"GET", "/api/v1/companies", http.StatusUnauthorized},
+		{"POST", "/api/v1/auth/login", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, tt.path, strings.NewReader(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != tt.status {
+			t.Errorf("%s %s returned wrong status code: got %v want %v", tt.method, tt.path, status, tt.status)
+		}
+	}
+}
+
+// the pages the frontend scripts navigate to
+var scriptRedirect = regexp.MustCompile(`location\.href = "([^"]+)"`)
+
+func TestHandler_LoginRedirect(t *testing.T) {
+	resolve := func(accID int) (middleware.Identity, error) {
+		return middleware.Identity{}, middleware.ErrUnknownAccount
+	}
+	accounts := account.NewServer(account.NewMemoryStore(account.Account{AccID: 2001, Username: "ziyi", Password: "userpwd1", AccType: "User", AccStatus: "Created"})).Router()
+	records := record.NewServer(record.NewMemoryStore(), resolve).Router()
+	handler := Handler(accounts, records, web.New(static.Files, "", false), readyPinger{}, config.Default().HTTP)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// The login page is served from the same origin as the API
+	loginPage, err := url.Parse(web.HomePage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr := get(loginPage.Path); rr.Code != http.StatusOK {
+		t.Fatalf("GET %s returned wrong status code: got %v want %v", loginPage.Path, rr.Code, http.StatusOK)
+	}
+
+	req, err := http.NewRequest("POST", "/api/v1/auth/login", strings.NewReader(`{"username": "ziyi", "password": "userpwd1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Login returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	// Every page the script sends the browser to, starting with the one
+	// after login, must be served by the gateway
+	script := get("/js/javascript.js").Body.String()
+	redirects := scriptRedirect.FindAllStringSubmatch(script, -1)
+	if len(redirects) == 0 {
+		t.Fatal("javascript.js has no redirects")
+	}
+	for _, m := range redirects {
+		target, err := loginPage.Parse(m[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rr := get(target.Path); rr.Code != http.StatusOK {
+			t.Errorf("Redirect to %s returned wrong status code: got %v want %v", m[1], rr.Code, http.StatusOK)
+		}
+	}
+}
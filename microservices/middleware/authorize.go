@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"  //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/auth" //change here
+
+	"github.com/gorilla/mux"
+)
+
+// Identity of the account making a request
+type Identity struct {
+	AccID     int    `json:"accId"`
+	AccType   string `json:"accType"`
+	AccStatus string `json:"accStatus"`
+}
+
+// Policy describes who may call a route. Empty Roles or Statuses allow any
+// authenticated account.
+type Policy struct {
+	Public   bool
+	Roles    []string
+	Statuses []string
+}
+
+// Policies are keyed by method and mux path template, e.g. "GET /api/v1/records/all"
+type Policies map[string]Policy
+
+// Commonly used policies
+var (
+	Public        = Policy{Public: true}
+	Authenticated = Policy{}
+	AdminOnly     = Policy{Roles: []string{"Admin"}}
+	CreatedOnly   = Policy{Statuses: []string{"Created"}}
+)
+
+// Resolver loads the current type and status of an account
+type Resolver func(accID int) (Identity, error)
+
+var ErrUnknownAccount = errors.New("account does not exist")
+
+// SQLResolver resolves identities from the Account table; deleted accounts
+// are unknown
+func SQLResolver(db *sql.DB) Resolver {
+	return func(accID int) (Identity, error) {
+		id := Identity{AccID: accID}
+		err := db.QueryRow("SELECT AccType, AccStatus FROM Account WHERE AccID = ? AND DeletedAt IS NULL", accID).Scan(&id.AccType, &id.AccStatus)
+		if err == sql.ErrNoRows {
+			return Identity{}, ErrUnknownAccount
+		}
+		return id, err
+	}
+}
+
+type contextKey int
+
+const identityKey contextKey = iota
+
+// IdentityFromContext returns the identity stored by Authorize
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey).(Identity)
+	return id, ok
+}
+
+// WithIdentity stores an identity on a context
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey, id)
+}
+
+// Authorize enforces the policy registered for the matched route. Routes that
+// are missing from the table are denied.
+func Authorize(policies Policies, resolve Resolver) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			policy, ok := lookupPolicy(policies, r)
+			if !ok {
+				api.Error(w, r, http.StatusForbidden, api.CodeForbidden, "No access policy for this route")
+				return
+			}
+			if policy.Public {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			claims, err := auth.FromRequest(r)
+			if err != nil {
+				api.Error(w, r, http.StatusUnauthorized, api.CodeUnauthorized, "Missing, invalid or expired token")
+				return
+			}
+
+			id, err := resolve(claims.AccID)
+			if err == ErrUnknownAccount {
+				api.Error(w, r, http.StatusUnauthorized, api.CodeUnauthorized, "Account no longer exists")
+				return
+			} else if err != nil {
+				api.Internal(w, r)
+				return
+			}
+
+			if !allowed(policy.Roles, id.AccType) || !allowed(policy.Statuses, id.AccStatus) {
+				api.Error(w, r, http.StatusForbidden, api.CodeForbidden, "Not allowed to access this resource")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+		})
+	}
+}
+
+func lookupPolicy(policies Policies, r *http.Request) (Policy, bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return Policy{}, false
+	}
+	tmpl, err := route.GetPathTemplate()
+	if err != nil {
+		return Policy{}, false
+	}
+	policy, ok := policies[r.Method+" "+tmpl]
+	return policy, ok
+}
+
+func allowed(values []string, v string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
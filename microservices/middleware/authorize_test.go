@@ -0,0 +1,194 @@
+// authorize_test.go
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"  //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/auth" //change here
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+var testAccounts = map[int]Identity{
+	1001: {AccID: 1001, AccType: "Admin", AccStatus: "Created"},
+	2001: {AccID: 2001, AccType: "User", AccStatus: "Created"},
+	2004: {AccID: 2004, AccType: "User", AccStatus: "Pending"},
+}
+
+func testResolver(accID int) (Identity, error) {
+	if accID == 500 {
+		return Identity{}, errors.New("database error")
+	}
+	id, ok := testAccounts[accID]
+	if !ok {
+		return Identity{}, ErrUnknownAccount
+	}
+	return id, nil
+}
+
+func testRouter() *mux.Router {
+	policies := Policies{
+		"GET /public":  Public,
+		"GET /any":     Authenticated,
+		"GET /admin":   AdminOnly,
+		"GET /created": CreatedOnly,
+	}
+
+	router := mux.NewRouter()
+	router.Use(Authorize(policies, testResolver))
+
+	reached := func(w http.ResponseWriter, r *http.Request) {
+		// Authorized requests carry the caller's identity
+		if _, ok := IdentityFromContext(r.Context()); !ok && r.URL.Path != "/public" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	for _, path := range []string{"/public", "/any", "/admin", "/created", "/unlisted"} {
+		router.HandleFunc(path, reached).Methods("GET")
+	}
+	return router
+}
+
+func tokenFor(t *testing.T, accID int, accType string) string {
+	token, err := auth.Issue(accID, accType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token.Token
+}
+
+func TestAuthorize(t *testing.T) {
+	auth.SetRevocationStore(auth.NewMemoryRevocationStore())
+	router := testRouter()
+
+	admin := tokenFor(t, 1001, "Admin")
+	user := tokenFor(t, 2001, "User")
+	pending := tokenFor(t, 2004, "User")
+	deleted := tokenFor(t, 3000, "User")
+	broken := tokenFor(t, 500, "User")
+
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		want  int
+	}{
+		{"public without token", "/public", "", http.StatusOK},
+		{"missing token", "/any", "", http.StatusUnauthorized},
+		{"malformed token", "/any", "not-a-token", http.StatusUnauthorized},
+		{"deleted account", "/any", deleted, http.StatusUnauthorized},
+		{"resolver error", "/any", broken, http.StatusInternalServerError},
+		{"any authenticated", "/any", pending, http.StatusOK},
+		{"admin route as user", "/admin", user, http.StatusForbidden},
+		{"admin route as admin", "/admin", admin, http.StatusOK},
+		{"created route as pending", "/created", pending, http.StatusForbidden},
+		{"created route as user", "/created", user, http.StatusOK},
+		{"route without policy", "/unlisted", admin, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.want {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, tt.want)
+			}
+
+			// Denied requests get the JSON error envelope
+			codes := map[int]string{
+				http.StatusUnauthorized:        api.CodeUnauthorized,
+				http.StatusForbidden:           api.CodeForbidden,
+				http.StatusInternalServerError: api.CodeInternal,
+			}
+			if code, ok := codes[rr.Code]; ok {
+				if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+					t.Errorf("Handler returned wrong content type: got %v want application/json", ct)
+				}
+				var body api.ErrorBody
+				if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Code != code {
+					t.Errorf("Handler returned wrong error body: got %v want code %v", rr.Body.String(), code)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorize_RevokedToken(t *testing.T) {
+	auth.SetRevocationStore(auth.NewMemoryRevocationStore())
+	router := testRouter()
+
+	token := tokenFor(t, 1001, "Admin")
+	claims, err := auth.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.Revoke(claims); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "/admin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+}
+
+func TestSQLResolver(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT AccType, AccStatus FROM Account WHERE AccID = ? AND DeletedAt IS NULL")).
+		WithArgs(2001).
+		WillReturnRows(sqlmock.NewRows([]string{"AccType", "AccStatus"}).AddRow("User", "Created"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT AccType, AccStatus FROM Account WHERE AccID = ? AND DeletedAt IS NULL")).
+		WithArgs(3000).
+		WillReturnRows(sqlmock.NewRows([]string{"AccType", "AccStatus"}))
+
+	resolve := SQLResolver(db)
+
+	id, err := resolve(2001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != testAccounts[2001] {
+		t.Errorf("SQLResolver returned unexpected identity: got %v want %v", id, testAccounts[2001])
+	}
+
+	if _, err := resolve(3000); err != ErrUnknownAccount {
+		t.Errorf("SQLResolver returned wrong error: got %v want %v", err, ErrUnknownAccount)
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,311 @@
+// Package migrate applies the versioned schema migrations embedded in the
+// binary and records them in the schema_migrations table.
+package migrate
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var embedded embed.FS
+
+// Migration is one schema change. Files are named
+// <version>_<name>.up.sql and <version>_<name>.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum identifies the up script so edits to applied migrations are caught
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// Status reports whether a migration has been applied
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt string
+}
+
+var (
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	ErrUnknownVersion   = errors.New("applied migration is not known to this binary")
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Embedded returns the migrations compiled into the binary
+func Embedded() ([]Migration, error) {
+	return Load(embedded, "migrations")
+}
+
+// Load reads the migrations in dir ordered by version. Every migration needs
+// an up script; the down script is optional.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(m[1])
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+
+		b, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if m[3] == "up" {
+			mig.Up = string(b)
+		} else {
+			mig.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator runs migrations against a database
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func New(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Up applies every pending migration up to and including target; a target
+// of 0 applies them all. It returns the number of migrations applied.
+func (m *Migrator) Up(target int) (int, error) {
+	applied, err := m.verified()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, mig := range m.migrations {
+		if target > 0 && mig.Version > target {
+			break
+		}
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+
+		if err := m.run(mig.Up, "INSERT INTO schema_migrations (Version, Name, Checksum) VALUES (?, ?, ?)", mig.Version, mig.Name, mig.Checksum()); err != nil {
+			return count, fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Down rolls back the most recently applied migrations, newest first, and
+// returns the number rolled back
+func (m *Migrator) Down(steps int) (int, error) {
+	applied, err := m.verified()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if mig.Down == "" {
+			return count, fmt.Errorf("migration %d_%s has no down script", mig.Version, mig.Name)
+		}
+
+		if err := m.run(mig.Down, "DELETE FROM schema_migrations WHERE Version = ?", mig.Version); err != nil {
+			return count, fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Baseline records every migration up to and including version as applied
+// without running it, for a database whose schema already has those changes.
+// It returns the number of migrations recorded.
+func (m *Migrator) Baseline(version int) (int, error) {
+	applied, err := m.verified()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, mig := range m.migrations {
+		if mig.Version > version {
+			break
+		}
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+
+		if err := m.run("", "INSERT INTO schema_migrations (Version, Name, Checksum) VALUES (?, ?, ?)", mig.Version, mig.Name, mig.Checksum()); err != nil {
+			return count, fmt.Errorf("migration %d_%s baseline: %w", mig.Version, mig.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.verified()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		a, ok := applied[mig.Version]
+		statuses[i] = Status{Migration: mig, Applied: ok, AppliedAt: a.appliedAt}
+	}
+	return statuses, nil
+}
+
+type appliedMigration struct {
+	checksum  string
+	appliedAt string
+}
+
+// verified loads the applied migrations and checks them against the known ones
+func (m *Migrator) verified() (map[int]appliedMigration, error) {
+	if _, err := m.db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (Version int NOT NULL, Name varchar (100) NOT NULL, Checksum char (64) NOT NULL, AppliedAt datetime NOT NULL DEFAULT CURRENT_TIMESTAMP, PRIMARY KEY (Version))"); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query("SELECT Version, Checksum, AppliedAt FROM schema_migrations ORDER BY Version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	known := make(map[int]Migration, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = mig
+	}
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+
+		mig, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: version %d", ErrUnknownVersion, version)
+		}
+		if mig.Checksum() != a.checksum {
+			return nil, fmt.Errorf("%w: %d_%s was changed after it was applied", ErrChecksumMismatch, mig.Version, mig.Name)
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// run executes a script and updates schema_migrations in one transaction.
+// MySQL commits DDL implicitly, so only data changes are rolled back on failure.
+func (m *Migrator) run(script, track string, args ...interface{}) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range splitStatements(script) {
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(track, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// splitStatements splits a script on semicolons outside quotes and drops
+// "--" comments, since the driver runs one statement per call
+func splitStatements(script string) []string {
+	var stmts []string
+	var b strings.Builder
+	var quote rune
+
+	flush := func() {
+		if s := strings.TrimSpace(b.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		b.Reset()
+	}
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			b.WriteRune(r)
+			if r == '\\' && i+1 < len(runes) {
+				i++
+				b.WriteRune(runes[i])
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+			b.WriteRune(r)
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			b.WriteRune('\n')
+		case r == ';':
+			flush()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	flush()
+	return stmts
+}
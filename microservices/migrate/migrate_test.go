@@ -0,0 +1,237 @@
+// migrate_test.go
+package migrate
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "create_item", Up: "CREATE TABLE Item (ItemID int);", Down: "DROP TABLE Item;"},
+	{Version: 2, Name: "seed_item", Up: "INSERT INTO Item VALUES (1);\nINSERT INTO Item VALUES (2);", Down: "DELETE FROM Item;"},
+}
+
+// expectApplied sets up the schema_migrations bootstrap and lists the given versions as applied
+func expectApplied(mock sqlmock.Sqlmock, applied ...Migration) {
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	rows := sqlmock.NewRows([]string{"Version", "Checksum", "AppliedAt"})
+	for _, mig := range applied {
+		rows.AddRow(mig.Version, mig.Checksum(), "2024-01-01 00:00:00")
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT Version, Checksum, AppliedAt FROM schema_migrations ORDER BY Version")).
+		WillReturnRows(rows)
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_seed.up.sql":     {Data: []byte("INSERT")},
+		"m/0001_create.up.sql":   {Data: []byte("CREATE")},
+		"m/0001_create.down.sql": {Data: []byte("DROP")},
+		"m/README.md":            {Data: []byte("ignored")},
+	}
+
+	migrations, err := Load(fsys, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Migration{
+		{Version: 1, Name: "create", Up: "CREATE", Down: "DROP"},
+		{Version: 2, Name: "seed", Up: "INSERT"},
+	}
+	if !reflect.DeepEqual(migrations, expected) {
+		t.Errorf("Load returned unexpected migrations: got %+v want %+v", migrations, expected)
+	}
+
+	// A down script without its up script is rejected
+	fsys["m/0003_orphan.down.sql"] = &fstest.MapFile{Data: []byte("DROP")}
+	if _, err := Load(fsys, "m"); err == nil {
+		t.Errorf("Load accepted a migration without an up script")
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	migrations, err := Embedded()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every embedded migration can be rolled back
+	for i, mig := range migrations {
+		if mig.Version != i+1 {
+			t.Errorf("Migration %s has version %d, want %d", mig.Name, mig.Version, i+1)
+		}
+		if mig.Down == "" {
+			t.Errorf("Migration %d_%s has no down script", mig.Version, mig.Name)
+		}
+	}
+}
+
+func TestUp(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Only the second migration is pending
+	expectApplied(mock, testMigrations[0])
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Item VALUES (1)")).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Item VALUES (2)")).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations (Version, Name, Checksum) VALUES (?, ?, ?)")).
+		WithArgs(2, "seed_item", testMigrations[1].Checksum()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := New(db, testMigrations).Up(0)
+	if err != nil || n != 1 {
+		t.Errorf("Up returned unexpected result: %v %v", n, err)
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUp_Failure(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	expectApplied(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE Item")).WillReturnError(errors.New("database error"))
+	mock.ExpectRollback()
+
+	n, err := New(db, testMigrations).Up(0)
+	if err == nil || n != 0 {
+		t.Errorf("Up returned unexpected result: %v %v", n, err)
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// The first migration is recorded without running its script
+	expectApplied(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations (Version, Name, Checksum) VALUES (?, ?, ?)")).
+		WithArgs(1, "create_item", testMigrations[0].Checksum()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := New(db, testMigrations).Baseline(1)
+	if err != nil || n != 1 {
+		t.Errorf("Baseline returned unexpected result: %v %v", n, err)
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDown(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Rolling back one step undoes the newest migration only
+	expectApplied(mock, testMigrations...)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM Item")).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM schema_migrations WHERE Version = ?")).
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := New(db, testMigrations).Down(1)
+	if err != nil || n != 1 {
+		t.Errorf("Down returned unexpected result: %v %v", n, err)
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStatus_ChecksumMismatch(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// The applied script differs from the one in the binary
+	edited := testMigrations[0]
+	edited.Up = "CREATE TABLE Item (ItemID bigint);"
+	expectApplied(mock, edited)
+
+	if _, err := New(db, testMigrations).Status(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Status returned wrong error: got %v want %v", err, ErrChecksumMismatch)
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	expectApplied(mock, testMigrations[0])
+
+	statuses, err := New(db, testMigrations).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || !statuses[0].Applied || statuses[0].AppliedAt == "" || statuses[1].Applied {
+		t.Errorf("Status returned unexpected statuses: %+v", statuses)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := "-- comment; not a statement\nINSERT INTO t VALUES ('a;b', 'it''s', \"c\\\";\");\n\nDELETE FROM t;  \n"
+
+	expected := []string{
+		"INSERT INTO t VALUES ('a;b', 'it''s', \"c\\\";\")",
+		"DELETE FROM t",
+	}
+	if stmts := splitStatements(script); !reflect.DeepEqual(stmts, expected) {
+		t.Errorf("splitStatements returned unexpected statements: got %q want %q", stmts, expected)
+	}
+}
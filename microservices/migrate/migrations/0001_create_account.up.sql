@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS `Account` (
+`AccID` int NOT NULL AUTO_INCREMENT,
+`Username` varchar (50) NOT NULL,
+`Password` varchar (255) NOT NULL,
+`AccType` varchar (10) NOT NULL,
+`AccStatus` varchar (30) NOT NULL,
+PRIMARY KEY (`AccID`)
+) ENGINE=InnoDB AUTO_INCREMENT=2002 DEFAULT CHARSET=utf8mb4;
+-- Databases created by the old record_db.sql have a varchar (50) Password,
+-- which cannot hold a bcrypt hash
+ALTER TABLE `Account` MODIFY `Password` varchar (255) NOT NULL;
@@ -0,0 +1 @@
+DELETE FROM `Account` WHERE `AccID` IN (1001, 2001, 2002, 2003, 2004, 2005);
@@ -0,0 +1,8 @@
+-- IGNORE keeps the rows a database created by the old record_db.sql already has
+INSERT IGNORE INTO `Account` (`AccID`, `Username`, `Password`, `AccType`, `AccStatus`)
+VALUES(1001, 'Shaniah', 'adminpwd1', 'Admin', 'Created'),
+(2001, 'ziyi', 'userpwd1', 'User', 'Created'),
+(2002, 'Luke', 'password','User', 'Created'),
+(2003, 'testdelete', 'deletetestpwd', 'User', 'Created'),
+(2004, 'testapprove', 'approvetestpwd', 'User', 'Pending'),
+(2005, 'testupdate', 'updatetestpwd', 'User', 'Created');
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS `RevokedToken` (
+`TokenID` varchar (64) NOT NULL,
+`ExpiresAt` datetime NOT NULL,
+PRIMARY KEY (`TokenID`)
+);
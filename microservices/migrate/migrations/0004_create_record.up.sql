@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS `Record` (
+`RecordID` int NOT NULL AUTO_INCREMENT,
+`Name` varchar (50) NOT NULL,
+`RoleOfContact` ENUM('Staff', 'Student'),
+`NoOfStudents` int NOT NULL,
+`AcadYr` varchar (10) NOT NULL,
+`CapstoneTitle` varchar (50) NOT NULL,
+`CompanyName` varchar (50) NOT NULL,
+`CompanyContact` varchar (50) NOT NULL,
+`ProjDesc` varchar (1000) NOT NULL,
+PRIMARY KEY (`RecordID`),
+FULLTEXT KEY `RecordSearch` (`CapstoneTitle`, `ProjDesc`, `CompanyName`, `CompanyContact`)
+)AUTO_INCREMENT=1;
+-- A Record table created by the old record_db.sql has no search index. MySQL
+-- has no ADD INDEX IF NOT EXISTS, so the ALTER is only run when it is missing
+SET @add_search = (SELECT IF(COUNT(*) = 0, 'ALTER TABLE `Record` ADD FULLTEXT KEY `RecordSearch` (`CapstoneTitle`, `ProjDesc`, `CompanyName`, `CompanyContact`)', 'DO 0')
+FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'Record' AND INDEX_NAME = 'RecordSearch');
+PREPARE add_search FROM @add_search;
+EXECUTE add_search;
+DEALLOCATE PREPARE add_search;
@@ -0,0 +1 @@
+DELETE FROM `Record` WHERE `RecordID` IN (1, 2, 3);
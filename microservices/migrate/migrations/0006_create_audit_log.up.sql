@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `AuditLog` (
+`AuditID` bigint NOT NULL AUTO_INCREMENT,
+`ActorID` int NULL,
+`Action` varchar (30) NOT NULL,
+`Entity` varchar (20) NOT NULL,
+`EntityID` int NOT NULL,
+`OldValue` json NULL,
+`NewValue` json NULL,
+`SourceIP` varchar (45) NOT NULL,
+`CreatedAt` datetime NOT NULL,
+PRIMARY KEY (`AuditID`),
+KEY `AuditActor` (`ActorID`, `CreatedAt`),
+KEY `AuditEntity` (`Entity`, `EntityID`, `CreatedAt`),
+KEY `AuditCreatedAt` (`CreatedAt`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
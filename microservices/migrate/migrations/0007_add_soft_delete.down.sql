@@ -0,0 +1,8 @@
+ALTER TABLE `Record`
+DROP KEY `RecordDeletedAt`,
+DROP `DeletedBy`,
+DROP `DeletedAt`;
+ALTER TABLE `Account`
+DROP KEY `AccountDeletedAt`,
+DROP `DeletedBy`,
+DROP `DeletedAt`;
@@ -0,0 +1,8 @@
+ALTER TABLE `Account`
+ADD `DeletedAt` datetime NULL,
+ADD `DeletedBy` int NULL,
+ADD KEY `AccountDeletedAt` (`DeletedAt`);
+ALTER TABLE `Record`
+ADD `DeletedAt` datetime NULL,
+ADD `DeletedBy` int NULL,
+ADD KEY `RecordDeletedAt` (`DeletedAt`);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS `RecordRevision` (
+`RecordID` int NOT NULL,
+`Revision` int NOT NULL,
+`Name` varchar (50) NOT NULL,
+`RoleOfContact` ENUM('Staff', 'Student'),
+`NoOfStudents` int NOT NULL,
+`AcadYr` varchar (10) NOT NULL,
+`CapstoneTitle` varchar (50) NOT NULL,
+`CompanyName` varchar (50) NOT NULL,
+`CompanyContact` varchar (50) NOT NULL,
+`ProjDesc` varchar (1000) NOT NULL,
+`CreatedBy` int NULL,
+`CreatedAt` datetime NOT NULL,
+PRIMARY KEY (`RecordID`, `Revision`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+INSERT INTO `RecordRevision` (`RecordID`, `Revision`, `Name`, `RoleOfContact`, `NoOfStudents`, `AcadYr`, `CapstoneTitle`, `CompanyName`, `CompanyContact`, `ProjDesc`, `CreatedBy`, `CreatedAt`)
+SELECT `RecordID`, 1, `Name`, `RoleOfContact`, `NoOfStudents`, `AcadYr`, `CapstoneTitle`, `CompanyName`, `CompanyContact`, `ProjDesc`, NULL, UTC_TIMESTAMP() FROM `Record`;
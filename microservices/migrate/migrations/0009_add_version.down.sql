@@ -0,0 +1,4 @@
+ALTER TABLE `Record`
+DROP `Version`;
+ALTER TABLE `Account`
+DROP `Version`;
@@ -0,0 +1,8 @@
+ALTER TABLE `Account`
+ADD `Version` int NOT NULL DEFAULT 1;
+ALTER TABLE `Record`
+ADD `Version` int NOT NULL DEFAULT 1;
+-- Revisions are numbered by version, so records continue from their latest one
+UPDATE `Record` r
+JOIN (SELECT `RecordID`, MAX(`Revision`) AS `Latest` FROM `RecordRevision` GROUP BY `RecordID`) v ON v.`RecordID` = r.`RecordID`
+SET r.`Version` = v.`Latest`;
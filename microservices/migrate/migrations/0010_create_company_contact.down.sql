@@ -0,0 +1,11 @@
+ALTER TABLE `RecordRevision`
+DROP `CompanyID`,
+DROP `ContactID`;
+ALTER TABLE `Record`
+DROP FOREIGN KEY `RecordCompany`,
+DROP FOREIGN KEY `RecordContact`;
+ALTER TABLE `Record`
+DROP `CompanyID`,
+DROP `ContactID`;
+DROP TABLE IF EXISTS `Contact`;
+DROP TABLE IF EXISTS `Company`;
@@ -0,0 +1,51 @@
+CREATE TABLE IF NOT EXISTS `Company` (
+`CompanyID` int NOT NULL AUTO_INCREMENT,
+`Name` varchar (50) NOT NULL,
+`NameKey` varchar (50) NOT NULL,
+`Version` int NOT NULL DEFAULT 1,
+PRIMARY KEY (`CompanyID`),
+UNIQUE KEY `CompanyNameKey` (`NameKey`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE IF NOT EXISTS `Contact` (
+`ContactID` int NOT NULL AUTO_INCREMENT,
+`CompanyID` int NOT NULL,
+`Name` varchar (50) NOT NULL,
+`NameKey` varchar (50) NOT NULL,
+`Version` int NOT NULL DEFAULT 1,
+PRIMARY KEY (`ContactID`),
+UNIQUE KEY `ContactNameKey` (`CompanyID`, `NameKey`),
+CONSTRAINT `ContactCompany` FOREIGN KEY (`CompanyID`) REFERENCES `Company` (`CompanyID`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+ALTER TABLE `Record`
+ADD `CompanyID` int NULL,
+ADD `ContactID` int NULL;
+-- Names that differ only in case and spaces, such as "CompanyA", "Company A"
+-- and "companya", are one company; the records keep their names as copies
+-- for searching, which now follow the company
+INSERT INTO `Company` (`Name`, `NameKey`)
+SELECT MIN(TRIM(`CompanyName`)), LOWER(REPLACE(`CompanyName`, ' ', '')) FROM `Record` GROUP BY LOWER(REPLACE(`CompanyName`, ' ', ''));
+UPDATE `Record` r
+JOIN `Company` c ON c.`NameKey` = LOWER(REPLACE(r.`CompanyName`, ' ', ''))
+SET r.`CompanyID` = c.`CompanyID`, r.`CompanyName` = c.`Name`;
+INSERT INTO `Contact` (`CompanyID`, `Name`, `NameKey`)
+SELECT `CompanyID`, MIN(TRIM(`CompanyContact`)), LOWER(REPLACE(`CompanyContact`, ' ', '')) FROM `Record` GROUP BY `CompanyID`, LOWER(REPLACE(`CompanyContact`, ' ', ''));
+UPDATE `Record` r
+JOIN `Contact` ct ON ct.`CompanyID` = r.`CompanyID` AND ct.`NameKey` = LOWER(REPLACE(r.`CompanyContact`, ' ', ''))
+SET r.`ContactID` = ct.`ContactID`, r.`CompanyContact` = ct.`Name`;
+ALTER TABLE `Record`
+MODIFY `CompanyID` int NOT NULL,
+MODIFY `ContactID` int NOT NULL,
+ADD CONSTRAINT `RecordCompany` FOREIGN KEY (`CompanyID`) REFERENCES `Company` (`CompanyID`),
+ADD CONSTRAINT `RecordContact` FOREIGN KEY (`ContactID`) REFERENCES `Contact` (`ContactID`);
+-- Revisions keep the IDs so a rollback follows renames. Earlier revisions get
+-- those of the company and contact their names match, if any; the others are
+-- found by name again when rolled back to
+ALTER TABLE `RecordRevision`
+ADD `CompanyID` int NULL,
+ADD `ContactID` int NULL;
+UPDATE `RecordRevision` rv
+JOIN `Company` c ON c.`NameKey` = LOWER(REPLACE(rv.`CompanyName`, ' ', ''))
+SET rv.`CompanyID` = c.`CompanyID`;
+UPDATE `RecordRevision` rv
+JOIN `Contact` ct ON ct.`CompanyID` = rv.`CompanyID` AND ct.`NameKey` = LOWER(REPLACE(rv.`CompanyContact`, ' ', ''))
+SET rv.`ContactID` = ct.`ContactID`;
@@ -0,0 +1,2 @@
+ALTER TABLE `Account`
+DROP `MustChangePassword`;
@@ -0,0 +1,4 @@
+-- Accounts provisioned with a generated password must choose their own at
+-- the first login
+ALTER TABLE `Account`
+ADD `MustChangePassword` tinyint (1) NOT NULL DEFAULT 0;
@@ -0,0 +1,150 @@
+// Package patch applies JSON merge patches (RFC 7386) to API resources, so
+// clients can change some fields of a record or account without resending
+// the others.
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/validate" //change here
+)
+
+// ErrNotObject is returned for a patch that is valid JSON but not an object.
+// RFC 7386 would replace the whole resource with it, which no API allows.
+var ErrNotObject = errors.New("merge patch must be a JSON object")
+
+// Merge applies patch to target as RFC 7386 describes: each member of an
+// object patch replaces the target's member of the same name, null removes
+// it, and objects are merged recursively. Any other patch replaces target.
+func Merge(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+
+	merged := make(map[string]interface{}, len(t))
+	for name, value := range t {
+		merged[name] = value
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(merged, name)
+		} else {
+			merged[name] = Merge(merged[name], value)
+		}
+	}
+	return merged
+}
+
+// Apply merges the patch in doc into v, a pointer to a struct, and returns
+// the JSON names of the fields it sets, in field order. A field set to null
+// is cleared to its zero value. Names listed in fixed, and names v does not
+// have, are rejected with validate.Errors; other errors mean doc is not a
+// usable patch.
+func Apply(v interface{}, doc []byte, fixed ...string) ([]string, error) {
+	var p interface{}
+	if err := json.Unmarshal(doc, &p); err != nil {
+		return nil, err
+	}
+	members, ok := p.(map[string]interface{})
+	if !ok {
+		return nil, ErrNotObject
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	var errs validate.Errors
+	var names []string
+	for _, name := range jsonNames(rv.Type()) {
+		if _, ok := members[name]; ok {
+			names = append(names, name)
+		}
+	}
+	for _, name := range sortedKeys(members) {
+		if !contains(names, name) {
+			errs = append(errs, validate.FieldError{Field: name, Message: "is not a known field"})
+		} else if contains(fixed, name) {
+			errs = append(errs, validate.FieldError{Field: name, Message: "cannot be changed"})
+		}
+	}
+	if errs != nil {
+		return nil, errs
+	}
+
+	// Round-trip v through its JSON form so the patch sees the same names
+	// and values a client does
+	current, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var target interface{}
+	if err := json.Unmarshal(current, &target); err != nil {
+		return nil, err
+	}
+	merged, err := json.Marshal(Merge(target, members))
+	if err != nil {
+		return nil, err
+	}
+
+	patched := reflect.New(rv.Type())
+	if err := json.Unmarshal(merged, patched.Interface()); err != nil {
+		return nil, err
+	}
+	rv.Set(patched.Elem())
+	return names, nil
+}
+
+// Changed returns the names of the fields that differ between two values of
+// the same struct type, in field order. The stores name columns after the
+// fields, so these are the columns an UPDATE has to set.
+func Changed(before, after interface{}) []string {
+	a, b := reflect.ValueOf(before), reflect.ValueOf(after)
+	var names []string
+	for i := 0; i < a.NumField(); i++ {
+		if a.Field(i).Interface() != b.Field(i).Interface() {
+			names = append(names, a.Type().Field(i).Name)
+		}
+	}
+	return names
+}
+
+// jsonNames returns the JSON name of every field of a struct type
+func jsonNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+// patch_test.go
+package patch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/validate" //change here
+)
+
+type item struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	Note  string `json:"note,omitempty"`
+}
+
+// The examples from Appendix A of RFC 7386
+func TestMerge(t *testing.T) {
+	tests := []struct{ target, patch, expected string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		var docs [3]interface{}
+		for i, doc := range []string{tt.target, tt.patch, tt.expected} {
+			if err := json.Unmarshal([]byte(doc), &docs[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := Merge(docs[0], docs[1]); !reflect.DeepEqual(got, docs[2]) {
+			t.Errorf("Merge(%s, %s) = %v, want %s", tt.target, tt.patch, got, tt.expected)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	it := item{ID: 1, Name: "Ann", Count: 3, Note: "old"}
+
+	names, err := Apply(&it, []byte(`{"count": 5, "note": null}`), "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"count", "note"}) {
+		t.Errorf("Apply returned unexpected names: %v", names)
+	}
+	if expected := (item{ID: 1, Name: "Ann", Count: 5}); it != expected {
+		t.Errorf("Apply produced %+v, want %+v", it, expected)
+	}
+}
+
+func TestApply_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		errs validate.Errors
+	}{
+		{"syntax", `{"name": `, nil},
+		{"not an object", `["name"]`, nil},
+		{"wrong type", `{"count": "many"}`, nil},
+		{"fixed and unknown fields", `{"id": 2, "colour": "red", "name": "Bo"}`, validate.Errors{
+			{Field: "colour", Message: "is not a known field"},
+			{Field: "id", Message: "cannot be changed"},
+		}},
+	}
+
+	for _, tt := range tests {
+		it := item{ID: 1, Name: "Ann"}
+		_, err := Apply(&it, []byte(tt.doc), "id")
+		if err == nil {
+			t.Errorf("%s: Apply accepted %s", tt.name, tt.doc)
+			continue
+		}
+		if errs, ok := err.(validate.Errors); ok != (tt.errs != nil) || !reflect.DeepEqual(errs, tt.errs) {
+			t.Errorf("%s: Apply returned unexpected error: %v", tt.name, err)
+		}
+		if it != (item{ID: 1, Name: "Ann"}) {
+			t.Errorf("%s: Apply changed the value on error: %+v", tt.name, it)
+		}
+	}
+}
+
+func TestChanged(t *testing.T) {
+	before := item{ID: 1, Name: "Ann", Count: 3}
+	after := item{ID: 1, Name: "Bo", Count: 3, Note: "new"}
+
+	if got := Changed(before, after); !reflect.DeepEqual(got, []string{"Name", "Note"}) {
+		t.Errorf("Changed returned %v", got)
+	}
+	if got := Changed(before, before); got != nil {
+		t.Errorf("Changed returned %v for equal values", got)
+	}
+}
@@ -0,0 +1,473 @@
+package query
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Spec describes a table that can be listed with pagination, sorting and
+// filtering. Only the whitelisted fields may appear in a request.
+type Spec struct {
+	Table   string
+	Columns []string
+	// Where is a fixed condition every query includes, such as excluding
+	// soft-deleted rows
+	Where string
+	// Key is a unique column used as the final sort key so pages never overlap
+	Key string
+	// Sortable and Filterable map request field names to columns
+	Sortable   map[string]string
+	Filterable map[string]string
+	// Ranges map request field names to a bound on a column, such as
+	// "from" to CreatedAt >= value
+	Ranges map[string]Range
+	// DefaultSort applies when the request has no sort parameter
+	DefaultSort []SortField
+
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// SortField is one column of the ORDER BY clause
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// Filter is a condition on a column; an empty Op means an exact match
+type Filter struct {
+	Column string
+	Op     string
+	Value  string
+}
+
+// Range is a filter comparing a column with the request value. Op is one of
+// >, >=, < or <=.
+type Range struct {
+	Column string
+	Op     string
+}
+
+// Params are the parsed list options of a request
+type Params struct {
+	Limit   int
+	Sort    []SortField
+	Filters []Filter
+	After   []interface{} // sort key values of the last row of the previous page
+}
+
+// Page is the response envelope for list endpoints
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	Total      int    `json:"total"`
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Parse reads limit, cursor, sort and filter parameters from a query string
+func (s Spec) Parse(values url.Values) (Params, error) {
+	p := Params{Limit: s.DefaultLimit}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return Params{}, errors.New("limit must be a positive integer")
+		}
+		p.Limit = limit
+	}
+	if s.MaxLimit > 0 && p.Limit > s.MaxLimit {
+		p.Limit = s.MaxLimit
+	}
+
+	if v := values.Get("sort"); v != "" {
+		for _, field := range strings.Split(v, ",") {
+			field = strings.TrimSpace(field)
+			desc := strings.HasPrefix(field, "-")
+			column, ok := s.Sortable[strings.TrimPrefix(field, "-")]
+			if !ok {
+				return Params{}, fmt.Errorf("cannot sort by %q", field)
+			}
+			p.Sort = append(p.Sort, SortField{Column: column, Desc: desc})
+		}
+	} else {
+		p.Sort = append(p.Sort, s.DefaultSort...)
+	}
+	if !hasColumn(p.Sort, s.Key) {
+		p.Sort = append(p.Sort, SortField{Column: s.Key})
+	}
+
+	// Filters are applied in a fixed order so the generated SQL is stable
+	for _, name := range sortedKeys(s.Filterable) {
+		if v, ok := values[name]; ok && len(v) > 0 {
+			p.Filters = append(p.Filters, Filter{Column: s.Filterable[name], Value: v[0]})
+		}
+	}
+	for _, name := range sortedKeys(s.Ranges) {
+		if v, ok := values[name]; ok && len(v) > 0 {
+			p.Filters = append(p.Filters, Filter{Column: s.Ranges[name].Column, Op: s.Ranges[name].Op, Value: v[0]})
+		}
+	}
+
+	if v := values.Get("cursor"); v != "" {
+		after, err := decodeCursor(v, p.Sort)
+		if err != nil {
+			return Params{}, err
+		}
+		p.After = after
+	}
+
+	return p, nil
+}
+
+// Select builds the page query. One extra row is fetched so callers can tell
+// whether another page follows.
+func (s Spec) Select(p Params) (string, []interface{}) {
+	var sb strings.Builder
+	sb.WriteString("SELECT " + strings.Join(s.Columns, ", ") + " FROM " + s.Table)
+
+	where, args := s.where(p, true)
+	sb.WriteString(where)
+	sb.WriteString(orderBy(p.Sort))
+
+	sb.WriteString(" LIMIT ?")
+	args = append(args, p.Limit+1)
+
+	return sb.String(), args
+}
+
+// SelectAll builds a query for every row matching the filters, in sort
+// order. The limit and cursor of p are ignored.
+func (s Spec) SelectAll(p Params) (string, []interface{}) {
+	where, args := s.where(p, false)
+	return "SELECT " + strings.Join(s.Columns, ", ") + " FROM " + s.Table + where + orderBy(p.Sort), args
+}
+
+func orderBy(sort []SortField) string {
+	order := make([]string, len(sort))
+	for i, f := range sort {
+		dir := "ASC"
+		if f.Desc {
+			dir = "DESC"
+		}
+		order[i] = f.Column + " " + dir
+	}
+	return " ORDER BY " + strings.Join(order, ", ")
+}
+
+// Count builds the query for the total number of rows matching the filters
+func (s Spec) Count(p Params) (string, []interface{}) {
+	where, args := s.where(p, false)
+	return "SELECT COUNT(*) FROM " + s.Table + where, args
+}
+
+func (s Spec) where(p Params, withCursor bool) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	if s.Where != "" {
+		conds = append(conds, s.Where)
+	}
+	for _, f := range p.Filters {
+		conds = append(conds, f.Column+" "+f.op()+" ?")
+		args = append(args, f.Value)
+	}
+
+	// Keyset condition: (a > x) OR (a = x AND b < y) OR ... following each
+	// column's sort direction
+	if withCursor && len(p.After) == len(p.Sort) {
+		var alts []string
+		for i, f := range p.Sort {
+			var parts []string
+			for j := 0; j < i; j++ {
+				parts = append(parts, p.Sort[j].Column+" = ?")
+				args = append(args, p.After[j])
+			}
+			op := " > ?"
+			if f.Desc {
+				op = " < ?"
+			}
+			parts = append(parts, f.Column+op)
+			args = append(args, p.After[i])
+			alts = append(alts, "("+strings.Join(parts, " AND ")+")")
+		}
+		conds = append(conds, "("+strings.Join(alts, " OR ")+")")
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// List runs the page and count queries for p. scan reads one row and value
+// returns a column of a scanned item for building the next cursor.
+func List[T any](db *sql.DB, s Spec, p Params, scan func(*sql.Rows) (T, error), value func(T, string) interface{}) (Page[T], error) {
+	page := Page[T]{Items: []T{}}
+
+	q, args := s.Select(p)
+	rows, err := db.Query(q, args...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return page, err
+		}
+		page.Items = append(page.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return page, err
+	}
+
+	// The extra row only signals that another page exists
+	if len(page.Items) > p.Limit {
+		page.Items = page.Items[:p.Limit]
+		last := page.Items[p.Limit-1]
+		page.NextCursor = NextCursor(p, func(column string) interface{} {
+			return value(last, column)
+		})
+	}
+
+	q, args = s.Count(p)
+	if err := db.QueryRow(q, args...).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	return page, nil
+}
+
+// NextCursor encodes the sort key of the last returned row. value returns the
+// value of a column for that row.
+func NextCursor(p Params, value func(column string) interface{}) string {
+	c := cursor{Sort: sortSignature(p.Sort)}
+	for _, f := range p.Sort {
+		c.Values = append(c.Values, value(f.Column))
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+type cursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+func decodeCursor(v string, order []SortField) ([]interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	// A cursor is only valid for the ordering it was created with
+	if c.Sort != sortSignature(order) || len(c.Values) != len(order) {
+		return nil, ErrInvalidCursor
+	}
+
+	for i, val := range c.Values {
+		if n, ok := val.(json.Number); ok {
+			if iv, err := n.Int64(); err == nil {
+				c.Values[i] = iv
+			} else {
+				c.Values[i] = n.String()
+			}
+		}
+	}
+	return c.Values, nil
+}
+
+func sortSignature(order []SortField) string {
+	parts := make([]string, len(order))
+	for i, f := range order {
+		parts[i] = f.Column
+		if f.Desc {
+			parts[i] = "-" + f.Column
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func hasColumn(sort []SortField, column string) bool {
+	for _, f := range sort {
+		if f.Column == column {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Each runs a query such as one built by SelectAll and calls fn for every
+// row as it is read, so a large result is never held in memory. It stops at
+// the first error from scan or fn.
+func Each[T any](db *sql.DB, q string, args []interface{}, scan func(*sql.Rows) (T, error), fn func(T) error) error {
+	rows, err := db.Query(q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// FilterSlice returns the items matching the filters of p in sort order, as
+// SelectAll does in SQL
+func FilterSlice[T any](items []T, p Params, value func(T, string) interface{}) []T {
+	var matched []T
+	for _, item := range items {
+		ok := true
+		for _, f := range p.Filters {
+			if !f.matches(value(item, f.Column)) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matched = append(matched, item)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return compareSort(p.Sort, func(column string) interface{} { return value(matched[i], column) },
+			func(column string) interface{} { return value(matched[j], column) }) < 0
+	})
+	return matched
+}
+
+// ListSlice applies p to items held in memory the same way List does in SQL.
+// value returns a column of an item for filtering, sorting and cursors.
+func ListSlice[T any](items []T, p Params, value func(T, string) interface{}) Page[T] {
+	page := Page[T]{Items: []T{}}
+
+	matched := FilterSlice(items, p, value)
+	page.Total = len(matched)
+
+	for _, item := range matched {
+		if len(p.After) == len(p.Sort) {
+			after := func(column string) interface{} {
+				for i, f := range p.Sort {
+					if f.Column == column {
+						return p.After[i]
+					}
+				}
+				return nil
+			}
+			if compareSort(p.Sort, func(column string) interface{} { return value(item, column) }, after) <= 0 {
+				continue
+			}
+		}
+		page.Items = append(page.Items, item)
+	}
+
+	if len(page.Items) > p.Limit {
+		page.Items = page.Items[:p.Limit]
+		last := page.Items[p.Limit-1]
+		page.NextCursor = NextCursor(p, func(column string) interface{} {
+			return value(last, column)
+		})
+	}
+
+	return page
+}
+
+func (f Filter) op() string {
+	if f.Op == "" {
+		return "="
+	}
+	return f.Op
+}
+
+// matches applies the filter to a value held in memory
+func (f Filter) matches(v interface{}) bool {
+	c := compare(v, f.Value)
+	switch f.op() {
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return c == 0
+}
+
+// compareSort orders two rows by the sort fields, given accessors for their columns
+func compareSort(order []SortField, a, b func(column string) interface{}) int {
+	for _, f := range order {
+		c := compare(a(f.Column), b(f.Column))
+		if f.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compare orders numbers numerically and everything else as case-insensitive
+// text, matching the default MySQL collation
+func compare(a, b interface{}) int {
+	an, aok := toInt64(a)
+	bn, bok := toInt64(b)
+	if aok && bok {
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(strings.ToLower(fmt.Sprint(a)), strings.ToLower(fmt.Sprint(b)))
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case string:
+		i, err := strconv.ParseInt(n, 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
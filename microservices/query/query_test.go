@@ -0,0 +1,324 @@
+// query_test.go
+package query
+
+import (
+	"database/sql"
+	"net/url"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var testSpec = Spec{
+	Table:   "Item",
+	Columns: []string{"ItemID", "Name", "Year"},
+	Key:     "ItemID",
+	Sortable: map[string]string{
+		"name": "Name",
+		"year": "Year",
+	},
+	Filterable: map[string]string{
+		"name": "Name",
+		"year": "Year",
+	},
+	DefaultLimit: 10,
+	MaxLimit:     20,
+}
+
+type item struct {
+	ItemID int
+	Name   string
+	Year   string
+}
+
+func scanItem(rows *sql.Rows) (item, error) {
+	var it item
+	err := rows.Scan(&it.ItemID, &it.Name, &it.Year)
+	return it, err
+}
+
+func itemValue(it item, column string) interface{} {
+	switch column {
+	case "ItemID":
+		return it.ItemID
+	case "Name":
+		return it.Name
+	case "Year":
+		return it.Year
+	}
+	return nil
+}
+
+func TestParse(t *testing.T) {
+	p, err := testSpec.Parse(url.Values{
+		"limit": {"50"},
+		"sort":  {"-year,name"},
+		"year":  {"2023/2024"},
+		"other": {"ignored"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The limit is clamped and the key is always the last sort column
+	if p.Limit != 20 {
+		t.Errorf("Parse returned wrong limit: got %v want %v", p.Limit, 20)
+	}
+	expectedSort := []SortField{{Column: "Year", Desc: true}, {Column: "Name"}, {Column: "ItemID"}}
+	if !reflect.DeepEqual(p.Sort, expectedSort) {
+		t.Errorf("Parse returned wrong sort: got %v want %v", p.Sort, expectedSort)
+	}
+	expectedFilters := []Filter{{Column: "Year", Value: "2023/2024"}}
+	if !reflect.DeepEqual(p.Filters, expectedFilters) {
+		t.Errorf("Parse returned wrong filters: got %v want %v", p.Filters, expectedFilters)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []string{"limit=0", "limit=-1", "limit=ten", "sort=ItemID", "sort=name,", "cursor=!!!", "cursor=e30"}
+
+	for _, rawQuery := range tests {
+		values, _ := url.ParseQuery(rawQuery)
+		if _, err := testSpec.Parse(values); err == nil {
+			t.Errorf("Parse accepted invalid query %q", rawQuery)
+		}
+	}
+}
+
+func TestSelectAndCount(t *testing.T) {
+	p := Params{
+		Limit:   5,
+		Sort:    []SortField{{Column: "Year", Desc: true}, {Column: "ItemID"}},
+		Filters: []Filter{{Column: "Name", Value: "a"}},
+		After:   []interface{}{"2023/2024", int64(7)},
+	}
+
+	q, args := testSpec.Select(p)
+	expected := "SELECT ItemID, Name, Year FROM Item WHERE Name = ? AND ((Year < ?) OR (Year = ? AND ItemID > ?)) ORDER BY Year DESC, ItemID ASC LIMIT ?"
+	if q != expected {
+		t.Errorf("Select returned wrong query:\n got %v\nwant %v", q, expected)
+	}
+	expectedArgs := []interface{}{"a", "2023/2024", "2023/2024", int64(7), 6}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Select returned wrong args: got %v want %v", args, expectedArgs)
+	}
+
+	// The cursor does not affect the total
+	q, args = testSpec.Count(p)
+	if q != "SELECT COUNT(*) FROM Item WHERE Name = ?" || !reflect.DeepEqual(args, []interface{}{"a"}) {
+		t.Errorf("Count returned wrong query: %v %v", q, args)
+	}
+
+	// A fixed condition comes before the filters
+	spec := testSpec
+	spec.Where = "DeletedAt IS NULL"
+	q, _ = spec.Count(p)
+	if q != "SELECT COUNT(*) FROM Item WHERE DeletedAt IS NULL AND Name = ?" {
+		t.Errorf("Count returned wrong query: %v", q)
+	}
+	q, _ = spec.Count(Params{})
+	if q != "SELECT COUNT(*) FROM Item WHERE DeletedAt IS NULL" {
+		t.Errorf("Count returned wrong query: %v", q)
+	}
+}
+
+func TestCursor_RoundTrip(t *testing.T) {
+	p, err := testSpec.Parse(url.Values{"sort": {"-name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cursor := NextCursor(p, func(column string) interface{} {
+		return itemValue(item{ItemID: 42, Name: "widget"}, column)
+	})
+
+	next, err := testSpec.Parse(url.Values{"sort": {"-name"}, "cursor": {cursor}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(next.After, []interface{}{"widget", int64(42)}) {
+		t.Errorf("cursor decoded to wrong values: %v", next.After)
+	}
+
+	// A cursor cannot be reused with a different ordering
+	if _, err := testSpec.Parse(url.Values{"sort": {"name"}, "cursor": {cursor}}); err != ErrInvalidCursor {
+		t.Errorf("Parse returned wrong error for a mismatched cursor: got %v want %v", err, ErrInvalidCursor)
+	}
+}
+
+func TestList(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT ItemID, Name, Year FROM Item ORDER BY ItemID ASC LIMIT ?")).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"ItemID", "Name", "Year"}).
+			AddRow(1, "a", "2022/2023").
+			AddRow(2, "b", "2022/2023").
+			AddRow(3, "c", "2023/2024"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM Item")).
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(3))
+
+	p, err := testSpec.Parse(url.Values{"limit": {"2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := List(db, testSpec, p, scanItem, itemValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Items) != 2 || page.Total != 3 || page.NextCursor == "" {
+		t.Errorf("List returned unexpected page: %+v", page)
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestListSlice(t *testing.T) {
+	items := []item{
+		{1, "b", "2022/2023"},
+		{2, "A", "2022/2023"},
+		{3, "c", "2023/2024"},
+		{4, "d", "2022/2023"},
+	}
+
+	p, err := testSpec.Parse(url.Values{"sort": {"name"}, "year": {"2022/2023"}, "limit": {"2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := ListSlice(items, p, itemValue)
+	if page.Total != 3 || len(page.Items) != 2 || page.Items[0].ItemID != 2 || page.Items[1].ItemID != 1 || page.NextCursor == "" {
+		t.Fatalf("ListSlice returned unexpected page: %+v", page)
+	}
+
+	// The cursor continues after the last item of the first page
+	next, err := testSpec.Parse(url.Values{"sort": {"name"}, "year": {"2022/2023"}, "limit": {"2"}, "cursor": {page.NextCursor}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page = ListSlice(items, next, itemValue)
+	if len(page.Items) != 1 || page.Items[0].ItemID != 4 || page.NextCursor != "" {
+		t.Errorf("ListSlice returned unexpected second page: %+v", page)
+	}
+}
+
+func TestEach(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	p := Params{
+		Limit:   1,
+		Sort:    []SortField{{Column: "Name"}, {Column: "ItemID"}},
+		Filters: []Filter{{Column: "Year", Value: "2022/2023"}},
+		After:   []interface{}{"a", int64(1)},
+	}
+
+	// The limit and cursor do not apply to SelectAll
+	q, args := testSpec.SelectAll(p)
+	expected := "SELECT ItemID, Name, Year FROM Item WHERE Year = ? ORDER BY Name ASC, ItemID ASC"
+	if q != expected || !reflect.DeepEqual(args, []interface{}{"2022/2023"}) {
+		t.Errorf("SelectAll returned wrong query:\n got %v %v\nwant %v", q, args, expected)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(expected)).
+		WithArgs("2022/2023").
+		WillReturnRows(sqlmock.NewRows([]string{"ItemID", "Name", "Year"}).
+			AddRow(2, "a", "2022/2023").
+			AddRow(1, "b", "2022/2023"))
+
+	var ids []int
+	err = Each(db, q, args, scanItem, func(it item) error {
+		ids = append(ids, it.ItemID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []int{2, 1}) {
+		t.Errorf("Each visited unexpected items: %v", ids)
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestFilterSlice(t *testing.T) {
+	items := []item{
+		{1, "b", "2022/2023"},
+		{2, "A", "2022/2023"},
+		{3, "c", "2023/2024"},
+	}
+
+	p, err := testSpec.Parse(url.Values{"sort": {"name"}, "year": {"2022/2023"}, "limit": {"1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := FilterSlice(items, p, itemValue); !reflect.DeepEqual(got, []item{items[1], items[0]}) {
+		t.Errorf("FilterSlice returned unexpected items: %+v", got)
+	}
+}
+
+func TestRangesAndDefaultSort(t *testing.T) {
+	spec := testSpec
+	spec.Ranges = map[string]Range{
+		"since": {Column: "Year", Op: ">="},
+		"until": {Column: "Year", Op: "<"},
+	}
+	spec.DefaultSort = []SortField{{Column: "Year", Desc: true}}
+
+	p, err := spec.Parse(url.Values{"since": {"2022/2023"}, "until": {"2024/2025"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedSort := []SortField{{Column: "Year", Desc: true}, {Column: "ItemID"}}
+	if !reflect.DeepEqual(p.Sort, expectedSort) {
+		t.Errorf("Parse returned wrong sort: got %v want %v", p.Sort, expectedSort)
+	}
+
+	q, args := spec.Count(p)
+	if q != "SELECT COUNT(*) FROM Item WHERE Year >= ? AND Year < ?" || !reflect.DeepEqual(args, []interface{}{"2022/2023", "2024/2025"}) {
+		t.Errorf("Count returned wrong query: %v %v", q, args)
+	}
+
+	items := []item{
+		{1, "a", "2021/2022"},
+		{2, "b", "2022/2023"},
+		{3, "c", "2023/2024"},
+		{4, "d", "2024/2025"},
+	}
+	page := ListSlice(items, p, itemValue)
+	if len(page.Items) != 2 || page.Items[0].ItemID != 3 || page.Items[1].ItemID != 2 {
+		t.Errorf("ListSlice returned unexpected page: %+v", page)
+	}
+
+	// The key is not repeated when the sort already ends with it
+	spec.Sortable = map[string]string{"id": "ItemID"}
+	p, err = spec.Parse(url.Values{"sort": {"-id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expectedSort := []SortField{{Column: "ItemID", Desc: true}}; !reflect.DeepEqual(p.Sort, expectedSort) {
+		t.Errorf("Parse returned wrong sort: got %v want %v", p.Sort, expectedSort)
+	}
+}
@@ -0,0 +1,532 @@
+package record
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"      //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit"    //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/query"    //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/validate" //change here
+
+	"github.com/gorilla/mux"
+)
+
+// Company is an organisation that proposes capstones. Names are unique when
+// compared by nameKey, so "Company A" and "companya" are the same company.
+type Company struct {
+	CompanyID int    `json:"companyId"`
+	Name      string `json:"name" validate:"required,max=50"`
+	Version   int    `json:"version,omitempty"`
+}
+
+// Contact is a person at a company. Names are unique within the company,
+// compared like company names.
+type Contact struct {
+	ContactID int    `json:"contactId"`
+	CompanyID int    `json:"companyId"`
+	Name      string `json:"name" validate:"required,max=50"`
+	Version   int    `json:"version,omitempty"`
+}
+
+// CompanyStore persists the companies and contacts records refer to. Renaming
+// one renames it on every record that refers to it, as a new version and
+// revision of each record. Changes are written to the audit log like record
+// changes.
+type CompanyStore interface {
+	CreateCompany(c Company, by audit.Actor) (int, error)
+	// GetCompany returns a company, or ErrCompanyNotFound
+	GetCompany(companyID int) (Company, error)
+	ListCompanies(p query.Params) (query.Page[Company], error)
+	// UpdateCompany renames the company at c.Version, or at any version when
+	// it is 0
+	UpdateCompany(c Company, by audit.Actor) error
+	// DeleteCompany removes a company no record refers to, with its contacts
+	DeleteCompany(companyID int, by audit.Actor) error
+	// CreateContact adds a contact to c.CompanyID
+	CreateContact(c Contact, by audit.Actor) (int, error)
+	// GetContact returns a contact, or ErrContactNotFound
+	GetContact(contactID int) (Contact, error)
+	// ListContacts lists the contacts of a company
+	ListContacts(companyID int, p query.Params) (query.Page[Contact], error)
+	UpdateContact(c Contact, by audit.Actor) error
+	DeleteContact(contactID int, by audit.Actor) error
+}
+
+var (
+	// ErrCompanyNotFound is returned when a company does not exist
+	ErrCompanyNotFound = errors.New("company not found")
+	// ErrContactNotFound is returned when a contact does not exist
+	ErrContactNotFound = errors.New("contact not found")
+	// ErrNameInUse is returned when another company, or another contact of
+	// the same company, already has the name
+	ErrNameInUse = errors.New("name in use")
+	// ErrInUse is returned when deleting a company or contact records refer to
+	ErrInUse = errors.New("referred to by records")
+)
+
+// nameKey is the form company and contact names are compared in: case and
+// spaces are ignored, as when the migration merged the free-text names
+func nameKey(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, " ", ""))
+}
+
+// unknownCompany and unknownContact report a reference to an ID that does not
+// exist, or a contact of another company
+var (
+	unknownCompany = validate.Errors{{Field: "companyId", Message: "does not exist"}}
+	unknownContact = validate.Errors{{Field: "contactId", Message: "does not exist at the company"}}
+)
+
+// referenceColumns are the record columns that tie it to its company and contact
+var referenceColumns = []string{"CompanyName", "CompanyContact", "CompanyID", "ContactID"}
+
+// changesReferences reports whether a patch to columns changes the company or
+// contact of a record
+func changesReferences(columns []string) bool {
+	for _, column := range referenceColumns {
+		if contains(columns, column) {
+			return true
+		}
+	}
+	return false
+}
+
+// patchReferences prepares a patched record to have its references resolved.
+// An ID the patch left alone no longer applies when the name beside it, or
+// the company of the contact, changed, so it is cleared to be found by name
+// instead. It returns the columns to write, which include every reference
+// column.
+func patchReferences(rec *Record, columns []string) []string {
+	if contains(columns, "CompanyName") && !contains(columns, "CompanyID") {
+		rec.CompanyID = 0
+	}
+	company := contains(columns, "CompanyID") || contains(columns, "CompanyName")
+	if (company || contains(columns, "CompanyContact")) && !contains(columns, "ContactID") {
+		rec.ContactID = 0
+	}
+
+	all := append([]string{}, columns...)
+	for _, column := range referenceColumns {
+		if !contains(all, column) {
+			all = append(all, column)
+		}
+	}
+	return all
+}
+
+// list options accepted by ListCompaniesHandler
+var companyListSpec = query.Spec{
+	Table:   "Company",
+	Columns: []string{"CompanyID", "Name", "Version"},
+	Key:     "CompanyID",
+	Sortable: map[string]string{
+		"companyId": "CompanyID",
+		"name":      "Name",
+	},
+	Filterable: map[string]string{
+		"name": "Name",
+	},
+	DefaultSort:  []query.SortField{{Column: "Name"}},
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// list options accepted by ListContactsHandler
+var contactListSpec = query.Spec{
+	Table:   "Contact",
+	Columns: []string{"ContactID", "CompanyID", "Name", "Version"},
+	Key:     "ContactID",
+	Sortable: map[string]string{
+		"contactId": "ContactID",
+		"name":      "Name",
+	},
+	Filterable: map[string]string{
+		"name": "Name",
+	},
+	DefaultSort:  []query.SortField{{Column: "Name"}},
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// forCompany limits list options to the contacts of one company
+func forCompany(p query.Params, companyID int) query.Params {
+	p.Filters = append([]query.Filter{{Column: "CompanyID", Value: strconv.Itoa(companyID)}}, p.Filters...)
+	return p
+}
+
+func scanCompany(rows *sql.Rows) (Company, error) {
+	var c Company
+	err := rows.Scan(&c.CompanyID, &c.Name, &c.Version)
+	return c, err
+}
+
+func scanContact(rows *sql.Rows) (Contact, error) {
+	var c Contact
+	err := rows.Scan(&c.ContactID, &c.CompanyID, &c.Name, &c.Version)
+	return c, err
+}
+
+// companyValue returns the value of a column for filtering, sorting and cursors
+func companyValue(c Company, column string) interface{} {
+	switch column {
+	case "CompanyID":
+		return c.CompanyID
+	case "Name":
+		return c.Name
+	case "Version":
+		return c.Version
+	}
+	return nil
+}
+
+// contactValue returns the value of a column for filtering, sorting and cursors
+func contactValue(c Contact, column string) interface{} {
+	switch column {
+	case "ContactID":
+		return c.ContactID
+	case "CompanyID":
+		return c.CompanyID
+	case "Name":
+		return c.Name
+	case "Version":
+		return c.Version
+	}
+	return nil
+}
+
+// namesFromIDs fills in the company and contact names a request left blank
+// but gave the ID of, so clients may send either
+func (s *Server) namesFromIDs(rec *Record) error {
+	if rec.CompanyID != 0 && rec.CompanyName == "" {
+		c, err := s.store.GetCompany(rec.CompanyID)
+		if err == ErrCompanyNotFound {
+			return unknownCompany
+		} else if err != nil {
+			return err
+		}
+		rec.CompanyName = c.Name
+	}
+	if rec.ContactID != 0 && rec.CompanyContact == "" {
+		c, err := s.store.GetContact(rec.ContactID)
+		if err == ErrContactNotFound {
+			return unknownContact
+		} else if err != nil {
+			return err
+		}
+		rec.CompanyContact = c.Name
+	}
+	return nil
+}
+
+// idParam reads a numeric ID from the path, writing a 400 response when it is
+// not a number
+func idParam(w http.ResponseWriter, r *http.Request, name, label string) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid "+label)
+		return 0, false
+	}
+	return id, true
+}
+
+// ListCompaniesHandler lists the companies a page at a time, by name
+func (s *Server) ListCompaniesHandler(w http.ResponseWriter, r *http.Request) {
+	params, err := companyListSpec.Parse(r.URL.Query())
+	if err != nil {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, err.Error())
+		return
+	}
+
+	page, err := s.store.ListCompanies(params)
+	if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	api.JSON(w, http.StatusOK, page)
+}
+
+// GetCompanyHandler gets one company with its version as the ETag
+func (s *Server) GetCompanyHandler(w http.ResponseWriter, r *http.Request) {
+	companyID, ok := idParam(w, r, "companyID", "company ID")
+	if !ok {
+		return
+	}
+
+	c, err := s.store.GetCompany(companyID)
+	if err == ErrCompanyNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Company not found")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	api.SetETag(w, c.Version)
+	api.JSON(w, http.StatusOK, c)
+}
+
+// CreateCompanyHandler adds a company, which must not share its name
+func (s *Server) CreateCompanyHandler(w http.ResponseWriter, r *http.Request) {
+	var c Company
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		api.InvalidPayload(w, r)
+		return
+	}
+	c.Name = strings.TrimSpace(c.Name)
+	if errs := validate.Struct(c); errs != nil {
+		api.Invalid(w, r, errs)
+		return
+	}
+
+	companyID, err := s.store.CreateCompany(c, audit.ActorFrom(r))
+	if err == ErrNameInUse {
+		api.Error(w, r, http.StatusConflict, api.CodeConflict, "A company with this name already exists")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	c.CompanyID, c.Version = companyID, 1
+	api.SetETag(w, c.Version)
+	api.Created(w, fmt.Sprintf("/api/v1/companies/%d", c.CompanyID), c)
+}
+
+// UpdateCompanyHandler renames a company and every record that refers to it
+func (s *Server) UpdateCompanyHandler(w http.ResponseWriter, r *http.Request) {
+	companyID, ok := idParam(w, r, "companyID", "company ID")
+	if !ok {
+		return
+	}
+
+	var c Company
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		api.InvalidPayload(w, r)
+		return
+	}
+	c.Name = strings.TrimSpace(c.Name)
+	if errs := validate.Struct(c); errs != nil {
+		api.Invalid(w, r, errs)
+		return
+	}
+
+	version, ok := api.IfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	c.CompanyID, c.Version = companyID, version
+	err := s.store.UpdateCompany(c, audit.ActorFrom(r))
+	if err == ErrCompanyNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Company not found")
+		return
+	} else if err == ErrVersionConflict {
+		api.PreconditionFailed(w, r, "Company was changed by someone else; reload it and try again")
+		return
+	} else if err == ErrNameInUse {
+		api.Error(w, r, http.StatusConflict, api.CodeConflict, "A company with this name already exists")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	if version > 0 {
+		api.SetETag(w, version+1)
+	}
+	api.Message(w, http.StatusOK, "Company updated successfully")
+}
+
+// DeleteCompanyHandler removes a company that no record refers to
+func (s *Server) DeleteCompanyHandler(w http.ResponseWriter, r *http.Request) {
+	companyID, ok := idParam(w, r, "companyID", "company ID")
+	if !ok {
+		return
+	}
+
+	err := s.store.DeleteCompany(companyID, audit.ActorFrom(r))
+	if err == ErrCompanyNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Company not found")
+		return
+	} else if err == ErrInUse {
+		api.Error(w, r, http.StatusConflict, api.CodeConflict, "Company is referred to by records, including any in the trash")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	api.Message(w, http.StatusOK, "Company deleted successfully")
+}
+
+// ListContactsHandler lists the contacts of a company a page at a time, by name
+func (s *Server) ListContactsHandler(w http.ResponseWriter, r *http.Request) {
+	companyID, ok := idParam(w, r, "companyID", "company ID")
+	if !ok {
+		return
+	}
+
+	params, err := contactListSpec.Parse(r.URL.Query())
+	if err != nil {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, err.Error())
+		return
+	}
+
+	// An empty page could be a company without contacts or no company at all
+	if _, err := s.store.GetCompany(companyID); err == ErrCompanyNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Company not found")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	page, err := s.store.ListContacts(companyID, params)
+	if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	api.JSON(w, http.StatusOK, page)
+}
+
+// GetContactHandler gets one contact with its version as the ETag
+func (s *Server) GetContactHandler(w http.ResponseWriter, r *http.Request) {
+	contactID, ok := idParam(w, r, "contactID", "contact ID")
+	if !ok {
+		return
+	}
+
+	c, err := s.store.GetContact(contactID)
+	if err == ErrContactNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Contact not found")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	api.SetETag(w, c.Version)
+	api.JSON(w, http.StatusOK, c)
+}
+
+// CreateContactHandler adds a contact to the company in the path
+func (s *Server) CreateContactHandler(w http.ResponseWriter, r *http.Request) {
+	companyID, ok := idParam(w, r, "companyID", "company ID")
+	if !ok {
+		return
+	}
+
+	var c Contact
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		api.InvalidPayload(w, r)
+		return
+	}
+	c.Name = strings.TrimSpace(c.Name)
+	if errs := validate.Struct(c); errs != nil {
+		api.Invalid(w, r, errs)
+		return
+	}
+
+	c.CompanyID = companyID
+	contactID, err := s.store.CreateContact(c, audit.ActorFrom(r))
+	if err == ErrCompanyNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Company not found")
+		return
+	} else if err == ErrNameInUse {
+		api.Error(w, r, http.StatusConflict, api.CodeConflict, "The company already has a contact with this name")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	c.ContactID, c.Version = contactID, 1
+	api.SetETag(w, c.Version)
+	api.Created(w, fmt.Sprintf("/api/v1/contacts/%d", c.ContactID), c)
+}
+
+// UpdateContactHandler renames a contact and every record that refers to it.
+// A contact stays with its company.
+func (s *Server) UpdateContactHandler(w http.ResponseWriter, r *http.Request) {
+	contactID, ok := idParam(w, r, "contactID", "contact ID")
+	if !ok {
+		return
+	}
+
+	var c Contact
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		api.InvalidPayload(w, r)
+		return
+	}
+	c.Name = strings.TrimSpace(c.Name)
+	if errs := validate.Struct(c); errs != nil {
+		api.Invalid(w, r, errs)
+		return
+	}
+
+	version, ok := api.IfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	c.ContactID, c.Version = contactID, version
+	err := s.store.UpdateContact(c, audit.ActorFrom(r))
+	if err == ErrContactNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Contact not found")
+		return
+	} else if err == ErrVersionConflict {
+		api.PreconditionFailed(w, r, "Contact was changed by someone else; reload it and try again")
+		return
+	} else if err == ErrNameInUse {
+		api.Error(w, r, http.StatusConflict, api.CodeConflict, "The company already has a contact with this name")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	if version > 0 {
+		api.SetETag(w, version+1)
+	}
+	api.Message(w, http.StatusOK, "Contact updated successfully")
+}
+
+// DeleteContactHandler removes a contact that no record refers to
+func (s *Server) DeleteContactHandler(w http.ResponseWriter, r *http.Request) {
+	contactID, ok := idParam(w, r, "contactID", "contact ID")
+	if !ok {
+		return
+	}
+
+	err := s.store.DeleteContact(contactID, audit.ActorFrom(r))
+	if err == ErrContactNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Contact not found")
+		return
+	} else if err == ErrInUse {
+		api.Error(w, r, http.StatusConflict, api.CodeConflict, "Contact is referred to by records, including any in the trash")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	api.Message(w, http.StatusOK, "Contact deleted successfully")
+}
+
+// referenceError answers a record create or update the store failed. A
+// reference it rejected is reported like any other invalid field.
+func referenceError(w http.ResponseWriter, r *http.Request, err error) {
+	if errs, ok := err.(validate.Errors); ok {
+		api.Invalid(w, r, errs)
+		return
+	}
+	api.Internal(w, r)
+}
@@ -0,0 +1,666 @@
+// company_test.go
+package record
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"   //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/query" //change here
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// companyRouter routes the company and contact paths straight to the
+// handlers, without the authorization middleware
+func companyRouter(s *Server) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/companies", s.ListCompaniesHandler).Methods("GET")
+	router.HandleFunc("/api/v1/companies", s.CreateCompanyHandler).Methods("POST")
+	router.HandleFunc("/api/v1/companies/{companyID}", s.GetCompanyHandler).Methods("GET")
+	router.HandleFunc("/api/v1/companies/{companyID}", s.UpdateCompanyHandler).Methods("PUT")
+	router.HandleFunc("/api/v1/companies/{companyID}", s.DeleteCompanyHandler).Methods("DELETE")
+	router.HandleFunc("/api/v1/companies/{companyID}/contacts", s.ListContactsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/companies/{companyID}/contacts", s.CreateContactHandler).Methods("POST")
+	router.HandleFunc("/api/v1/contacts/{contactID}", s.GetContactHandler).Methods("GET")
+	router.HandleFunc("/api/v1/contacts/{contactID}", s.UpdateContactHandler).Methods("PUT")
+	router.HandleFunc("/api/v1/contacts/{contactID}", s.DeleteContactHandler).Methods("DELETE")
+	router.HandleFunc("/api/v1/records", s.CreateRecordHandler).Methods("POST")
+	router.HandleFunc("/api/v1/records/{recordID}", s.PatchRecordHandler).Methods("PATCH")
+	return router
+}
+
+// serveCompanies sends one request to companyRouter; ifMatch is sent as the
+// If-Match header unless empty
+func serveCompanies(t *testing.T, s *Server, method, path, body, ifMatch string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, err := http.NewRequest(method, path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	rr := httptest.NewRecorder()
+	companyRouter(s).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestPatchReferences(t *testing.T) {
+	tests := []struct {
+		changed  []string
+		company  int
+		contact  int
+		expected []string
+	}{
+		// A new company name finds the company and its contact again by name
+		{[]string{"CompanyName"}, 0, 0, []string{"CompanyName", "CompanyContact", "CompanyID", "ContactID"}},
+		// A new company ID keeps the ID, but the contact is found by name
+		{[]string{"CompanyID"}, 2, 0, []string{"CompanyID", "CompanyName", "CompanyContact", "ContactID"}},
+		// A new contact name keeps the company
+		{[]string{"CompanyContact"}, 2, 0, []string{"CompanyContact", "CompanyName", "CompanyID", "ContactID"}},
+		// IDs given with the names win
+		{[]string{"CompanyName", "CompanyID", "ContactID"}, 2, 2, []string{"CompanyName", "CompanyID", "ContactID", "CompanyContact"}},
+	}
+
+	for _, tt := range tests {
+		rec := testRecords[1]
+		columns := patchReferences(&rec, tt.changed)
+		if rec.CompanyID != tt.company || rec.ContactID != tt.contact {
+			t.Errorf("patchReferences(%v) left company %d and contact %d, want %d and %d", tt.changed, rec.CompanyID, rec.ContactID, tt.company, tt.contact)
+		}
+		if !reflect.DeepEqual(columns, tt.expected) {
+			t.Errorf("patchReferences(%v) returned %v, want %v", tt.changed, columns, tt.expected)
+		}
+	}
+
+	if changesReferences([]string{"Name", "ProjDesc"}) {
+		t.Errorf("changesReferences reported a change to other columns")
+	}
+}
+
+func TestCreateCompanyHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT CompanyID, Name, Version FROM Company WHERE NameKey = ?")).
+				WithArgs("acmepteltd").
+				WillReturnRows(sqlmock.NewRows(companyColumns))
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Company (Name, NameKey) VALUES (?, ?)")).
+				WithArgs("Acme Pte Ltd", "acmepteltd").
+				WillReturnResult(sqlmock.NewResult(4, 1))
+			expectEntityAudit(mock, audit.ActionCreate, audit.EntityCompany, 4)
+			mock.ExpectCommit()
+		})
+
+		rr := serveCompanies(t, f.server(), "POST", "/api/v1/companies", `{"name": " Acme Pte Ltd "}`, "")
+
+		if status := rr.Code; status != http.StatusCreated {
+			t.Fatalf("Handler returned wrong status code: got %v want %v: %s", status, http.StatusCreated, rr.Body.String())
+		}
+		if location := rr.Header().Get("Location"); location != "/api/v1/companies/4" {
+			t.Errorf("Handler returned wrong location: got %v want %v", location, "/api/v1/companies/4")
+		}
+		expected := `{"companyId":4,"name":"Acme Pte Ltd","version":1}` + "\n"
+		if rr.Body.String() != expected {
+			t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+		}
+	})
+}
+
+func TestCreateCompanyHandler_NameInUse(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		// Names differing only in case and spaces are the same
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT CompanyID, Name, Version FROM Company WHERE NameKey = ?")).
+				WithArgs("companya").
+				WillReturnRows(sqlmock.NewRows(companyColumns).AddRow(2, "CompanyA", 1))
+			mock.ExpectRollback()
+		})
+
+		rr := serveCompanies(t, f.server(), "POST", "/api/v1/companies", `{"name": "Company a"}`, "")
+
+		if status := rr.Code; status != http.StatusConflict {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+		}
+		expectErrorCode(t, rr, api.CodeConflict)
+	})
+}
+
+func TestCreateCompanyHandler_Concurrent(t *testing.T) {
+	s, mock := mysqlServer(t)
+
+	// Another request inserts the name between the lookup and the insert
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT CompanyID, Name, Version FROM Company WHERE NameKey = ?")).
+		WithArgs("acmepteltd").
+		WillReturnRows(sqlmock.NewRows(companyColumns))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Company (Name, NameKey) VALUES (?, ?)")).
+		WithArgs("Acme Pte Ltd", "acmepteltd").
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'acmepteltd' for key 'CompanyNameKey'"})
+	mock.ExpectRollback()
+
+	rr := serveCompanies(t, s, "POST", "/api/v1/companies", `{"name": "Acme Pte Ltd"}`, "")
+
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+	}
+	expectErrorCode(t, rr, api.CodeConflict)
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdateCompanyHandler(t *testing.T) {
+	renamed := testRecords[1]
+	renamed.CompanyName, renamed.Version = "Company A", 2
+
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		// The new name has the same key as the old one, which is not a clash
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT CompanyID, Name, Version FROM Company WHERE CompanyID = ? FOR UPDATE")).
+				WithArgs(2).
+				WillReturnRows(sqlmock.NewRows(companyColumns).AddRow(2, "CompanyA", 1))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT CompanyID, Name, Version FROM Company WHERE NameKey = ?")).
+				WithArgs("companya").
+				WillReturnRows(sqlmock.NewRows(companyColumns).AddRow(2, "CompanyA", 1))
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE Company SET Name = ?, NameKey = ?, Version = Version + 1 WHERE CompanyID = ?")).
+				WithArgs("Company A", "companya", 2).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, Version, CompanyID, ContactID FROM Record WHERE CompanyID = ? ORDER BY RecordID FOR UPDATE")).
+				WithArgs(2).
+				WillReturnRows(exportRows(testRecords[1]))
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE Record SET CompanyName = ?, Version = Version + 1 WHERE CompanyID = ?")).
+				WithArgs("Company A", 2).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO RecordRevision (RecordID, Revision, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, CompanyID, ContactID, CreatedBy, CreatedAt) "+
+				"SELECT RecordID, Version, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, CompanyID, ContactID, ?, UTC_TIMESTAMP() FROM Record WHERE CompanyID = ?")).
+				WithArgs(sqlmock.AnyArg(), 2).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO AuditLog (ActorID, Action, Entity, EntityID, OldValue, NewValue, SourceIP, CreatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")).
+				WithArgs(sqlmock.AnyArg(), audit.ActionUpdate, audit.EntityRecord, 2, jsonOf(t, testRecords[1]), jsonOf(t, renamed), sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectEntityAudit(mock, audit.ActionUpdate, audit.EntityCompany, 2)
+			mock.ExpectCommit()
+		})
+
+		rr := serveCompanies(t, f.server(), "PUT", "/api/v1/companies/2", `{"name": "Company A"}`, `"1"`)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("Handler returned wrong status code: got %v want %v: %s", status, http.StatusOK, rr.Body.String())
+		}
+		if etag := rr.Header().Get("ETag"); etag != `"2"` {
+			t.Errorf("Handler returned wrong ETag: got %v want %v", etag, `"2"`)
+		}
+
+		// The record takes the new name as its next version and revision
+		if f.memory != nil {
+			if rec, _ := f.stored(2); rec != renamed {
+				t.Errorf("Record was not renamed: %+v", rec)
+			}
+			if rev, err := f.store.Revision(2, 2); err != nil || rev.CompanyName != "Company A" {
+				t.Errorf("Rename stored unexpected revision: %+v %v", rev, err)
+			}
+
+			// The rename of the record is audited like any other change to it
+			params, err := audit.Parse(url.Values{"entity": {audit.EntityRecord}, "entityId": {"2"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries, err := f.memory.Log.List(params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entries.Total != 1 || string(entries.Items[0].Before) != jsonOf(t, testRecords[1]) || string(entries.Items[0].After) != jsonOf(t, renamed) {
+				t.Errorf("Rename logged unexpected entries: %+v", entries.Items)
+			}
+		}
+	})
+}
+
+// jsonOf returns v as the JSON an audit entry stores
+func jsonOf(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestUpdateCompanyHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		ifMatch string
+		status  int
+		code    string
+	}{
+		{"InvalidID", "/api/v1/companies/x", `{"name": "Acme"}`, `"1"`, http.StatusBadRequest, api.CodeInvalidParameter},
+		{"InvalidName", "/api/v1/companies/2", `{"name": " "}`, `"1"`, http.StatusUnprocessableEntity, api.CodeValidationFailed},
+		{"NoIfMatch", "/api/v1/companies/2", `{"name": "Acme"}`, "", http.StatusPreconditionRequired, api.CodePreconditionRequired},
+		{"NotFound", "/api/v1/companies/99", `{"name": "Acme"}`, "*", http.StatusNotFound, api.CodeNotFound},
+		{"Conflict", "/api/v1/companies/2", `{"name": "Acme"}`, `"5"`, http.StatusPreconditionFailed, api.CodePreconditionFailed},
+		{"NameInUse", "/api/v1/companies/2", `{"name": "companyb"}`, `"1"`, http.StatusConflict, api.CodeConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(NewMemoryStore(testRecords...), testResolver)
+			rr := serveCompanies(t, s, "PUT", tt.path, tt.body, tt.ifMatch)
+
+			if status := rr.Code; status != tt.status {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, tt.status)
+			}
+			expectErrorCode(t, rr, tt.code)
+		})
+	}
+}
+
+func TestDeleteCompanyHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+		if f.memory != nil {
+			if _, err := f.memory.CreateCompany(Company{Name: "Acme"}, audit.Actor{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT CompanyID, Name, Version FROM Company WHERE CompanyID = ? FOR UPDATE")).
+				WithArgs(4).
+				WillReturnRows(sqlmock.NewRows(companyColumns).AddRow(4, "Acme", 1))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM Record WHERE CompanyID = ?")).
+				WithArgs(4).
+				WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM Contact WHERE CompanyID = ?")).
+				WithArgs(4).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM Company WHERE CompanyID = ?")).
+				WithArgs(4).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectEntityAudit(mock, audit.ActionDelete, audit.EntityCompany, 4)
+			mock.ExpectCommit()
+		})
+
+		rr := serveCompanies(t, f.server(), "DELETE", "/api/v1/companies/4", "", "")
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v: %s", status, http.StatusOK, rr.Body.String())
+		}
+		if _, err := f.store.GetCompany(4); f.memory != nil && err != ErrCompanyNotFound {
+			t.Errorf("Company was not deleted: %v", err)
+		}
+	})
+}
+
+func TestDeleteCompanyHandler_InUse(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT CompanyID, Name, Version FROM Company WHERE CompanyID = ? FOR UPDATE")).
+				WithArgs(2).
+				WillReturnRows(sqlmock.NewRows(companyColumns).AddRow(2, "CompanyA", 1))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM Record WHERE CompanyID = ?")).
+				WithArgs(2).
+				WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+			mock.ExpectRollback()
+		})
+
+		rr := serveCompanies(t, f.server(), "DELETE", "/api/v1/companies/2", "", "")
+
+		if status := rr.Code; status != http.StatusConflict {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+		}
+		expectErrorCode(t, rr, api.CodeConflict)
+	})
+}
+
+func TestListContactsHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT CompanyID, Name, Version FROM Company WHERE CompanyID = ?")).
+				WithArgs(2).
+				WillReturnRows(sqlmock.NewRows(companyColumns).AddRow(2, "CompanyA", 1))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT ContactID, CompanyID, Name, Version FROM Contact WHERE CompanyID = ? ORDER BY Name ASC, ContactID ASC LIMIT ?")).
+				WithArgs("2", 51).
+				WillReturnRows(sqlmock.NewRows(contactColumns).AddRow(2, 2, "Mr Choo CH", 1))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM Contact WHERE CompanyID = ?")).
+				WithArgs("2").
+				WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT CompanyID, Name, Version FROM Company WHERE CompanyID = ?")).
+				WithArgs(99).
+				WillReturnRows(sqlmock.NewRows(companyColumns))
+		})
+
+		rr := serveCompanies(t, f.server(), "GET", "/api/v1/companies/2/contacts", "", "")
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("Handler returned wrong status code: got %v want %v: %s", status, http.StatusOK, rr.Body.String())
+		}
+		var page query.Page[Contact]
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		expected := []Contact{{ContactID: 2, CompanyID: 2, Name: "Mr Choo CH", Version: 1}}
+		if page.Total != 1 || !reflect.DeepEqual(page.Items, expected) {
+			t.Errorf("Handler returned unexpected page: %+v", page)
+		}
+
+		// A missing company is not the same as one without contacts
+		rr = serveCompanies(t, f.server(), "GET", "/api/v1/companies/99/contacts", "", "")
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+		expectErrorCode(t, rr, api.CodeNotFound)
+	})
+}
+
+func TestCreateContactHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT CompanyID, Name, Version FROM Company WHERE CompanyID = ? FOR SHARE")).
+				WithArgs(2).
+				WillReturnRows(sqlmock.NewRows(companyColumns).AddRow(2, "CompanyA", 1))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT ContactID, CompanyID, Name, Version FROM Contact WHERE CompanyID = ? AND NameKey = ?")).
+				WithArgs(2, "mslee").
+				WillReturnRows(sqlmock.NewRows(contactColumns))
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Contact (CompanyID, Name, NameKey) VALUES (?, ?, ?)")).
+				WithArgs(2, "Ms Lee", "mslee").
+				WillReturnResult(sqlmock.NewResult(4, 1))
+			expectEntityAudit(mock, audit.ActionCreate, audit.EntityContact, 4)
+			mock.ExpectCommit()
+		})
+
+		rr := serveCompanies(t, f.server(), "POST", "/api/v1/companies/2/contacts", `{"name": "Ms Lee"}`, "")
+
+		if status := rr.Code; status != http.StatusCreated {
+			t.Fatalf("Handler returned wrong status code: got %v want %v: %s", status, http.StatusCreated, rr.Body.String())
+		}
+		if location := rr.Header().Get("Location"); location != "/api/v1/contacts/4" {
+			t.Errorf("Handler returned wrong location: got %v want %v", location, "/api/v1/contacts/4")
+		}
+		expected := `{"contactId":4,"companyId":2,"name":"Ms Lee","version":1}` + "\n"
+		if rr.Body.String() != expected {
+			t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+		}
+	})
+}
+
+func TestContactHandlers_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		ifMatch string
+		status  int
+		code    string
+	}{
+		{"CreateForMissingCompany", "POST", "/api/v1/companies/99/contacts", `{"name": "Ms Lee"}`, "", http.StatusNotFound, api.CodeNotFound},
+		{"CreateNameInUse", "POST", "/api/v1/companies/2/contacts", `{"name": "mr choo ch"}`, "", http.StatusConflict, api.CodeConflict},
+		{"GetMissing", "GET", "/api/v1/contacts/99", "", "", http.StatusNotFound, api.CodeNotFound},
+		{"UpdateNoIfMatch", "PUT", "/api/v1/contacts/2", `{"name": "Mr Choo"}`, "", http.StatusPreconditionRequired, api.CodePreconditionRequired},
+		{"UpdateConflict", "PUT", "/api/v1/contacts/2", `{"name": "Mr Choo"}`, `"3"`, http.StatusPreconditionFailed, api.CodePreconditionFailed},
+		{"DeleteInUse", "DELETE", "/api/v1/contacts/2", "", "", http.StatusConflict, api.CodeConflict},
+		{"DeleteInvalidID", "DELETE", "/api/v1/contacts/x", "", "", http.StatusBadRequest, api.CodeInvalidParameter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(NewMemoryStore(testRecords...), testResolver)
+			rr := serveCompanies(t, s, tt.method, tt.path, tt.body, tt.ifMatch)
+
+			if status := rr.Code; status != tt.status {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, tt.status)
+			}
+			expectErrorCode(t, rr, tt.code)
+		})
+	}
+}
+
+func TestUpdateContactHandler(t *testing.T) {
+	store := NewMemoryStore(testRecords...)
+	s := NewServer(store, testResolver)
+
+	// Records move with a renamed contact, trashed ones included
+	if err := store.Delete(3, audit.Actor{AccID: 1001}); err != nil {
+		t.Fatal(err)
+	}
+	rr := serveCompanies(t, s, "PUT", "/api/v1/contacts/3", `{"name": "Dr Pamela Lim"}`, "*")
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v: %s", status, http.StatusOK, rr.Body.String())
+	}
+	if c, err := store.GetContact(3); err != nil || c != (Contact{ContactID: 3, CompanyID: 3, Name: "Dr Pamela Lim", Version: 2}) {
+		t.Errorf("Contact was not renamed: %+v %v", c, err)
+	}
+	if rec := store.deleted[3]; rec.CompanyContact != "Dr Pamela Lim" || rec.Version != 2 {
+		t.Errorf("Trashed record was not renamed: %+v", rec)
+	}
+}
+
+func TestRollbackRecord_References(t *testing.T) {
+	store := NewMemoryStore(testRecords...)
+	by := audit.Actor{AccID: 1001}
+
+	// The record goes back to the same company under its new name
+	if err := store.UpdateCompany(Company{CompanyID: 2, Name: "Company Alpha"}, by); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Rollback(2, 1, by); err != nil {
+		t.Fatal(err)
+	}
+	if rec, _ := store.Get(2); rec.CompanyID != 2 || rec.CompanyName != "Company Alpha" || rec.ContactID != 2 {
+		t.Errorf("Rollback did not follow the rename: %+v", rec)
+	}
+	if len(store.companies) != 3 {
+		t.Errorf("Rollback created a company: %v", store.companies)
+	}
+
+	// A company deleted since the revision is found by name again
+	moved := testRecords[0]
+	moved.CompanyID, moved.ContactID = 3, 3
+	if err := store.Update(moved, by); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteCompany(1, by); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Rollback(1, 1, by); err != nil {
+		t.Fatal(err)
+	}
+	if rec, _ := store.Get(1); rec.CompanyID != 4 || rec.CompanyName != testRecords[0].CompanyName || rec.ContactID != 4 {
+		t.Errorf("Rollback did not recreate the deleted company: %+v", rec)
+	}
+}
+
+func TestCreateRecordHandler_References(t *testing.T) {
+	created := testRecords[1]
+	created.RecordID = 4
+
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		// The names are read for validation, then the IDs are checked again in
+		// the transaction
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectReferenceIDs(mock, created, "")
+			mock.ExpectBegin()
+			insert := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO Record (Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, CompanyID, ContactID) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"))
+			expectReferenceIDs(mock, created, " FOR SHARE")
+			insert.ExpectExec().
+				WithArgs(created.Name, created.RoleOfContact, created.NoOfStudents, created.AcadYr, created.CapstoneTitle, "CompanyA", "Mr Choo CH", created.ProjDesc, 2, 2).
+				WillReturnResult(sqlmock.NewResult(4, 1))
+			expectRevision(mock, created)
+			expectAudit(mock, audit.ActionCreate, 4)
+			mock.ExpectCommit()
+		})
+
+		body := `{"name": "Yi Ting", "roleOfContact": "Student", "noOfStudents": 3, "acadYr": "2022/2023", "capstoneTitle": "Carpooling System", ` +
+			`"companyId": 2, "contactId": 2, "projDesc": "A carpooling system connecting passengers and car owners."}`
+		rr := serveCompanies(t, f.server(), "POST", "/api/v1/records", body, "")
+
+		if status := rr.Code; status != http.StatusCreated {
+			t.Fatalf("Handler returned wrong status code: got %v want %v: %s", status, http.StatusCreated, rr.Body.String())
+		}
+		var rec Record
+		if err := json.NewDecoder(rr.Body).Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec != created {
+			t.Errorf("Handler returned unexpected record: %+v", rec)
+		}
+	})
+}
+
+func TestCreateRecordHandler_ConcurrentCompany(t *testing.T) {
+	s, mock := mysqlServer(t)
+	created := Record{1, "Ann", "Staff", 2, "2023/2024", "Robots", "Acme", "Ms Tan", "Build robots", 1, 7, 8}
+
+	// Another request creates the company after the lookup, so its row is
+	// read again and used without a second audit entry
+	mock.ExpectBegin()
+	insert := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO Record (Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, CompanyID, ContactID) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT CompanyID, Name, Version FROM Company WHERE NameKey = ? FOR SHARE")).
+		WithArgs("acme").
+		WillReturnRows(sqlmock.NewRows(companyColumns))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Company (Name, NameKey) VALUES (?, ?)")).
+		WithArgs("Acme", "acme").
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'acme' for key 'CompanyNameKey'"})
+	expectReferences(mock, created)
+	insert.ExpectExec().
+		WithArgs("Ann", "Staff", 2, "2023/2024", "Robots", "Acme", "Ms Tan", "Build robots", 7, 8).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRevision(mock, created)
+	expectAudit(mock, audit.ActionCreate, 1)
+	mock.ExpectCommit()
+
+	body := `{"name": "Ann", "roleOfContact": "Staff", "noOfStudents": 2, "acadYr": "2023/2024", "capstoneTitle": "Robots", ` +
+		`"companyName": "Acme", "companyContact": "Ms Tan", "projDesc": "Build robots"}`
+	rr := serveCompanies(t, s, "POST", "/api/v1/records", body, "")
+
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("Handler returned wrong status code: got %v want %v: %s", status, http.StatusCreated, rr.Body.String())
+	}
+	var rec Record
+	if err := json.NewDecoder(rr.Body).Decode(&rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec != created {
+		t.Errorf("Handler returned unexpected record: %+v", rec)
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCreateRecordHandler_UnknownReferences(t *testing.T) {
+	const fields = `"name": "Ann", "roleOfContact": "Staff", "noOfStudents": 2, "acadYr": "2023/2024", "capstoneTitle": "Robots", "projDesc": "Build robots", `
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"MissingCompany", `{` + fields + `"companyId": 99, "companyContact": "Ms Tan"}`, "companyId"},
+		{"MissingContact", `{` + fields + `"companyName": "Acme", "contactId": 99}`, "contactId"},
+		// The contact works for CompanyB, not CompanyA
+		{"ContactOfOtherCompany", `{` + fields + `"companyId": 2, "contactId": 3}`, "contactId"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore(testRecords...)
+			rr := serveCompanies(t, NewServer(store, testResolver), "POST", "/api/v1/records", tt.body, "")
+
+			if status := rr.Code; status != http.StatusUnprocessableEntity {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
+			}
+			body := expectErrorCode(t, rr, api.CodeValidationFailed)
+			if details, _ := json.Marshal(body.Details); !strings.Contains(string(details), `"field":"`+tt.field+`"`) {
+				t.Errorf("Handler rejected the wrong field: %s", details)
+			}
+			if _, ok := store.records[4]; ok {
+				t.Errorf("Handler created the record")
+			}
+		})
+	}
+}
+
+func TestPatchRecordHandler_Company(t *testing.T) {
+	// The company name is canonicalised and the contact, new at that company,
+	// is created there
+	patched := testRecords[1]
+	patched.CompanyName, patched.CompanyID, patched.ContactID, patched.Version = "CompanyB", 3, 4, 2
+
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectRead(mock, testRecords[1])
+			expectLock(mock, testRecords[1])
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT CompanyID, Name, Version FROM Company WHERE NameKey = ? FOR SHARE")).
+				WithArgs("companyb").
+				WillReturnRows(sqlmock.NewRows(companyColumns).AddRow(3, "CompanyB", 1))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT ContactID, CompanyID, Name, Version FROM Contact WHERE CompanyID = ? AND NameKey = ? FOR SHARE")).
+				WithArgs(3, "mrchooch").
+				WillReturnRows(sqlmock.NewRows(contactColumns))
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Contact (CompanyID, Name, NameKey) VALUES (?, ?, ?)")).
+				WithArgs(3, "Mr Choo CH", "mrchooch").
+				WillReturnResult(sqlmock.NewResult(4, 1))
+			expectEntityAudit(mock, audit.ActionCreate, audit.EntityContact, 4)
+			mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Record SET CompanyName=?, CompanyContact=?, CompanyID=?, ContactID=?, Version=Version+1 WHERE RecordID=?")).
+				ExpectExec().
+				WithArgs("CompanyB", "Mr Choo CH", 3, 4, 2).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectRead(mock, patched)
+			expectRevision(mock, patched)
+			expectAudit(mock, audit.ActionUpdate, 2)
+			mock.ExpectCommit()
+			expectRead(mock, patched)
+		})
+
+		rr := serveCompanies(t, f.server(), "PATCH", "/api/v1/records/2", `{"companyName": "companyb"}`, "")
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("Handler returned wrong status code: got %v want %v: %s", status, http.StatusOK, rr.Body.String())
+		}
+		var rec Record
+		if err := json.NewDecoder(rr.Body).Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec != patched {
+			t.Errorf("Handler returned unexpected record: %+v", rec)
+		}
+	})
+}
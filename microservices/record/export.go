@@ -0,0 +1,208 @@
+package record
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"  //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/xlsx" //change here
+)
+
+// exportColumns head CSV and XLSX exports, named as in the record JSON so an
+// export can be imported again
+var exportColumns = []string{"recordId", "name", "roleOfContact", "noOfStudents", "acadYr", "capstoneTitle", "companyName", "companyContact", "projDesc", "version"}
+
+// recordEncoder writes records to an export as they are read
+type recordEncoder interface {
+	Encode(rec Record) error
+	// Close writes anything the format needs after the last record
+	Close() error
+}
+
+// exportFormat is a file format records can be exported in
+type exportFormat struct {
+	contentType string
+	newEncoder  func(w io.Writer) (recordEncoder, error)
+}
+
+// formats accepted by ExportRecordsHandler, by their name and file extension
+var exportFormats = map[string]exportFormat{
+	"csv":   {"text/csv; charset=utf-8", newCSVEncoder},
+	"jsonl": {"application/x-ndjson", newJSONLEncoder},
+	"xlsx":  {xlsx.ContentType, newXLSXEncoder},
+}
+
+// ExportRecordsHandler streams every record matching the list filters and
+// sort, and the search in q, as a file download in the given format. Rows go
+// to the client as they are read from the store.
+func (s *Server) ExportRecordsHandler(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	name := values.Get("format")
+	if name == "" {
+		name = "csv"
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "format must be csv, jsonl or xlsx")
+		return
+	}
+
+	params, err := recordListSpec.Parse(values)
+	if err != nil {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, err.Error())
+		return
+	}
+	q := values.Get("q")
+	if q == "" {
+		q = values.Get("query")
+	}
+
+	// The server's WriteTimeout would cut a long export off, so the deadline
+	// is pushed back as each row is written. A writer without deadlines,
+	// such as a test recorder, has none to extend.
+	rc := http.NewResponseController(w)
+	extend := func() {
+		rc.SetWriteDeadline(time.Now().Add(cfg.HTTP.WriteTimeout))
+	}
+
+	// The response starts with the first record, so a store that fails
+	// before then can still be answered with an error
+	var enc recordEncoder
+	started := false
+	start := func() error {
+		w.Header().Set("Content-Type", format.contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="records-%s.%s"`, time.Now().UTC().Format("20060102"), name))
+		w.WriteHeader(http.StatusOK)
+		started = true
+
+		var err error
+		enc, err = format.newEncoder(w)
+		return err
+	}
+
+	err = s.store.Export(params, ParseSearch(q), func(rec Record) error {
+		extend()
+		if enc == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return enc.Encode(rec)
+	})
+	// An export without records still has its header
+	if err == nil && enc == nil {
+		err = start()
+	}
+	if err == nil {
+		extend()
+		err = enc.Close()
+	}
+
+	if err != nil && !started {
+		api.Internal(w, r)
+	} else if err != nil {
+		// The status is already sent; break the connection so the client
+		// sees a failed download rather than a complete-looking file
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// csvEncoder writes a header line and one line per record. It starts with a
+// byte order mark so spreadsheet programs read the file as UTF-8.
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func newCSVEncoder(w io.Writer) (recordEncoder, error) {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return nil, err
+	}
+	enc := &csvEncoder{w: csv.NewWriter(w)}
+	return enc, enc.w.Write(exportColumns)
+}
+
+func (e *csvEncoder) Encode(rec Record) error {
+	return e.w.Write([]string{
+		strconv.Itoa(rec.RecordID),
+		formulaSafe(rec.Name),
+		formulaSafe(rec.RoleOfContact),
+		strconv.Itoa(rec.NoOfStudents),
+		formulaSafe(rec.AcadYr),
+		formulaSafe(rec.CapstoneTitle),
+		formulaSafe(rec.CompanyName),
+		formulaSafe(rec.CompanyContact),
+		formulaSafe(rec.ProjDesc),
+		strconv.Itoa(rec.Version),
+	})
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// formulaPrefixes start text that spreadsheet programs run as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// formulaSafe quotes text that a spreadsheet would otherwise run as a
+// formula with a leading apostrophe, which the spreadsheet hides and
+// unformulaSafe removes on import
+func formulaSafe(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func unformulaSafe(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+// jsonlEncoder writes each record as one line of JSON
+type jsonlEncoder struct {
+	enc *json.Encoder
+}
+
+func newJSONLEncoder(w io.Writer) (recordEncoder, error) {
+	return &jsonlEncoder{enc: json.NewEncoder(w)}, nil
+}
+
+func (e *jsonlEncoder) Encode(rec Record) error {
+	return e.enc.Encode(rec)
+}
+
+func (e *jsonlEncoder) Close() error {
+	return nil
+}
+
+// xlsxEncoder writes a workbook with a bold header row. Its text cells are
+// never formulas, so they need no quoting.
+type xlsxEncoder struct {
+	w *xlsx.Writer
+}
+
+func newXLSXEncoder(w io.Writer) (recordEncoder, error) {
+	xw, err := xlsx.NewWriter(w, "Records")
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxEncoder{w: xw}, xw.WriteHeader(exportColumns...)
+}
+
+func (e *xlsxEncoder) Encode(rec Record) error {
+	return e.w.WriteRow(rec.RecordID, rec.Name, rec.RoleOfContact, rec.NoOfStudents, rec.AcadYr, rec.CapstoneTitle, rec.CompanyName, rec.CompanyContact, rec.ProjDesc, rec.Version)
+}
+
+func (e *xlsxEncoder) Close() error {
+	return e.w.Close()
+}
@@ -0,0 +1,260 @@
+// export_test.go
+package record
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"  //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/xlsx" //change here
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const selectRecords = "SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, Version, CompanyID, ContactID FROM Record WHERE DeletedAt IS NULL"
+
+func exportRows(records ...Record) *sqlmock.Rows {
+	rows := sqlmock.NewRows(recordColumns)
+	for _, rec := range records {
+		rows.AddRow(rec.RecordID, rec.Name, rec.RoleOfContact, rec.NoOfStudents, rec.AcadYr, rec.CapstoneTitle, rec.CompanyName, rec.CompanyContact, rec.ProjDesc, rec.Version, rec.CompanyID, rec.ContactID)
+	}
+	return rows
+}
+
+func exportRequest(t *testing.T, s *Server, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, err := http.NewRequest("GET", "/api/v1/records/export?"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	s.ExportRecordsHandler(rr, req)
+	return rr
+}
+
+func expectDownload(t *testing.T, rr *httptest.ResponseRecorder, contentType, extension string) {
+	t.Helper()
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v: %s", status, http.StatusOK, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != contentType {
+		t.Errorf("Handler returned wrong content type: got %v want %v", ct, contentType)
+	}
+	disposition := regexp.MustCompile(`^attachment; filename="records-\d{8}\.` + extension + `"$`)
+	if cd := rr.Header().Get("Content-Disposition"); !disposition.MatchString(cd) {
+		t.Errorf("Handler returned wrong content disposition: %v", cd)
+	}
+}
+
+func TestExportRecordsHandler_CSV(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta(selectRecords + " AND RoleOfContact = ? ORDER BY RecordID DESC")).
+				WithArgs("Student").
+				WillReturnRows(exportRows(testRecords[2], testRecords[1]))
+		})
+
+		rr := exportRequest(t, f.server(), "format=csv&roleOfContact=Student&sort=-recordId&limit=1")
+		expectDownload(t, rr, "text/csv; charset=utf-8", "csv")
+
+		// The limit only applies to pages, never to an export
+		expected := "\ufeffrecordId,name,roleOfContact,noOfStudents,acadYr,capstoneTitle,companyName,companyContact,projDesc,version\n" +
+			"3,Luke,Student,3,2023/2024,Android Based E-learning,CompanyB,Dr Pamela,\"Mobile application for learning anytime, anywhere.\",1\n" +
+			"2,Yi Ting,Student,3,2022/2023,Carpooling System,CompanyA,Mr Choo CH,A carpooling system connecting passengers and car owners.,1\n"
+		if rr.Body.String() != expected {
+			t.Errorf("Handler returned unexpected body:\n got %q\nwant %q", rr.Body.String(), expected)
+		}
+	})
+}
+
+func TestExportRecordsHandler_JSONLSearch(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta(selectRecords+" AND CompanyName LIKE ? AND (CapstoneTitle LIKE ? OR ProjDesc LIKE ? OR CompanyName LIKE ? OR CompanyContact LIKE ?) ORDER BY RecordID ASC")).
+				WithArgs("%Company%", "%system%", "%system%", "%system%", "%system%").
+				WillReturnRows(exportRows(testRecords[1]))
+		})
+
+		rr := exportRequest(t, f.server(), "format=jsonl&q=System+company:Company")
+		expectDownload(t, rr, "application/x-ndjson", "jsonl")
+
+		var got []Record
+		dec := json.NewDecoder(rr.Body)
+		for dec.More() {
+			var rec Record
+			if err := dec.Decode(&rec); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, rec)
+		}
+		if len(got) != 1 || got[0] != testRecords[1] {
+			t.Errorf("Handler exported unexpected records: %+v", got)
+		}
+	})
+}
+
+func TestExportRecordsHandler_XLSX(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta(selectRecords + " ORDER BY RecordID ASC")).
+				WillReturnRows(exportRows(testRecords...))
+		})
+
+		rr := exportRequest(t, f.server(), "format=xlsx")
+		expectDownload(t, rr, xlsx.ContentType, "xlsx")
+
+		body := rr.Body.Bytes()
+		zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range zr.File {
+			if file.Name != "xl/worksheets/sheet1.xml" {
+				continue
+			}
+			rc, err := file.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sheet, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := strings.Count(string(sheet), "<row "); n != len(testRecords)+1 {
+				t.Errorf("Sheet has %d rows, want %d", n, len(testRecords)+1)
+			}
+			if !strings.Contains(string(sheet), `<c r="D2"><v>4</v></c>`) {
+				t.Errorf("Sheet does not hold noOfStudents as a number: %s", sheet)
+			}
+			return
+		}
+		t.Errorf("Workbook has no sheet")
+	})
+}
+
+func TestExportRecordsHandler_Empty(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta(selectRecords + " AND AcadYr = ? ORDER BY RecordID ASC")).
+				WithArgs("1999/2000").
+				WillReturnRows(exportRows())
+		})
+
+		rr := exportRequest(t, f.server(), "acadYr=1999/2000")
+		expectDownload(t, rr, "text/csv; charset=utf-8", "csv")
+
+		// The header is written even when nothing matches
+		if lines := strings.Count(rr.Body.String(), "\n"); lines != 1 {
+			t.Errorf("Handler returned %d lines, want only the header: %q", lines, rr.Body.String())
+		}
+	})
+}
+
+func TestExportRecordsHandler_Invalid(t *testing.T) {
+	for _, query := range []string{"format=pdf", "sort=projDesc"} {
+		rr := exportRequest(t, NewServer(NewMemoryStore(), testResolver), query)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Handler returned wrong status code for %q: got %v want %v", query, status, http.StatusBadRequest)
+		}
+		expectErrorCode(t, rr, api.CodeInvalidParameter)
+	}
+}
+
+func TestExportRecordsHandler_Errors(t *testing.T) {
+	s, mock := mysqlServer(t)
+
+	// A failure before the first record is reported as usual
+	mock.ExpectQuery(regexp.QuoteMeta(selectRecords)).
+		WillReturnError(errors.New("sql: query failed"))
+
+	rr := exportRequest(t, s, "format=csv")
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+	}
+	expectErrorCode(t, rr, api.CodeInternal)
+
+	// A failure after the download started aborts the response
+	mock.ExpectQuery(regexp.QuoteMeta(selectRecords)).
+		WillReturnRows(exportRows(testRecords[:2]...).RowError(1, errors.New("connection lost")))
+
+	func() {
+		defer func() {
+			if r := recover(); r != http.ErrAbortHandler {
+				t.Errorf("Handler did not abort the response: recovered %v", r)
+			}
+		}()
+		exportRequest(t, s, "format=csv")
+	}()
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// deadlineRecorder records the write deadlines a handler sets
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	deadlines []time.Time
+}
+
+func (d *deadlineRecorder) SetWriteDeadline(deadline time.Time) error {
+	d.deadlines = append(d.deadlines, deadline)
+	return nil
+}
+
+func TestExportRecordsHandler_WriteDeadline(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/v1/records/export?format=jsonl", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+	before := time.Now()
+	NewServer(NewMemoryStore(testRecords...), testResolver).ExportRecordsHandler(rr, req)
+
+	// Each of the three rows and the end of the file get a fresh deadline
+	if len(rr.deadlines) != len(testRecords)+1 {
+		t.Fatalf("Handler set %d write deadlines, want %d", len(rr.deadlines), len(testRecords)+1)
+	}
+	if rr.deadlines[0].Before(before.Add(cfg.HTTP.WriteTimeout)) {
+		t.Errorf("Handler set a write deadline shorter than the write timeout: %v", rr.deadlines[0])
+	}
+}
+
+func TestFormulaSafe(t *testing.T) {
+	tests := map[string]string{
+		"=SUM(A1:A9)": "'=SUM(A1:A9)",
+		"+65 9123":    "'+65 9123",
+		"-":           "'-",
+		"@home":       "'@home",
+		"Ms Tan":      "Ms Tan",
+		"'quoted'":    "'quoted'",
+		"":            "",
+	}
+	for s, expected := range tests {
+		if got := formulaSafe(s); got != expected {
+			t.Errorf("formulaSafe(%q) = %q, want %q", s, got, expected)
+		}
+		if got := unformulaSafe(formulaSafe(s)); got != s {
+			t.Errorf("unformulaSafe(%q) = %q, want %q", formulaSafe(s), got, s)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package record
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"      //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit"    //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/validate" //change here
+)
+
+// maxImportRows bounds the rows of one import, which are all inserted in a
+// single transaction
+const maxImportRows = 1000
+
+// maxImportBytes bounds the CSV body, since a row is only counted once it has
+// been read and a field can be any size. 1000 rows of the longest valid
+// records fit with room to spare.
+const maxImportBytes = 4 << 20
+
+// importFields are the record fields a CSV column can be imported into.
+// recordId and version columns, as in an export, are skipped: an import
+// always creates new records.
+var importFields = []string{"name", "roleOfContact", "noOfStudents", "acadYr", "capstoneTitle", "companyName", "companyContact", "projDesc"}
+
+// importRow reports the outcome for one data row, by the line it starts on
+type importRow struct {
+	Line     int             `json:"line"`
+	RecordID int             `json:"recordId,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Errors   validate.Errors `json:"errors,omitempty"`
+}
+
+// importReport summarises an import. Created is 0 for a dry run and
+// whenever nothing was committed.
+type importReport struct {
+	DryRun  bool        `json:"dryRun"`
+	Atomic  bool        `json:"atomic"`
+	Total   int         `json:"total"`
+	Valid   int         `json:"valid"`
+	Invalid int         `json:"invalid"`
+	Created int         `json:"created"`
+	Rows    []importRow `json:"rows"`
+}
+
+// ImportRecordsHandler creates records from a CSV body whose first line names
+// the columns. dryRun=true only validates the rows; otherwise the valid rows
+// are inserted in one transaction, or none of them when atomic=true and any
+// row is invalid.
+func (s *Server) ImportRecordsHandler(w http.ResponseWriter, r *http.Request) {
+	var flags [2]bool
+	for i, name := range []string{"dryRun", "atomic"} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, name+" must be true or false")
+			return
+		}
+		flags[i] = b
+	}
+	report := importReport{DryRun: flags[0], Atomic: flags[1], Rows: []importRow{}}
+
+	reader := csv.NewReader(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidPayload, "CSV has no header line")
+		return
+	} else if err != nil {
+		csvError(w, r, err)
+		return
+	}
+	columns, err := importColumns(header, r.URL.Query()["map"])
+	if err != nil {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, err.Error())
+		return
+	}
+
+	var recs []Record
+	var valid []int // indexes into report.Rows of the rows in recs
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			csvError(w, r, err)
+			return
+		}
+		if blank(fields) {
+			continue
+		}
+		if report.Total++; report.Total > maxImportRows {
+			api.Error(w, r, http.StatusBadRequest, api.CodeInvalidPayload, fmt.Sprintf("CSV has more than %d rows", maxImportRows))
+			return
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := importRow{Line: line}
+		rec, errs := importRecord(columns, fields)
+		if len(fields) != len(columns) {
+			row.Error = fmt.Sprintf("has %d fields but the header has %d", len(fields), len(columns))
+		} else if errs != nil {
+			row.Error, row.Errors = errs.Error(), errs
+		}
+
+		if row.Error == "" {
+			recs = append(recs, rec)
+			valid = append(valid, len(report.Rows))
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	report.Valid = len(recs)
+	report.Invalid = report.Total - report.Valid
+
+	switch {
+	case report.Total == 0:
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidPayload, "CSV has no data rows")
+		return
+	case report.DryRun:
+		api.JSON(w, http.StatusOK, report)
+		return
+	case report.Valid == 0 || (report.Atomic && report.Invalid > 0):
+		api.ErrorDetails(w, r, http.StatusUnprocessableEntity, api.CodeValidationFailed, "No records were imported because some rows were invalid", report)
+		return
+	}
+
+	ids, err := s.store.CreateMany(recs, audit.ActorFrom(r))
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		report.Rows[valid[batchErr.Index]].Error = "could not create record"
+		api.ErrorDetails(w, r, http.StatusInternalServerError, api.CodeInternal, "No records were imported", report)
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	for i, id := range ids {
+		report.Rows[valid[i]].RecordID = id
+	}
+	report.Created = len(ids)
+	api.JSON(w, http.StatusCreated, report)
+}
+
+// csvError reports a CSV body that could not be read
+func csvError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		api.Error(w, r, http.StatusRequestEntityTooLarge, api.CodePayloadTooLarge, fmt.Sprintf("CSV is larger than %d bytes", tooLarge.Limit))
+	} else {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidPayload, err.Error())
+	}
+}
+
+// importColumns returns the record field each CSV column is imported into,
+// or "" for a column that is skipped. A column matches the field whose JSON
+// name it spells, ignoring case, spaces, underscores and hyphens; mappings of
+// the form "Column=field" override that, and "Column=-" skips the column.
+func importColumns(header, mappings []string) ([]string, error) {
+	explicit := map[string]string{}
+	for _, m := range mappings {
+		i := strings.LastIndex(m, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("map %q must be of the form column=field", m)
+		}
+		column, field := strings.TrimSpace(m[:i]), strings.TrimSpace(m[i+1:])
+		if field != "-" && !contains(importFields, field) {
+			return nil, fmt.Errorf("map %q names %q, which is not a field that can be imported", m, field)
+		}
+		explicit[column] = field
+	}
+
+	columns := make([]string, len(header))
+	var unknown []string
+	for i, name := range header {
+		// Spreadsheet programs often start their CSV with a byte order mark
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.TrimSpace(name)
+
+		if field, ok := explicit[name]; ok {
+			if field != "-" {
+				columns[i] = field
+			}
+			delete(explicit, name)
+			continue
+		}
+		switch key := columnKey(name); key {
+		case "recordid", "version":
+			continue
+		default:
+			for _, field := range importFields {
+				if columnKey(field) == key {
+					columns[i] = field
+				}
+			}
+		}
+		if columns[i] == "" {
+			unknown = append(unknown, name)
+		}
+	}
+
+	if len(explicit) > 0 {
+		for _, m := range mappings {
+			if column := strings.TrimSpace(m[:strings.LastIndex(m, "=")]); explicit[column] != "" {
+				return nil, fmt.Errorf("map names column %q, which is not in the header", column)
+			}
+		}
+	}
+	if unknown != nil {
+		return nil, fmt.Errorf("columns %q do not match a record field; map them with map=column=field or skip them with map=column=-", unknown)
+	}
+	for i, field := range columns {
+		for _, other := range columns[i+1:] {
+			if field != "" && field == other {
+				return nil, fmt.Errorf("more than one column is imported into %s", field)
+			}
+		}
+	}
+	return columns, nil
+}
+
+// columnKey reduces a column or field name to its letters and digits, in lower case
+func columnKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// importRecord builds a record from one CSV row and validates it
+func importRecord(columns, fields []string) (Record, validate.Errors) {
+	var rec Record
+	var errs validate.Errors
+	unreadable := false
+	for i, field := range columns {
+		if i >= len(fields) {
+			break
+		}
+		v := unformulaSafe(strings.TrimSpace(fields[i]))
+		switch field {
+		case "name":
+			rec.Name = v
+		case "roleOfContact":
+			rec.RoleOfContact = v
+		case "noOfStudents":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, validate.FieldError{Field: field, Message: "must be a whole number"})
+				unreadable = true
+			}
+			rec.NoOfStudents = n
+		case "acadYr":
+			rec.AcadYr = v
+		case "capstoneTitle":
+			rec.CapstoneTitle = v
+		case "companyName":
+			rec.CompanyName = v
+		case "companyContact":
+			rec.CompanyContact = v
+		case "projDesc":
+			rec.ProjDesc = v
+		}
+	}
+
+	// A number that could not be read is not also reported as out of range
+	for _, e := range validate.Struct(rec) {
+		if !unreadable || e.Field != "noOfStudents" {
+			errs = append(errs, e)
+		}
+	}
+	return rec, errs
+}
+
+// blank reports whether every field of a row is empty, as in the trailing
+// rows some spreadsheet programs export
+func blank(fields []string) bool {
+	for _, f := range fields {
+		if strings.TrimSpace(f) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,288 @@
+// import_test.go
+package record
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"      //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit"    //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/validate" //change here
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// importCSV has a column that needs mapping, one that is skipped, a row with
+// a multi-line description and an invalid row
+const importCSV = "\ufeffName,Role of Contact,No of Students,Acad Yr,Capstone Title,Company,Company Contact,Proj Desc,Notes\n" +
+	"Ann,Staff,4,2022/2023,Robots,Acme,Ms Tan,Build robots,first\n" +
+	"Bob,Student,many,2022/2023,Drones,Acme,Mr Lim,Fly drones,\n" +
+	"\n" +
+	"Cat,Student,2,2023/2024,Kiosk,Shop,Mr Ong,\"Self-service\nkiosk\",\n" +
+	",,,,,,,,\n"
+
+var importMapping = url.Values{"map": {"Company=companyName", "Notes=-"}}
+
+var importedRecords = []Record{
+	{1, "Ann", "Staff", 4, "2022/2023", "Robots", "Acme", "Ms Tan", "Build robots", 1, 1, 1},
+	{2, "Cat", "Student", 2, "2023/2024", "Kiosk", "Shop", "Mr Ong", "Self-service\nkiosk", 1, 2, 2},
+}
+
+const insertRecord = "INSERT INTO Record (Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, CompanyID, ContactID) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+func importRequest(t *testing.T, params url.Values, body string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest("POST", "/api/v1/records/import?"+params.Encode(), strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "text/csv")
+	return req
+}
+
+func withParams(params url.Values, extra ...string) url.Values {
+	out := url.Values{}
+	for k, v := range params {
+		out[k] = v
+	}
+	for i := 0; i < len(extra); i += 2 {
+		out.Set(extra[i], extra[i+1])
+	}
+	return out
+}
+
+func TestImportRecordsHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			insert := mock.ExpectPrepare(regexp.QuoteMeta(insertRecord))
+			for _, rec := range importedRecords {
+				expectNewReferences(mock, rec)
+				insert.ExpectExec().
+					WithArgs(rec.Name, rec.RoleOfContact, rec.NoOfStudents, rec.AcadYr, rec.CapstoneTitle, rec.CompanyName, rec.CompanyContact, rec.ProjDesc, rec.CompanyID, rec.ContactID).
+					WillReturnResult(sqlmock.NewResult(int64(rec.RecordID), 1))
+				expectRevision(mock, rec)
+				expectAudit(mock, audit.ActionCreate, rec.RecordID)
+			}
+			mock.ExpectCommit()
+		})
+
+		rr := httptest.NewRecorder()
+		f.server().ImportRecordsHandler(rr, importRequest(t, importMapping, importCSV))
+
+		if status := rr.Code; status != http.StatusCreated {
+			t.Fatalf("Handler returned wrong status code: got %v want %v: %s", status, http.StatusCreated, rr.Body.String())
+		}
+
+		var report importReport
+		if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+		expected := importReport{Total: 3, Valid: 2, Invalid: 1, Created: 2, Rows: []importRow{
+			{Line: 2, RecordID: 1},
+			{Line: 3, Error: "noOfStudents must be a whole number", Errors: validate.Errors{{Field: "noOfStudents", Message: "must be a whole number"}}},
+			{Line: 5, RecordID: 2},
+		}}
+		if !reflect.DeepEqual(report, expected) {
+			t.Errorf("Handler returned unexpected report:\n got %+v\nwant %+v", report, expected)
+		}
+
+		for _, rec := range importedRecords {
+			if stored, ok := f.stored(rec.RecordID); f.memory != nil && (!ok || stored != rec) {
+				t.Errorf("Record %d was not stored as imported: %+v", rec.RecordID, stored)
+			}
+		}
+	})
+}
+
+func TestImportRecordsHandler_DryRun(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		rr := httptest.NewRecorder()
+		f.server().ImportRecordsHandler(rr, importRequest(t, withParams(importMapping, "dryRun", "true"), importCSV))
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("Handler returned wrong status code: got %v want %v: %s", status, http.StatusOK, rr.Body.String())
+		}
+
+		var report importReport
+		if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+		if !report.DryRun || report.Valid != 2 || report.Invalid != 1 || report.Created != 0 {
+			t.Errorf("Handler returned unexpected report: %+v", report)
+		}
+		for _, row := range report.Rows {
+			if row.RecordID != 0 {
+				t.Errorf("Dry run created a record for line %d", row.Line)
+			}
+		}
+		if _, ok := f.stored(1); ok {
+			t.Errorf("Dry run stored a record")
+		}
+	})
+}
+
+func TestImportRecordsHandler_Atomic(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		rr := httptest.NewRecorder()
+		f.server().ImportRecordsHandler(rr, importRequest(t, withParams(importMapping, "atomic", "true"), importCSV))
+
+		if status := rr.Code; status != http.StatusUnprocessableEntity {
+			t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
+		}
+		body := expectErrorCode(t, rr, api.CodeValidationFailed)
+		details, _ := json.Marshal(body.Details)
+		var report importReport
+		if err := json.Unmarshal(details, &report); err != nil {
+			t.Fatal(err)
+		}
+		if report.Invalid != 1 || report.Created != 0 || report.Rows[1].Line != 3 {
+			t.Errorf("Handler returned unexpected report: %+v", report)
+		}
+		if _, ok := f.stored(1); ok {
+			t.Errorf("Atomic import stored a record despite an invalid row")
+		}
+	})
+}
+
+func TestImportRecordsHandler_Rejected(t *testing.T) {
+	const header = "name,roleOfContact,noOfStudents,acadYr,capstoneTitle,companyName,companyContact,projDesc\n"
+	const row = "Ann,Staff,4,2022/2023,Robots,Acme,Ms Tan,Build robots\n"
+
+	tests := []struct {
+		name   string
+		params url.Values
+		body   string
+		code   string
+	}{
+		{"bad flag", url.Values{"dryRun": {"maybe"}}, header + row, api.CodeInvalidParameter},
+		{"empty body", nil, "", api.CodeInvalidPayload},
+		{"no data rows", nil, header, api.CodeInvalidPayload},
+		{"bad quoting", nil, header + "Ann,\"Staff,4\n", api.CodeInvalidPayload},
+		{"unknown column", nil, "colour," + header, api.CodeInvalidParameter},
+		{"unknown field", url.Values{"map": {"name=title"}}, header + row, api.CodeInvalidParameter},
+		{"malformed map", url.Values{"map": {"name"}}, header + row, api.CodeInvalidParameter},
+		{"column not in header", url.Values{"map": {"Title=capstoneTitle"}}, header + row, api.CodeInvalidParameter},
+		{"duplicate field", url.Values{"map": {"companyContact=companyName"}}, header + row, api.CodeInvalidParameter},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		NewServer(NewMemoryStore(), testResolver).ImportRecordsHandler(rr, importRequest(t, tt.params, tt.body))
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("%s: Handler returned wrong status code: got %v want %v", tt.name, status, http.StatusBadRequest)
+		}
+		expectErrorCode(t, rr, tt.code)
+	}
+}
+
+func TestImportRecordsHandler_TooLarge(t *testing.T) {
+	// A single oversized field is refused before the row is ever counted
+	body := "name,projDesc\nAnn," + strings.Repeat("x", maxImportBytes) + "\n"
+
+	rr := httptest.NewRecorder()
+	NewServer(NewMemoryStore(), testResolver).ImportRecordsHandler(rr, importRequest(t, url.Values{"dryRun": {"true"}}, body))
+
+	if status := rr.Code; status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusRequestEntityTooLarge)
+	}
+	expectErrorCode(t, rr, api.CodePayloadTooLarge)
+}
+
+func TestImportRecordsHandler_FieldCount(t *testing.T) {
+	body := "name,roleOfContact,noOfStudents,acadYr,capstoneTitle,companyName,companyContact,projDesc\n" +
+		"Ann,Staff,4,2022/2023\n"
+
+	rr := httptest.NewRecorder()
+	NewServer(NewMemoryStore(), testResolver).ImportRecordsHandler(rr, importRequest(t, url.Values{"dryRun": {"1"}}, body))
+
+	var report importReport
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Rows) != 1 || report.Rows[0].Error != "has 4 fields but the header has 8" {
+		t.Errorf("Handler returned unexpected report: %+v", report)
+	}
+}
+
+func TestImportRecordsHandler_FieldErrors(t *testing.T) {
+	// Every bad field of a line is reported, but a number that could not be
+	// read is not also reported as out of range
+	body := "name,roleOfContact,noOfStudents,acadYr,capstoneTitle,companyName,companyContact,projDesc\n" +
+		",Manager,0,2022/2023,Robots,Acme,Ms Tan,Build robots\n" +
+		",Staff,four,2022/2023,Robots,Acme,Ms Tan,Build robots\n"
+
+	rr := httptest.NewRecorder()
+	NewServer(NewMemoryStore(), testResolver).ImportRecordsHandler(rr, importRequest(t, url.Values{"dryRun": {"1"}}, body))
+
+	var report importReport
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Rows) != 2 {
+		t.Fatalf("Handler returned unexpected report: %+v", report)
+	}
+
+	expected := [][]string{{"name", "roleOfContact", "noOfStudents"}, {"noOfStudents", "name"}}
+	for i, row := range report.Rows {
+		var fields []string
+		for _, e := range row.Errors {
+			fields = append(fields, e.Field)
+		}
+		if !reflect.DeepEqual(fields, expected[i]) {
+			t.Errorf("Line %d reported errors for %v, want %v: %+v", row.Line, fields, expected[i], row.Errors)
+		}
+	}
+	for _, e := range report.Rows[1].Errors {
+		if e.Field == "noOfStudents" && e.Message != "must be a whole number" {
+			t.Errorf("Line %d reported %q for the unreadable number", report.Rows[1].Line, e.Message)
+		}
+	}
+}
+
+func TestImportRecordsHandler_Exec(t *testing.T) {
+	s, mock := mysqlServer(t)
+
+	rec := importedRecords[0]
+	mock.ExpectBegin()
+	insert := mock.ExpectPrepare(regexp.QuoteMeta(insertRecord))
+	expectNewReferences(mock, rec)
+	insert.ExpectExec().
+		WithArgs(rec.Name, rec.RoleOfContact, rec.NoOfStudents, rec.AcadYr, rec.CapstoneTitle, rec.CompanyName, rec.CompanyContact, rec.ProjDesc, rec.CompanyID, rec.ContactID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRevision(mock, rec)
+	expectAudit(mock, audit.ActionCreate, 1)
+	expectNewReferences(mock, importedRecords[1])
+	insert.ExpectExec().
+		WillReturnError(errors.New("sql: execution failed"))
+	mock.ExpectRollback()
+
+	rr := httptest.NewRecorder()
+	s.ImportRecordsHandler(rr, importRequest(t, importMapping, importCSV))
+
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+	}
+	body := expectErrorCode(t, rr, api.CodeInternal)
+	details, _ := json.Marshal(body.Details)
+	var report importReport
+	if err := json.Unmarshal(details, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 0 || report.Rows[0].RecordID != 0 || report.Rows[2].Error != "could not create record" {
+		t.Errorf("Handler returned unexpected report: %+v", report)
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
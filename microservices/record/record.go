@@ -1,222 +1,523 @@
 package record
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
 	"strconv"
 
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"        //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit"      //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/auth"       //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/config"     //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/middleware" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/patch"      //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/query"      //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/service"    //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/validate"   //change here
+
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
 )
 
+// Record fields are limited to what the Record table can hold. Version counts
+// the changes to a record, starting at 1, and is sent as its ETag; clients
+// cannot set it. A record refers to its company and contact by ID, and
+// carries their names as well so older clients can keep sending and reading
+// them; see resolveReferences.
 type Record struct {
 	RecordID       int    `json:"recordId"`
-	Name           string `json:"name"`
-	RoleOfContact  string `json:"roleOfContact"`
-	NoOfStudents   int    `json:"noOfStudents"`
-	AcadYr         string `json:"acadYr"`
-	CapstoneTitle  string `json:"capstoneTitle"`
-	CompanyName    string `json:"companyName"`
-	CompanyContact string `json:"companyContact"`
-	ProjDesc       string `json:"projDesc"`
-}
-
-var (
-	db  *sql.DB
-	err error
-)
+	Name           string `json:"name" validate:"required,max=50"`
+	RoleOfContact  string `json:"roleOfContact" validate:"required,oneof=Staff Student"`
+	NoOfStudents   int    `json:"noOfStudents" validate:"min=1"`
+	AcadYr         string `json:"acadYr" validate:"required,max=10,acadyr"`
+	CapstoneTitle  string `json:"capstoneTitle" validate:"required,max=50"`
+	CompanyName    string `json:"companyName" validate:"required,max=50"`
+	CompanyContact string `json:"companyContact" validate:"required,max=50"`
+	ProjDesc       string `json:"projDesc" validate:"required,max=1000"`
+	Version        int    `json:"version,omitempty"`
+	CompanyID      int    `json:"companyId,omitempty"`
+	ContactID      int    `json:"contactId,omitempty"`
+}
 
-func SetDB(database *sql.DB) {
-	db = database
+var cfg = config.Default()
+
+func SetConfig(c config.Config) {
+	cfg = c
 }
 
-func DB() {
-	db, err = sql.Open("mysql", "record_system:dopasgpwd@tcp(127.0.0.1:3306)/record_db")
+// DB opens the database configured for the service
+func DB() (*sql.DB, error) {
+	db, err := sql.Open("mysql", cfg.Database.DSN)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	cfg.Database.ApplyPool(db)
 
 	if err := db.Ping(); err != nil {
-		log.Fatal(err)
+		db.Close()
+		return nil, err
 	}
 
 	fmt.Println("Connected to the database")
+	return db, nil
+}
+
+// Server serves the record API from a RecordStore
+type Server struct {
+	store   RecordStore
+	resolve middleware.Resolver
+}
+
+// NewServer returns a server that checks callers with resolve
+func NewServer(store RecordStore, resolve middleware.Resolver) *Server {
+	return &Server{store: store, resolve: resolve}
+}
+
+// Run serves the record API until ctx is cancelled, then drains in-flight
+// requests and closes the database pool
+func Run(ctx context.Context) error {
+	db, err := DB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	server := &http.Server{
+		Addr:         cfg.Record.Addr(),
+		Handler:      service.CORS(cfg.HTTP)(api.RequestID(newRouter(db))),
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+	}
+
+	fmt.Println("Listening at port", cfg.Record.Port)
+	return service.Serve(ctx, server, cfg.HTTP.ShutdownTimeout)
 }
 
-func InitHTTPServer() {
-	DB()
+// newRouter serves the record API from db. Logouts and refreshes on the
+// account service are shared through the database, so their tokens are
+// refused here too
+func newRouter(db *sql.DB) *mux.Router {
+	auth.SetRevocationStore(auth.NewSQLRevocationStore(db))
+	return NewServer(NewMySQLStore(db), middleware.SQLResolver(db)).Router()
+}
 
+// access policy for every record route
+var routePolicies = middleware.Policies{
+	"GET /healthz":                                                  middleware.Public,
+	"GET /readyz":                                                   middleware.Public,
+	"GET /api/v1/records/all":                                       middleware.Authenticated,
+	"GET /api/v1/records/export":                                    middleware.Authenticated,
+	"POST /api/v1/records":                                          middleware.CreatedOnly,
+	"POST /api/v1/records/import":                                   middleware.AdminOnly,
+	"GET /api/v1/records/report":                                    middleware.Authenticated,
+	"DELETE /api/v1/records/delete":                                 middleware.AdminOnly,
+	"DELETE /api/v1/records/{recordID}":                             middleware.AdminOnly,
+	"GET /api/v1/records/{recordID}":                                middleware.Authenticated,
+	"PUT /api/v1/records/{recordID}":                                middleware.CreatedOnly,
+	"PATCH /api/v1/records/{recordID}":                              middleware.CreatedOnly,
+	"GET /api/v1/records/search":                                    middleware.Authenticated,
+	"GET /api/v1/records/trash":                                     middleware.AdminOnly,
+	"POST /api/v1/records/trash/{recordID}/restore":                 middleware.AdminOnly,
+	"GET /api/v1/records/{recordID}/revisions":                      middleware.Authenticated,
+	"GET /api/v1/records/{recordID}/revisions/diff":                 middleware.Authenticated,
+	"POST /api/v1/records/{recordID}/revisions/{revision}/rollback": middleware.CreatedOnly,
+	"GET /api/v1/companies":                                         middleware.Authenticated,
+	"POST /api/v1/companies":                                        middleware.CreatedOnly,
+	"GET /api/v1/companies/{companyID}":                             middleware.Authenticated,
+	"PUT /api/v1/companies/{companyID}":                             middleware.AdminOnly,
+	"DELETE /api/v1/companies/{companyID}":                          middleware.AdminOnly,
+	"GET /api/v1/companies/{companyID}/contacts":                    middleware.Authenticated,
+	"POST /api/v1/companies/{companyID}/contacts":                   middleware.CreatedOnly,
+	"GET /api/v1/contacts/{contactID}":                              middleware.Authenticated,
+	"PUT /api/v1/contacts/{contactID}":                              middleware.AdminOnly,
+	"DELETE /api/v1/contacts/{contactID}":                           middleware.AdminOnly,
+}
+
+// Router returns the record routes behind the authorization middleware
+func (s *Server) Router() *mux.Router {
 	router := mux.NewRouter()
-	router.Use(corsMiddleware)
+	router.NotFoundHandler = http.HandlerFunc(api.NotFound)
+	router.MethodNotAllowedHandler = http.HandlerFunc(api.MethodNotAllowed)
+	router.Use(middleware.Authorize(routePolicies, s.resolve))
+
+	router.HandleFunc("/healthz", service.Healthz).Methods("GET")
+	router.HandleFunc("/readyz", service.Readyz(s.store, cfg.HTTP.ReadyTimeout)).Methods("GET")
+
+	router.HandleFunc("/api/v1/records/all", s.ListAllRecordsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/records/export", s.ExportRecordsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/records", s.CreateRecordHandler).Methods("POST")
+	router.HandleFunc("/api/v1/records/import", s.ImportRecordsHandler).Methods("POST")
+	router.HandleFunc("/api/v1/records/report", s.ReportHandler).Methods("GET")
+	router.HandleFunc("/api/v1/records/delete", s.DeleteRecordHandler).Methods("DELETE")
+	router.HandleFunc("/api/v1/records/{recordID}", s.UpdateRecordHandler).Methods("PUT")
+	router.HandleFunc("/api/v1/records/{recordID}", s.PatchRecordHandler).Methods("PATCH")
+	router.HandleFunc("/api/v1/records/search", s.QueryRecordHandler).Methods("GET")
+	router.HandleFunc("/api/v1/records/trash", s.ListTrashHandler).Methods("GET")
+	router.HandleFunc("/api/v1/records/trash/{recordID}/restore", s.RestoreRecordHandler).Methods("POST")
+	router.HandleFunc("/api/v1/records/{recordID}/revisions", s.ListRevisionsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/records/{recordID}/revisions/diff", s.DiffRevisionsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/records/{recordID}/revisions/{revision}/rollback", s.RollbackRecordHandler).Methods("POST")
+	// After the fixed paths so they do not shadow them
+	router.HandleFunc("/api/v1/records/{recordID}", s.GetRecordHandler).Methods("GET")
+	router.HandleFunc("/api/v1/records/{recordID}", s.DeleteRecordHandler).Methods("DELETE")
+
+	router.HandleFunc("/api/v1/companies", s.ListCompaniesHandler).Methods("GET")
+	router.HandleFunc("/api/v1/companies", s.CreateCompanyHandler).Methods("POST")
+	router.HandleFunc("/api/v1/companies/{companyID}", s.GetCompanyHandler).Methods("GET")
+	router.HandleFunc("/api/v1/companies/{companyID}", s.UpdateCompanyHandler).Methods("PUT")
+	router.HandleFunc("/api/v1/companies/{companyID}", s.DeleteCompanyHandler).Methods("DELETE")
+	router.HandleFunc("/api/v1/companies/{companyID}/contacts", s.ListContactsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/companies/{companyID}/contacts", s.CreateContactHandler).Methods("POST")
+	router.HandleFunc("/api/v1/contacts/{contactID}", s.GetContactHandler).Methods("GET")
+	router.HandleFunc("/api/v1/contacts/{contactID}", s.UpdateContactHandler).Methods("PUT")
+	router.HandleFunc("/api/v1/contacts/{contactID}", s.DeleteContactHandler).Methods("DELETE")
+
+	return router
+}
 
-	router.HandleFunc("/api/v1/records/all", ListAllRecordsHandler).Methods("GET")
-	router.HandleFunc("/api/v1/records", CreateRecordHandler).Methods("POST")
-	router.HandleFunc("/api/v1/records/delete", DeleteRecordHandler).Methods("DELETE")
-	router.HandleFunc("/api/v1/records/{recordID}", UpdateRecordHandler).Methods("PUT")
-	router.HandleFunc("/api/v1/records/search", QueryRecordHandler).Methods("GET")
+// list options accepted by ListAllRecordsHandler
+var recordListSpec = query.Spec{
+	Table:   "Record",
+	Columns: []string{"RecordID", "Name", "RoleOfContact", "NoOfStudents", "AcadYr", "CapstoneTitle", "CompanyName", "CompanyContact", "ProjDesc", "Version", "CompanyID", "ContactID"},
+	Where:   "DeletedAt IS NULL",
+	Key:     "RecordID",
+	Sortable: map[string]string{
+		"recordId":      "RecordID",
+		"name":          "Name",
+		"noOfStudents":  "NoOfStudents",
+		"acadYr":        "AcadYr",
+		"capstoneTitle": "CapstoneTitle",
+		"companyName":   "CompanyName",
+	},
+	Filterable: map[string]string{
+		"acadYr":         "AcadYr",
+		"roleOfContact":  "RoleOfContact",
+		"companyName":    "CompanyName",
+		"companyContact": "CompanyContact",
+		"name":           "Name",
+		"companyId":      "CompanyID",
+		"contactId":      "ContactID",
+	},
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+func scanRecord(rows *sql.Rows) (Record, error) {
+	var record Record
+	err := rows.Scan(recordFields(&record)...)
+	return record, err
+}
 
-	fmt.Println("Listening at port 5002")
-	go func() {
-		log.Fatal(http.ListenAndServe(":5002", router))
-	}()
+// recordFields points at the fields of a record in the order of
+// recordListSpec.Columns, for scanning
+func recordFields(record *Record) []interface{} {
+	return []interface{}{&record.RecordID, &record.Name, &record.RoleOfContact, &record.NoOfStudents, &record.AcadYr, &record.CapstoneTitle, &record.CompanyName, &record.CompanyContact, &record.ProjDesc, &record.Version, &record.CompanyID, &record.ContactID}
 }
 
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, PUT, PATCH, GET, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Origin, X-Api-Key, X-Requested-With, Content-Type, Accept, Authorization")
-		next.ServeHTTP(w, r)
-	})
+// recordValue returns the value of a column for building cursors and filtering
+func recordValue(record Record, column string) interface{} {
+	switch column {
+	case "RecordID":
+		return record.RecordID
+	case "Name":
+		return record.Name
+	case "RoleOfContact":
+		return record.RoleOfContact
+	case "NoOfStudents":
+		return record.NoOfStudents
+	case "AcadYr":
+		return record.AcadYr
+	case "CapstoneTitle":
+		return record.CapstoneTitle
+	case "CompanyName":
+		return record.CompanyName
+	case "CompanyContact":
+		return record.CompanyContact
+	case "ProjDesc":
+		return record.ProjDesc
+	case "Version":
+		return record.Version
+	case "CompanyID":
+		return record.CompanyID
+	case "ContactID":
+		return record.ContactID
+	}
+	return nil
 }
 
-// gets and lists all capstone records
-func ListAllRecordsHandler(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc FROM Record")
+// gets and lists capstone records a page at a time
+func (s *Server) ListAllRecordsHandler(w http.ResponseWriter, r *http.Request) {
+	params, err := recordListSpec.Parse(r.URL.Query())
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, err.Error())
 		return
 	}
-	defer rows.Close()
 
-	var records []Record
-	for rows.Next() {
-		var record Record
-		err := rows.Scan(&record.RecordID, &record.Name, &record.RoleOfContact, &record.NoOfStudents, &record.AcadYr, &record.CapstoneTitle, &record.CompanyName, &record.CompanyContact, &record.ProjDesc)
-		if err != nil {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		records = append(records, record)
+	page, err := s.store.List(params)
+	if err != nil {
+		api.Internal(w, r)
+		return
 	}
 
-	// Respond with the list of records
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(records)
+	// Respond with the page of records
+	api.JSON(w, http.StatusOK, page)
 }
 
 // create a capstone record
-func CreateRecordHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateRecordHandler(w http.ResponseWriter, r *http.Request) {
 	var newRecord Record
 	err := json.NewDecoder(r.Body).Decode(&newRecord)
 	if err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		api.InvalidPayload(w, r)
 		return
 	}
 
-	// Insert the new record into the database
-	stmt, err := db.Prepare("INSERT INTO Record (Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
-	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	// The company and contact may be given by ID instead of by name
+	if err := s.namesFromIDs(&newRecord); err != nil {
+		referenceError(w, r, err)
+		return
+	}
+	if errs := validate.Struct(newRecord); errs != nil {
+		api.Invalid(w, r, errs)
 		return
 	}
-	defer stmt.Close()
 
-	_, err = stmt.Exec(newRecord.Name, newRecord.RoleOfContact, newRecord.NoOfStudents, newRecord.AcadYr, newRecord.CapstoneTitle, newRecord.CompanyName, newRecord.CompanyContact, newRecord.ProjDesc)
-	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	// Insert the new record into the store, which fills in its ID, version
+	// and references
+	newRecord.Version = 0
+	created := []Record{newRecord}
+	if _, err := s.store.CreateMany(created, audit.ActorFrom(r)); err != nil {
+		var batchErr *BatchError
+		if errors.As(err, &batchErr) {
+			err = batchErr.Err
+		}
+		referenceError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	fmt.Fprintln(w, "Record created successfully")
+	newRecord = created[0]
+	api.SetETag(w, newRecord.Version)
+	api.Created(w, fmt.Sprintf("/api/v1/records/%d", newRecord.RecordID), newRecord)
 }
 
-func DeleteRecordHandler(w http.ResponseWriter, r *http.Request) {
-	// Parse the record ID from the request parameters
-	recordID := r.URL.Query().Get("recordID")
-	if recordID == "" {
-		http.Error(w, "Record ID parameter is required", http.StatusBadRequest)
-		return
+// recordIDParam reads the record ID from the path, or from the recordID query
+// parameter of the older routes, writing a 400 response when it is missing
+// or not a number
+func recordIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	param, ok := mux.Vars(r)["recordID"]
+	if !ok {
+		param = r.URL.Query().Get("recordID")
 	}
-
-	// Delete the record from the database
-	stmt, err := db.Prepare("DELETE FROM Record WHERE RecordID = ?")
+	if param == "" {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Record ID parameter is required")
+		return 0, false
+	}
+	recordID, err := strconv.Atoi(param)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid record ID")
+		return 0, false
+	}
+	return recordID, true
+}
+
+func (s *Server) DeleteRecordHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the record ID from the request parameters
+	recordID, ok := recordIDParam(w, r)
+	if !ok {
 		return
 	}
-	defer stmt.Close()
 
-	_, err = stmt.Exec(recordID)
-	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	// Delete the record from the store
+	err := s.store.Delete(recordID, audit.ActorFrom(r))
+	if err == ErrNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Record not found")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintln(w, "Record deleted successfully")
+	api.Message(w, http.StatusOK, "Record deleted successfully")
 }
 
-func UpdateRecordHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) UpdateRecordHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse the record ID from the request URL
 	vars := mux.Vars(r)
 	recordID, err := strconv.Atoi(vars["recordID"])
 	if err != nil {
-		http.Error(w, "Invalid record ID", http.StatusBadRequest)
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid record ID")
 		return
 	}
 
 	var updatedRecord Record
 	err = json.NewDecoder(r.Body).Decode(&updatedRecord)
 	if err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		api.InvalidPayload(w, r)
+		return
+	}
+
+	if err := s.namesFromIDs(&updatedRecord); err != nil {
+		referenceError(w, r, err)
+		return
+	}
+	if errs := validate.Struct(updatedRecord); errs != nil {
+		api.Invalid(w, r, errs)
+		return
+	}
+
+	// Only the version the client last read may be replaced
+	version, ok := api.IfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	// Update the record's information in the store
+	updatedRecord.RecordID = recordID
+	updatedRecord.Version = version
+	err = s.store.Update(updatedRecord, audit.ActorFrom(r))
+	if err == ErrNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Record not found")
+		return
+	} else if err == ErrVersionConflict {
+		api.PreconditionFailed(w, r, "Record was changed by someone else; reload it and try again")
+		return
+	} else if err != nil {
+		referenceError(w, r, err)
 		return
 	}
 
-	// Update the record's information in the database
-	stmt, err := db.Prepare("UPDATE Record SET Name=?, RoleOfContact=?, NoOfStudents=?, AcadYr=?, CapstoneTitle=?, CompanyName=?, CompanyContact=?, ProjDesc=? WHERE RecordID=?")
+	if version > 0 {
+		api.SetETag(w, version+1)
+	}
+	api.Message(w, http.StatusAccepted, "Record updated successfully!")
+}
+
+// changes the fields a JSON merge patch names and responds with the record
+func (s *Server) PatchRecordHandler(w http.ResponseWriter, r *http.Request) {
+	recordID, err := strconv.Atoi(mux.Vars(r)["recordID"])
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid record ID")
 		return
 	}
-	defer stmt.Close()
 
-	_, err = stmt.Exec(updatedRecord.Name, updatedRecord.RoleOfContact, updatedRecord.NoOfStudents, updatedRecord.AcadYr, updatedRecord.CapstoneTitle, updatedRecord.CompanyName, updatedRecord.CompanyContact, updatedRecord.ProjDesc, recordID)
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		api.InvalidPayload(w, r)
 		return
 	}
 
-	w.WriteHeader(http.StatusAccepted)
-	fmt.Fprintln(w, "Record updated successfully!")
-}
+	current, err := s.store.Get(recordID)
+	if err == ErrNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Record not found")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	// Apply the patch and check only the fields it supplies
+	patched := current
+	fields, err := patch.Apply(&patched, body, "recordId", "version")
+	if errs, ok := err.(validate.Errors); ok {
+		api.Invalid(w, r, errs)
+		return
+	} else if err != nil {
+		api.InvalidPayload(w, r)
+		return
+	}
+	if errs := validate.Fields(patched, fields...); errs != nil {
+		api.Invalid(w, r, errs)
+		return
+	}
+
+	// If-Match is optional, as the patch leaves the other fields alone
+	if version := api.MatchVersion(r); version != 0 && version != current.Version {
+		api.PreconditionFailed(w, r, "Record was changed by someone else; reload it and try again")
+		return
+	}
+
+	// Write only the columns that changed; an empty patch writes nothing
+	if columns := patch.Changed(current, patched); columns != nil {
+		err = s.store.Patch(patched, columns, audit.ActorFrom(r))
+		if err == ErrNotFound {
+			api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Record not found")
+			return
+		} else if err == ErrVersionConflict {
+			api.PreconditionFailed(w, r, "Record was changed by someone else; reload it and try again")
+			return
+		} else if err != nil {
+			referenceError(w, r, err)
+			return
+		}
+		patched.Version++
+
+		// The store settled the company and contact, so respond with its copy
+		if changesReferences(columns) {
+			if patched, err = s.store.Get(recordID); err != nil {
+				api.Internal(w, r)
+				return
+			}
+		}
+	}
 
-func QueryRecordHandler(w http.ResponseWriter, r *http.Request) {
-	// Parse the capstoneTitle from the query parameters
-	query := r.URL.Query().Get("query")
+	api.SetETag(w, patched.Version)
+	api.JSON(w, http.StatusOK, patched)
+}
 
-	// Query the database to search for trips based on the acadYr
-	rows, err := db.Query("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc FROM Record WHERE AcadYr LIKE ? OR CapstoneTitle LIKE ?", "%"+query+"%", "%"+query+"%")
+// gets one capstone record with its version as the ETag
+func (s *Server) GetRecordHandler(w http.ResponseWriter, r *http.Request) {
+	recordID, err := strconv.Atoi(mux.Vars(r)["recordID"])
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid record ID")
 		return
 	}
-	defer rows.Close()
 
-	// Create a slice to store the search results
-	var searchResults []Record
+	rec, err := s.store.Get(recordID)
+	if err == ErrNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Record not found")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
 
-	// Iterate through the rows and populate the search results slice
-	for rows.Next() {
-		var record Record
-		if err := rows.Scan(&record.RecordID, &record.Name, &record.RoleOfContact, &record.NoOfStudents, &record.AcadYr, &record.CapstoneTitle, &record.CompanyName, &record.CompanyContact, &record.ProjDesc); err != nil {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+	api.SetETag(w, rec.Version)
+	api.JSON(w, http.StatusOK, rec)
+}
+
+// searches capstone records by keyword and field, best matches first
+func (s *Server) QueryRecordHandler(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	// Parse the search string from the query parameters
+	q := values.Get("q")
+	if q == "" {
+		q = values.Get("query")
+	}
+
+	limit := defaultSearchLimit
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "limit must be a positive integer")
 			return
 		}
-		searchResults = append(searchResults, record)
+		limit = min(n, maxSearchLimit)
 	}
 
-	// Check for errors during row iteration
-	if err := rows.Err(); err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	search := ParseSearch(q)
+	results, err := s.store.Search(search, limit)
+	if err != nil {
+		api.Internal(w, r)
 		return
 	}
+	highlight(results, search)
 
 	// Encode the search results as JSON and send the response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(searchResults)
+	api.JSON(w, http.StatusOK, results)
 }
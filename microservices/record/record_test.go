@@ -4,6 +4,7 @@ package record
 import (
 	//change here
 
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -12,90 +13,151 @@ import (
 	"strings"
 	"testing"
 
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"   //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/auth"  //change here
+
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
 )
 
 func TestDeleteRecordHandler(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		// Set up expectations for the Prepare call
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectLock(mock, testRecords[2])
+			mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Record SET DeletedAt = UTC_TIMESTAMP(), DeletedBy = ? WHERE RecordID = ?")).
+				ExpectExec().
+				WithArgs(nil, 3).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectAudit(mock, audit.ActionDelete, 3)
+			mock.ExpectCommit()
+		})
+
+		// recordID follows existing record for deletion with recordID=4 in record_db for testing deletion
+		recordID := "3"
+
+		req, err := http.NewRequest("DELETE", fmt.Sprintf("/api/v1/records/delete?recordID=%s", recordID), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
 
-	SetDB(db)
+		rr := httptest.NewRecorder()
 
-	// Set up expectations for the Prepare call
-	mock.ExpectPrepare("DELETE FROM Record WHERE RecordID = ?")
+		f.server().DeleteRecordHandler(rr, req)
 
-	mock.ExpectExec("DELETE FROM Record WHERE RecordID = ?").WithArgs(sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
 
-	// recordID follows existing record for deletion with recordID=4 in record_db for testing deletion
-	recordID := "3"
+		// Check the response body
+		expected := `{"message":"Record deleted successfully"}` + "\n"
+		if rr.Body.String() != expected {
+			t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+		}
 
-	req, err := http.NewRequest("DELETE", fmt.Sprintf("/api/v1/records/delete?recordID=%s", recordID), nil)
-	if err != nil {
-		t.Fatal(err)
-	}
+		if _, ok := f.stored(3); ok {
+			t.Errorf("Record 3 is still stored after deletion")
+		}
+	})
+}
 
-	rr := httptest.NewRecorder()
+func TestDeleteRecordHandler_Path(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
 
-	DeleteRecordHandler(rr, req)
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectLock(mock, testRecords[1])
+			mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Record SET DeletedAt = UTC_TIMESTAMP(), DeletedBy = ? WHERE RecordID = ?")).
+				ExpectExec().
+				WithArgs(nil, 2).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectAudit(mock, audit.ActionDelete, 2)
+			mock.ExpectCommit()
+		})
 
-	if status := rr.Code; status != http.StatusOK {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
-	}
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/records/{recordID}", f.server().DeleteRecordHandler)
 
-	// Check the response body
-	expected := "Record deleted successfully\n"
-	if rr.Body.String() != expected {
-		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
-	}
+		req, err := http.NewRequest("DELETE", "/api/v1/records/2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if _, ok := f.stored(2); ok {
+			t.Errorf("Record 2 is still stored after deletion")
+		}
+	})
 }
 
-func TestDeleteRecordHandler_NoID(t *testing.T) {
-	// accID follows the existing acc with pending status in record_db for testing approval
-	recordID := ""
+func TestDeleteRecordHandler_NotFound(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
 
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("FROM Record WHERE RecordID = ? AND DeletedAt IS NULL FOR UPDATE")).
+				WithArgs(99).
+				WillReturnRows(sqlmock.NewRows(recordColumns))
+			mock.ExpectRollback()
+		})
 
-	SetDB(db)
+		req, err := http.NewRequest("DELETE", "/api/v1/records/delete?recordID=99", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
 
-	// Set up expectations for the Prepare call
-	mock.ExpectPrepare("DELETE FROM Record WHERE RecordID = ?")
+		rr := httptest.NewRecorder()
+		f.server().DeleteRecordHandler(rr, req)
 
-	mock.ExpectExec("DELETE FROM Record WHERE RecordID = ?").WithArgs(sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+		expectErrorCode(t, rr, api.CodeNotFound)
+	})
+}
 
-	req, err := http.NewRequest("DELETE", fmt.Sprintf("/api/v1/records/delete?recordID=%s", recordID), nil)
-	if err != nil {
-		t.Fatal(err)
+func TestDeleteRecordHandler_NoID(t *testing.T) {
+	tests := []struct {
+		recordID string
+		expected string
+	}{
+		{"", "Record ID parameter is required"},
+		{"abc", "Invalid record ID"},
 	}
 
-	rr := httptest.NewRecorder()
+	for _, tt := range tests {
+		forEachStore(t, func(t *testing.T, f *storeFixture) {
+			req, err := http.NewRequest("DELETE", fmt.Sprintf("/api/v1/records/delete?recordID=%s", tt.recordID), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	DeleteRecordHandler(rr, req)
+			rr := httptest.NewRecorder()
 
-	if status := rr.Code; status != http.StatusBadRequest {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+			f.server().DeleteRecordHandler(rr, req)
+
+			if status := rr.Code; status != http.StatusBadRequest {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+			}
+			if body := expectErrorCode(t, rr, api.CodeInvalidParameter); body.Message != tt.expected {
+				t.Errorf("Handler returned unexpected message: got %v want %v", body.Message, tt.expected)
+			}
+		})
 	}
 }
 
 func TestDeleteRecordHandler_Prepare(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	SetDB(db)
+	s, mock := mysqlServer(t)
 
 	// Create a mock MySQL error
 	mockError := &mysql.MySQLError{
@@ -104,7 +166,9 @@ func TestDeleteRecordHandler_Prepare(t *testing.T) {
 	}
 
 	// Mock Prepare to return the mock MySQL error
-	mock.ExpectPrepare("DELETE FROM Record").WillReturnError(mockError)
+	expectLock(mock, testRecords[2])
+	mock.ExpectPrepare("UPDATE Record SET DeletedAt").WillReturnError(mockError)
+	mock.ExpectRollback()
 
 	// recordID follows existing record for deletion with recordID=4 in record_db for testing deletion
 	recordID := "3"
@@ -117,23 +181,17 @@ func TestDeleteRecordHandler_Prepare(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call the handler directly
-	DeleteRecordHandler(rr, req)
+	s.DeleteRecordHandler(rr, req)
 
 	// Check the status code
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
+	expectErrorCode(t, rr, api.CodeInternal)
 }
 
 func TestDeleteRecordHandler_Exec(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	SetDB(db)
+	s, mock := mysqlServer(t)
 
 	// Create a mock MySQL error
 	mockError := &mysql.MySQLError{
@@ -142,8 +200,10 @@ func TestDeleteRecordHandler_Exec(t *testing.T) {
 	}
 
 	// Set up expectations for your query
-	mock.ExpectPrepare("DELETE FROM Record").ExpectExec().
+	expectLock(mock, testRecords[2])
+	mock.ExpectPrepare("UPDATE Record SET DeletedAt").ExpectExec().
 		WillReturnError(mockError)
+	mock.ExpectRollback()
 
 	// recordID follows existing record for deletion with recordID=4 in record_db for testing deletion
 	recordID := "3"
@@ -156,60 +216,65 @@ func TestDeleteRecordHandler_Exec(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call the handler directly
-	DeleteRecordHandler(rr, req)
+	s.DeleteRecordHandler(rr, req)
 
 	// Check the status code
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
+	expectErrorCode(t, rr, api.CodeInternal)
 }
 
 func TestListAllRecordsHandler(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
-
 	// Test case for successful query execution
 	t.Run("Success", func(t *testing.T) {
-		// Set up expected database query and result
-		rows := sqlmock.NewRows([]string{"RecordID", "Name", "RoleOfContact", "NoOfStudents", "AcadYr", "CapstoneTitle", "CompanyName", "CompanyContact", "ProjDesc"}).
-			AddRow(1, "Test Name1", "Student", 3, "2022/2023", "Title1", "Company1", "Contact Name1", "Description").
-			AddRow(2, "Test Name2", "Staff", 4, "2023/2024", "Title2", "Company2", "Contact Name2", "Description")
-
-		mock.ExpectQuery("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc FROM Record").
-			WillReturnRows(rows)
-
-		req, err := http.NewRequest("GET", "/api/v1/records", nil)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		rr := httptest.NewRecorder()
-
-		// Call the handler
-		ListAllRecordsHandler(rr, req)
-
-		// Check the status code
-		if status := rr.Code; status != http.StatusOK {
-			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
-		}
-
-		// Verify that the expectations were met
-		if err := mock.ExpectationsWereMet(); err != nil {
-			t.Errorf("there were unfulfilled expectations: %s", err)
-		}
+		forEachStore(t, func(t *testing.T, f *storeFixture) {
+			f.seed(
+				Record{1, "Test Name1", "Student", 3, "2022/2023", "Title1", "Company1", "Contact Name1", "Description", 1, 0, 0},
+				Record{2, "Test Name2", "Staff", 4, "2023/2024", "Title2", "Company2", "Contact Name2", "Description", 1, 0, 0},
+			)
+
+			// Set up expected database query and result
+			f.expectSQL(func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(recordColumns).
+					AddRow(1, "Test Name1", "Student", 3, "2022/2023", "Title1", "Company1", "Contact Name1", "Description", 1, 0, 0).
+					AddRow(2, "Test Name2", "Staff", 4, "2023/2024", "Title2", "Company2", "Contact Name2", "Description", 1, 0, 0)
+
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, Version, CompanyID, ContactID FROM Record WHERE DeletedAt IS NULL")).
+					WillReturnRows(rows)
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM Record WHERE DeletedAt IS NULL")).
+					WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(2))
+			})
+
+			req, err := http.NewRequest("GET", "/api/v1/records", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			// Call the handler
+			f.server().ListAllRecordsHandler(rr, req)
+
+			// Check the status code
+			if status := rr.Code; status != http.StatusOK {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+			}
+
+			// Check the response body
+			expected := `{"items":[{"recordId":1,"name":"Test Name1","roleOfContact":"Student","noOfStudents":3,"acadYr":"2022/2023","capstoneTitle":"Title1","companyName":"Company1","companyContact":"Contact Name1","projDesc":"Description","version":1},{"recordId":2,"name":"Test Name2","roleOfContact":"Staff","noOfStudents":4,"acadYr":"2023/2024","capstoneTitle":"Title2","companyName":"Company2","companyContact":"Contact Name2","projDesc":"Description","version":1}],"total":2}` + "\n"
+			if rr.Body.String() != expected {
+				t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+			}
+		})
 	})
 
 	// Test case for database query error
 	t.Run("DatabaseError", func(t *testing.T) {
+		s, mock := mysqlServer(t)
+
 		// Set up mock to return an error
-		mock.ExpectQuery("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc FROM Record").
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, Version, CompanyID, ContactID FROM Record WHERE DeletedAt IS NULL")).
 			WillReturnError(errors.New("database error"))
 
 		req, err := http.NewRequest("GET", "/api/v1/records", nil)
@@ -220,12 +285,13 @@ func TestListAllRecordsHandler(t *testing.T) {
 		rr := httptest.NewRecorder()
 
 		// Call the handler
-		ListAllRecordsHandler(rr, req)
+		s.ListAllRecordsHandler(rr, req)
 
 		// Check the status code
 		if status := rr.Code; status != http.StatusInternalServerError {
 			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 		}
+		expectErrorCode(t, rr, api.CodeInternal)
 
 		// Verify that the expectations were met
 		if err := mock.ExpectationsWereMet(); err != nil {
@@ -235,63 +301,73 @@ func TestListAllRecordsHandler(t *testing.T) {
 }
 
 func TestCreateRecordHandler(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
-
-	// Set up expected database query and result
-	mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO Record (Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")).
-		ExpectExec().
-		WithArgs("Test Create Reecord", "Student", 3, "2022/2023", "Title", "Company", "Contact Name", "Description").
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	// Create JSON request body
-	requestBody := `{"Name": "Test Create Reecord", "RoleOfContact": "Student", "NoOfStudents": 3, "AcadYr": "2022/2023", "CapstoneTitle": "Title", "CompanyName": "Company", "CompanyContact": "Contact Name", "ProjDesc": "Description"}`
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		// Set up expected database query and result
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			created := Record{1, "Test Create Reecord", "Student", 3, "2022/2023", "Title", "Company", "Contact Name", "Description", 1, 1, 1}
+			mock.ExpectBegin()
+			insert := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO Record (Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, CompanyID, ContactID) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"))
+			// The company and contact are named for the first time
+			expectNewReferences(mock, created)
+			insert.ExpectExec().
+				WithArgs("Test Create Reecord", "Student", 3, "2022/2023", "Title", "Company", "Contact Name", "Description", 1, 1).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectRevision(mock, created)
+			expectAudit(mock, audit.ActionCreate, 1)
+			mock.ExpectCommit()
+		})
+
+		// Create JSON request body
+		requestBody := `{"Name": "Test Create Reecord", "RoleOfContact": "Student", "NoOfStudents": 3, "AcadYr": "2022/2023", "CapstoneTitle": "Title", "CompanyName": "Company", "CompanyContact": "Contact Name", "ProjDesc": "Description"}`
+
+		req, err := http.NewRequest("POST", "/api/v1/records", strings.NewReader(requestBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
 
-	req, err := http.NewRequest("POST", "/api/v1/records", strings.NewReader(requestBody))
-	if err != nil {
-		t.Fatal(err)
-	}
-	req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
 
-	rr := httptest.NewRecorder()
+		// Call the handler
+		f.server().CreateRecordHandler(rr, req)
 
-	// Call the handler
-	CreateRecordHandler(rr, req)
+		// Check the status code
+		if status := rr.Code; status != http.StatusCreated {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+		}
 
-	// Check the status code
-	if status := rr.Code; status != http.StatusCreated {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusCreated)
-	}
+		// The created record is returned with its new ID
+		var created Record
+		if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
+			t.Fatal(err)
+		}
+		if created.RecordID != 1 || created.Name != "Test Create Reecord" || created.Version != 1 || created.CompanyID != 1 || created.ContactID != 1 {
+			t.Errorf("Handler returned unexpected record: %+v", created)
+		}
+		if etag := rr.Header().Get("ETag"); etag != `"1"` {
+			t.Errorf("Handler returned wrong ETag: got %v want %v", etag, `"1"`)
+		}
+		if location := rr.Header().Get("Location"); location != "/api/v1/records/1" {
+			t.Errorf("Handler returned wrong location: got %v want %v", location, "/api/v1/records/1")
+		}
 
-	// Verify that the expectations were met
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Errorf("there were unfulfilled expectations: %s", err)
-	}
+		if rec, ok := f.stored(1); f.memory != nil && (!ok || rec.Name != "Test Create Reecord") {
+			t.Errorf("Record was not stored: %+v", rec)
+		}
+	})
 }
 
 func TestCreateRecordHandler_Error(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
+	s, mock := mysqlServer(t)
 
 	// Simulate a database error
-	mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO Record (Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")).
-		ExpectExec().
-		WithArgs("Create Error", "Staff", 3, "2022/2023", "Title", "Company", "Contact Name", "Description").
+	mock.ExpectBegin()
+	insert := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO Record (Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, CompanyID, ContactID) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"))
+	expectReferences(mock, Record{CompanyName: "Company", CompanyContact: "Contact Name", CompanyID: 1, ContactID: 1})
+	insert.ExpectExec().
+		WithArgs("Create Error", "Staff", 3, "2022/2023", "Title", "Company", "Contact Name", "Description", 1, 1).
 		WillReturnError(fmt.Errorf("database error"))
+	mock.ExpectRollback()
 
 	// Create JSON request body
 	requestBody := `{"Name": "Create Error", "RoleOfContact": "Staff", "NoOfStudents": 3, "AcadYr": "2022/2023", "CapstoneTitle": "Title", "CompanyName": "Company", "CompanyContact": "Contact Name", "ProjDesc": "Description"}`
@@ -305,18 +381,15 @@ func TestCreateRecordHandler_Error(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call the handler
-	CreateRecordHandler(rr, req)
+	s.CreateRecordHandler(rr, req)
 
 	// Check the status code
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
 
-	// Check the response body
-	expectedBody := "Internal server error\n"
-	if rr.Body.String() != expectedBody {
-		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expectedBody)
-	}
+	// Check the error code
+	expectErrorCode(t, rr, api.CodeInternal)
 
 	// Verify that the expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -334,34 +407,25 @@ func TestCreateRecordHandler_InvalidRequestPayload(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call the handler
-	CreateRecordHandler(rr, req)
+	NewServer(NewMemoryStore(), testResolver).CreateRecordHandler(rr, req)
 
 	// Check the status code
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
 
-	// Check the response body
-	expectedBody := "Invalid request payload\n"
-	if rr.Body.String() != expectedBody {
-		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expectedBody)
-	}
+	// Check the error code
+	expectErrorCode(t, rr, api.CodeInvalidPayload)
 }
 
 func TestCreateRecordHandler_ErrorPreparingStatement(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
+	s, mock := mysqlServer(t)
 
 	// Simulate an error when preparing the SQL statement
-	mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO Record (Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")).
+	mock.ExpectBegin()
+	mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO Record (Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, CompanyID, ContactID) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")).
 		WillReturnError(fmt.Errorf("failed to prepare statement"))
+	mock.ExpectRollback()
 
 	// Create JSON request body
 	requestBody := `{"Name": "Create Error", "RoleOfContact": "Staff", "NoOfStudents": 3, "AcadYr": "2022/2023", "CapstoneTitle": "Title", "CompanyName": "Company", "CompanyContact": "Contact Name", "ProjDesc": "Description"}`
@@ -375,18 +439,15 @@ func TestCreateRecordHandler_ErrorPreparingStatement(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call the handler
-	CreateRecordHandler(rr, req)
+	s.CreateRecordHandler(rr, req)
 
 	// Check the status code
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
 
-	// Check the response body
-	expectedBody := "Internal server error\n"
-	if rr.Body.String() != expectedBody {
-		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expectedBody)
-	}
+	// Check the error code
+	expectErrorCode(t, rr, api.CodeInternal)
 
 	// Verify that the expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -395,54 +456,517 @@ func TestCreateRecordHandler_ErrorPreparingStatement(t *testing.T) {
 }
 
 func TestUpdateRecordHandler_Success(t *testing.T) {
-	// Create a new mock database connection
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
-
-	// Replace the actual database connection with the mock
-	SetDB(db)
-
-	// Prepare mock for successful update
-	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Record SET Name=?, RoleOfContact=?, NoOfStudents=?, AcadYr=?, CapstoneTitle=?, CompanyName=?, CompanyContact=?, ProjDesc=? WHERE RecordID=?")).
-		ExpectExec().
-		WithArgs("newName", "Student", 1, "newAcadYr", "newCapstoneTitle", "newCompanyName", "newCompanyContact", "newProjDesc", 123).
-		WillReturnResult(sqlmock.NewResult(0, 1))
-
-	// Create a new mux router
-	router := mux.NewRouter()
-	router.HandleFunc("/api/v1/records/{recordID}", UpdateRecordHandler)
-
-	// Prepare request with valid payload and record ID
-	reqBody := `{"Name": "newName", 
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Record{RecordID: 123, Name: "oldName", Version: 1})
+
+		// Prepare mock for successful update
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			updated := Record{123, "newName", "Student", 1, "2024/2025", "newCapstoneTitle", "newCompanyName", "newCompanyContact", "newProjDesc", 2, 1, 1}
+			expectLock(mock, Record{RecordID: 123, Name: "oldName", Version: 1})
+			expectNewReferences(mock, updated)
+			mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Record SET Name=?, RoleOfContact=?, NoOfStudents=?, AcadYr=?, CapstoneTitle=?, CompanyName=?, CompanyContact=?, ProjDesc=?, CompanyID=?, ContactID=?, Version=Version+1 WHERE RecordID=?")).
+				ExpectExec().
+				WithArgs("newName", "Student", 1, "2024/2025", "newCapstoneTitle", "newCompanyName", "newCompanyContact", "newProjDesc", 1, 1, 123).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectRead(mock, updated)
+			expectRevision(mock, updated)
+			expectAudit(mock, audit.ActionUpdate, 123)
+			mock.ExpectCommit()
+		})
+
+		// Create a new mux router
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/records/{recordID}", f.server().UpdateRecordHandler)
+
+		// Prepare request with valid payload and record ID
+		reqBody := `{"Name": "newName", 
 				"RoleOfContact": "Student",
 				"NoOfStudents" : 1, 
-				"AcadYr": "newAcadYr", 
+				"AcadYr": "2024/2025", 
 				"CapstoneTitle" : "newCapstoneTitle", 
 				"CompanyName" : "newCompanyName", 
 				"CompanyContact" : "newCompanyContact", 
 				"ProjDesc" : "newProjDesc"}`
+		req, err := http.NewRequest("PUT", "/api/v1/records/123", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-Match", `"1"`)
+
+		rr := httptest.NewRecorder()
+
+		// Serve the request using the router
+		router.ServeHTTP(rr, req)
+
+		// Check the response status code for success
+		if status := rr.Code; status != http.StatusAccepted {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusAccepted)
+		}
+		if etag := rr.Header().Get("ETag"); etag != `"2"` {
+			t.Errorf("Handler returned wrong ETag: got %v want %v", etag, `"2"`)
+		}
+
+		// Check the response body
+		expectedBody := `{"message":"Record updated successfully!"}` + "\n"
+		if rr.Body.String() != expectedBody {
+			t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expectedBody)
+		}
+
+		if rec, ok := f.stored(123); f.memory != nil && (!ok || rec.Name != "newName" || rec.ProjDesc != "newProjDesc") {
+			t.Errorf("Record was not updated: %+v", rec)
+		}
+	})
+}
+
+func TestUpdateRecordHandler_Conflict(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(Record{RecordID: 123, Name: "oldName", Version: 2})
+
+		// The record moved on to version 2 since the client read version 1
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectLock(mock, Record{RecordID: 123, Name: "oldName", Version: 2})
+			mock.ExpectRollback()
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/records/{recordID}", f.server().UpdateRecordHandler)
+
+		reqBody := `{"name": "newName", "roleOfContact": "Staff", "noOfStudents": 2, "acadYr": "2024/2025", "capstoneTitle": "Title", "companyName": "Company", "companyContact": "Contact", "projDesc": "Description"}`
+		req, err := http.NewRequest("PUT", "/api/v1/records/123", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-Match", `"1"`)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusPreconditionFailed {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusPreconditionFailed)
+		}
+		expectErrorCode(t, rr, api.CodePreconditionFailed)
+
+		if rec, _ := f.stored(123); f.memory != nil && rec.Name != "oldName" {
+			t.Errorf("Record was updated despite the conflict: %+v", rec)
+		}
+	})
+}
+
+func TestUpdateRecordHandler_NotFound(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("FROM Record WHERE RecordID = ? AND DeletedAt IS NULL FOR UPDATE")).
+				WithArgs(99).
+				WillReturnRows(sqlmock.NewRows(recordColumns))
+			mock.ExpectRollback()
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/records/{recordID}", f.server().UpdateRecordHandler)
+
+		reqBody := `{"name": "newName", "roleOfContact": "Staff", "noOfStudents": 2, "acadYr": "2024/2025", "capstoneTitle": "Title", "companyName": "Company", "companyContact": "Contact", "projDesc": "Description"}`
+		req, err := http.NewRequest("PUT", "/api/v1/records/99", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-Match", "*")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+		expectErrorCode(t, rr, api.CodeNotFound)
+
+		if _, ok := f.stored(99); ok {
+			t.Errorf("Handler created the missing record")
+		}
+	})
+}
+
+func TestUpdateRecordHandler_NoIfMatch(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/records/{recordID}", NewServer(NewMemoryStore(Record{RecordID: 123, Name: "oldName"}), testResolver).UpdateRecordHandler)
+
+	reqBody := `{"name": "newName", "roleOfContact": "Staff", "noOfStudents": 2, "acadYr": "2024/2025", "capstoneTitle": "Title", "companyName": "Company", "companyContact": "Contact", "projDesc": "Description"}`
 	req, err := http.NewRequest("PUT", "/api/v1/records/123", strings.NewReader(reqBody))
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	rr := httptest.NewRecorder()
-
-	// Serve the request using the router
 	router.ServeHTTP(rr, req)
 
-	// Check the response status code for success
-	if status := rr.Code; status != http.StatusAccepted {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusAccepted)
+	if status := rr.Code; status != http.StatusPreconditionRequired {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusPreconditionRequired)
 	}
+	expectErrorCode(t, rr, api.CodePreconditionRequired)
+}
+
+func TestGetRecordHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectRead(mock, testRecords[1])
+			mock.ExpectQuery(regexp.QuoteMeta("FROM Record WHERE RecordID = ? AND DeletedAt IS NULL")).
+				WithArgs(99).
+				WillReturnRows(sqlmock.NewRows(recordColumns))
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/records/{recordID}", f.server().GetRecordHandler)
+
+		req, err := http.NewRequest("GET", "/api/v1/records/2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
 
-	// Check the response body
-	expectedBody := "Record updated successfully!\n"
-	if rr.Body.String() != expectedBody {
-		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expectedBody)
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if etag := rr.Header().Get("ETag"); etag != `"1"` {
+			t.Errorf("Handler returned wrong ETag: got %v want %v", etag, `"1"`)
+		}
+		var rec Record
+		if err := json.NewDecoder(rr.Body).Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec != testRecords[1] {
+			t.Errorf("Handler returned unexpected record: %+v", rec)
+		}
+
+		// A missing record is not found
+		req, err = http.NewRequest("GET", "/api/v1/records/99", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+		expectErrorCode(t, rr, api.CodeNotFound)
+	})
+}
+
+func TestPatchRecordHandler(t *testing.T) {
+	patched := testRecords[2]
+	patched.Name, patched.NoOfStudents, patched.Version = "Luke Tan", 5, 2
+
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		// Only the changed columns are written; the unchanged acadYr is not
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectRead(mock, testRecords[2])
+			expectLock(mock, testRecords[2])
+			mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Record SET Name=?, NoOfStudents=?, Version=Version+1 WHERE RecordID=?")).
+				ExpectExec().
+				WithArgs("Luke Tan", 5, 3).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectRead(mock, patched)
+			expectRevision(mock, patched)
+			expectAudit(mock, audit.ActionUpdate, 3)
+			mock.ExpectCommit()
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/records/{recordID}", f.server().PatchRecordHandler)
+
+		reqBody := `{"name": "Luke Tan", "noOfStudents": 5, "acadYr": "2023/2024"}`
+		req, err := http.NewRequest("PATCH", "/api/v1/records/3", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if etag := rr.Header().Get("ETag"); etag != `"2"` {
+			t.Errorf("Handler returned wrong ETag: got %v want %v", etag, `"2"`)
+		}
+		var rec Record
+		if err := json.NewDecoder(rr.Body).Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec != patched {
+			t.Errorf("Handler returned unexpected record: %+v", rec)
+		}
+
+		if stored, ok := f.stored(3); ok && stored != patched {
+			t.Errorf("Handler stored unexpected record: %+v", stored)
+		}
+	})
+}
+
+func TestPatchRecordHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		ifMatch string
+		status  int
+		code    string
+	}{
+		{"invalid ID", "/api/v1/records/abc", `{}`, "", http.StatusBadRequest, api.CodeInvalidParameter},
+		{"missing record", "/api/v1/records/99", `{}`, "", http.StatusNotFound, api.CodeNotFound},
+		{"malformed JSON", "/api/v1/records/3", `{"name": `, "", http.StatusBadRequest, api.CodeInvalidPayload},
+		{"not an object", "/api/v1/records/3", `["name"]`, "", http.StatusBadRequest, api.CodeInvalidPayload},
+		{"wrong type", "/api/v1/records/3", `{"noOfStudents": "five"}`, "", http.StatusBadRequest, api.CodeInvalidPayload},
+		{"read-only field", "/api/v1/records/3", `{"version": 7}`, "", http.StatusUnprocessableEntity, api.CodeValidationFailed},
+		{"unknown field", "/api/v1/records/3", `{"colour": "red"}`, "", http.StatusUnprocessableEntity, api.CodeValidationFailed},
+		{"removed required field", "/api/v1/records/3", `{"name": null}`, "", http.StatusUnprocessableEntity, api.CodeValidationFailed},
+		{"invalid value", "/api/v1/records/3", `{"acadYr": "2023"}`, "", http.StatusUnprocessableEntity, api.CodeValidationFailed},
+		{"stale version", "/api/v1/records/3", `{"name": "Luke Tan"}`, `"2"`, http.StatusPreconditionFailed, api.CodePreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore(testRecords...)
+			router := mux.NewRouter()
+			router.HandleFunc("/api/v1/records/{recordID}", NewServer(store, testResolver).PatchRecordHandler)
+
+			req, err := http.NewRequest("PATCH", tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.status {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, tt.status)
+			}
+			expectErrorCode(t, rr, tt.code)
+
+			if rec, _ := store.Get(3); rec != testRecords[2] {
+				t.Errorf("Handler changed the record: %+v", rec)
+			}
+		})
+	}
+}
+
+func TestPatchRecordHandler_NoChange(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		// A patch that changes nothing is not written
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectRead(mock, testRecords[2])
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/records/{recordID}", f.server().PatchRecordHandler)
+
+		req, err := http.NewRequest("PATCH", "/api/v1/records/3", strings.NewReader(`{"name": "Luke"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-Match", `"1"`)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if etag := rr.Header().Get("ETag"); etag != `"1"` {
+			t.Errorf("Handler returned wrong ETag: got %v want %v", etag, `"1"`)
+		}
+	})
+}
+
+func TestRecordHandlers_Validation(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected string
+	}{
+		{
+			"create missing fields", "POST", "/api/v1/records",
+			`{"name": "Test Name", "roleOfContact": "Student", "noOfStudents": 3, "acadYr": "2022/2023"}`,
+			`{"code":"validation_failed","message":"Request validation failed","details":[{"field":"capstoneTitle","message":"is required"},{"field":"companyName","message":"is required"},{"field":"companyContact","message":"is required"},{"field":"projDesc","message":"is required"}],"requestId":""}`,
+		},
+		{
+			"create bad values", "POST", "/api/v1/records",
+			`{"name": "` + strings.Repeat("n", 51) + `", "roleOfContact": "Parent", "noOfStudents": 0, "acadYr": "2022/2024", "capstoneTitle": "Title", "companyName": "Company", "companyContact": "Contact Name", "projDesc": "Description"}`,
+			`{"code":"validation_failed","message":"Request validation failed","details":[{"field":"name","message":"must be at most 50 characters"},{"field":"roleOfContact","message":"must be one of Staff, Student"},{"field":"noOfStudents","message":"must be at least 1"},{"field":"acadYr","message":"must be two consecutive years as YYYY/YYYY"}],"requestId":""}`,
+		},
+		{
+			"update long description", "PUT", "/api/v1/records/123",
+			`{"name": "Test Name", "roleOfContact": "Staff", "noOfStudents": 3, "acadYr": "2022/2023", "capstoneTitle": "Title", "companyName": "Company", "companyContact": "Contact Name", "projDesc": "` + strings.Repeat("d", 1001) + `"}`,
+			`{"code":"validation_failed","message":"Request validation failed","details":[{"field":"projDesc","message":"must be at most 1000 characters"}],"requestId":""}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Invalid payloads are rejected before any SQL is run
+			s, mock := mysqlServer(t)
+			router := mux.NewRouter()
+			router.HandleFunc("/api/v1/records", s.CreateRecordHandler).Methods("POST")
+			router.HandleFunc("/api/v1/records/{recordID}", s.UpdateRecordHandler).Methods("PUT")
+
+			req, err := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != http.StatusUnprocessableEntity {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
+			}
+			if rr.Body.String() != tt.expected+"\n" {
+				t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), tt.expected)
+			}
+
+			// Verify that the expectations were met
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestRoutePolicies(t *testing.T) {
+	auth.SetRevocationStore(auth.NewMemoryRevocationStore())
+
+	// Replace every handler so only the authorization middleware is exercised
+	router := NewServer(NewMemoryStore(), testResolver).Router()
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		route.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+		return nil
+	})
+
+	callers := []struct {
+		name    string
+		accID   int
+		accType string
+	}{
+		{"anonymous", 0, ""},
+		{"pending user", 2004, "User"},
+		{"user", 2001, "User"},
+		{"admin", 1001, "Admin"},
+	}
+
+	const ok = http.StatusNoContent
+	const unauthorized = http.StatusUnauthorized
+	const forbidden = http.StatusForbidden
+
+	tests := []struct {
+		method string
+		path   string
+		want   [4]int // anonymous, pending user, user, admin
+	}{
+		{"GET", "/healthz", [4]int{ok, ok, ok, ok}},
+		{"GET", "/readyz", [4]int{ok, ok, ok, ok}},
+		{"GET", "/api/v1/records/all", [4]int{unauthorized, ok, ok, ok}},
+		{"GET", "/api/v1/records/export?format=jsonl", [4]int{unauthorized, ok, ok, ok}},
+		{"POST", "/api/v1/records", [4]int{unauthorized, forbidden, ok, ok}},
+		{"POST", "/api/v1/records/import", [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"GET", "/api/v1/records/report?acadYr=2023/2024", [4]int{unauthorized, ok, ok, ok}},
+		{"DELETE", "/api/v1/records/delete?recordID=3", [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"PUT", "/api/v1/records/3", [4]int{unauthorized, forbidden, ok, ok}},
+		{"PATCH", "/api/v1/records/3", [4]int{unauthorized, forbidden, ok, ok}},
+		{"GET", "/api/v1/records/search?query=2023", [4]int{unauthorized, ok, ok, ok}},
+		{"GET", "/api/v1/records/trash", [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"POST", "/api/v1/records/trash/3/restore", [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"GET", "/api/v1/records/3", [4]int{unauthorized, ok, ok, ok}},
+		{"DELETE", "/api/v1/records/3", [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"GET", "/api/v1/records/3/revisions", [4]int{unauthorized, ok, ok, ok}},
+		{"GET", "/api/v1/records/3/revisions/diff?from=1&to=2", [4]int{unauthorized, ok, ok, ok}},
+		{"POST", "/api/v1/records/3/revisions/1/rollback", [4]int{unauthorized, forbidden, ok, ok}},
+		{"GET", "/api/v1/companies", [4]int{unauthorized, ok, ok, ok}},
+		{"POST", "/api/v1/companies", [4]int{unauthorized, forbidden, ok, ok}},
+		{"GET", "/api/v1/companies/2", [4]int{unauthorized, ok, ok, ok}},
+		{"PUT", "/api/v1/companies/2", [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"DELETE", "/api/v1/companies/2", [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"GET", "/api/v1/companies/2/contacts", [4]int{unauthorized, ok, ok, ok}},
+		{"POST", "/api/v1/companies/2/contacts", [4]int{unauthorized, forbidden, ok, ok}},
+		{"GET", "/api/v1/contacts/2", [4]int{unauthorized, ok, ok, ok}},
+		{"PUT", "/api/v1/contacts/2", [4]int{unauthorized, forbidden, forbidden, ok}},
+		{"DELETE", "/api/v1/contacts/2", [4]int{unauthorized, forbidden, forbidden, ok}},
+	}
+
+	for _, tt := range tests {
+		for i, caller := range callers {
+			t.Run(tt.method+" "+tt.path+" as "+caller.name, func(t *testing.T) {
+				req, err := http.NewRequest(tt.method, tt.path, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if caller.accID != 0 {
+					token, err := auth.Issue(caller.accID, caller.accType)
+					if err != nil {
+						t.Fatal(err)
+					}
+					req.Header.Set("Authorization", "Bearer "+token.Token)
+				}
+
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, req)
+
+				if status := rr.Code; status != tt.want[i] {
+					t.Errorf("Handler returned wrong status code: got %v want %v", status, tt.want[i])
+				}
+			})
+		}
+	}
+}
+
+func TestNewRouter_RevokedToken(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	defer auth.SetRevocationStore(auth.NewMemoryRevocationStore())
+
+	token, err := auth.Issue(1001, "Admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The token was logged out on the account service
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM RevokedToken WHERE TokenID = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+
+	req, err := http.NewRequest("GET", "/api/v1/records/all", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+
+	rr := httptest.NewRecorder()
+	newRouter(db).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
 	}
 
 	// Verify that the expectations were met
@@ -450,3 +974,23 @@ func TestUpdateRecordHandler_Success(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestRoutePolicies_Complete(t *testing.T) {
+	// Every registered route needs an entry in the policy table
+	NewServer(NewMemoryStore(), testResolver).Router().Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			if _, ok := routePolicies[method+" "+tmpl]; !ok {
+				t.Errorf("No access policy for %s %s", method, tmpl)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,105 @@
+package record
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"      //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/report"   //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/validate" //change here
+)
+
+// ErrNoRecords is returned by Catalogue for a year without records
+var ErrNoRecords = errors.New("no records for the academic year")
+
+// ReportFormats render a catalogue, by their name and file extension
+var ReportFormats = map[string]func(io.Writer, report.Catalogue) error{
+	"html": report.HTML,
+	"pdf":  report.PDF,
+}
+
+// reportContentTypes are sent with each report format
+var reportContentTypes = map[string]string{
+	"html": "text/html; charset=utf-8",
+	"pdf":  "application/pdf",
+}
+
+// ValidateAcadYr checks an academic year as the acadYr field of a record
+func ValidateAcadYr(acadYr string) validate.Errors {
+	return validate.Fields(Record{AcadYr: acadYr}, "acadYr")
+}
+
+// Catalogue reads the records of an academic year from store, sorted by
+// title, into the catalogue for that year
+func Catalogue(store RecordStore, acadYr string, generated time.Time) (report.Catalogue, error) {
+	p, err := recordListSpec.Parse(url.Values{"acadYr": {acadYr}, "sort": {"capstoneTitle"}})
+	if err != nil {
+		return report.Catalogue{}, err
+	}
+
+	var capstones []report.Capstone
+	err = store.Export(p, SearchQuery{}, func(rec Record) error {
+		capstones = append(capstones, report.Capstone{
+			Title:          rec.CapstoneTitle,
+			Contact:        rec.Name,
+			Role:           rec.RoleOfContact,
+			Company:        rec.CompanyName,
+			CompanyContact: rec.CompanyContact,
+			TeamSize:       rec.NoOfStudents,
+			Description:    rec.ProjDesc,
+		})
+		return nil
+	})
+	if err != nil {
+		return report.Catalogue{}, err
+	}
+	if len(capstones) == 0 {
+		return report.Catalogue{}, ErrNoRecords
+	}
+	return report.New(acadYr, generated, capstones), nil
+}
+
+// ReportHandler renders the catalogue of one academic year as an HTML page
+// or a PDF document. The catalogue is rendered in full before it is sent, so
+// a failure is always reported as an error.
+func (s *Server) ReportHandler(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	acadYr := values.Get("acadYr")
+	if errs := ValidateAcadYr(acadYr); errs != nil {
+		api.ErrorDetails(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "acadYr must be an academic year such as 2023/2024", errs)
+		return
+	}
+	format := values.Get("format")
+	if format == "" {
+		format = "html"
+	}
+	render, ok := ReportFormats[format]
+	if !ok {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "format must be html or pdf")
+		return
+	}
+
+	c, err := Catalogue(s.store, acadYr, time.Now().UTC())
+	if errors.Is(err, ErrNoRecords) {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "No records for "+acadYr)
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := render(&buf, c); err != nil {
+		api.Internal(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", reportContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, c.FileName(format)))
+	buf.WriteTo(w)
+}
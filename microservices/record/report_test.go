@@ -0,0 +1,123 @@
+// report_test.go
+package record
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api" //change here
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var reportRecords = []Record{
+	testRecords[2],
+	{4, "Jeremy", "Staff", 2, "2023/2024", "Android Based E-learning", "CompanyC", "Ms Lim", "Quizzes that work offline.", 1, 4, 4},
+	{5, "Zi Yi", "Staff", 1, "2023/2024", "Smart Farm", "CompanyD", "Mr Tan", "Sensors for crops.", 1, 5, 5},
+}
+
+func reportRequest(t *testing.T, s *Server, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, err := http.NewRequest("GET", "/api/v1/records/report?"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	s.ReportHandler(rr, req)
+	return rr
+}
+
+func TestReportHandler(t *testing.T) {
+	for _, format := range []string{"html", "pdf"} {
+		forEachStore(t, func(t *testing.T, f *storeFixture) {
+			f.seed(append([]Record{testRecords[0], testRecords[1]}, reportRecords...)...)
+
+			f.expectSQL(func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(selectRecords + " AND AcadYr = ? ORDER BY CapstoneTitle ASC, RecordID ASC")).
+					WithArgs("2023/2024").
+					WillReturnRows(exportRows(reportRecords...))
+			})
+
+			rr := reportRequest(t, f.server(), "acadYr=2023/2024&format="+format)
+
+			if status := rr.Code; status != http.StatusOK {
+				t.Fatalf("Handler returned wrong status code: got %v want %v: %s", status, http.StatusOK, rr.Body.String())
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != reportContentTypes[format] {
+				t.Errorf("Handler returned wrong content type: got %v want %v", ct, reportContentTypes[format])
+			}
+			if cd := rr.Header().Get("Content-Disposition"); cd != `inline; filename="capstones-2023-2024.`+format+`"` {
+				t.Errorf("Handler returned wrong content disposition: %v", cd)
+			}
+
+			body := rr.Body.String()
+			if format == "pdf" {
+				if !strings.HasPrefix(body, "%PDF-") {
+					t.Errorf("Handler did not return a PDF")
+				}
+				return
+			}
+			// Two sections, the first with both e-learning projects
+			if n := strings.Count(body, "<section "); n != 2 {
+				t.Errorf("Report has %d sections, want 2", n)
+			}
+			if n := strings.Count(body, `<div class="capstone">`); n != len(reportRecords) {
+				t.Errorf("Report has %d capstones, want %d", n, len(reportRecords))
+			}
+			if strings.Contains(body, "Carpooling System") {
+				t.Errorf("Report includes a record from another year")
+			}
+		})
+	}
+}
+
+func TestReportHandler_NotFound(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta(selectRecords + " AND AcadYr = ?")).
+				WithArgs("1999/2000").
+				WillReturnRows(exportRows())
+		})
+
+		rr := reportRequest(t, f.server(), "acadYr=1999/2000")
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+		expectErrorCode(t, rr, api.CodeNotFound)
+	})
+}
+
+func TestReportHandler_Invalid(t *testing.T) {
+	for _, query := range []string{"", "acadYr=2023", "acadYr=2023/2025", "acadYr=2023/2024&format=docx"} {
+		rr := reportRequest(t, NewServer(NewMemoryStore(), testResolver), query)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Handler returned wrong status code for %q: got %v want %v", query, status, http.StatusBadRequest)
+		}
+		expectErrorCode(t, rr, api.CodeInvalidParameter)
+	}
+}
+
+func TestReportHandler_Error(t *testing.T) {
+	s, mock := mysqlServer(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectRecords)).
+		WillReturnError(errors.New("sql: query failed"))
+
+	rr := reportRequest(t, s, "acadYr=2023/2024&format=pdf")
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+	}
+	expectErrorCode(t, rr, api.CodeInternal)
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,205 @@
+package record
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"   //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/query" //change here
+
+	"github.com/gorilla/mux"
+)
+
+// Revision is an immutable copy of a record as it was after a create,
+// update or rollback, numbered by the version the record had then. CreatedBy
+// is 0 when the account that made the change is unknown.
+type Revision struct {
+	Revision int `json:"revision"`
+	Record
+	CreatedBy int    `json:"createdBy,omitempty"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// list options accepted by ListRevisionsHandler; the newest revision comes
+// first by default
+var revisionListSpec = query.Spec{
+	Table:   "RecordRevision",
+	Columns: []string{"RecordID", "Revision", "Name", "RoleOfContact", "NoOfStudents", "AcadYr", "CapstoneTitle", "CompanyName", "CompanyContact", "ProjDesc", "CompanyID", "ContactID", "CreatedBy", "CreatedAt"},
+	// Revisions are only listed for one record, where the number is unique
+	Key: "Revision",
+	Sortable: map[string]string{
+		"revision":  "Revision",
+		"createdAt": "CreatedAt",
+	},
+	Filterable: map[string]string{
+		"createdBy": "CreatedBy",
+	},
+	DefaultSort:  []query.SortField{{Column: "Revision", Desc: true}},
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// forRecord limits list options to the revisions of one record
+func forRecord(p query.Params, recordID int) query.Params {
+	p.Filters = append([]query.Filter{{Column: "RecordID", Value: strconv.Itoa(recordID)}}, p.Filters...)
+	return p
+}
+
+func scanRevision(rows *sql.Rows) (Revision, error) {
+	return readRevision(rows)
+}
+
+// readRevision reads a row of revisionListSpec.Columns. Revisions from before
+// companies had IDs have none.
+func readRevision(row interface{ Scan(...interface{}) error }) (Revision, error) {
+	var rev Revision
+	var companyID, contactID, createdBy sql.NullInt64
+	err := row.Scan(&rev.RecordID, &rev.Revision, &rev.Name, &rev.RoleOfContact, &rev.NoOfStudents, &rev.AcadYr, &rev.CapstoneTitle, &rev.CompanyName, &rev.CompanyContact, &rev.ProjDesc, &companyID, &contactID, &createdBy, &rev.CreatedAt)
+	rev.CompanyID, rev.ContactID = int(companyID.Int64), int(contactID.Int64)
+	rev.CreatedBy, rev.Version = int(createdBy.Int64), rev.Revision
+	return rev, err
+}
+
+// revisionValue returns the value of a column for filtering, sorting and cursors
+func revisionValue(rev Revision, column string) interface{} {
+	switch column {
+	case "Revision":
+		return rev.Revision
+	case "CreatedBy":
+		return rev.CreatedBy
+	case "CreatedAt":
+		return rev.CreatedAt
+	}
+	return recordValue(rev.Record, column)
+}
+
+// FieldChange is one field that differs between two revisions, named as in
+// the record JSON
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// RevisionDiff lists the fields changed from one revision to another
+type RevisionDiff struct {
+	RecordID int           `json:"recordId"`
+	From     int           `json:"from"`
+	To       int           `json:"to"`
+	Changes  []FieldChange `json:"changes"`
+}
+
+// Diff compares the content of two states of a record field by field, in
+// declaration order. Company and contact IDs are left out: a change of either
+// shows as a change of its name, and revisions from before the IDs have none.
+func Diff(from, to Record) []FieldChange {
+	changes := []FieldChange{}
+	a, b := reflect.ValueOf(from), reflect.ValueOf(to)
+	for i := 0; i < a.NumField(); i++ {
+		field := a.Type().Field(i)
+		if field.Name == "RecordID" || field.Name == "Version" || field.Name == "CompanyID" || field.Name == "ContactID" || a.Field(i).Interface() == b.Field(i).Interface() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		changes = append(changes, FieldChange{Field: name, From: a.Field(i).Interface(), To: b.Field(i).Interface()})
+	}
+	return changes
+}
+
+// ListRevisionsHandler lists the revisions of a record, newest first
+func (s *Server) ListRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	recordID, err := strconv.Atoi(mux.Vars(r)["recordID"])
+	if err != nil {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid record ID")
+		return
+	}
+
+	params, err := revisionListSpec.Parse(r.URL.Query())
+	if err != nil {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, err.Error())
+		return
+	}
+
+	page, err := s.store.Revisions(recordID, params)
+	if err != nil {
+		api.Internal(w, r)
+		return
+	}
+	// Every record has at least the revision it was created with
+	if page.Total == 0 && len(params.Filters) == 0 {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Record not found")
+		return
+	}
+
+	api.JSON(w, http.StatusOK, page)
+}
+
+// DiffRevisionsHandler compares the revisions given by the from and to
+// query parameters
+func (s *Server) DiffRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	recordID, err := strconv.Atoi(mux.Vars(r)["recordID"])
+	if err != nil {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid record ID")
+		return
+	}
+
+	var numbers [2]int
+	for i, name := range []string{"from", "to"} {
+		n, err := strconv.Atoi(r.URL.Query().Get(name))
+		if err != nil || n < 1 {
+			api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, name+" must be a revision number")
+			return
+		}
+		numbers[i] = n
+	}
+
+	var revs [2]Revision
+	for i, n := range numbers {
+		revs[i], err = s.store.Revision(recordID, n)
+		if err == ErrNotFound {
+			api.Error(w, r, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("Revision %d not found", n))
+			return
+		} else if err != nil {
+			api.Internal(w, r)
+			return
+		}
+	}
+
+	api.JSON(w, http.StatusOK, RevisionDiff{
+		RecordID: recordID,
+		From:     revs[0].Revision,
+		To:       revs[1].Revision,
+		Changes:  Diff(revs[0].Record, revs[1].Record),
+	})
+}
+
+// RollbackRecordHandler sets a record back to an earlier revision
+func (s *Server) RollbackRecordHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	recordID, err := strconv.Atoi(vars["recordID"])
+	if err != nil {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid record ID")
+		return
+	}
+	revision, err := strconv.Atoi(vars["revision"])
+	if err != nil {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid revision")
+		return
+	}
+
+	err = s.store.Rollback(recordID, revision, audit.ActorFrom(r))
+	if err == ErrNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Revision not found")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	api.Message(w, http.StatusOK, fmt.Sprintf("Record rolled back to revision %d", revision))
+}
@@ -0,0 +1,290 @@
+// revision_test.go
+package record
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"   //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/query" //change here
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+var revisionColumns = []string{"RecordID", "Revision", "Name", "RoleOfContact", "NoOfStudents", "AcadYr", "CapstoneTitle", "CompanyName", "CompanyContact", "ProjDesc", "CompanyID", "ContactID", "CreatedBy", "CreatedAt"}
+
+// the state of testRecords[2] after an update by user 2001
+var revisedRecord = Record{3, "Luke", "Student", 4, "2023/2024", "Android Based E-learning", "CompanyC", "Dr Pamela", "Mobile application for learning anytime, anywhere.", 2, 4, 4}
+
+func revisionRow(rows *sqlmock.Rows, rec Record, revision int, createdBy interface{}) *sqlmock.Rows {
+	return rows.AddRow(rec.RecordID, revision, rec.Name, rec.RoleOfContact, rec.NoOfStudents, rec.AcadYr, rec.CapstoneTitle, rec.CompanyName, rec.CompanyContact, rec.ProjDesc, rec.CompanyID, rec.ContactID, createdBy, "2024-05-01 09:30:00")
+}
+
+func expectGetRevision(mock sqlmock.Sqlmock, rows *sqlmock.Rows, revision int) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT RecordID, Revision, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, CompanyID, ContactID, CreatedBy, CreatedAt FROM RecordRevision WHERE RecordID = ? AND Revision = ?")).
+		WithArgs(3, revision).
+		WillReturnRows(rows)
+}
+
+// reviseRecord seeds the memory store with testRecords and updates the third
+// record to revisedRecord
+func reviseRecord(t *testing.T, f *storeFixture) {
+	f.seed(testRecords...)
+	if f.memory != nil {
+		// Update from the version the record was seeded with. The new company
+		// and contact are given by name, so they are created with the next IDs.
+		rec := revisedRecord
+		rec.CompanyID, rec.ContactID, rec.Version = 0, 0, 1
+		if err := f.memory.Update(rec, audit.Actor{AccID: 2001}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListRevisionsHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		reviseRecord(t, f)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			rows := sqlmock.NewRows(revisionColumns)
+			revisionRow(rows, revisedRecord, 2, 2001)
+			revisionRow(rows, testRecords[2], 1, nil)
+
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT RecordID, Revision, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, CompanyID, ContactID, CreatedBy, CreatedAt FROM RecordRevision WHERE RecordID = ? ORDER BY Revision DESC LIMIT ?")).
+				WithArgs("3", 51).
+				WillReturnRows(rows)
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM RecordRevision WHERE RecordID = ?")).
+				WithArgs("3").
+				WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(2))
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/records/{recordID}/revisions", f.server().ListRevisionsHandler)
+
+		req, err := http.NewRequest("GET", "/api/v1/records/3/revisions", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		var page query.Page[Revision]
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 2 || page.Items[0].Revision != 2 || page.Items[0].Record != revisedRecord || page.Items[0].CreatedBy != 2001 ||
+			page.Items[1].Revision != 1 || page.Items[1].Record != testRecords[2] {
+			t.Errorf("Handler returned unexpected page: %+v", page)
+		}
+	})
+}
+
+func TestListRevisionsHandler_NotFound(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery("SELECT (.+) FROM RecordRevision").
+				WithArgs("99", 51).
+				WillReturnRows(sqlmock.NewRows(revisionColumns))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM RecordRevision WHERE RecordID = ?")).
+				WithArgs("99").
+				WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/records/{recordID}/revisions", f.server().ListRevisionsHandler)
+
+		req, err := http.NewRequest("GET", "/api/v1/records/99/revisions", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+		expectErrorCode(t, rr, api.CodeNotFound)
+	})
+}
+
+func TestDiffRevisionsHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		reviseRecord(t, f)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectGetRevision(mock, revisionRow(sqlmock.NewRows(revisionColumns), testRecords[2], 1, nil), 1)
+			expectGetRevision(mock, revisionRow(sqlmock.NewRows(revisionColumns), revisedRecord, 2, 2001), 2)
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/records/{recordID}/revisions/diff", f.server().DiffRevisionsHandler)
+
+		req, err := http.NewRequest("GET", "/api/v1/records/3/revisions/diff?from=1&to=2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		expected := `{"recordId":3,"from":1,"to":2,"changes":[{"field":"noOfStudents","from":3,"to":4},{"field":"companyName","from":"CompanyB","to":"CompanyC"}]}` + "\n"
+		if rr.Body.String() != expected {
+			t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+		}
+	})
+}
+
+func TestDiffRevisionsHandler_Errors(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		reviseRecord(t, f)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			expectGetRevision(mock, revisionRow(sqlmock.NewRows(revisionColumns), testRecords[2], 1, nil), 1)
+			expectGetRevision(mock, sqlmock.NewRows(revisionColumns), 7)
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/records/{recordID}/revisions/diff", f.server().DiffRevisionsHandler)
+
+		tests := []struct {
+			query  string
+			status int
+			code   string
+		}{
+			{"to=2", http.StatusBadRequest, api.CodeInvalidParameter},
+			{"from=1&to=latest", http.StatusBadRequest, api.CodeInvalidParameter},
+			{"from=1&to=7", http.StatusNotFound, api.CodeNotFound},
+		}
+
+		for _, tt := range tests {
+			req, err := http.NewRequest("GET", "/api/v1/records/3/revisions/diff?"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.status {
+				t.Errorf("%s: handler returned wrong status code: got %v want %v", tt.query, status, tt.status)
+			}
+			expectErrorCode(t, rr, tt.code)
+		}
+	})
+}
+
+func TestRollbackRecordHandler(t *testing.T) {
+	// The content of revision 1 as a new version
+	rolledBack := testRecords[2]
+	rolledBack.Version = 3
+
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		reviseRecord(t, f)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			expectGetRevision(mock, revisionRow(sqlmock.NewRows(revisionColumns), testRecords[2], 1, nil), 1)
+			// The company and contact of the revision still exist
+			expectReferenceIDs(mock, rolledBack, "")
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, Version, CompanyID, ContactID FROM Record WHERE RecordID = ? AND DeletedAt IS NULL FOR UPDATE")).
+				WithArgs(3).
+				WillReturnRows(recordRows(revisedRecord))
+			expectReferenceIDs(mock, rolledBack, " FOR SHARE")
+			mock.ExpectPrepare(regexp.QuoteMeta("UPDATE Record SET Name=?, RoleOfContact=?, NoOfStudents=?, AcadYr=?, CapstoneTitle=?, CompanyName=?, CompanyContact=?, ProjDesc=?, CompanyID=?, ContactID=?, Version=Version+1 WHERE RecordID=?")).
+				ExpectExec().
+				WithArgs("Luke", "Student", 3, "2023/2024", "Android Based E-learning", "CompanyB", "Dr Pamela", "Mobile application for learning anytime, anywhere.", 3, 3, 3).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectRead(mock, rolledBack)
+			expectRevision(mock, rolledBack)
+			expectAudit(mock, audit.ActionRollback, 3)
+			mock.ExpectCommit()
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/records/{recordID}/revisions/{revision}/rollback", f.server().RollbackRecordHandler)
+
+		req, err := http.NewRequest("POST", "/api/v1/records/3/revisions/1/rollback", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		expected := `{"message":"Record rolled back to revision 1"}` + "\n"
+		if rr.Body.String() != expected {
+			t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+		}
+
+		if f.memory != nil {
+			if rec, _ := f.stored(3); rec != rolledBack {
+				t.Errorf("Record was not rolled back: %+v", rec)
+			}
+			// The rollback is itself a revision
+			if rev, err := f.store.Revision(3, 3); err != nil || rev.Record != rolledBack {
+				t.Errorf("Rollback stored unexpected revision: %+v %v", rev, err)
+			}
+		}
+	})
+}
+
+func TestRollbackRecordHandler_NotFound(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		reviseRecord(t, f)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			expectGetRevision(mock, sqlmock.NewRows(revisionColumns), 7)
+			mock.ExpectRollback()
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/records/{recordID}/revisions/{revision}/rollback", f.server().RollbackRecordHandler)
+
+		req, err := http.NewRequest("POST", "/api/v1/records/3/revisions/7/rollback", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+		expectErrorCode(t, rr, api.CodeNotFound)
+	})
+}
+
+func TestDiff(t *testing.T) {
+	if changes := Diff(testRecords[0], testRecords[0]); len(changes) != 0 {
+		t.Errorf("Diff of identical records returned changes: %+v", changes)
+	}
+
+	// The record id is not a field of the content
+	changes := Diff(testRecords[1], testRecords[2])
+	if len(changes) != 6 || changes[0] != (FieldChange{Field: "name", From: "Yi Ting", To: "Luke"}) {
+		t.Errorf("Diff returned unexpected changes: %+v", changes)
+	}
+	if !reflect.DeepEqual(changes[len(changes)-1], FieldChange{Field: "projDesc", From: testRecords[1].ProjDesc, To: testRecords[2].ProjDesc}) {
+		t.Errorf("Diff returned unexpected last change: %+v", changes[len(changes)-1])
+	}
+}
@@ -0,0 +1,437 @@
+package record
+
+import (
+	"database/sql"
+	"html"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SearchFilter restricts a search to records whose column contains Value, or
+// equals it when Exact is set
+type SearchFilter struct {
+	Column string
+	Value  string
+	Exact  bool
+}
+
+// SearchQuery is a parsed search string. Terms are matched against the
+// full-text columns and ranked; Filters must all match.
+type SearchQuery struct {
+	Terms   []string
+	Filters []SearchFilter
+}
+
+// SearchResult is a matching record with its relevance and highlighted snippets
+type SearchResult struct {
+	Record
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// Searcher runs a search query and returns at most limit results, best first
+type Searcher interface {
+	Search(q SearchQuery, limit int) ([]SearchResult, error)
+}
+
+// field names accepted in "field:value" search syntax
+var searchFields = map[string]SearchFilter{
+	"title":   {Column: "CapstoneTitle"},
+	"desc":    {Column: "ProjDesc"},
+	"company": {Column: "CompanyName"},
+	"contact": {Column: "CompanyContact"},
+	"name":    {Column: "Name"},
+	"year":    {Column: "AcadYr", Exact: true},
+	"role":    {Column: "RoleOfContact", Exact: true},
+}
+
+// columns covered by the FULLTEXT index, with their weight in the fallback index
+var fullTextColumns = []string{"CapstoneTitle", "ProjDesc", "CompanyName", "CompanyContact"}
+
+var columnWeights = map[string]float64{
+	"CapstoneTitle":  3,
+	"CompanyName":    2,
+	"CompanyContact": 2,
+	"ProjDesc":       1,
+}
+
+var searchToken = regexp.MustCompile(`(\w+):"([^"]*)"|(\w+):(\S+)|"([^"]*)"|(\S+)`)
+
+// a bare academic year, which is not in the FULLTEXT index
+var acadYrTerm = regexp.MustCompile(`^\d{4}/\d{4}$`)
+
+// ParseSearch splits a search string such as
+//
+//	carpool company:"Company A" year:2023/2024
+//
+// into free terms and field filters. Unknown field prefixes are searched as
+// plain terms, and a bare academic year such as 2023/2024 filters like year:.
+func ParseSearch(s string) SearchQuery {
+	var q SearchQuery
+	for _, m := range searchToken.FindAllStringSubmatch(s, -1) {
+		field, value := m[1]+m[3], m[2]+m[4]
+		if field != "" {
+			if f, ok := searchFields[strings.ToLower(field)]; ok {
+				if value != "" {
+					f.Value = value
+					q.Filters = append(q.Filters, f)
+				}
+				continue
+			}
+			value = field + ":" + value
+		} else if acadYrTerm.MatchString(m[6]) {
+			f := searchFields["year"]
+			f.Value = m[6]
+			q.Filters = append(q.Filters, f)
+			continue
+		} else {
+			value = m[5] + m[6]
+		}
+		q.Terms = append(q.Terms, tokenize(value)...)
+	}
+	return q
+}
+
+// tokenize lower-cases s and splits it into words
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// FullTextSearcher searches with the MySQL FULLTEXT index on the Record table
+type FullTextSearcher struct {
+	db *sql.DB
+}
+
+func NewFullTextSearcher(db *sql.DB) *FullTextSearcher {
+	return &FullTextSearcher{db: db}
+}
+
+func (s *FullTextSearcher) Search(q SearchQuery, limit int) ([]SearchResult, error) {
+	match := "MATCH(" + strings.Join(fullTextColumns, ", ") + ") AGAINST (? IN NATURAL LANGUAGE MODE)"
+	text := strings.Join(q.Terms, " ")
+
+	conds := []string{recordListSpec.Where}
+	var args []interface{}
+	score := "0"
+	if text != "" {
+		score = match
+		args = append(args, text)
+		conds = append(conds, match)
+		args = append(args, text)
+	}
+	filterConds, filterArgs := filterConditions(q.Filters)
+	conds, args = append(conds, filterConds...), append(args, filterArgs...)
+
+	stmt := "SELECT " + strings.Join(recordListSpec.Columns, ", ") + ", " + score + " AS Score FROM Record WHERE " + strings.Join(conds, " AND ")
+	stmt += " ORDER BY Score DESC, RecordID ASC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(append(recordFields(&r.Record), &r.Score)...); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// filterConditions returns the SQL conditions for search filters
+func filterConditions(filters []SearchFilter) ([]string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	for _, f := range filters {
+		if f.Exact {
+			conds = append(conds, f.Column+" = ?")
+			args = append(args, f.Value)
+		} else {
+			conds = append(conds, f.Column+" LIKE ?")
+			args = append(args, "%"+escapeLike(f.Value)+"%")
+		}
+	}
+	return conds, args
+}
+
+// matchConditions returns SQL conditions that keep the records a search
+// matches without ranking them: every filter must match and every term must
+// appear in one of the full-text columns
+func matchConditions(q SearchQuery) ([]string, []interface{}) {
+	conds, args := filterConditions(q.Filters)
+	for _, term := range q.Terms {
+		alts := make([]string, len(fullTextColumns))
+		for i, column := range fullTextColumns {
+			alts[i] = column + " LIKE ?"
+			args = append(args, "%"+escapeLike(term)+"%")
+		}
+		conds = append(conds, "("+strings.Join(alts, " OR ")+")")
+	}
+	return conds, args
+}
+
+// matches applies matchConditions to a record held in memory
+func (q SearchQuery) matches(record Record) bool {
+	if !matchesFilters(record, q.Filters) {
+		return false
+	}
+	for _, term := range q.Terms {
+		found := false
+		for _, column := range fullTextColumns {
+			if strings.Contains(strings.ToLower(columnValue(record, column)), term) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// IndexSearcher loads every record outside the trash and ranks them with an in-memory index.
+// It needs no FULLTEXT support, so it works against sqlmock and SQLite.
+type IndexSearcher struct {
+	db *sql.DB
+}
+
+func NewIndexSearcher(db *sql.DB) *IndexSearcher {
+	return &IndexSearcher{db: db}
+}
+
+func (s *IndexSearcher) Search(q SearchQuery, limit int) ([]SearchResult, error) {
+	rows, err := s.db.Query("SELECT " + strings.Join(recordListSpec.Columns, ", ") + " FROM Record WHERE " + recordListSpec.Where)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		record, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewIndex(records).Search(q, limit), nil
+}
+
+// Index is an inverted index over the full-text columns of a set of records
+type Index struct {
+	records  []Record
+	postings map[string]map[int]float64 // term -> record position -> weighted term count
+}
+
+func NewIndex(records []Record) *Index {
+	ix := &Index{records: records, postings: map[string]map[int]float64{}}
+	for i, record := range records {
+		for _, column := range fullTextColumns {
+			for _, term := range tokenize(columnValue(record, column)) {
+				if ix.postings[term] == nil {
+					ix.postings[term] = map[int]float64{}
+				}
+				ix.postings[term][i] += columnWeights[column]
+			}
+		}
+	}
+	return ix
+}
+
+// Search ranks records by tf-idf over the query terms. Like MySQL's natural
+// language mode a record needs to match only one term.
+func (ix *Index) Search(q SearchQuery, limit int) []SearchResult {
+	scores := map[int]float64{}
+	if len(q.Terms) == 0 {
+		for i := range ix.records {
+			scores[i] = 0
+		}
+	}
+	for _, term := range q.Terms {
+		postings := ix.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		idf := math.Log(1 + float64(len(ix.records))/float64(len(postings)))
+		for i, tf := range postings {
+			scores[i] += tf * idf
+		}
+	}
+
+	results := []SearchResult{}
+	for i, score := range scores {
+		if !matchesFilters(ix.records[i], q.Filters) {
+			continue
+		}
+		results = append(results, SearchResult{Record: ix.records[i], Score: score})
+	}
+
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+		return results[a].RecordID < results[b].RecordID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func matchesFilters(record Record, filters []SearchFilter) bool {
+	for _, f := range filters {
+		v := columnValue(record, f.Column)
+		if f.Exact && !strings.EqualFold(v, f.Value) {
+			return false
+		}
+		if !f.Exact && !strings.Contains(strings.ToLower(v), strings.ToLower(f.Value)) {
+			return false
+		}
+	}
+	return true
+}
+
+func columnValue(record Record, column string) string {
+	switch column {
+	case "Name":
+		return record.Name
+	case "RoleOfContact":
+		return record.RoleOfContact
+	case "AcadYr":
+		return record.AcadYr
+	case "CapstoneTitle":
+		return record.CapstoneTitle
+	case "CompanyName":
+		return record.CompanyName
+	case "CompanyContact":
+		return record.CompanyContact
+	case "ProjDesc":
+		return record.ProjDesc
+	}
+	return ""
+}
+
+// fields that get highlighted snippets, keyed by their JSON name
+var highlightFields = map[string]string{
+	"name":           "Name",
+	"capstoneTitle":  "CapstoneTitle",
+	"companyName":    "CompanyName",
+	"companyContact": "CompanyContact",
+	"projDesc":       "ProjDesc",
+}
+
+const snippetLength = 160
+
+// highlight adds snippets for every field that contains a query term or a
+// filter value. Matches are wrapped in <mark> and the rest is HTML escaped.
+func highlight(results []SearchResult, q SearchQuery) {
+	patterns := map[string]*regexp.Regexp{}
+	for field, column := range highlightFields {
+		var alts []string
+		for _, term := range q.Terms {
+			alts = append(alts, `\b`+regexp.QuoteMeta(term)+`\b`)
+		}
+		for _, f := range q.Filters {
+			if f.Column == column {
+				alts = append(alts, regexp.QuoteMeta(f.Value))
+			}
+		}
+		if len(alts) > 0 {
+			patterns[field] = regexp.MustCompile(`(?i)(` + strings.Join(alts, "|") + `)`)
+		}
+	}
+
+	for i := range results {
+		for field, re := range patterns {
+			if s, ok := snippet(columnValue(results[i].Record, highlightFields[field]), re); ok {
+				if results[i].Highlights == nil {
+					results[i].Highlights = map[string]string{}
+				}
+				results[i].Highlights[field] = s
+			}
+		}
+	}
+}
+
+// snippet returns up to snippetLength bytes of text around the first match of
+// re. A longer match is kept whole
+func snippet(text string, re *regexp.Regexp) (string, bool) {
+	first := re.FindStringIndex(text)
+	if first == nil {
+		return "", false
+	}
+
+	start, end := 0, len(text)
+	if len(text) > snippetLength {
+		start = first[0] - snippetLength/3
+		if start < 0 {
+			start = 0
+		}
+		end = start + snippetLength
+		if end > len(text) {
+			end = len(text)
+		}
+		if end < first[1] {
+			end = first[1]
+		}
+		for start > 0 && !utf8.RuneStart(text[start]) {
+			start--
+		}
+		for end < len(text) && !utf8.RuneStart(text[end]) {
+			end--
+		}
+		// Do not cut words in half
+		if start > 0 {
+			if sp := strings.IndexByte(text[start:first[0]], ' '); sp >= 0 {
+				start += sp + 1
+			}
+		}
+		if end < len(text) {
+			if sp := strings.LastIndexByte(text[first[1]:end], ' '); sp >= 0 {
+				end = first[1] + sp
+			}
+		}
+	}
+	window := text[start:end]
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	last := 0
+	for _, m := range re.FindAllStringIndex(window, -1) {
+		sb.WriteString(html.EscapeString(window[last:m[0]]))
+		sb.WriteString("<mark>" + html.EscapeString(window[m[0]:m[1]]) + "</mark>")
+		last = m[1]
+	}
+	sb.WriteString(html.EscapeString(window[last:]))
+	if end < len(text) {
+		sb.WriteString("…")
+	}
+	return sb.String(), true
+}
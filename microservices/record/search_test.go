@@ -0,0 +1,264 @@
+// search_test.go
+package record
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api" //change here
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var recordColumns = []string{"RecordID", "Name", "RoleOfContact", "NoOfStudents", "AcadYr", "CapstoneTitle", "CompanyName", "CompanyContact", "ProjDesc", "Version", "CompanyID", "ContactID"}
+
+var testRecords = []Record{
+	{1, "Zi Yi", "Staff", 4, "2021/2022", "Poverty Monitoring System", "Shaniah Corporation", "Koay YT", "Explores virtual economies and real-world poverty.", 1, 1, 1},
+	{2, "Yi Ting", "Student", 3, "2022/2023", "Carpooling System", "CompanyA", "Mr Choo CH", "A carpooling system connecting passengers and car owners.", 1, 2, 2},
+	{3, "Luke", "Student", 3, "2023/2024", "Android Based E-learning", "CompanyB", "Dr Pamela", "Mobile application for learning anytime, anywhere.", 1, 3, 3},
+}
+
+func TestParseSearch(t *testing.T) {
+	q := ParseSearch(`Carpooling "car owners" company:"Company A" year:2023/2024 note:urgent`)
+
+	expectedTerms := []string{"carpooling", "car", "owners", "note", "urgent"}
+	if !reflect.DeepEqual(q.Terms, expectedTerms) {
+		t.Errorf("ParseSearch returned wrong terms: got %v want %v", q.Terms, expectedTerms)
+	}
+
+	expectedFilters := []SearchFilter{
+		{Column: "CompanyName", Value: "Company A"},
+		{Column: "AcadYr", Value: "2023/2024", Exact: true},
+	}
+	if !reflect.DeepEqual(q.Filters, expectedFilters) {
+		t.Errorf("ParseSearch returned wrong filters: got %v want %v", q.Filters, expectedFilters)
+	}
+}
+
+func TestParseSearch_AcadYr(t *testing.T) {
+	// The year is not in the FULLTEXT index, so a bare one becomes a filter
+	q := ParseSearch(`carpooling 2023/2024 "2022/2023"`)
+
+	expectedTerms := []string{"carpooling", "2022", "2023"}
+	if !reflect.DeepEqual(q.Terms, expectedTerms) {
+		t.Errorf("ParseSearch returned wrong terms: got %v want %v", q.Terms, expectedTerms)
+	}
+
+	expectedFilters := []SearchFilter{{Column: "AcadYr", Value: "2023/2024", Exact: true}}
+	if !reflect.DeepEqual(q.Filters, expectedFilters) {
+		t.Errorf("ParseSearch returned wrong filters: got %v want %v", q.Filters, expectedFilters)
+	}
+}
+
+func TestIndex_Search(t *testing.T) {
+	ix := NewIndex(testRecords)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{"title ranks above description", "system", []int{2, 1}},
+		{"any term matches", "poverty learning", []int{1, 3}},
+		{"company filter", "company:companyb", []int{3}},
+		{"term and filter", "system year:2022/2023", []int{2}},
+		{"bare year", "2023/2024", []int{3}},
+		{"no terms lists everything", "", []int{1, 2, 3}},
+		{"no match", "blockchain", []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := ix.Search(ParseSearch(tt.query), 10)
+
+			ids := []int{}
+			for _, r := range results {
+				ids = append(ids, r.RecordID)
+			}
+			if !reflect.DeepEqual(ids, tt.expected) {
+				t.Errorf("Search returned wrong records: got %v want %v", ids, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFullTextSearcher(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	match := "MATCH(CapstoneTitle, ProjDesc, CompanyName, CompanyContact) AGAINST (? IN NATURAL LANGUAGE MODE)"
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, Version, CompanyID, ContactID, "+match+" AS Score FROM Record WHERE DeletedAt IS NULL AND "+match+" AND CompanyName LIKE ? AND AcadYr = ? ORDER BY Score DESC, RecordID ASC LIMIT ?")).
+		WithArgs("carpooling", "carpooling", `%100\%%`, "2022/2023", 5).
+		WillReturnRows(sqlmock.NewRows(append(recordColumns, "Score")).
+			AddRow(2, "Yi Ting", "Student", 3, "2022/2023", "Carpooling System", "CompanyA", "Mr Choo CH", "A carpooling system", 1, 2, 2, 1.5))
+
+	results, err := NewFullTextSearcher(db).Search(ParseSearch("carpooling company:100% year:2022/2023"), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].RecordID != 2 || results[0].Score != 1.5 {
+		t.Errorf("Search returned unexpected results: %+v", results)
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestIndexSearcher(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// The index reads every record outside the trash
+	rows := sqlmock.NewRows(recordColumns)
+	for _, r := range testRecords {
+		rows.AddRow(r.RecordID, r.Name, r.RoleOfContact, r.NoOfStudents, r.AcadYr, r.CapstoneTitle, r.CompanyName, r.CompanyContact, r.ProjDesc, r.Version, r.CompanyID, r.ContactID)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, Version, CompanyID, ContactID FROM Record WHERE DeletedAt IS NULL")).
+		WillReturnRows(rows)
+
+	results, err := NewIndexSearcher(db).Search(ParseSearch("carpooling"), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].RecordID != 2 {
+		t.Errorf("Search returned unexpected results: %+v", results)
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestQueryRecordHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		f.seed(testRecords...)
+
+		// The MySQL store ranks with the FULLTEXT index
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			r := testRecords[1]
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, Version, CompanyID, ContactID, MATCH(")).
+				WithArgs("carpooling", "carpooling", "%companya%", defaultSearchLimit).
+				WillReturnRows(sqlmock.NewRows(append(recordColumns, "Score")).
+					AddRow(r.RecordID, r.Name, r.RoleOfContact, r.NoOfStudents, r.AcadYr, r.CapstoneTitle, r.CompanyName, r.CompanyContact, r.ProjDesc, r.Version, r.CompanyID, r.ContactID, 1.5))
+		})
+
+		req, err := http.NewRequest("GET", "/api/v1/records/search?q=carpooling+company:companya", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		// Call the handler
+		f.server().QueryRecordHandler(rr, req)
+
+		// Check the status code
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		// Check the response body
+		var results []SearchResult
+		if err := json.NewDecoder(rr.Body).Decode(&results); err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].RecordID != 2 {
+			t.Fatalf("Handler returned unexpected results: %+v", results)
+		}
+		expectedHighlights := map[string]string{
+			"capstoneTitle": "<mark>Carpooling</mark> System",
+			"companyName":   "<mark>CompanyA</mark>",
+			"projDesc":      "A <mark>carpooling</mark> system connecting passengers and car owners.",
+		}
+		if !reflect.DeepEqual(results[0].Highlights, expectedHighlights) {
+			t.Errorf("Handler returned wrong highlights: got %v want %v", results[0].Highlights, expectedHighlights)
+		}
+	})
+}
+
+func TestQueryRecordHandler_Errors(t *testing.T) {
+	s, mock := mysqlServer(t)
+
+	req, err := http.NewRequest("GET", "/api/v1/records/search?q=x&limit=none", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	s.QueryRecordHandler(rr, req)
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	expectErrorCode(t, rr, api.CodeInvalidParameter)
+
+	mock.ExpectQuery("SELECT (.+) FROM Record").
+		WillReturnError(errors.New("database error"))
+
+	req, err = http.NewRequest("GET", "/api/v1/records/search?q=x", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	s.QueryRecordHandler(rr, req)
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+	}
+	expectErrorCode(t, rr, api.CodeInternal)
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := strings.Repeat("filler ", 40) + "the <carpool> app " + strings.Repeat("more ", 40)
+	re := regexp.MustCompile(`(?i)(\bcarpool\b)`)
+
+	s, ok := snippet(text, re)
+	if !ok {
+		t.Fatal("snippet found no match")
+	}
+	if !strings.Contains(s, "&lt;<mark>carpool</mark>&gt;") {
+		t.Errorf("snippet did not highlight and escape the match: %q", s)
+	}
+	if !strings.HasPrefix(s, "…") || !strings.HasSuffix(s, "…") || len(s) > snippetLength+40 {
+		t.Errorf("snippet was not trimmed around the match: %q", s)
+	}
+
+	if _, ok := snippet("nothing here", re); ok {
+		t.Errorf("snippet matched text without the term")
+	}
+}
+
+func TestHighlight_LongFilter(t *testing.T) {
+	// A filter match longer than the part of the window after its start is
+	// kept whole instead of cutting the window short
+	phrase := strings.Repeat("a carpooling system for drivers ", 5)
+	results := []SearchResult{{Record: Record{ProjDesc: strings.Repeat("filler ", 40) + phrase + strings.Repeat("more ", 40)}}}
+	highlight(results, ParseSearch(`desc:"`+strings.TrimSpace(phrase)+`"`))
+
+	s := results[0].Highlights["projDesc"]
+	if !strings.Contains(s, "<mark>"+strings.TrimSpace(phrase)+"</mark>") {
+		t.Errorf("highlight did not keep the long match whole: %q", s)
+	}
+	if !strings.HasPrefix(s, "…") || !strings.HasSuffix(s, "…") {
+		t.Errorf("highlight was not trimmed around the long match: %q", s)
+	}
+}
@@ -0,0 +1,1355 @@
+package record
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/query" //change here
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// RecordStore persists capstone records. Every change is written to the
+// audit log together with the change itself, attributed to by, and every
+// state a record takes is kept as a revision numbered by its version.
+// Changing a missing record returns ErrNotFound. Deleted records move to the
+// trash, where only Trash, Restore and Purge see them. Creating or changing
+// a record resolves its company and contact as resolveReferences describes.
+type RecordStore interface {
+	CompanyStore
+	Create(rec Record, by audit.Actor) (int, error)
+	// CreateMany creates every record or none of them. On success recs hold
+	// the records as created, with their IDs and resolved references.
+	CreateMany(recs []Record, by audit.Actor) ([]int, error)
+	// Get returns a record outside the trash, or ErrNotFound
+	Get(recordID int) (Record, error)
+	List(p query.Params) (query.Page[Record], error)
+	// Update replaces the record at rec.Version, or at any version when it
+	// is 0, and returns ErrVersionConflict when the record has moved on
+	Update(rec Record, by audit.Actor) error
+	// Patch is Update for only the named columns, which are set to their
+	// values in rec; the other columns keep whatever they hold
+	Patch(rec Record, columns []string, by audit.Actor) error
+	Delete(recordID int, by audit.Actor) error
+	Trash(p query.Params) (query.Page[DeletedRecord], error)
+	// Restore takes a record out of the trash, or returns ErrNotFound
+	Restore(recordID int, by audit.Actor) error
+	// Purge permanently removes records deleted before the given time and
+	// returns how many there were
+	Purge(before time.Time, by audit.Actor) (int, error)
+	// Revisions lists the revisions of a record
+	Revisions(recordID int, p query.Params) (query.Page[Revision], error)
+	// Revision returns one revision of a record, or ErrNotFound
+	Revision(recordID, revision int) (Revision, error)
+	// Rollback sets a record back to an earlier revision, which is stored
+	// again as the newest revision. It returns ErrNotFound when the record or
+	// the revision does not exist.
+	Rollback(recordID, revision int, by audit.Actor) error
+	Search(q SearchQuery, limit int) ([]SearchResult, error)
+	// Export calls fn with every record matching the list filters of p and
+	// the search q, in the order of p, as the records are read
+	Export(p query.Params, q SearchQuery, fn func(Record) error) error
+	// Ping reports whether the backing database is reachable
+	Ping(ctx context.Context) error
+}
+
+var (
+	// ErrNotFound is returned when a record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrVersionConflict is returned when a record changed since the caller read it
+	ErrVersionConflict = errors.New("record version conflict")
+)
+
+// BatchError reports which record of a CreateMany call could not be created
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("record %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// MySQLStore keeps records in the Record table
+type MySQLStore struct {
+	db *sql.DB
+	// Searcher runs searches; replace it with NewIndexSearcher for databases
+	// without FULLTEXT support
+	Searcher Searcher
+}
+
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db, Searcher: NewFullTextSearcher(db)}
+}
+
+func (s *MySQLStore) Create(rec Record, by audit.Actor) (int, error) {
+	ids, err := s.CreateMany([]Record{rec}, by)
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		return 0, batchErr.Err
+	} else if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+func (s *MySQLStore) CreateMany(recs []Record, by audit.Actor) ([]int, error) {
+	ids := make([]int, len(recs))
+	err := audit.InTx(s.db, func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare("INSERT INTO Record (Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, CompanyID, ContactID) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for i, rec := range recs {
+			if err := resolveReferences(tx, &rec, by); err != nil {
+				return &BatchError{Index: i, Err: err}
+			}
+			res, err := stmt.Exec(rec.Name, rec.RoleOfContact, rec.NoOfStudents, rec.AcadYr, rec.CapstoneTitle, rec.CompanyName, rec.CompanyContact, rec.ProjDesc, rec.CompanyID, rec.ContactID)
+			if err != nil {
+				return &BatchError{Index: i, Err: err}
+			}
+
+			id, err := res.LastInsertId()
+			if err != nil {
+				return &BatchError{Index: i, Err: err}
+			}
+			rec.RecordID, rec.Version = int(id), 1
+			ids[i] = rec.RecordID
+			recs[i] = rec
+
+			if err := addRevision(tx, rec, by); err != nil {
+				return err
+			}
+			if err := audit.Write(tx, by, audit.Change{Action: audit.ActionCreate, Entity: audit.EntityRecord, EntityID: rec.RecordID, After: rec}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (s *MySQLStore) Get(recordID int) (Record, error) {
+	rec, ok, err := getRecord(s.db, recordID, "")
+	if err == nil && !ok {
+		return Record{}, ErrNotFound
+	}
+	return rec, err
+}
+
+func (s *MySQLStore) List(p query.Params) (query.Page[Record], error) {
+	return query.List(s.db, recordListSpec, p, scanRecord, recordValue)
+}
+
+const updateRecord = "UPDATE Record SET Name=?, RoleOfContact=?, NoOfStudents=?, AcadYr=?, CapstoneTitle=?, CompanyName=?, CompanyContact=?, ProjDesc=?, CompanyID=?, ContactID=?, Version=Version+1 WHERE RecordID=?"
+
+func (s *MySQLStore) Update(rec Record, by audit.Actor) error {
+	return s.change(rec.RecordID, audit.ActionUpdate, by, rec.Version, replaceWith(rec, by))
+}
+
+// replaceWith sets every column of a record to those of rec, once its
+// references are resolved
+func replaceWith(rec Record, by audit.Actor) statement {
+	return func(tx *sql.Tx, _ Record) (string, []interface{}, error) {
+		if err := resolveReferences(tx, &rec, by); err != nil {
+			return "", nil, err
+		}
+		return updateRecord, []interface{}{rec.Name, rec.RoleOfContact, rec.NoOfStudents, rec.AcadYr, rec.CapstoneTitle, rec.CompanyName, rec.CompanyContact, rec.ProjDesc, rec.CompanyID, rec.ContactID, rec.RecordID}, nil
+	}
+}
+
+func (s *MySQLStore) Patch(rec Record, columns []string, by audit.Actor) error {
+	return s.change(rec.RecordID, audit.ActionUpdate, by, rec.Version, func(tx *sql.Tx, before Record) (string, []interface{}, error) {
+		// References are resolved from the record as the patch leaves it
+		if changesReferences(columns) {
+			after := before
+			setColumns(&after, rec, columns)
+			columns = patchReferences(&after, columns)
+			if err := resolveReferences(tx, &after, by); err != nil {
+				return "", nil, err
+			}
+			rec = after
+		}
+
+		args := make([]interface{}, 0, len(columns)+1)
+		for _, column := range columns {
+			args = append(args, recordValue(rec, column))
+		}
+		q := "UPDATE Record SET " + strings.Join(columns, "=?, ") + "=?, Version=Version+1 WHERE RecordID=?"
+		return q, append(args, rec.RecordID), nil
+	})
+}
+
+func (s *MySQLStore) Delete(recordID int, by audit.Actor) error {
+	return s.change(recordID, audit.ActionDelete, by, 0, fixed("UPDATE Record SET DeletedAt = UTC_TIMESTAMP(), DeletedBy = ? WHERE RecordID = ?", by.ID(), recordID))
+}
+
+func (s *MySQLStore) Trash(p query.Params) (query.Page[DeletedRecord], error) {
+	return query.List(s.db, recordTrashSpec, p, scanDeletedRecord, deletedRecordValue)
+}
+
+func (s *MySQLStore) Restore(recordID int, by audit.Actor) error {
+	return audit.InTx(s.db, func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT "+strings.Join(recordTrashSpec.Columns, ", ")+" FROM Record WHERE RecordID = ? AND DeletedAt IS NOT NULL FOR UPDATE", recordID)
+		if err != nil {
+			return err
+		}
+		before, err := scanAll(rows, scanDeletedRecord)
+		if err != nil {
+			return err
+		}
+		if len(before) == 0 {
+			return ErrNotFound
+		}
+
+		if _, err := tx.Exec("UPDATE Record SET DeletedAt = NULL, DeletedBy = NULL WHERE RecordID = ?", recordID); err != nil {
+			return err
+		}
+
+		after, _, err := getRecord(tx, recordID, "")
+		if err != nil {
+			return err
+		}
+		return audit.Write(tx, by, audit.Change{Action: audit.ActionRestore, Entity: audit.EntityRecord, EntityID: recordID, Before: before[0], After: after})
+	})
+}
+
+func (s *MySQLStore) Purge(before time.Time, by audit.Actor) (int, error) {
+	cutoff := before.UTC().Format(audit.TimeFormat)
+
+	var purged []DeletedRecord
+	err := audit.InTx(s.db, func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT "+strings.Join(recordTrashSpec.Columns, ", ")+" FROM Record WHERE DeletedAt < ? FOR UPDATE", cutoff)
+		if err != nil {
+			return err
+		}
+		if purged, err = scanAll(rows, scanDeletedRecord); err != nil || len(purged) == 0 {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM Record WHERE DeletedAt < ?", cutoff); err != nil {
+			return err
+		}
+
+		for _, rec := range purged {
+			if err := audit.Write(tx, by, audit.Change{Action: audit.ActionPurge, Entity: audit.EntityRecord, EntityID: rec.RecordID, Before: rec}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(purged), nil
+}
+
+func (s *MySQLStore) Revisions(recordID int, p query.Params) (query.Page[Revision], error) {
+	return query.List(s.db, revisionListSpec, forRecord(p, recordID), scanRevision, revisionValue)
+}
+
+func (s *MySQLStore) Revision(recordID, revision int) (Revision, error) {
+	return getRevision(s.db, recordID, revision)
+}
+
+func (s *MySQLStore) Rollback(recordID, revision int, by audit.Actor) error {
+	return audit.InTx(s.db, func(tx *sql.Tx) error {
+		rev, err := getRevision(tx, recordID, revision)
+		if err != nil {
+			return err
+		}
+
+		// The company and contact are found by ID, so their current names are
+		// used; one deleted since is found by name again
+		if err := dropDeleted(tx, &rev.Record); err != nil {
+			return err
+		}
+		return changeTx(tx, recordID, audit.ActionRollback, by, 0, replaceWith(rev.Record, by))
+	})
+}
+
+// scanAll reads every row and closes rows
+func scanAll[T any](rows *sql.Rows, scan func(*sql.Rows) (T, error)) ([]T, error) {
+	defer rows.Close()
+
+	var items []T
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *MySQLStore) Search(q SearchQuery, limit int) ([]SearchResult, error) {
+	return s.Searcher.Search(q, limit)
+}
+
+func (s *MySQLStore) Export(p query.Params, q SearchQuery, fn func(Record) error) error {
+	// The search conditions join the fixed condition, ahead of the filters
+	spec := recordListSpec
+	conds, args := matchConditions(q)
+	spec.Where = strings.Join(append([]string{spec.Where}, conds...), " AND ")
+
+	stmt, listArgs := spec.SelectAll(p)
+	return query.Each(s.db, stmt, append(args, listArgs...), scanRecord, fn)
+}
+
+func (s *MySQLStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// getRecord reads a record that is not in the trash; lock is appended to the
+// query, e.g. " FOR UPDATE"
+func getRecord(q queryer, recordID int, lock string) (Record, bool, error) {
+	var rec Record
+	err := q.QueryRow("SELECT "+strings.Join(recordListSpec.Columns, ", ")+" FROM Record WHERE RecordID = ? AND DeletedAt IS NULL"+lock, recordID).
+		Scan(recordFields(&rec)...)
+	if err == sql.ErrNoRows {
+		return Record{}, false, nil
+	}
+	return rec, err == nil, err
+}
+
+// statement returns the query and arguments a change runs, given the record
+// as it was locked before the change
+type statement func(tx *sql.Tx, before Record) (string, []interface{}, error)
+
+// fixed is a statement that does not depend on the record
+func fixed(q string, args ...interface{}) statement {
+	return func(*sql.Tx, Record) (string, []interface{}, error) {
+		return q, args, nil
+	}
+}
+
+// change runs one prepared statement against a record and logs the record
+// as it was before and after, all in one transaction. It returns ErrNotFound
+// for a missing record. A version other than 0 must match the record's
+// current version.
+func (s *MySQLStore) change(recordID int, action string, by audit.Actor, version int, stmt statement) error {
+	return audit.InTx(s.db, func(tx *sql.Tx) error {
+		return changeTx(tx, recordID, action, by, version, stmt)
+	})
+}
+
+// changeTx is change inside an open transaction. Any state but a deletion is
+// also stored as a new revision.
+func changeTx(tx *sql.Tx, recordID int, action string, by audit.Actor, version int, build statement) error {
+	before, ok, err := getRecord(tx, recordID, " FOR UPDATE")
+	if err != nil {
+		return err
+	} else if !ok {
+		return ErrNotFound
+	}
+	if version != 0 && version != before.Version {
+		return ErrVersionConflict
+	}
+
+	q, args, err := build(tx, before)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(q)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(args...); err != nil {
+		return err
+	}
+
+	c := audit.Change{Action: action, Entity: audit.EntityRecord, EntityID: recordID, Before: before}
+	if action != audit.ActionDelete {
+		after, _, err := getRecord(tx, recordID, "")
+		if err != nil {
+			return err
+		}
+		if err := addRevision(tx, after, by); err != nil {
+			return err
+		}
+		c.After = after
+	}
+	return audit.Write(tx, by, c)
+}
+
+// addRevision stores rec as the revision numbered by its version
+func addRevision(tx *sql.Tx, rec Record, by audit.Actor) error {
+	_, err := tx.Exec("INSERT INTO RecordRevision (RecordID, Revision, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, CompanyID, ContactID, CreatedBy, CreatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())",
+		rec.RecordID, rec.Version, rec.Name, rec.RoleOfContact, rec.NoOfStudents, rec.AcadYr, rec.CapstoneTitle, rec.CompanyName, rec.CompanyContact, rec.ProjDesc, rec.CompanyID, rec.ContactID, by.ID())
+	return err
+}
+
+func getRevision(q queryer, recordID, revision int) (Revision, error) {
+	rev, err := readRevision(q.QueryRow("SELECT "+strings.Join(revisionListSpec.Columns, ", ")+" FROM RecordRevision WHERE RecordID = ? AND Revision = ?", recordID, revision))
+	if err == sql.ErrNoRows {
+		return Revision{}, ErrNotFound
+	}
+	return rev, err
+}
+
+// dropDeleted clears the IDs of a company or contact that no longer exists,
+// so resolveReferences finds them by name instead
+func dropDeleted(tx *sql.Tx, rec *Record) error {
+	if rec.CompanyID != 0 {
+		if _, err := getCompany(tx, "", "CompanyID = ?", rec.CompanyID); err == ErrCompanyNotFound {
+			rec.CompanyID, rec.ContactID = 0, 0
+		} else if err != nil {
+			return err
+		}
+	}
+	if rec.ContactID != 0 {
+		if _, err := getContact(tx, "", "ContactID = ?", rec.ContactID); err == ErrContactNotFound {
+			rec.ContactID = 0
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveReferences points rec at its company and contact and copies their
+// names into it. Each is found by ID when rec has one and by name otherwise,
+// and is created when no name matches, so clients that only send names keep
+// working. An unknown ID, or a contact of another company, is reported as
+// validate.Errors. The rows found are share-locked, so a rename running at
+// the same time waits for the transaction and then renames rec too. When
+// another transaction creates the same name first, its row is used instead.
+func resolveReferences(tx *sql.Tx, rec *Record, by audit.Actor) error {
+	var company Company
+	var err error
+	if rec.CompanyID != 0 {
+		if company, err = getCompany(tx, " FOR SHARE", "CompanyID = ?", rec.CompanyID); err == ErrCompanyNotFound {
+			return unknownCompany
+		}
+	} else if company, err = getCompany(tx, " FOR SHARE", "NameKey = ?", nameKey(rec.CompanyName)); err == ErrCompanyNotFound {
+		company, err = createCompany(tx, Company{Name: strings.TrimSpace(rec.CompanyName)}, by)
+		// A locking read sees the row the other transaction committed
+		if err == ErrNameInUse {
+			company, err = getCompany(tx, " FOR SHARE", "NameKey = ?", nameKey(rec.CompanyName))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	var contact Contact
+	if rec.ContactID != 0 {
+		contact, err = getContact(tx, " FOR SHARE", "ContactID = ?", rec.ContactID)
+		if err == ErrContactNotFound || err == nil && contact.CompanyID != company.CompanyID {
+			return unknownContact
+		}
+	} else if contact, err = getContact(tx, " FOR SHARE", "CompanyID = ? AND NameKey = ?", company.CompanyID, nameKey(rec.CompanyContact)); err == ErrContactNotFound {
+		contact, err = createContact(tx, Contact{CompanyID: company.CompanyID, Name: strings.TrimSpace(rec.CompanyContact)}, by)
+		if err == ErrNameInUse {
+			contact, err = getContact(tx, " FOR SHARE", "CompanyID = ? AND NameKey = ?", company.CompanyID, nameKey(rec.CompanyContact))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	rec.CompanyID, rec.CompanyName = company.CompanyID, company.Name
+	rec.ContactID, rec.CompanyContact = contact.ContactID, contact.Name
+	return nil
+}
+
+// getCompany reads the company matching where; lock is appended to the query
+func getCompany(q queryer, lock, where string, args ...interface{}) (Company, error) {
+	var c Company
+	err := q.QueryRow("SELECT "+strings.Join(companyListSpec.Columns, ", ")+" FROM Company WHERE "+where+lock, args...).
+		Scan(&c.CompanyID, &c.Name, &c.Version)
+	if err == sql.ErrNoRows {
+		return Company{}, ErrCompanyNotFound
+	}
+	return c, err
+}
+
+// getContact reads the contact matching where; lock is appended to the query
+func getContact(q queryer, lock, where string, args ...interface{}) (Contact, error) {
+	var c Contact
+	err := q.QueryRow("SELECT "+strings.Join(contactListSpec.Columns, ", ")+" FROM Contact WHERE "+where+lock, args...).
+		Scan(&c.ContactID, &c.CompanyID, &c.Name, &c.Version)
+	if err == sql.ErrNoRows {
+		return Contact{}, ErrContactNotFound
+	}
+	return c, err
+}
+
+// isDuplicateKey reports whether a statement broke a unique index, which for
+// companies and contacts means another transaction took the name first
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+// createCompany inserts a company, or returns ErrNameInUse when its name was
+// taken after the caller looked
+func createCompany(tx *sql.Tx, c Company, by audit.Actor) (Company, error) {
+	res, err := tx.Exec("INSERT INTO Company (Name, NameKey) VALUES (?, ?)", c.Name, nameKey(c.Name))
+	if isDuplicateKey(err) {
+		return Company{}, ErrNameInUse
+	} else if err != nil {
+		return Company{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Company{}, err
+	}
+	c.CompanyID, c.Version = int(id), 1
+	return c, audit.Write(tx, by, audit.Change{Action: audit.ActionCreate, Entity: audit.EntityCompany, EntityID: c.CompanyID, After: c})
+}
+
+// createContact inserts a contact, or returns ErrNameInUse when its name was
+// taken in the company after the caller looked
+func createContact(tx *sql.Tx, c Contact, by audit.Actor) (Contact, error) {
+	res, err := tx.Exec("INSERT INTO Contact (CompanyID, Name, NameKey) VALUES (?, ?, ?)", c.CompanyID, c.Name, nameKey(c.Name))
+	if isDuplicateKey(err) {
+		return Contact{}, ErrNameInUse
+	} else if err != nil {
+		return Contact{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Contact{}, err
+	}
+	c.ContactID, c.Version = int(id), 1
+	return c, audit.Write(tx, by, audit.Change{Action: audit.ActionCreate, Entity: audit.EntityContact, EntityID: c.ContactID, After: c})
+}
+
+// renameOnRecords copies a new company or contact name to every record that
+// refers to it, in the trash or not, as a new version, revision and audit
+// entry of each
+func renameOnRecords(tx *sql.Tx, nameColumn, idColumn, name string, id int, by audit.Actor) error {
+	rows, err := tx.Query("SELECT "+strings.Join(recordListSpec.Columns, ", ")+" FROM Record WHERE "+idColumn+" = ? ORDER BY RecordID FOR UPDATE", id)
+	if err != nil {
+		return err
+	}
+	befores, err := scanAll(rows, scanRecord)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE Record SET "+nameColumn+" = ?, Version = Version + 1 WHERE "+idColumn+" = ?", name, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO RecordRevision (RecordID, Revision, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, CompanyID, ContactID, CreatedBy, CreatedAt) "+
+		"SELECT RecordID, Version, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, CompanyID, ContactID, ?, UTC_TIMESTAMP() FROM Record WHERE "+idColumn+" = ?", by.ID(), id); err != nil {
+		return err
+	}
+
+	for _, before := range befores {
+		after := before
+		if nameColumn == "CompanyName" {
+			after.CompanyName = name
+		} else {
+			after.CompanyContact = name
+		}
+		after.Version++
+		if err := audit.Write(tx, by, audit.Change{Action: audit.ActionUpdate, Entity: audit.EntityRecord, EntityID: before.RecordID, Before: before, After: after}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkUnused returns ErrInUse when a record, in the trash or not, refers to
+// the company or contact
+func checkUnused(tx *sql.Tx, idColumn string, id int) error {
+	var n int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM Record WHERE "+idColumn+" = ?", id).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrInUse
+	}
+	return nil
+}
+
+func (s *MySQLStore) CreateCompany(c Company, by audit.Actor) (int, error) {
+	err := audit.InTx(s.db, func(tx *sql.Tx) error {
+		if _, err := getCompany(tx, "", "NameKey = ?", nameKey(c.Name)); err == nil {
+			return ErrNameInUse
+		} else if err != ErrCompanyNotFound {
+			return err
+		}
+		created, err := createCompany(tx, c, by)
+		c = created
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return c.CompanyID, nil
+}
+
+func (s *MySQLStore) GetCompany(companyID int) (Company, error) {
+	return getCompany(s.db, "", "CompanyID = ?", companyID)
+}
+
+func (s *MySQLStore) ListCompanies(p query.Params) (query.Page[Company], error) {
+	return query.List(s.db, companyListSpec, p, scanCompany, companyValue)
+}
+
+func (s *MySQLStore) UpdateCompany(c Company, by audit.Actor) error {
+	return audit.InTx(s.db, func(tx *sql.Tx) error {
+		before, err := getCompany(tx, " FOR UPDATE", "CompanyID = ?", c.CompanyID)
+		if err != nil {
+			return err
+		}
+		if c.Version != 0 && c.Version != before.Version {
+			return ErrVersionConflict
+		}
+		if other, err := getCompany(tx, "", "NameKey = ?", nameKey(c.Name)); err == nil && other.CompanyID != c.CompanyID {
+			return ErrNameInUse
+		} else if err != nil && err != ErrCompanyNotFound {
+			return err
+		}
+
+		if _, err := tx.Exec("UPDATE Company SET Name = ?, NameKey = ?, Version = Version + 1 WHERE CompanyID = ?", c.Name, nameKey(c.Name), c.CompanyID); isDuplicateKey(err) {
+			return ErrNameInUse
+		} else if err != nil {
+			return err
+		}
+		if c.Name != before.Name {
+			if err := renameOnRecords(tx, "CompanyName", "CompanyID", c.Name, c.CompanyID, by); err != nil {
+				return err
+			}
+		}
+		c.Version = before.Version + 1
+		return audit.Write(tx, by, audit.Change{Action: audit.ActionUpdate, Entity: audit.EntityCompany, EntityID: c.CompanyID, Before: before, After: c})
+	})
+}
+
+func (s *MySQLStore) DeleteCompany(companyID int, by audit.Actor) error {
+	return audit.InTx(s.db, func(tx *sql.Tx) error {
+		before, err := getCompany(tx, " FOR UPDATE", "CompanyID = ?", companyID)
+		if err != nil {
+			return err
+		}
+		if err := checkUnused(tx, "CompanyID", companyID); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM Contact WHERE CompanyID = ?", companyID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM Company WHERE CompanyID = ?", companyID); err != nil {
+			return err
+		}
+		return audit.Write(tx, by, audit.Change{Action: audit.ActionDelete, Entity: audit.EntityCompany, EntityID: companyID, Before: before})
+	})
+}
+
+func (s *MySQLStore) CreateContact(c Contact, by audit.Actor) (int, error) {
+	err := audit.InTx(s.db, func(tx *sql.Tx) error {
+		if _, err := getCompany(tx, " FOR SHARE", "CompanyID = ?", c.CompanyID); err != nil {
+			return err
+		}
+		if _, err := getContact(tx, "", "CompanyID = ? AND NameKey = ?", c.CompanyID, nameKey(c.Name)); err == nil {
+			return ErrNameInUse
+		} else if err != ErrContactNotFound {
+			return err
+		}
+		created, err := createContact(tx, c, by)
+		c = created
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return c.ContactID, nil
+}
+
+func (s *MySQLStore) GetContact(contactID int) (Contact, error) {
+	return getContact(s.db, "", "ContactID = ?", contactID)
+}
+
+func (s *MySQLStore) ListContacts(companyID int, p query.Params) (query.Page[Contact], error) {
+	return query.List(s.db, contactListSpec, forCompany(p, companyID), scanContact, contactValue)
+}
+
+func (s *MySQLStore) UpdateContact(c Contact, by audit.Actor) error {
+	return audit.InTx(s.db, func(tx *sql.Tx) error {
+		before, err := getContact(tx, " FOR UPDATE", "ContactID = ?", c.ContactID)
+		if err != nil {
+			return err
+		}
+		if c.Version != 0 && c.Version != before.Version {
+			return ErrVersionConflict
+		}
+		c.CompanyID = before.CompanyID
+		if other, err := getContact(tx, "", "CompanyID = ? AND NameKey = ?", c.CompanyID, nameKey(c.Name)); err == nil && other.ContactID != c.ContactID {
+			return ErrNameInUse
+		} else if err != nil && err != ErrContactNotFound {
+			return err
+		}
+
+		if _, err := tx.Exec("UPDATE Contact SET Name = ?, NameKey = ?, Version = Version + 1 WHERE ContactID = ?", c.Name, nameKey(c.Name), c.ContactID); isDuplicateKey(err) {
+			return ErrNameInUse
+		} else if err != nil {
+			return err
+		}
+		if c.Name != before.Name {
+			if err := renameOnRecords(tx, "CompanyContact", "ContactID", c.Name, c.ContactID, by); err != nil {
+				return err
+			}
+		}
+		c.Version = before.Version + 1
+		return audit.Write(tx, by, audit.Change{Action: audit.ActionUpdate, Entity: audit.EntityContact, EntityID: c.ContactID, Before: before, After: c})
+	})
+}
+
+func (s *MySQLStore) DeleteContact(contactID int, by audit.Actor) error {
+	return audit.InTx(s.db, func(tx *sql.Tx) error {
+		before, err := getContact(tx, " FOR UPDATE", "ContactID = ?", contactID)
+		if err != nil {
+			return err
+		}
+		if err := checkUnused(tx, "ContactID", contactID); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM Contact WHERE ContactID = ?", contactID); err != nil {
+			return err
+		}
+		return audit.Write(tx, by, audit.Change{Action: audit.ActionDelete, Entity: audit.EntityContact, EntityID: contactID, Before: before})
+	})
+}
+
+// MemoryStore keeps records in memory for tests and local development
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[int]Record
+	deleted map[int]DeletedRecord
+	// revisions of every record, oldest first
+	revisions map[int][]Revision
+	nextID    int
+	companies map[int]Company
+	contacts  map[int]Contact
+	// next IDs of companies and contacts
+	nextCompanyID, nextContactID int
+	// Log receives an entry for every change
+	Log *audit.MemoryLog
+}
+
+// NewMemoryStore returns a store holding the given records, which keep their
+// ids. The companies and contacts the records refer to by ID are added with
+// the names the records carry.
+func NewMemoryStore(records ...Record) *MemoryStore {
+	s := &MemoryStore{
+		records:       make(map[int]Record),
+		deleted:       make(map[int]DeletedRecord),
+		revisions:     make(map[int][]Revision),
+		nextID:        1,
+		companies:     make(map[int]Company),
+		contacts:      make(map[int]Contact),
+		nextCompanyID: 1,
+		nextContactID: 1,
+		Log:           audit.NewMemoryLog(),
+	}
+	for _, rec := range records {
+		rec.Version = max(rec.Version, 1)
+		s.records[rec.RecordID] = rec
+		s.addRevision(rec, audit.Actor{})
+		if rec.RecordID >= s.nextID {
+			s.nextID = rec.RecordID + 1
+		}
+
+		if _, ok := s.companies[rec.CompanyID]; rec.CompanyID != 0 && !ok {
+			s.companies[rec.CompanyID] = Company{CompanyID: rec.CompanyID, Name: rec.CompanyName, Version: 1}
+			s.nextCompanyID = max(s.nextCompanyID, rec.CompanyID+1)
+		}
+		if _, ok := s.contacts[rec.ContactID]; rec.ContactID != 0 && !ok {
+			s.contacts[rec.ContactID] = Contact{ContactID: rec.ContactID, CompanyID: rec.CompanyID, Name: rec.CompanyContact, Version: 1}
+			s.nextContactID = max(s.nextContactID, rec.ContactID+1)
+		}
+	}
+	return s
+}
+
+func (s *MemoryStore) Create(rec Record, by audit.Actor) (int, error) {
+	ids, err := s.CreateMany([]Record{rec}, by)
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+func (s *MemoryStore) CreateMany(recs []Record, by audit.Actor) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Check every reference first so a bad one leaves no record behind
+	for i, rec := range recs {
+		if err := s.checkReferences(rec); err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+	}
+
+	ids := make([]int, len(recs))
+	for i, rec := range recs {
+		if err := s.resolveReferences(&rec, by); err != nil {
+			return nil, err
+		}
+		rec.RecordID, rec.Version = s.nextID, 1
+		s.nextID++
+		s.records[rec.RecordID] = rec
+		ids[i] = rec.RecordID
+		recs[i] = rec
+
+		s.addRevision(rec, by)
+		if err := s.Log.Write(by, audit.Change{Action: audit.ActionCreate, Entity: audit.EntityRecord, EntityID: rec.RecordID, After: rec}); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+func (s *MemoryStore) Get(recordID int) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[recordID]
+	if !ok {
+		return Record{}, ErrNotFound
+	}
+	return rec, nil
+}
+
+func (s *MemoryStore) List(p query.Params) (query.Page[Record], error) {
+	return query.ListSlice(s.all(), p, recordValue), nil
+}
+
+func (s *MemoryStore) Update(rec Record, by audit.Actor) error {
+	return s.update(rec.RecordID, rec.Version, by, func(stored *Record) error {
+		*stored = rec
+		return s.resolveReferences(stored, by)
+	})
+}
+
+func (s *MemoryStore) Patch(rec Record, columns []string, by audit.Actor) error {
+	return s.update(rec.RecordID, rec.Version, by, func(stored *Record) error {
+		setColumns(stored, rec, columns)
+		if !changesReferences(columns) {
+			return nil
+		}
+		patchReferences(stored, columns)
+		return s.resolveReferences(stored, by)
+	})
+}
+
+// setColumns sets the named columns of dst to their values in src
+func setColumns(dst *Record, src Record, columns []string) {
+	to, from := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src)
+	for _, column := range columns {
+		to.FieldByName(column).Set(from.FieldByName(column))
+	}
+}
+
+// update applies fn to a stored record as the next version. It returns
+// ErrNotFound for a missing record. A version other than 0 must match the
+// record's current version.
+func (s *MemoryStore) update(recordID, version int, by audit.Actor, fn func(*Record) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.records[recordID]
+	if !ok {
+		return ErrNotFound
+	}
+	if version != 0 && version != before.Version {
+		return ErrVersionConflict
+	}
+	after := before
+	if err := fn(&after); err != nil {
+		return err
+	}
+	after.RecordID, after.Version = recordID, before.Version+1
+	s.records[recordID] = after
+	s.addRevision(after, by)
+	return s.Log.Write(by, audit.Change{Action: audit.ActionUpdate, Entity: audit.EntityRecord, EntityID: recordID, Before: before, After: after})
+}
+
+func (s *MemoryStore) Delete(recordID int, by audit.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.records[recordID]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.records, recordID)
+	s.deleted[recordID] = DeletedRecord{Record: before, DeletedAt: time.Now().UTC().Format(audit.TimeFormat), DeletedBy: by.AccID}
+	return s.Log.Write(by, audit.Change{Action: audit.ActionDelete, Entity: audit.EntityRecord, EntityID: recordID, Before: before})
+}
+
+func (s *MemoryStore) Trash(p query.Params) (query.Page[DeletedRecord], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]DeletedRecord, 0, len(s.deleted))
+	for _, rec := range s.deleted {
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].RecordID < records[j].RecordID })
+
+	return query.ListSlice(records, p, deletedRecordValue), nil
+}
+
+func (s *MemoryStore) Restore(recordID int, by audit.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.deleted[recordID]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.deleted, recordID)
+	s.records[recordID] = before.Record
+	return s.Log.Write(by, audit.Change{Action: audit.ActionRestore, Entity: audit.EntityRecord, EntityID: recordID, Before: before, After: before.Record})
+}
+
+func (s *MemoryStore) Purge(before time.Time, by audit.Actor) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := before.UTC().Format(audit.TimeFormat)
+	var expired []int
+	for recordID, rec := range s.deleted {
+		if rec.DeletedAt < cutoff {
+			expired = append(expired, recordID)
+		}
+	}
+	sort.Ints(expired)
+
+	for count, recordID := range expired {
+		rec := s.deleted[recordID]
+		delete(s.deleted, recordID)
+		if err := s.Log.Write(by, audit.Change{Action: audit.ActionPurge, Entity: audit.EntityRecord, EntityID: recordID, Before: rec}); err != nil {
+			return count + 1, err
+		}
+	}
+	return len(expired), nil
+}
+
+func (s *MemoryStore) Revisions(recordID int, p query.Params) (query.Page[Revision], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return query.ListSlice(s.revisions[recordID], forRecord(p, recordID), revisionValue), nil
+}
+
+func (s *MemoryStore) Revision(recordID, revision int) (Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rev, ok := s.revision(recordID, revision)
+	if !ok {
+		return Revision{}, ErrNotFound
+	}
+	return rev, nil
+}
+
+func (s *MemoryStore) Rollback(recordID, revision int, by audit.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.records[recordID]
+	rev, found := s.revision(recordID, revision)
+	if !ok || !found {
+		return ErrNotFound
+	}
+	after := rev.Record
+	after.Version = before.Version + 1
+	// The company and contact are found by ID, so their current names are
+	// used; one deleted since is found by name again
+	if _, ok := s.companies[after.CompanyID]; !ok {
+		after.CompanyID, after.ContactID = 0, 0
+	}
+	if _, ok := s.contacts[after.ContactID]; !ok {
+		after.ContactID = 0
+	}
+	if err := s.resolveReferences(&after, by); err != nil {
+		return err
+	}
+	s.records[recordID] = after
+	s.addRevision(after, by)
+	return s.Log.Write(by, audit.Change{Action: audit.ActionRollback, Entity: audit.EntityRecord, EntityID: recordID, Before: before, After: after})
+}
+
+// revision finds a revision of a record; the caller holds s.mu
+func (s *MemoryStore) revision(recordID, revision int) (Revision, bool) {
+	for _, rev := range s.revisions[recordID] {
+		if rev.Revision == revision {
+			return rev, true
+		}
+	}
+	return Revision{}, false
+}
+
+// addRevision stores rec as the revision numbered by its version; the caller
+// holds s.mu
+func (s *MemoryStore) addRevision(rec Record, by audit.Actor) {
+	s.revisions[rec.RecordID] = append(s.revisions[rec.RecordID], Revision{
+		Revision:  rec.Version,
+		Record:    rec,
+		CreatedBy: by.AccID,
+		CreatedAt: time.Now().UTC().Format(audit.TimeFormat),
+	})
+}
+
+// Ping always succeeds since there is no database to reach
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Search ranks the records with the in-memory full-text index
+func (s *MemoryStore) Search(q SearchQuery, limit int) ([]SearchResult, error) {
+	return NewIndex(s.all()).Search(q, limit), nil
+}
+
+func (s *MemoryStore) Export(p query.Params, q SearchQuery, fn func(Record) error) error {
+	var matched []Record
+	for _, rec := range s.all() {
+		if q.matches(rec) {
+			matched = append(matched, rec)
+		}
+	}
+	for _, rec := range query.FilterSlice(matched, p, recordValue) {
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// all returns every record ordered by id
+func (s *MemoryStore) all() []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]Record, 0, len(s.records))
+	for _, rec := range s.records {
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].RecordID < records[j].RecordID })
+	return records
+}
+
+// checkReferences returns the error resolveReferences would for rec, without
+// creating anything; the caller holds s.mu
+func (s *MemoryStore) checkReferences(rec Record) error {
+	if _, ok := s.companies[rec.CompanyID]; rec.CompanyID != 0 && !ok {
+		return unknownCompany
+	}
+	if rec.ContactID == 0 {
+		return nil
+	}
+	contact, ok := s.contacts[rec.ContactID]
+	if !ok {
+		return unknownContact
+	}
+	if rec.CompanyID != 0 && contact.CompanyID != rec.CompanyID ||
+		rec.CompanyID == 0 && nameKey(s.companies[contact.CompanyID].Name) != nameKey(rec.CompanyName) {
+		return unknownContact
+	}
+	return nil
+}
+
+// resolveReferences is the MemoryStore version of the function of the same
+// name; the caller holds s.mu
+func (s *MemoryStore) resolveReferences(rec *Record, by audit.Actor) error {
+	if err := s.checkReferences(*rec); err != nil {
+		return err
+	}
+
+	company, ok := s.companies[rec.CompanyID]
+	if !ok {
+		if company, ok = s.companyNamed(rec.CompanyName); !ok {
+			var err error
+			if company, err = s.createCompany(Company{Name: strings.TrimSpace(rec.CompanyName)}, by); err != nil {
+				return err
+			}
+		}
+	}
+	contact, ok := s.contacts[rec.ContactID]
+	if !ok {
+		if contact, ok = s.contactNamed(company.CompanyID, rec.CompanyContact); !ok {
+			var err error
+			if contact, err = s.createContact(Contact{CompanyID: company.CompanyID, Name: strings.TrimSpace(rec.CompanyContact)}, by); err != nil {
+				return err
+			}
+		}
+	}
+
+	rec.CompanyID, rec.CompanyName = company.CompanyID, company.Name
+	rec.ContactID, rec.CompanyContact = contact.ContactID, contact.Name
+	return nil
+}
+
+// companyNamed finds a company by nameKey; the caller holds s.mu
+func (s *MemoryStore) companyNamed(name string) (Company, bool) {
+	for _, c := range s.companies {
+		if nameKey(c.Name) == nameKey(name) {
+			return c, true
+		}
+	}
+	return Company{}, false
+}
+
+// contactNamed finds a contact of a company by nameKey; the caller holds s.mu
+func (s *MemoryStore) contactNamed(companyID int, name string) (Contact, bool) {
+	for _, c := range s.contacts {
+		if c.CompanyID == companyID && nameKey(c.Name) == nameKey(name) {
+			return c, true
+		}
+	}
+	return Contact{}, false
+}
+
+// createCompany adds a company; the caller holds s.mu
+func (s *MemoryStore) createCompany(c Company, by audit.Actor) (Company, error) {
+	c.CompanyID, c.Version = s.nextCompanyID, 1
+	s.nextCompanyID++
+	s.companies[c.CompanyID] = c
+	return c, s.Log.Write(by, audit.Change{Action: audit.ActionCreate, Entity: audit.EntityCompany, EntityID: c.CompanyID, After: c})
+}
+
+// createContact adds a contact; the caller holds s.mu
+func (s *MemoryStore) createContact(c Contact, by audit.Actor) (Contact, error) {
+	c.ContactID, c.Version = s.nextContactID, 1
+	s.nextContactID++
+	s.contacts[c.ContactID] = c
+	return c, s.Log.Write(by, audit.Change{Action: audit.ActionCreate, Entity: audit.EntityContact, EntityID: c.ContactID, After: c})
+}
+
+// renameOnRecords applies rename to every record that matches, in the trash
+// or not, as a new version, revision and audit entry of each; the caller
+// holds s.mu
+func (s *MemoryStore) renameOnRecords(matches func(Record) bool, rename func(*Record), by audit.Actor) error {
+	for id, rec := range s.records {
+		if matches(rec) {
+			before := rec
+			rename(&rec)
+			rec.Version++
+			s.records[id] = rec
+			s.addRevision(rec, by)
+			if err := s.Log.Write(by, audit.Change{Action: audit.ActionUpdate, Entity: audit.EntityRecord, EntityID: id, Before: before, After: rec}); err != nil {
+				return err
+			}
+		}
+	}
+	for id, rec := range s.deleted {
+		if matches(rec.Record) {
+			before := rec.Record
+			rename(&rec.Record)
+			rec.Version++
+			s.deleted[id] = rec
+			s.addRevision(rec.Record, by)
+			if err := s.Log.Write(by, audit.Change{Action: audit.ActionUpdate, Entity: audit.EntityRecord, EntityID: id, Before: before, After: rec.Record}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// inUse reports whether a record, in the trash or not, matches; the caller
+// holds s.mu
+func (s *MemoryStore) inUse(matches func(Record) bool) bool {
+	for _, rec := range s.records {
+		if matches(rec) {
+			return true
+		}
+	}
+	for _, rec := range s.deleted {
+		if matches(rec.Record) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStore) CreateCompany(c Company, by audit.Actor) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.companyNamed(c.Name); ok {
+		return 0, ErrNameInUse
+	}
+	c, err := s.createCompany(c, by)
+	return c.CompanyID, err
+}
+
+func (s *MemoryStore) GetCompany(companyID int) (Company, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.companies[companyID]
+	if !ok {
+		return Company{}, ErrCompanyNotFound
+	}
+	return c, nil
+}
+
+func (s *MemoryStore) ListCompanies(p query.Params) (query.Page[Company], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	companies := make([]Company, 0, len(s.companies))
+	for _, c := range s.companies {
+		companies = append(companies, c)
+	}
+	sort.Slice(companies, func(i, j int) bool { return companies[i].CompanyID < companies[j].CompanyID })
+	return query.ListSlice(companies, p, companyValue), nil
+}
+
+func (s *MemoryStore) UpdateCompany(c Company, by audit.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.companies[c.CompanyID]
+	if !ok {
+		return ErrCompanyNotFound
+	}
+	if c.Version != 0 && c.Version != before.Version {
+		return ErrVersionConflict
+	}
+	if other, ok := s.companyNamed(c.Name); ok && other.CompanyID != c.CompanyID {
+		return ErrNameInUse
+	}
+
+	c.Version = before.Version + 1
+	s.companies[c.CompanyID] = c
+	if c.Name != before.Name {
+		if err := s.renameOnRecords(func(rec Record) bool { return rec.CompanyID == c.CompanyID }, func(rec *Record) { rec.CompanyName = c.Name }, by); err != nil {
+			return err
+		}
+	}
+	return s.Log.Write(by, audit.Change{Action: audit.ActionUpdate, Entity: audit.EntityCompany, EntityID: c.CompanyID, Before: before, After: c})
+}
+
+func (s *MemoryStore) DeleteCompany(companyID int, by audit.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.companies[companyID]
+	if !ok {
+		return ErrCompanyNotFound
+	}
+	if s.inUse(func(rec Record) bool { return rec.CompanyID == companyID }) {
+		return ErrInUse
+	}
+
+	for id, c := range s.contacts {
+		if c.CompanyID == companyID {
+			delete(s.contacts, id)
+		}
+	}
+	delete(s.companies, companyID)
+	return s.Log.Write(by, audit.Change{Action: audit.ActionDelete, Entity: audit.EntityCompany, EntityID: companyID, Before: before})
+}
+
+func (s *MemoryStore) CreateContact(c Contact, by audit.Actor) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.companies[c.CompanyID]; !ok {
+		return 0, ErrCompanyNotFound
+	}
+	if _, ok := s.contactNamed(c.CompanyID, c.Name); ok {
+		return 0, ErrNameInUse
+	}
+	c, err := s.createContact(c, by)
+	return c.ContactID, err
+}
+
+func (s *MemoryStore) GetContact(contactID int) (Contact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.contacts[contactID]
+	if !ok {
+		return Contact{}, ErrContactNotFound
+	}
+	return c, nil
+}
+
+func (s *MemoryStore) ListContacts(companyID int, p query.Params) (query.Page[Contact], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contacts := make([]Contact, 0, len(s.contacts))
+	for _, c := range s.contacts {
+		contacts = append(contacts, c)
+	}
+	sort.Slice(contacts, func(i, j int) bool { return contacts[i].ContactID < contacts[j].ContactID })
+	return query.ListSlice(contacts, forCompany(p, companyID), contactValue), nil
+}
+
+func (s *MemoryStore) UpdateContact(c Contact, by audit.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.contacts[c.ContactID]
+	if !ok {
+		return ErrContactNotFound
+	}
+	if c.Version != 0 && c.Version != before.Version {
+		return ErrVersionConflict
+	}
+	c.CompanyID = before.CompanyID
+	if other, ok := s.contactNamed(c.CompanyID, c.Name); ok && other.ContactID != c.ContactID {
+		return ErrNameInUse
+	}
+
+	c.Version = before.Version + 1
+	s.contacts[c.ContactID] = c
+	if c.Name != before.Name {
+		if err := s.renameOnRecords(func(rec Record) bool { return rec.ContactID == c.ContactID }, func(rec *Record) { rec.CompanyContact = c.Name }, by); err != nil {
+			return err
+		}
+	}
+	return s.Log.Write(by, audit.Change{Action: audit.ActionUpdate, Entity: audit.EntityContact, EntityID: c.ContactID, Before: before, After: c})
+}
+
+func (s *MemoryStore) DeleteContact(contactID int, by audit.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.contacts[contactID]
+	if !ok {
+		return ErrContactNotFound
+	}
+	if s.inUse(func(rec Record) bool { return rec.ContactID == contactID }) {
+		return ErrInUse
+	}
+
+	delete(s.contacts, contactID)
+	return s.Log.Write(by, audit.Change{Action: audit.ActionDelete, Entity: audit.EntityContact, EntityID: contactID, Before: before})
+}
@@ -0,0 +1,270 @@
+// store_test.go
+package record
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"        //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit"      //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/middleware" //change here
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// storeFixture is one RecordStore implementation under test. Handler tests
+// seed records for the memory store and set query expectations for the
+// MySQL store; each call only applies to its own implementation.
+type storeFixture struct {
+	store  RecordStore
+	mock   sqlmock.Sqlmock // nil for the memory store
+	memory *MemoryStore    // nil for the MySQL store
+}
+
+// seed puts records into the memory store
+func (f *storeFixture) seed(records ...Record) {
+	if f.memory != nil {
+		f.memory = NewMemoryStore(records...)
+		f.store = f.memory
+	}
+}
+
+// expectSQL registers query expectations on the MySQL store
+func (f *storeFixture) expectSQL(expect func(mock sqlmock.Sqlmock)) {
+	if f.mock != nil {
+		expect(f.mock)
+	}
+}
+
+// stored returns a record as held by the memory store; ok is false for the
+// MySQL store or a missing record
+func (f *storeFixture) stored(recordID int) (rec Record, ok bool) {
+	if f.memory == nil {
+		return Record{}, false
+	}
+	f.memory.mu.Lock()
+	defer f.memory.mu.Unlock()
+	rec, ok = f.memory.records[recordID]
+	return rec, ok
+}
+
+func (f *storeFixture) server() *Server {
+	return NewServer(f.store, testResolver)
+}
+
+// forEachStore runs a test against the MySQL store backed by sqlmock and
+// against the memory store
+func forEachStore(t *testing.T, test func(t *testing.T, f *storeFixture)) {
+	t.Run("MySQL", func(t *testing.T) {
+		// Create a new mock database connection
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		test(t, &storeFixture{store: NewMySQLStore(db), mock: mock})
+
+		// Verify that the expectations were met
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Memory", func(t *testing.T) {
+		memory := NewMemoryStore()
+		test(t, &storeFixture{store: memory, memory: memory})
+	})
+}
+
+// mysqlServer returns a server on a sqlmock database for tests of database failures
+func mysqlServer(t *testing.T) (*Server, sqlmock.Sqlmock) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return NewServer(NewMySQLStore(db), testResolver), mock
+}
+
+// accounts known to testResolver
+var testAccounts = map[int]middleware.Identity{
+	1001: {AccID: 1001, AccType: "Admin", AccStatus: "Created"},
+	2001: {AccID: 2001, AccType: "User", AccStatus: "Created"},
+	2004: {AccID: 2004, AccType: "User", AccStatus: "Pending"},
+}
+
+func testResolver(accID int) (middleware.Identity, error) {
+	id, ok := testAccounts[accID]
+	if !ok {
+		return middleware.Identity{}, middleware.ErrUnknownAccount
+	}
+	return id, nil
+}
+
+func recordRows(rec Record) *sqlmock.Rows {
+	return sqlmock.NewRows(recordColumns).AddRow(rec.RecordID, rec.Name, rec.RoleOfContact, rec.NoOfStudents, rec.AcadYr, rec.CapstoneTitle, rec.CompanyName, rec.CompanyContact, rec.ProjDesc, rec.Version, rec.CompanyID, rec.ContactID)
+}
+
+// expectLock registers the read of a record's current state that starts
+// every update and delete in the MySQL store
+func expectLock(mock sqlmock.Sqlmock, rec Record) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, Version, CompanyID, ContactID FROM Record WHERE RecordID = ? AND DeletedAt IS NULL FOR UPDATE")).
+		WithArgs(rec.RecordID).
+		WillReturnRows(recordRows(rec))
+}
+
+// expectRead registers the read of a changed record, which follows every
+// change but a create or delete in the MySQL store
+func expectRead(mock sqlmock.Sqlmock, rec Record) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, Version, CompanyID, ContactID FROM Record WHERE RecordID = ? AND DeletedAt IS NULL")).
+		WithArgs(rec.RecordID).
+		WillReturnRows(recordRows(rec))
+}
+
+// expectRevision registers the insert of a revision, which follows every
+// create, update and rollback in the MySQL store
+func expectRevision(mock sqlmock.Sqlmock, rec Record) {
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO RecordRevision (RecordID, Revision, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, CompanyID, ContactID, CreatedBy, CreatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())")).
+		WithArgs(rec.RecordID, rec.Version, rec.Name, rec.RoleOfContact, rec.NoOfStudents, rec.AcadYr, rec.CapstoneTitle, rec.CompanyName, rec.CompanyContact, rec.ProjDesc, rec.CompanyID, rec.ContactID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// expectAudit registers the audit entry that ends every change in the
+// MySQL store
+func expectAudit(mock sqlmock.Sqlmock, action string, recordID int) {
+	expectEntityAudit(mock, action, audit.EntityRecord, recordID)
+}
+
+// expectEntityAudit is expectAudit for changes to any entity
+func expectEntityAudit(mock sqlmock.Sqlmock, action, entity string, entityID int) {
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO AuditLog (ActorID, Action, Entity, EntityID, OldValue, NewValue, SourceIP, CreatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")).
+		WithArgs(sqlmock.AnyArg(), action, entity, entityID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+var (
+	companyColumns = []string{"CompanyID", "Name", "Version"}
+	contactColumns = []string{"ContactID", "CompanyID", "Name", "Version"}
+)
+
+// expectReferences registers finding the company and contact of rec by name,
+// which every create and update of a record in the MySQL store does
+func expectReferences(mock sqlmock.Sqlmock, rec Record) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT CompanyID, Name, Version FROM Company WHERE NameKey = ? FOR SHARE")).
+		WithArgs(nameKey(rec.CompanyName)).
+		WillReturnRows(sqlmock.NewRows(companyColumns).AddRow(rec.CompanyID, rec.CompanyName, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT ContactID, CompanyID, Name, Version FROM Contact WHERE CompanyID = ? AND NameKey = ? FOR SHARE")).
+		WithArgs(rec.CompanyID, nameKey(rec.CompanyContact)).
+		WillReturnRows(sqlmock.NewRows(contactColumns).AddRow(rec.ContactID, rec.CompanyID, rec.CompanyContact, 1))
+}
+
+// expectNewReferences is expectReferences for a company and contact named for
+// the first time, which are created with the IDs rec holds
+func expectNewReferences(mock sqlmock.Sqlmock, rec Record) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT CompanyID, Name, Version FROM Company WHERE NameKey = ? FOR SHARE")).
+		WithArgs(nameKey(rec.CompanyName)).
+		WillReturnRows(sqlmock.NewRows(companyColumns))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Company (Name, NameKey) VALUES (?, ?)")).
+		WithArgs(rec.CompanyName, nameKey(rec.CompanyName)).
+		WillReturnResult(sqlmock.NewResult(int64(rec.CompanyID), 1))
+	expectEntityAudit(mock, audit.ActionCreate, audit.EntityCompany, rec.CompanyID)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT ContactID, CompanyID, Name, Version FROM Contact WHERE CompanyID = ? AND NameKey = ? FOR SHARE")).
+		WithArgs(rec.CompanyID, nameKey(rec.CompanyContact)).
+		WillReturnRows(sqlmock.NewRows(contactColumns))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Contact (CompanyID, Name, NameKey) VALUES (?, ?, ?)")).
+		WithArgs(rec.CompanyID, rec.CompanyContact, nameKey(rec.CompanyContact)).
+		WillReturnResult(sqlmock.NewResult(int64(rec.ContactID), 1))
+	expectEntityAudit(mock, audit.ActionCreate, audit.EntityContact, rec.ContactID)
+}
+
+// expectReferenceIDs registers finding the company and contact of rec by ID;
+// lock is appended to the queries
+func expectReferenceIDs(mock sqlmock.Sqlmock, rec Record, lock string) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT CompanyID, Name, Version FROM Company WHERE CompanyID = ?" + lock)).
+		WithArgs(rec.CompanyID).
+		WillReturnRows(sqlmock.NewRows(companyColumns).AddRow(rec.CompanyID, rec.CompanyName, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT ContactID, CompanyID, Name, Version FROM Contact WHERE ContactID = ?" + lock)).
+		WithArgs(rec.ContactID).
+		WillReturnRows(sqlmock.NewRows(contactColumns).AddRow(rec.ContactID, rec.CompanyID, rec.CompanyContact, 1))
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(testRecords...)
+	by := audit.Actor{AccID: 1001, IP: "192.0.2.1"}
+
+	// New records continue after the seeded ids
+	id, err := store.Create(Record{Name: "New", AcadYr: "2023/2024"}, by)
+	if err != nil || id != 4 {
+		t.Fatalf("Create returned unexpected id: %v %v", id, err)
+	}
+
+	if err := store.Update(Record{RecordID: 4, Name: "Renamed", AcadYr: "2023/2024"}, by); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(1, by); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes to missing records fail and are not logged
+	if err := store.Delete(99, by); err != ErrNotFound {
+		t.Errorf("Delete returned wrong error for a missing record: got %v want %v", err, ErrNotFound)
+	}
+
+	params, err := recordListSpec.Parse(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := store.List(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || page.Items[0].RecordID != 2 || page.Items[2].Name != "Renamed" {
+		t.Errorf("List returned unexpected page: %+v", page)
+	}
+
+	results, err := store.Search(ParseSearch("year:2023/2024"), 10)
+	if err != nil || len(results) != 2 {
+		t.Errorf("Search returned unexpected results: %+v %v", results, err)
+	}
+
+	params, err = audit.Parse(url.Values{"entity": {audit.EntityRecord}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := store.Log.List(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries.Total != 3 {
+		t.Fatalf("Log returned unexpected entries: %+v", entries)
+	}
+	if e := entries.Items[0]; e.Action != audit.ActionDelete || e.EntityID != 1 || e.After != nil || e.ActorID != 1001 || e.SourceIP != "192.0.2.1" {
+		t.Errorf("Log returned unexpected delete entry: %+v", e)
+	}
+	if e := entries.Items[1]; e.Action != audit.ActionUpdate || string(e.Before) != `{"recordId":4,"name":"New","roleOfContact":"","noOfStudents":0,"acadYr":"2023/2024","capstoneTitle":"","companyName":"","companyContact":"","projDesc":"","version":1,"companyId":4,"contactId":4}` {
+		t.Errorf("Log returned unexpected update entry: %+v", e)
+	}
+}
+
+// expectErrorCode checks that a response is the JSON error envelope with code
+func expectErrorCode(t *testing.T, rr *httptest.ResponseRecorder, code string) api.ErrorBody {
+	t.Helper()
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Handler returned wrong content type: got %v want application/json", ct)
+	}
+	var body api.ErrorBody
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Handler returned a body that is not an error envelope: %v", rr.Body.String())
+	}
+	if body.Code != code {
+		t.Errorf("Handler returned wrong error code: got %v want %v", body.Code, code)
+	}
+	return body
+}
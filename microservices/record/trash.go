@@ -0,0 +1,100 @@
+package record
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"   //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/query" //change here
+
+	"github.com/gorilla/mux"
+)
+
+// DeletedRecord is a record in the trash. DeletedBy is 0 when the deleting
+// account is unknown.
+type DeletedRecord struct {
+	Record
+	DeletedAt string `json:"deletedAt"`
+	DeletedBy int    `json:"deletedBy,omitempty"`
+}
+
+// list options accepted by ListTrashHandler; the most recently deleted
+// records come first by default
+var recordTrashSpec = query.Spec{
+	Table:   "Record",
+	Columns: append(append([]string{}, recordListSpec.Columns...), "DeletedAt", "DeletedBy"),
+	Where:   "DeletedAt IS NOT NULL",
+	Key:     "RecordID",
+	Sortable: map[string]string{
+		"recordId":  "RecordID",
+		"name":      "Name",
+		"acadYr":    "AcadYr",
+		"deletedAt": "DeletedAt",
+	},
+	Filterable: map[string]string{
+		"acadYr":      "AcadYr",
+		"companyName": "CompanyName",
+		"deletedBy":   "DeletedBy",
+	},
+	DefaultSort:  []query.SortField{{Column: "DeletedAt", Desc: true}},
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+func scanDeletedRecord(rows *sql.Rows) (DeletedRecord, error) {
+	var rec DeletedRecord
+	var deletedBy sql.NullInt64
+	err := rows.Scan(append(recordFields(&rec.Record), &rec.DeletedAt, &deletedBy)...)
+	rec.DeletedBy = int(deletedBy.Int64)
+	return rec, err
+}
+
+// deletedRecordValue returns the value of a column for filtering, sorting and cursors
+func deletedRecordValue(rec DeletedRecord, column string) interface{} {
+	switch column {
+	case "DeletedAt":
+		return rec.DeletedAt
+	case "DeletedBy":
+		return rec.DeletedBy
+	}
+	return recordValue(rec.Record, column)
+}
+
+// ListTrashHandler lists deleted records that have not been purged yet
+func (s *Server) ListTrashHandler(w http.ResponseWriter, r *http.Request) {
+	params, err := recordTrashSpec.Parse(r.URL.Query())
+	if err != nil {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, err.Error())
+		return
+	}
+
+	page, err := s.store.Trash(params)
+	if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	api.JSON(w, http.StatusOK, page)
+}
+
+// RestoreRecordHandler takes a record out of the trash
+func (s *Server) RestoreRecordHandler(w http.ResponseWriter, r *http.Request) {
+	recordID, err := strconv.Atoi(mux.Vars(r)["recordID"])
+	if err != nil {
+		api.Error(w, r, http.StatusBadRequest, api.CodeInvalidParameter, "Invalid record ID")
+		return
+	}
+
+	err = s.store.Restore(recordID, audit.ActorFrom(r))
+	if err == ErrNotFound {
+		api.Error(w, r, http.StatusNotFound, api.CodeNotFound, "Record is not in the trash")
+		return
+	} else if err != nil {
+		api.Internal(w, r)
+		return
+	}
+
+	api.Message(w, http.StatusOK, "Record restored successfully")
+}
@@ -0,0 +1,187 @@
+// trash_test.go
+package record
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/api"   //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit" //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/query" //change here
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+var trashColumns = append(append([]string{}, recordColumns...), "DeletedAt", "DeletedBy")
+
+func trashRow(rows *sqlmock.Rows, rec Record, deletedAt string, deletedBy interface{}) *sqlmock.Rows {
+	return rows.AddRow(rec.RecordID, rec.Name, rec.RoleOfContact, rec.NoOfStudents, rec.AcadYr, rec.CapstoneTitle, rec.CompanyName, rec.CompanyContact, rec.ProjDesc, rec.Version, rec.CompanyID, rec.ContactID, deletedAt, deletedBy)
+}
+
+// trashRecords seeds the memory store with records that admin 1001 deleted
+func trashRecords(t *testing.T, f *storeFixture, records ...Record) {
+	f.seed(records...)
+	if f.memory != nil {
+		for _, rec := range records {
+			if err := f.memory.Delete(rec.RecordID, audit.Actor{AccID: 1001}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestListTrashHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		trashRecords(t, f, testRecords[2])
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, Version, CompanyID, ContactID, DeletedAt, DeletedBy FROM Record WHERE DeletedAt IS NOT NULL ORDER BY DeletedAt DESC, RecordID ASC LIMIT ?")).
+				WithArgs(51).
+				WillReturnRows(trashRow(sqlmock.NewRows(trashColumns), testRecords[2], "2024-05-01 09:30:00", 1001))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM Record WHERE DeletedAt IS NOT NULL")).
+				WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+		})
+
+		req, err := http.NewRequest("GET", "/api/v1/records/trash", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		f.server().ListTrashHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		var page query.Page[DeletedRecord]
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 1 || page.Items[0].Record != testRecords[2] || page.Items[0].DeletedBy != 1001 || page.Items[0].DeletedAt == "" {
+			t.Errorf("Handler returned unexpected page: %+v", page)
+		}
+	})
+}
+
+func TestRestoreRecordHandler(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		trashRecords(t, f, testRecords[2])
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, Version, CompanyID, ContactID, DeletedAt, DeletedBy FROM Record WHERE RecordID = ? AND DeletedAt IS NOT NULL FOR UPDATE")).
+				WithArgs(3).
+				WillReturnRows(trashRow(sqlmock.NewRows(trashColumns), testRecords[2], "2024-05-01 09:30:00", 1001))
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE Record SET DeletedAt = NULL, DeletedBy = NULL WHERE RecordID = ?")).
+				WithArgs(3).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectRead(mock, testRecords[2])
+			expectAudit(mock, audit.ActionRestore, 3)
+			mock.ExpectCommit()
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/records/trash/{recordID}/restore", f.server().RestoreRecordHandler)
+
+		req, err := http.NewRequest("POST", "/api/v1/records/trash/3/restore", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		expected := `{"message":"Record restored successfully"}` + "\n"
+		if rr.Body.String() != expected {
+			t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+		}
+
+		if f.memory != nil {
+			if rec, ok := f.stored(3); !ok || rec != testRecords[2] {
+				t.Errorf("Record was not restored: %+v", rec)
+			}
+		}
+	})
+}
+
+func TestRestoreRecordHandler_NotInTrash(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		// A live record is not in the trash either
+		f.seed(testRecords...)
+
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, Version, CompanyID, ContactID, DeletedAt, DeletedBy FROM Record WHERE RecordID = ? AND DeletedAt IS NOT NULL FOR UPDATE")).
+				WithArgs(3).
+				WillReturnRows(sqlmock.NewRows(trashColumns))
+			mock.ExpectRollback()
+		})
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/records/trash/{recordID}/restore", f.server().RestoreRecordHandler)
+
+		req, err := http.NewRequest("POST", "/api/v1/records/trash/3/restore", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+		expectErrorCode(t, rr, api.CodeNotFound)
+	})
+}
+
+func TestPurge(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *storeFixture) {
+		trashRecords(t, f, testRecords[1], testRecords[2])
+		if f.memory != nil {
+			// Records deleted after the cutoff are kept
+			if count, err := f.store.Purge(time.Now().Add(-time.Hour), audit.Actor{}); err != nil || count != 0 {
+				t.Errorf("Purge returned unexpected count: %v %v", count, err)
+			}
+		}
+
+		cutoff := time.Now().Add(time.Hour)
+		f.expectSQL(func(mock sqlmock.Sqlmock) {
+			rows := sqlmock.NewRows(trashColumns)
+			trashRow(rows, testRecords[1], "2024-05-01 09:30:00", 1001)
+			trashRow(rows, testRecords[2], "2024-05-01 09:31:00", nil)
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT RecordID, Name, RoleOfContact, NoOfStudents, AcadYr, CapstoneTitle, CompanyName, CompanyContact, ProjDesc, Version, CompanyID, ContactID, DeletedAt, DeletedBy FROM Record WHERE DeletedAt < ? FOR UPDATE")).
+				WithArgs(cutoff.UTC().Format(audit.TimeFormat)).
+				WillReturnRows(rows)
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM Record WHERE DeletedAt < ?")).
+				WithArgs(cutoff.UTC().Format(audit.TimeFormat)).
+				WillReturnResult(sqlmock.NewResult(0, 2))
+			expectAudit(mock, audit.ActionPurge, 2)
+			expectAudit(mock, audit.ActionPurge, 3)
+			mock.ExpectCommit()
+		})
+
+		count, err := f.store.Purge(cutoff, audit.Actor{})
+		if err != nil || count != 2 {
+			t.Errorf("Purge returned unexpected count: %v %v", count, err)
+		}
+
+		if f.memory != nil {
+			// Purged records cannot be restored
+			if err := f.store.Restore(3, audit.Actor{}); err != ErrNotFound {
+				t.Errorf("Restore returned wrong error for a purged record: got %v want %v", err, ErrNotFound)
+			}
+		}
+	})
+}
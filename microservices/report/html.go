@@ -0,0 +1,24 @@
+package report
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+)
+
+//go:embed catalogue.html
+var catalogueHTML string
+
+var htmlTemplate = template.Must(template.New("catalogue").Funcs(template.FuncMap{
+	"teamSize": teamSize,
+}).Parse(catalogueHTML))
+
+// HTML writes the catalogue as a single HTML page. Every value from the
+// records is escaped by html/template.
+func HTML(w io.Writer, c Catalogue) error {
+	return htmlTemplate.Execute(w, struct {
+		Catalogue
+		Heading string
+		Count   int
+	}{c, c.heading(), c.Count()})
+}
@@ -0,0 +1,102 @@
+package report
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Layout of the PDF, in millimetres on A4 paper
+const (
+	pdfMargin     = 20.0
+	pdfLine       = 6.0
+	pdfPageNumber = 15.0
+)
+
+// PDF writes the catalogue as an A4 document with a cover page, a table of
+// contents that links to each section with its page number, and one section
+// per capstone title. Text outside the Windows-1252 character set, which is
+// all the core fonts hold, is replaced.
+func PDF(w io.Writer, c Catalogue) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin)
+	pdf.SetTitle(c.heading(), true)
+	pdf.SetCreator("DevOps_Oct2023_TeamB_Assignment", true)
+	pdf.SetCreationDate(c.Generated)
+	pdf.AliasNbPages("")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pageWidth, pageHeight := pdf.GetPageSize()
+	width := pageWidth - 2*pdfMargin
+
+	pdf.SetFooterFunc(func() {
+		if pdf.PageNo() == 1 {
+			return
+		}
+		pdf.SetY(-pdfPageNumber)
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(0, pdfLine, fmt.Sprintf("Page %d of {nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	// Cover
+	pdf.AddPage()
+	pdf.SetY(pageHeight / 3)
+	pdf.SetFont("Helvetica", "B", 28)
+	pdf.MultiCell(0, 12, tr(c.heading()), "", "C", false)
+	pdf.Ln(pdfLine)
+	pdf.SetFont("Helvetica", "", 14)
+	pdf.CellFormat(0, 8, fmt.Sprintf("%d projects", c.Count()), "", 1, "C", false, 0, "")
+	pdf.CellFormat(0, 8, "Generated "+c.Generated.Format("2 January 2006"), "", 1, "C", false, 0, "")
+
+	// Table of contents. Section pages are not known until they are written,
+	// so each entry shows an alias that is replaced when the PDF is output.
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, "Contents", "", 1, "L", false, 0, "")
+	pdf.Ln(pdfLine / 2)
+	pdf.SetFont("Helvetica", "", 11)
+	links := make([]int, len(c.Sections))
+	for i, s := range c.Sections {
+		links[i] = pdf.AddLink()
+		title := pdf.SplitText(tr(fmt.Sprintf("%d. %s", s.Number, s.Title)), width-pdfPageNumber)
+		if len(title) > 1 {
+			title[0] = title[0] + "..."
+		}
+		pdf.CellFormat(width-pdfPageNumber, pdfLine, title[0], "", 0, "L", false, links[i], "")
+		pdf.CellFormat(pdfPageNumber, pdfLine, pageAlias(s), "", 1, "R", false, links[i], "")
+	}
+
+	// Sections
+	for i, s := range c.Sections {
+		pdf.AddPage()
+		pdf.SetLink(links[i], 0, -1)
+		pdf.RegisterAlias(pageAlias(s), fmt.Sprint(pdf.PageNo()))
+
+		pdf.SetFont("Helvetica", "B", 16)
+		pdf.MultiCell(0, 8, tr(fmt.Sprintf("%d. %s", s.Number, s.Title)), "", "L", false)
+		for _, capstone := range s.Capstones {
+			pdf.Ln(pdfLine / 2)
+			for _, field := range [][2]string{
+				{"Company", capstone.Company},
+				{"Company contact", capstone.CompanyContact},
+				{"Contact", fmt.Sprintf("%s (%s)", capstone.Contact, capstone.Role)},
+				{"Team size", teamSize(capstone.TeamSize)},
+			} {
+				pdf.SetFont("Helvetica", "B", 11)
+				pdf.CellFormat(40, pdfLine, field[0], "", 0, "L", false, 0, "")
+				pdf.SetFont("Helvetica", "", 11)
+				pdf.MultiCell(0, pdfLine, tr(field[1]), "", "L", false)
+			}
+			pdf.Ln(pdfLine / 2)
+			pdf.MultiCell(0, pdfLine, tr(capstone.Description), "", "L", false)
+		}
+	}
+
+	return pdf.Output(w)
+}
+
+// pageAlias stands in for the page a section starts on until it is known
+func pageAlias(s Section) string {
+	return fmt.Sprintf("{p%d}", s.Number)
+}
@@ -0,0 +1,83 @@
+// Package report renders the yearly catalogue of capstone projects: a cover
+// page, a table of contents and one section per capstone title, as HTML or
+// as PDF.
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Capstone is one project as it appears in the catalogue. Contact is the
+// staff member or student who proposed it, in the role given.
+type Capstone struct {
+	Title          string
+	Contact        string
+	Role           string
+	Company        string
+	CompanyContact string
+	TeamSize       int
+	Description    string
+}
+
+// Section holds the capstones that share a title
+type Section struct {
+	Number    int
+	Title     string
+	Capstones []Capstone
+}
+
+// Anchor names the section in links from the table of contents
+func (s Section) Anchor() string {
+	return fmt.Sprintf("section-%d", s.Number)
+}
+
+// Catalogue is the report for one academic year
+type Catalogue struct {
+	AcadYr    string
+	Generated time.Time
+	Sections  []Section
+}
+
+// New groups capstones, which must be sorted by title, into the sections of
+// a catalogue. Titles that differ only in case share a section, as they
+// sort together in the database.
+func New(acadYr string, generated time.Time, capstones []Capstone) Catalogue {
+	c := Catalogue{AcadYr: acadYr, Generated: generated}
+	for _, capstone := range capstones {
+		if n := len(c.Sections); n > 0 && strings.EqualFold(c.Sections[n-1].Title, capstone.Title) {
+			c.Sections[n-1].Capstones = append(c.Sections[n-1].Capstones, capstone)
+			continue
+		}
+		c.Sections = append(c.Sections, Section{Number: len(c.Sections) + 1, Title: capstone.Title, Capstones: []Capstone{capstone}})
+	}
+	return c
+}
+
+// Count is the number of capstones in the catalogue
+func (c Catalogue) Count() int {
+	n := 0
+	for _, s := range c.Sections {
+		n += len(s.Capstones)
+	}
+	return n
+}
+
+// FileName is the name a catalogue is saved under, with the given extension
+func (c Catalogue) FileName(ext string) string {
+	return "capstones-" + strings.ReplaceAll(c.AcadYr, "/", "-") + "." + ext
+}
+
+// heading is the title on the cover page and at the top of the HTML page
+func (c Catalogue) heading() string {
+	return "Capstone Projects " + c.AcadYr
+}
+
+// teamSize describes the size of a team in words
+func teamSize(n int) string {
+	if n == 1 {
+		return "1 student"
+	}
+	return fmt.Sprintf("%d students", n)
+}
@@ -0,0 +1,87 @@
+// report_test.go
+package report
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testCapstones = []Capstone{
+	{Title: "Carpooling System", Contact: "Yi Ting", Role: "Student", Company: "CompanyA", CompanyContact: "Mr Choo CH", TeamSize: 3, Description: "A carpooling system connecting passengers and car owners."},
+	{Title: "carpooling system", Contact: "Jeremy", Role: "Staff", Company: "CompanyC", CompanyContact: "Ms Lim", TeamSize: 1, Description: "A second take on carpooling."},
+	{Title: "Smart <Farm> & Co", Contact: "Luke", Role: "Student", Company: "CompanyB", CompanyContact: "Dr Pamela", TeamSize: 4, Description: "Sensors for crops."},
+}
+
+func testCatalogue() Catalogue {
+	return New("2023/2024", time.Date(2023, time.November, 1, 0, 0, 0, 0, time.UTC), testCapstones)
+}
+
+func TestNew(t *testing.T) {
+	c := testCatalogue()
+
+	if len(c.Sections) != 2 {
+		t.Fatalf("New returned %d sections, want 2", len(c.Sections))
+	}
+	if s := c.Sections[0]; s.Number != 1 || s.Title != "Carpooling System" || len(s.Capstones) != 2 {
+		t.Errorf("New returned unexpected first section: %+v", s)
+	}
+	if s := c.Sections[1]; s.Number != 2 || s.Anchor() != "section-2" || len(s.Capstones) != 1 {
+		t.Errorf("New returned unexpected second section: %+v", s)
+	}
+	if n := c.Count(); n != 3 {
+		t.Errorf("Count returned %d, want 3", n)
+	}
+	if name := c.FileName("pdf"); name != "capstones-2023-2024.pdf" {
+		t.Errorf("FileName returned %q", name)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := HTML(&buf, testCatalogue()); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+
+	for _, expected := range []string{
+		"<title>Capstone Projects 2023/2024</title>",
+		"<p>3 projects</p>",
+		"<p>Generated 1 November 2023</p>",
+		`<li><a href="#section-1">Carpooling System</a></li>`,
+		`<section id="section-2">`,
+		"<h2>2. Smart &lt;Farm&gt; &amp; Co</h2>",
+		"<dt>Contact</dt><dd>Jeremy (Staff)</dd>",
+		"<dt>Team size</dt><dd>1 student</dd>",
+		"<dt>Team size</dt><dd>4 students</dd>",
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("HTML does not contain %q", expected)
+		}
+	}
+	if strings.Contains(page, "<Farm>") {
+		t.Errorf("HTML does not escape record values")
+	}
+}
+
+func TestPDF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := PDF(&buf, testCatalogue()); err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.String()
+
+	if !strings.HasPrefix(doc, "%PDF-") || !strings.HasSuffix(strings.TrimSpace(doc), "%%EOF") {
+		t.Fatalf("PDF is not a complete document")
+	}
+	// A cover, the contents and one page per section
+	if n := len(regexp.MustCompile(`/Type /Page\b[^s]`).FindAllString(doc, -1)); n != 4 {
+		t.Errorf("PDF has %d pages, want 4", n)
+	}
+	// Each contents entry links to its section
+	if n := strings.Count(doc, "/Subtype /Link"); n != 2*len(testCatalogue().Sections) {
+		t.Errorf("PDF has %d links, want %d", n, 2*len(testCatalogue().Sections))
+	}
+}
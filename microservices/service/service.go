@@ -0,0 +1,89 @@
+// Package service holds the pieces shared by the account and record
+// services and the gateway: graceful shutdown, health endpoints and CORS.
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/config" //change here
+
+	"github.com/gorilla/handlers"
+)
+
+// Serve runs srv until ctx is cancelled, then stops accepting connections and
+// waits up to timeout for in-flight requests to finish
+func Serve(ctx context.Context, srv *http.Server, timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		// The listener failed before a shutdown was requested
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Pinger reports whether a dependency such as the database is reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type healthStatus struct {
+	Status string `json:"status"`
+}
+
+// Healthz reports that the process is up and serving requests
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, http.StatusOK, "ok")
+}
+
+// Readyz returns a handler that reports whether p answers within timeout
+func Readyz(p Pinger, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		if err := p.Ping(ctx); err != nil {
+			writeStatus(w, http.StatusServiceUnavailable, "unavailable")
+			return
+		}
+		writeStatus(w, http.StatusOK, "ok")
+	}
+}
+
+func writeStatus(w http.ResponseWriter, code int, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(healthStatus{Status: status})
+}
+
+// CORS applies the configured cross-origin policy. It wraps the whole handler
+// rather than a router so preflight requests are answered before routing.
+func CORS(h config.HTTPConfig) func(http.Handler) http.Handler {
+	return handlers.CORS(
+		handlers.AllowedOrigins(h.AllowedOrigins),
+		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
+		handlers.AllowedHeaders([]string{"Origin", "X-Api-Key", "X-Requested-With", "X-Request-ID", "Content-Type", "Accept", "Authorization", "If-Match"}),
+		handlers.ExposedHeaders([]string{"X-Request-ID", "ETag", "Content-Disposition"}),
+		handlers.AllowCredentials(),
+	)
+}
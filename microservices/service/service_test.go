@@ -0,0 +1,93 @@
+// service_test.go
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type pingerFunc func(ctx context.Context) error
+
+func (f pingerFunc) Ping(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestHealthz(t *testing.T) {
+	req, err := http.NewRequest("GET", "/healthz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	Healthz(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	expected := `{"status":"ok"}` + "\n"
+	if rr.Body.String() != expected {
+		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name     string
+		ping     pingerFunc
+		expected int
+	}{
+		{"reachable", func(ctx context.Context) error { return nil }, http.StatusOK},
+		{"unreachable", func(ctx context.Context) error { return errors.New("connection refused") }, http.StatusServiceUnavailable},
+		{"slow", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/readyz", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			Readyz(tt.ping, 10*time.Millisecond)(rr, req)
+
+			if status := rr.Code; status != tt.expected {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, tt.expected)
+			}
+		})
+	}
+}
+
+func TestServe_Shutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, &http.Server{Addr: "127.0.0.1:0"}, time.Second)
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve returned unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after the context was cancelled")
+	}
+}
+
+func TestServe_ListenError(t *testing.T) {
+	err := Serve(context.Background(), &http.Server{Addr: "127.0.0.1:-1"}, time.Second)
+	if err == nil {
+		t.Errorf("Serve returned no error for an invalid address")
+	}
+}
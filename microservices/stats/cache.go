@@ -0,0 +1,56 @@
+package stats
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache keeps each summary from a Store for ttl, so repeated requests for the
+// overview do not run the aggregate queries again. Errors are not cached. A
+// ttl of 0 turns caching off.
+type Cache struct {
+	store Store
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu      sync.Mutex
+	entries map[Range]cacheEntry
+}
+
+type cacheEntry struct {
+	summary Summary
+	expires time.Time
+}
+
+func NewCache(store Store, ttl time.Duration) *Cache {
+	return &Cache{store: store, ttl: ttl, now: time.Now, entries: make(map[Range]cacheEntry)}
+}
+
+func (c *Cache) Summary(r Range) (Summary, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[r]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.summary, nil
+	}
+
+	// The lock is not held while the store works, so two requests that miss
+	// together may both compute the summary
+	summary, err := c.store.Summary(r)
+	if err != nil || c.ttl <= 0 {
+		return summary, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Drop expired ranges so the cache only holds recently requested ones
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[r] = cacheEntry{summary: summary, expires: now.Add(c.ttl)}
+	return summary, nil
+}
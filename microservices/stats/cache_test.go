@@ -0,0 +1,82 @@
+// cache_test.go
+package stats
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingStore returns a summary numbered by how often it was asked
+type countingStore struct {
+	calls int
+	err   error
+}
+
+func (s *countingStore) Summary(r Range) (Summary, error) {
+	s.calls++
+	if s.err != nil {
+		return Summary{}, s.err
+	}
+	return Summary{Range: r, Records: Records{Total: s.calls}}, nil
+}
+
+func TestCache(t *testing.T) {
+	store := &countingStore{}
+	c := NewCache(store, time.Minute)
+	now := time.Date(2024, time.May, 1, 9, 30, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	summary := func(r Range) int {
+		t.Helper()
+		s, err := c.Summary(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.Records.Total
+	}
+
+	if n := summary(Range{}); n != 1 {
+		t.Errorf("Cache returned summary %d, want 1", n)
+	}
+	now = now.Add(30 * time.Second)
+	if n := summary(Range{}); n != 1 {
+		t.Errorf("Cache did not reuse the summary within the TTL: got %d", n)
+	}
+	// Each range is cached on its own
+	if n := summary(Range{From: "2023/2024"}); n != 2 {
+		t.Errorf("Cache returned summary %d for a new range, want 2", n)
+	}
+	now = now.Add(time.Minute)
+	if n := summary(Range{}); n != 3 {
+		t.Errorf("Cache did not refresh the summary after the TTL: got %d", n)
+	}
+	if len(c.entries) != 1 {
+		t.Errorf("Cache kept %d entries, want only the fresh one", len(c.entries))
+	}
+}
+
+func TestCache_Errors(t *testing.T) {
+	store := &countingStore{err: errors.New("sql: query failed")}
+	c := NewCache(store, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Summary(Range{}); err == nil {
+			t.Fatalf("Cache did not return the store error")
+		}
+	}
+	if store.calls != 2 {
+		t.Errorf("Cache cached an error: store called %d times, want 2", store.calls)
+	}
+}
+
+func TestCache_Disabled(t *testing.T) {
+	store := &countingStore{}
+	c := NewCache(store, 0)
+
+	c.Summary(Range{})
+	c.Summary(Range{})
+	if store.calls != 2 {
+		t.Errorf("Cache with no TTL called the store %d times, want 2", store.calls)
+	}
+}
@@ -0,0 +1,214 @@
+// Package stats summarises the records and accounts in the database for the
+// admin overview, and caches the summaries for a short time.
+package stats
+
+import (
+	"database/sql"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"DevOps_Oct2023_TeamB_Assignment/microservices/audit"    //change here
+	"DevOps_Oct2023_TeamB_Assignment/microservices/validate" //change here
+)
+
+// Range limits the records counted to the academic years from From to To,
+// inclusive. Either may be empty to leave that end open. Accounts have no
+// year and are always counted in full.
+type Range struct {
+	From string `json:"from,omitempty" validate:"omitempty,acadyr"`
+	To   string `json:"to,omitempty" validate:"omitempty,acadyr"`
+}
+
+// Parse reads a range from the from and to parameters of a query string
+func Parse(values url.Values) (Range, validate.Errors) {
+	r := Range{From: values.Get("from"), To: values.Get("to")}
+	if errs := validate.Struct(r); errs != nil {
+		return Range{}, errs
+	}
+	// Academic years sort as text, so they are compared the same way in SQL
+	if r.From != "" && r.To != "" && r.From > r.To {
+		return Range{}, validate.Errors{{Field: "to", Message: "must not be before from"}}
+	}
+	return r, nil
+}
+
+// Count is the number of records or accounts sharing a value
+type Count struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// Summary holds the statistics for one range
+type Summary struct {
+	Range
+	Records     Records  `json:"records"`
+	Accounts    Accounts `json:"accounts"`
+	GeneratedAt string   `json:"generatedAt"`
+}
+
+// Records summarises the records outside the trash. ByCompany lists the
+// companies with the most records first.
+type Records struct {
+	Total         int      `json:"total"`
+	ByAcadYr      []Count  `json:"byAcadYr"`
+	ByCompany     []Count  `json:"byCompany"`
+	RoleOfContact Roles    `json:"roleOfContact"`
+	NoOfStudents  Students `json:"noOfStudents"`
+}
+
+// Roles splits records by the role of their contact
+type Roles struct {
+	Staff   int `json:"staff"`
+	Student int `json:"student"`
+}
+
+// Students totals the team sizes of the records. Average is rounded to two
+// decimal places and is 0 without records.
+type Students struct {
+	Total   int     `json:"total"`
+	Average float64 `json:"average"`
+}
+
+// Accounts summarises the accounts outside the trash. PendingApproval
+// counts the accounts waiting for an admin to approve them.
+type Accounts struct {
+	Total           int            `json:"total"`
+	ByType          []Count        `json:"byType"`
+	ByStatus        []Count        `json:"byStatus"`
+	ByTypeAndStatus []AccountCount `json:"byTypeAndStatus"`
+	PendingApproval int            `json:"pendingApproval"`
+}
+
+// AccountCount is the number of accounts of one type and status
+type AccountCount struct {
+	AccType   string `json:"accType"`
+	AccStatus string `json:"accStatus"`
+	Count     int    `json:"count"`
+}
+
+// NewAccounts totals the counts of each type and status of account
+func NewAccounts(counts []AccountCount) Accounts {
+	a := Accounts{ByType: []Count{}, ByStatus: []Count{}, ByTypeAndStatus: []AccountCount{}}
+	byType := map[string]int{}
+	byStatus := map[string]int{}
+	for _, c := range counts {
+		a.Total += c.Count
+		byType[c.AccType] += c.Count
+		byStatus[c.AccStatus] += c.Count
+		if c.AccStatus == "Pending" {
+			a.PendingApproval += c.Count
+		}
+		a.ByTypeAndStatus = append(a.ByTypeAndStatus, c)
+	}
+	a.ByType = sortedCounts(byType)
+	a.ByStatus = sortedCounts(byStatus)
+	sort.Slice(a.ByTypeAndStatus, func(i, j int) bool {
+		x, y := a.ByTypeAndStatus[i], a.ByTypeAndStatus[j]
+		return x.AccType < y.AccType || x.AccType == y.AccType && x.AccStatus < y.AccStatus
+	})
+	return a
+}
+
+func sortedCounts(m map[string]int) []Count {
+	counts := make([]Count, 0, len(m))
+	for key, n := range m {
+		counts = append(counts, Count{Key: key, Count: n})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Key < counts[j].Key })
+	return counts
+}
+
+// Store computes summaries
+type Store interface {
+	Summary(r Range) (Summary, error)
+}
+
+// MySQLStore computes summaries with aggregate queries, so no rows are read
+// into the service
+type MySQLStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db, now: time.Now}
+}
+
+func (s *MySQLStore) Summary(r Range) (Summary, error) {
+	where, args := r.conditions()
+	summary := Summary{Range: r, GeneratedAt: s.now().UTC().Format(audit.TimeFormat)}
+
+	rec := &summary.Records
+	var average float64
+	err := s.db.QueryRow("SELECT COUNT(*), COALESCE(SUM(NoOfStudents), 0), COALESCE(AVG(NoOfStudents), 0), "+
+		"COALESCE(SUM(RoleOfContact = 'Staff'), 0), COALESCE(SUM(RoleOfContact = 'Student'), 0) FROM Record WHERE "+where, args...).
+		Scan(&rec.Total, &rec.NoOfStudents.Total, &average, &rec.RoleOfContact.Staff, &rec.RoleOfContact.Student)
+	if err != nil {
+		return Summary{}, err
+	}
+	rec.NoOfStudents.Average = math.Round(average*100) / 100
+
+	if rec.ByAcadYr, err = s.counts("SELECT AcadYr, COUNT(*) FROM Record WHERE "+where+" GROUP BY AcadYr ORDER BY AcadYr", args); err != nil {
+		return Summary{}, err
+	}
+	if rec.ByCompany, err = s.counts("SELECT CompanyName, COUNT(*) FROM Record WHERE "+where+" GROUP BY CompanyName ORDER BY COUNT(*) DESC, CompanyName", args); err != nil {
+		return Summary{}, err
+	}
+
+	rows, err := s.db.Query("SELECT AccType, AccStatus, COUNT(*) FROM Account WHERE DeletedAt IS NULL GROUP BY AccType, AccStatus")
+	if err != nil {
+		return Summary{}, err
+	}
+	defer rows.Close()
+	var accounts []AccountCount
+	for rows.Next() {
+		var c AccountCount
+		if err := rows.Scan(&c.AccType, &c.AccStatus, &c.Count); err != nil {
+			return Summary{}, err
+		}
+		accounts = append(accounts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return Summary{}, err
+	}
+	summary.Accounts = NewAccounts(accounts)
+
+	return summary, nil
+}
+
+// counts reads the key and count columns of a grouped query
+func (s *MySQLStore) counts(q string, args []interface{}) ([]Count, error) {
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []Count{}
+	for rows.Next() {
+		var c Count
+		if err := rows.Scan(&c.Key, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// conditions selects the records in the range that are not in the trash
+func (r Range) conditions() (string, []interface{}) {
+	conds := []string{"DeletedAt IS NULL"}
+	var args []interface{}
+	if r.From != "" {
+		conds = append(conds, "AcadYr >= ?")
+		args = append(args, r.From)
+	}
+	if r.To != "" {
+		conds = append(conds, "AcadYr <= ?")
+		args = append(args, r.To)
+	}
+	return strings.Join(conds, " AND "), args
+}
@@ -0,0 +1,127 @@
+// stats_test.go
+package stats
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestParse(t *testing.T) {
+	r, errs := Parse(url.Values{"from": {"2022/2023"}, "to": {"2023/2024"}})
+	if errs != nil || r != (Range{From: "2022/2023", To: "2023/2024"}) {
+		t.Errorf("Parse returned %+v, %v", r, errs)
+	}
+	if r, errs := Parse(url.Values{}); errs != nil || r != (Range{}) {
+		t.Errorf("Parse returned %+v, %v for an open range", r, errs)
+	}
+
+	for _, values := range []url.Values{
+		{"from": {"2023"}},
+		{"to": {"2023/2025"}},
+		{"from": {"2023/2024"}, "to": {"2022/2023"}},
+	} {
+		if _, errs := Parse(values); errs == nil {
+			t.Errorf("Parse accepted %v", values)
+		}
+	}
+}
+
+func TestNewAccounts(t *testing.T) {
+	a := NewAccounts([]AccountCount{
+		{AccType: "User", AccStatus: "Pending", Count: 2},
+		{AccType: "Admin", AccStatus: "Created", Count: 1},
+		{AccType: "User", AccStatus: "Created", Count: 3},
+	})
+
+	if a.Total != 6 || a.PendingApproval != 2 {
+		t.Errorf("NewAccounts returned total %d and %d pending", a.Total, a.PendingApproval)
+	}
+	if expected := []Count{{"Admin", 1}, {"User", 5}}; !reflect.DeepEqual(a.ByType, expected) {
+		t.Errorf("NewAccounts returned wrong types: got %v want %v", a.ByType, expected)
+	}
+	if expected := []Count{{"Created", 4}, {"Pending", 2}}; !reflect.DeepEqual(a.ByStatus, expected) {
+		t.Errorf("NewAccounts returned wrong statuses: got %v want %v", a.ByStatus, expected)
+	}
+	if first := a.ByTypeAndStatus[0]; first.AccType != "Admin" {
+		t.Errorf("NewAccounts did not sort by type and status: %v", a.ByTypeAndStatus)
+	}
+}
+
+func TestMySQLStore_Summary(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	const where = " FROM Record WHERE DeletedAt IS NULL AND AcadYr >= ? AND AcadYr <= ?"
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*), COALESCE(SUM(NoOfStudents), 0), COALESCE(AVG(NoOfStudents), 0), COALESCE(SUM(RoleOfContact = 'Staff'), 0), COALESCE(SUM(RoleOfContact = 'Student'), 0)" + where)).
+		WithArgs("2022/2023", "2023/2024").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)", "SUM", "AVG", "Staff", "Student"}).AddRow(3, 10, 3.3333, 1, 2))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT AcadYr, COUNT(*)" + where + " GROUP BY AcadYr ORDER BY AcadYr")).
+		WithArgs("2022/2023", "2023/2024").
+		WillReturnRows(sqlmock.NewRows([]string{"AcadYr", "COUNT(*)"}).AddRow("2022/2023", 1).AddRow("2023/2024", 2))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT CompanyName, COUNT(*)" + where + " GROUP BY CompanyName ORDER BY COUNT(*) DESC, CompanyName")).
+		WithArgs("2022/2023", "2023/2024").
+		WillReturnRows(sqlmock.NewRows([]string{"CompanyName", "COUNT(*)"}).AddRow("CompanyA", 2).AddRow("CompanyB", 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT AccType, AccStatus, COUNT(*) FROM Account WHERE DeletedAt IS NULL GROUP BY AccType, AccStatus")).
+		WillReturnRows(sqlmock.NewRows([]string{"AccType", "AccStatus", "COUNT(*)"}).AddRow("Admin", "Created", 1).AddRow("User", "Pending", 4))
+
+	s := NewMySQLStore(db)
+	s.now = func() time.Time { return time.Date(2024, time.May, 1, 9, 30, 0, 0, time.UTC) }
+
+	summary, err := s.Summary(Range{From: "2022/2023", To: "2023/2024"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Records{
+		Total:         3,
+		ByAcadYr:      []Count{{"2022/2023", 1}, {"2023/2024", 2}},
+		ByCompany:     []Count{{"CompanyA", 2}, {"CompanyB", 1}},
+		RoleOfContact: Roles{Staff: 1, Student: 2},
+		NoOfStudents:  Students{Total: 10, Average: 3.33},
+	}
+	if !reflect.DeepEqual(summary.Records, expected) {
+		t.Errorf("Summary returned wrong records: got %+v want %+v", summary.Records, expected)
+	}
+	if summary.Accounts.Total != 5 || summary.Accounts.PendingApproval != 4 {
+		t.Errorf("Summary returned wrong accounts: %+v", summary.Accounts)
+	}
+	if summary.GeneratedAt != "2024-05-01 09:30:00" || summary.From != "2022/2023" {
+		t.Errorf("Summary returned wrong range or time: %+v", summary)
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMySQLStore_SummaryError(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*)")).
+		WillReturnError(errors.New("sql: query failed"))
+
+	if _, err := NewMySQLStore(db).Summary(Range{}); err == nil {
+		t.Errorf("Summary did not return the query error")
+	}
+
+	// Verify that the expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,179 @@
+// Package validate checks request bodies against the rules declared in their
+// struct tags, so handlers can reject bad input before it reaches a store.
+//
+// Rules are listed comma-separated in a `validate` tag and apply to string
+// and int fields:
+//
+//	required      the field must not be empty (or zero)
+//	omitempty     skip the remaining rules when the field is empty
+//	min=N, max=N  length in characters for strings, value for ints
+//	maxbytes=N    length in bytes for strings
+//	oneof=A B     the value must be one of the space-separated options
+//	acadyr        an academic year such as 2023/2024
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes why one field was rejected. Field is the JSON name.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// Errors is every rule a value broke, in field order
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Struct checks every tagged field of v, which must be a struct or a pointer
+// to one, and returns nil when all rules pass
+func Struct(v interface{}) Errors {
+	return fields(v, func(string) bool { return true })
+}
+
+// Fields checks only the tagged fields of v with the given JSON names, such
+// as the fields a partial update supplies
+func Fields(v interface{}, names ...string) Errors {
+	return fields(v, func(name string) bool { return contains(names, name) })
+}
+
+func fields(v interface{}, include func(name string) bool) Errors {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rt := rv.Type()
+
+	var errs Errors
+	for i := 0; i < rt.NumField(); i++ {
+		tag, ok := rt.Field(i).Tag.Lookup("validate")
+		if !ok || !include(fieldName(rt.Field(i))) {
+			continue
+		}
+		if msg := check(rv.Field(i), tag); msg != "" {
+			errs = append(errs, FieldError{Field: fieldName(rt.Field(i)), Message: msg})
+		}
+	}
+	return errs
+}
+
+// check applies the rules in tag to one field and returns the first failure
+func check(v reflect.Value, tag string) string {
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			if v.IsZero() {
+				return "is required"
+			}
+		case "omitempty":
+			if v.IsZero() {
+				return ""
+			}
+		case "min", "max":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				panic("validate: bad " + name + " rule " + strconv.Quote(rule))
+			}
+			if msg := bound(v, name, n); msg != "" {
+				return msg
+			}
+		case "maxbytes":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				panic("validate: bad maxbytes rule " + strconv.Quote(rule))
+			}
+			if len(v.String()) > n {
+				return fmt.Sprintf("must be at most %d bytes", n)
+			}
+		case "oneof":
+			options := strings.Fields(arg)
+			if !contains(options, fmt.Sprint(v.Interface())) {
+				return "must be one of " + strings.Join(options, ", ")
+			}
+		case "acadyr":
+			if !isAcadYr(v.String()) {
+				return "must be two consecutive years as YYYY/YYYY"
+			}
+		default:
+			panic("validate: unknown rule " + strconv.Quote(rule))
+		}
+	}
+	return ""
+}
+
+// bound checks a min or max rule against a string's length or an int's value
+func bound(v reflect.Value, name string, n int) string {
+	if v.Kind() == reflect.String {
+		length := utf8.RuneCountInString(v.String())
+		if name == "min" && length < n {
+			return fmt.Sprintf("must be at least %d characters", n)
+		}
+		if name == "max" && length > n {
+			return fmt.Sprintf("must be at most %d characters", n)
+		}
+		return ""
+	}
+
+	value := v.Int()
+	if name == "min" && value < int64(n) {
+		return fmt.Sprintf("must be at least %d", n)
+	}
+	if name == "max" && value > int64(n) {
+		return fmt.Sprintf("must be at most %d", n)
+	}
+	return ""
+}
+
+// isAcadYr reports whether s is two four-digit years a year apart, e.g. 2023/2024
+func isAcadYr(s string) bool {
+	first, second, ok := strings.Cut(s, "/")
+	if !ok || !isYear(first) || !isYear(second) {
+		return false
+	}
+	start, _ := strconv.Atoi(first)
+	end, _ := strconv.Atoi(second)
+	return end == start+1
+}
+
+func isYear(s string) bool {
+	if len(s) != 4 {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldName returns the JSON name of a field, falling back to its Go name
+func fieldName(f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return f.Name
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+// validate_test.go
+package validate
+
+import (
+	"reflect"
+	"testing"
+)
+
+type sample struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name" validate:"required,max=5"`
+	Role   string `json:"role" validate:"omitempty,oneof=Staff Student"`
+	Count  int    `json:"count" validate:"min=1,max=10"`
+	Year   string `json:"year" validate:"required,acadyr"`
+	Secret string `json:"secret,omitempty" validate:"maxbytes=4"`
+	Plain  string `validate:"min=2"`
+}
+
+func TestStruct(t *testing.T) {
+	valid := sample{Name: "Ann", Count: 3, Year: "2023/2024", Secret: "abcd", Plain: "ok"}
+
+	tests := []struct {
+		name     string
+		edit     func(s *sample)
+		expected Errors
+	}{
+		{"valid", func(s *sample) {}, nil},
+		{"optional enum set", func(s *sample) { s.Role = "Staff" }, nil},
+		{"multibyte within limit", func(s *sample) { s.Name = "ééééé" }, nil},
+		{"missing name", func(s *sample) { s.Name = "" }, Errors{{"name", "is required"}}},
+		{"long name", func(s *sample) { s.Name = "Annabel" }, Errors{{"name", "must be at most 5 characters"}}},
+		{"bad enum", func(s *sample) { s.Role = "Parent" }, Errors{{"role", "must be one of Staff, Student"}}},
+		{"count too small", func(s *sample) { s.Count = 0 }, Errors{{"count", "must be at least 1"}}},
+		{"count too large", func(s *sample) { s.Count = 11 }, Errors{{"count", "must be at most 10"}}},
+		{"secret too long", func(s *sample) { s.Secret = "ééé" }, Errors{{"secret", "must be at most 4 bytes"}}},
+		{"go name fallback", func(s *sample) { s.Plain = "x" }, Errors{{"Plain", "must be at least 2 characters"}}},
+		{"several fields", func(s *sample) { s.Name, s.Year = "", "2024" }, Errors{
+			{"name", "is required"},
+			{"year", "must be two consecutive years as YYYY/YYYY"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid
+			tt.edit(&s)
+			if errs := Struct(&s); !reflect.DeepEqual(errs, tt.expected) {
+				t.Errorf("Struct returned unexpected errors: got %v want %v", errs, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFields(t *testing.T) {
+	s := sample{Name: "Annabel", Count: 0, Year: "2024"}
+
+	if errs := Fields(&s, "name"); !reflect.DeepEqual(errs, Errors{{"name", "must be at most 5 characters"}}) {
+		t.Errorf("Fields returned unexpected errors: %v", errs)
+	}
+	if errs := Fields(&s, "count", "year"); len(errs) != 2 || errs[0].Field != "count" || errs[1].Field != "year" {
+		t.Errorf("Fields returned unexpected errors: %v", errs)
+	}
+	if errs := Fields(&s, "role"); errs != nil {
+		t.Errorf("Fields checked fields that were not named: %v", errs)
+	}
+	if errs := Fields(&s); errs != nil {
+		t.Errorf("Fields without names returned errors: %v", errs)
+	}
+}
+
+func TestIsAcadYr(t *testing.T) {
+	tests := map[string]bool{
+		"2023/2024":  true,
+		"1999/2000":  true,
+		"2023/2025":  false,
+		"2024/2023":  false,
+		"2023-2024":  false,
+		"23/24":      false,
+		"+202/+203":  false,
+		"2023/2024/": false,
+		"":           false,
+	}
+
+	for input, expected := range tests {
+		if got := isAcadYr(input); got != expected {
+			t.Errorf("isAcadYr(%q) = %v, want %v", input, got, expected)
+		}
+	}
+}
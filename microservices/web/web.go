@@ -0,0 +1,102 @@
+// Package web serves the static frontend and tells it where the API lives.
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// HomePage is where requests for the site root are sent
+const HomePage = "/templates/signup_login.html"
+
+// Handler serves files from an fs.FS, injecting the API base URL into every
+// HTML page so the scripts call the right origin
+type Handler struct {
+	files   fs.FS
+	apiBase string
+	dev     bool
+}
+
+// New returns a handler for files. apiBase is prefixed to API paths by the
+// scripts; an empty string means the API shares the page's origin. dev turns
+// off caching so edits on disk show up on the next reload.
+func New(files fs.FS, apiBase string, dev bool) *Handler {
+	return &Handler{files: files, apiBase: apiBase, dev: dev}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Path == "/" {
+		http.Redirect(w, r, HomePage, http.StatusFound)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	content, err := fs.ReadFile(h.files, name)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		// Directories cannot be read as files and are not listed
+		if info, statErr := fs.Stat(h.files, name); statErr == nil && info.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	isHTML := path.Ext(name) == ".html"
+	if isHTML {
+		content = h.inject(content)
+	}
+
+	sum := sha256.Sum256(content)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	w.Header().Set("Cache-Control", h.cacheControl(isHTML))
+
+	// ServeContent answers If-None-Match with 304 using the ETag set above
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+}
+
+// cacheControl lets browsers keep scripts and styles for a while but always
+// revalidate pages, so a deploy is picked up on the next navigation
+func (h *Handler) cacheControl(isHTML bool) string {
+	switch {
+	case h.dev:
+		return "no-store"
+	case isHTML:
+		return "no-cache"
+	}
+	return "public, max-age=3600"
+}
+
+// inject adds a script setting window.API_BASE at the start of <head>
+func (h *Handler) inject(page []byte) []byte {
+	base, _ := json.Marshal(h.apiBase)
+	script := []byte("\n    <script>window.API_BASE = " + string(base) + ";</script>")
+
+	i := bytes.Index(page, []byte("<head>"))
+	if i < 0 {
+		return append(script, page...)
+	}
+	i += len("<head>")
+
+	out := make([]byte, 0, len(page)+len(script))
+	out = append(out, page[:i]...)
+	out = append(out, script...)
+	return append(out, page[i:]...)
+}
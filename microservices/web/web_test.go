@@ -0,0 +1,112 @@
+// web_test.go
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"DevOps_Oct2023_TeamB_Assignment/static" //change here
+)
+
+var testFiles = fstest.MapFS{
+	"templates/signup_login.html": {Data: []byte("<html><head><title>Login</title></head></html>")},
+	"js/javascript.js":            {Data: []byte("var ACCOUNT_API = '';")},
+}
+
+func serve(h http.Handler, method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestHandler_HTML(t *testing.T) {
+	rr := serve(New(testFiles, "https://api.example.com", false), "GET", "/templates/signup_login.html", nil)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	// The API base URL is set before any page script runs
+	expected := "<html><head>\n    <script>window.API_BASE = \"https://api.example.com\";</script><title>Login</title></head></html>"
+	if rr.Body.String() != expected {
+		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+	if cc := rr.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("Handler returned wrong Cache-Control: got %v want %v", cc, "no-cache")
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Handler returned wrong Content-Type: %v", ct)
+	}
+}
+
+func TestHandler_Assets(t *testing.T) {
+	h := New(testFiles, "", false)
+
+	rr := serve(h, "GET", "/js/javascript.js", nil)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if rr.Body.String() != "var ACCOUNT_API = '';" {
+		t.Errorf("Handler changed a script: %v", rr.Body.String())
+	}
+	if cc := rr.Header().Get("Cache-Control"); cc != "public, max-age=3600" {
+		t.Errorf("Handler returned wrong Cache-Control: got %v want %v", cc, "public, max-age=3600")
+	}
+
+	// A matching ETag is answered without a body
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Handler returned no ETag")
+	}
+	rr = serve(h, "GET", "/js/javascript.js", http.Header{"If-None-Match": {etag}})
+	if status := rr.Code; status != http.StatusNotModified {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotModified)
+	}
+}
+
+func TestHandler_Dev(t *testing.T) {
+	rr := serve(New(testFiles, "", true), "GET", "/js/javascript.js", nil)
+	if cc := rr.Header().Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("Handler returned wrong Cache-Control: got %v want %v", cc, "no-store")
+	}
+}
+
+func TestHandler_Errors(t *testing.T) {
+	h := New(testFiles, "", false)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{"GET", "/", http.StatusFound},
+		{"GET", "/missing.html", http.StatusNotFound},
+		{"GET", "/templates", http.StatusNotFound},
+		{"GET", "/../static.go", http.StatusNotFound},
+		{"POST", "/js/javascript.js", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		rr := serve(h, tt.method, tt.path, nil)
+		if status := rr.Code; status != tt.status {
+			t.Errorf("%s %s returned wrong status code: got %v want %v", tt.method, tt.path, status, tt.status)
+		}
+	}
+}
+
+func TestHandler_Embedded(t *testing.T) {
+	// Every page the site links to is compiled into the binary
+	h := New(static.Files, "", false)
+	for _, path := range []string{HomePage, "/templates/admin_main.html", "/js/javascript.js", "/js/javascript_admin.js", "/css/style.css"} {
+		if rr := serve(h, "GET", path, nil); rr.Code != http.StatusOK {
+			t.Errorf("GET %s returned wrong status code: got %v want %v", path, rr.Code, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// xlsx_test.go
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// sheet is the part of the worksheet XML the tests read back
+type sheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R      string `xml:"r,attr"`
+			S      string `xml:"s,attr"`
+			T      string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readParts(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name], err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return parts
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "Records <2023/2024>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader("id", "name"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow(7, "Tan & Lim\n<Pte>"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow(8, "late"); err != ErrClosed {
+		t.Errorf("WriteRow after Close returned %v, want %v", err, ErrClosed)
+	}
+
+	parts := readParts(t, buf.Bytes())
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/workbook.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("Workbook has no %s", name)
+		}
+	}
+
+	var wb struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(parts["xl/workbook.xml"], &wb); err != nil {
+		t.Fatal(err)
+	}
+	if len(wb.Sheets) != 1 || wb.Sheets[0].Name != "Records <2023_2024>" {
+		t.Errorf("Workbook has unexpected sheets: %+v", wb.Sheets)
+	}
+
+	var s sheet
+	if err := xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Rows) != 2 || s.Rows[1].R != 2 {
+		t.Fatalf("Sheet has unexpected rows: %+v", s.Rows)
+	}
+	if c := s.Rows[0].Cells[1]; c.R != "B1" || c.S != "1" || c.Inline != "name" {
+		t.Errorf("Header cell is unexpected: %+v", c)
+	}
+	if c := s.Rows[1].Cells[0]; c.R != "A2" || c.T != "" || c.Value != "7" {
+		t.Errorf("Number cell is unexpected: %+v", c)
+	}
+	if c := s.Rows[1].Cells[1]; c.T != "inlineStr" || c.Inline != "Tan & Lim\n<Pte>" {
+		t.Errorf("Text cell is unexpected: %+v", c)
+	}
+}
+
+func TestColumn(t *testing.T) {
+	var got []string
+	for _, i := range []int{0, 1, 25, 26, 27, 701, 702} {
+		got = append(got, column(i))
+	}
+	expected := []string{"A", "B", "Z", "AA", "AB", "ZZ", "AAA"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("column returned %v, want %v", got, expected)
+	}
+}
+
+func TestSheetName(t *testing.T) {
+	tests := map[string]string{
+		"":                                    "Sheet1",
+		"a/b:c":                               "a_b_c",
+		"a name longer than thirty-one chars": "a name longer than thirty-one c",
+	}
+	for name, expected := range tests {
+		if got := sheetName(name); got != expected {
+			t.Errorf("sheetName(%q) = %q, want %q", name, got, expected)
+		}
+	}
+}
@@ -1,8 +1,12 @@
+// Base URL of the account API. The Go server sets window.API_BASE when it
+// serves these pages; opened from disk they talk to the local service.
+var ACCOUNT_API = (typeof window.API_BASE === 'string' ? window.API_BASE : 'http://localhost:5001') + '/api/v1';
+
 function signup(){
     var request = new XMLHttpRequest();
     const form = document.getElementById('signupForm');
 
-    const curl = 'http://localhost:5001/api/v1/accounts';
+    const curl = `${ACCOUNT_API}/accounts`;
 
     const username = form.elements['signup_username'].value;
     const password = form.elements['signup_password'].value;
@@ -10,53 +14,129 @@ function signup(){
     console.log(password);
 
     request.open("POST", curl);
+    request.onload = function() {
+      if (request.status === 201) {
+        form.reset();
+        alert("Account request sent. Please wait for admin approval.");
+      } else {
+        alert("Sign up failed.\n" + describeError(request.responseText));
+      }
+    };
     request.send(JSON.stringify({
         "username": username,
-        "password": password, 
-        "accType": "User", 
-        "accStatus": "Pending"
-        
+        "password": password
     }));
-    form.reset();
-    alert("Account request sent. Please wait for admin approval.");
     return false //prevent default submission
 }
 
-function login(){
+// describeError turns an API error body into a message for the user. Errors
+// are {code, message, details, requestId}; validation_failed lists the
+// rejected fields in details.
+function describeError(text) {
+  let body;
+  try {
+    body = typeof text === 'string' ? JSON.parse(text) : text;
+  } catch (e) {
+    return 'An error occurred. Please try again later.';
+  }
+  if (!body || !body.code) {
+    return 'An error occurred. Please try again later.';
+  }
+  if (body.code === 'validation_failed' && Array.isArray(body.details)) {
+    return body.message + ':\n' + body.details.map(d => `${d.field} ${d.message}`).join('\n');
+  }
+  return body.message;
+}
+
+// login signs in with the form's credentials; newPassword replaces a
+// generated password, which the API requires at the first login
+function login(newPassword){
     var request = new XMLHttpRequest();
     const form = document.getElementById('loginForm');
     const username = form.elements['login_username'].value;
     const password = form.elements['login_password'].value;
     console.log(username);
-    console.log(password);
 
-    const curl = 'http://localhost:5001/api/v1/accounts?username=' + encodeURIComponent(username) + '&password=' + encodeURIComponent(password);
+    const curl = `${ACCOUNT_API}/auth/login`;
     console.log(curl);
 
-    request.open("GET", curl);
+    request.open("POST", curl);
+    request.setRequestHeader('Content-Type', 'application/json');
     request.onreadystatechange = function() {
       if (request.readyState === 4) {
         if (request.status === 200) {
-          // Successful login, redirect to main page
-          location.href = "/static/templates/user_details.html";
-        } else if (request.status === 401) {
+          // Successful login, keep the session token and redirect to main page
+          const session = JSON.parse(request.responseText);
+          localStorage.setItem('token', session.token);
+          localStorage.setItem('accType', session.accType);
+          location.href = "../templates/user_details.html";
+        } else if (errorCode(request.responseText) === 'invalid_credentials') {
           // Login failed, handle error
           form.reset();
-          document.getElementById('error-message').innerHTML = 'Incorrect Phone Number or Password.';
+          document.getElementById('error-message').innerHTML = 'Incorrect Username or Password.';
+        } else if (errorCode(request.responseText) === 'password_change_required') {
+          // The password was generated by an admin and must be replaced
+          const chosen = prompt('Your password was generated for you. Choose a new password:');
+          if (chosen) {
+            login(chosen);
+          }
+        } else if (errorCode(request.responseText) === 'validation_failed') {
+          document.getElementById('error-message').innerText = describeError(request.responseText);
         } else {
           // Handle other status codes or network errors
           document.getElementById('error-message').innerHTML = 'An error occurred. Please try again later.';
         }
       }
     };
-    request.send();
+    request.send(JSON.stringify({
+        "username": username,
+        "password": password,
+        "newPassword": newPassword || ""
+    }));
     return false
 }
 
-function listUsers() {
+// errorCode returns the code of an API error body, or '' when there is none
+function errorCode(text) {
+  try {
+    return JSON.parse(text).code || '';
+  } catch (e) {
+    return '';
+  }
+}
+
+// Headers carrying the session token for authenticated requests
+function authHeaders() {
+  return {
+    'Authorization': 'Bearer ' + localStorage.getItem('token'),
+  };
+}
+
+async function logout() {
+  const url = `${ACCOUNT_API}/auth/logout`;
+
+  try {
+    await fetch(url, {
+      method: 'POST',
+      headers: authHeaders(),
+    });
+  } catch (error) {
+    console.error("Error logging out: ", error);
+  }
+
+  localStorage.removeItem('token');
+  localStorage.removeItem('accType');
+  location.href = "../templates/signup_login.html";
+}
+
+// listUsers fills the user table, following nextCursor until every page
+// has been appended
+function listUsers(cursor) {
   // Make a GET request to the server endpoint
-  const url = `http://localhost:5001/api/v1/accounts/all`;
-  fetch(url)
+  const url = `${ACCOUNT_API}/accounts/all` + (cursor ? `?cursor=${encodeURIComponent(cursor)}` : '');
+  fetch(url, {
+    headers: authHeaders(),
+  })
     .then(response => {
       if (!response.ok) {
           throw new Error(`HTTP error! Status: ${response.status}`);
@@ -69,11 +149,13 @@ function listUsers() {
       // Get the table body element
       var tableBody = document.getElementById('user_details_table').getElementsByTagName('tbody')[0];
 
-      // Clear existing rows
-      tableBody.innerHTML = '';
+      // Clear existing rows before the first page
+      if (!cursor) {
+        tableBody.innerHTML = '';
+      }
 
       // Iterate through the received data and append rows to the table
-      data.forEach(user => {
+      data.items.forEach(user => {
         var row = tableBody.insertRow();
         row.innerHTML = `<td>${user.accId}</td>
                         <td>${user.username}</td>
@@ -87,6 +169,10 @@ function listUsers() {
                           <button class="btn btn-outline-secondary" onclick="return deleteUser(${user.accId})">delete</button>
                         </td>`;
       });
+
+      if (data.nextCursor) {
+        listUsers(data.nextCursor);
+      }
     })
     .catch(error => console.error('Error fetching user details:', error));
 }
@@ -94,19 +180,20 @@ function listUsers() {
 // Function to delete a user (replace this with your actual delete logic)
 function deleteUser(userId) {
   console.log('Deleting user with ID:', userId);
-  const url = `http://localhost:5001/api/v1/accounts/delete?accID=${userId}`;
+  const url = `${ACCOUNT_API}/accounts/delete?accID=${userId}`;
 
   // Confirm deletion with the user (you can customize this)
   if (confirm("Are you sure you want to delete this user?")) {
   //     // Make a DELETE request to the server endpoint
       fetch(url, {
         method: 'DELETE',
+        headers: authHeaders(),
       })
       .then(response => {
         if (!response.ok) {
             throw new Error(`HTTP error! Status: ${response.status}`);
         }
-        return response.text(); // assuming the server returns a text response
+        return response.json();
       })
       .then(data => {
         console.log('Server response:', data);
@@ -122,18 +209,31 @@ function deleteUser(userId) {
 // Function to modify a user (replace this with your actual modify logic)
 function modifyUser(userId) {
   console.log('Modifying user with ID:', userId);
-  const url = `http://localhost:5001/api/v1/accounts/get?accID=${userId}`
+  const url = `${ACCOUNT_API}/accounts/get?accID=${userId}`
   // Fetch user details by userId
-  fetch(url)
+  fetch(url, {
+    headers: authHeaders(),
+  })
   .then(response => {
     if (!response.ok) {
       throw new Error(`HTTP error! Status: ${response.status}`);
     }
+    // The ETag names the version being edited; the update sends it back.
+    // Without it the update could only overwrite blindly, so do not edit.
+    const etag = response.headers.get('ETag');
+    if (!etag) {
+      throw new Error('The account was loaded without its version');
+    }
+    localStorage.setItem('modifyUserETag', etag);
     return response.json();
   })
   .then(user => {
     localStorage.setItem('modifyUserData', JSON.stringify(user));
-    location.href = "/static/templates/modify_user.html";
+    location.href = "../templates/modify_user.html";
+  })
+  .catch(error => {
+    console.error('Error loading the account:', error);
+    alert("The account could not be loaded for editing. Please try again.");
   })
 
 }
@@ -145,16 +245,26 @@ async function submitModification() {
   const form = document.getElementById('modifyForm');
   const accID = document.getElementById('modify_accID').innerHTML;
 
-  const url = `http://localhost:5001/api/v1/accounts/${accID}`;
+  const url = `${ACCOUNT_API}/accounts/${accID}`;
 
   const username = form.elements['modify_username'].value;
   const accType = document.getElementById('modify_user').checked ? 'User' : 'Admin';
 
+  // Never fall back to If-Match: *, which would overwrite other changes
+  const etag = localStorage.getItem('modifyUserETag');
+  if (!etag) {
+    alert("The version of this account is unknown, so it will be reloaded. Please apply your changes again.");
+    modifyUser(accID);
+    return;
+  }
+
   try {
     const response = await fetch(url, {
       method: 'PUT',
       headers: {
         'Content-Type': 'application/json',
+        'If-Match': etag,
+        ...authHeaders(),
       },
       body: JSON.stringify({
         "username": username,
@@ -165,10 +275,17 @@ async function submitModification() {
 
     if (response.ok) {
       console.log("Update successful");
-      window.location.href = "/static/templates/user_details.html";
+      localStorage.removeItem('modifyUserETag');
+      window.location.href = "../templates/user_details.html";
+    } else if (response.status === 412) {
+      // Someone else saved the account after it was loaded here
+      const errorText = await response.text();
+      if (confirm(describeError(errorText) + "\n\nLoad the latest version? Your changes will be lost.")) {
+        modifyUser(accID);
+      }
     } else {
       const errorText = await response.text();
-      alert("Error updating the Account Details. Status: " + response.status + "\n" + errorText);
+      alert("Error updating the Account Details.\n" + describeError(errorText));
     }
   } catch (error) {
     console.error("Error updating the Account Details:", error);
@@ -183,13 +300,14 @@ async function submitModification() {
 async function approveUser(userId){
   event.preventDefault();
 
-  const url = `http://localhost:5001/api/v1/accounts/approve?accID=${userId}`
+  const url = `${ACCOUNT_API}/accounts/approve?accID=${userId}`
 
   try {
     const response = await fetch(url, {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
+        ...authHeaders(),
       },
       body: JSON.stringify({
         "accStatus": "Created"
@@ -198,10 +316,10 @@ async function approveUser(userId){
 
     if (response.ok) {
       console.log("Update successful");
-      window.location.href = "/static/templates/user_details.html";
+      window.location.href = "../templates/user_details.html";
     } else {
       const errorText = await response.text();
-      alert("Error approving the Account. Status: " + response.status + "\n" + errorText);
+      alert("Error approving the Account.\n" + describeError(errorText));
     }
   } catch (error) {
     console.error("Error approving the Account: ", error);
@@ -209,3 +327,34 @@ async function approveUser(userId){
   }
 
 }
+
+// Fill the overview on the admin page from the statistics endpoint
+async function loadStats() {
+  const overview = document.getElementById('stats');
+  if (!overview) {
+    return;
+  }
+
+  try {
+    const response = await fetch(`${ACCOUNT_API}/stats`, { headers: authHeaders() });
+    if (!response.ok) {
+      throw new Error(describeError(await response.text()));
+    }
+    const stats = await response.json();
+
+    const values = {
+      stats_records: stats.records.total,
+      stats_students: `${stats.records.noOfStudents.total} (average ${stats.records.noOfStudents.average})`,
+      stats_roles: `${stats.records.roleOfContact.staff} staff, ${stats.records.roleOfContact.student} student`,
+      stats_years: stats.records.byAcadYr.map(c => `${c.key}: ${c.count}`).join(', ') || '-',
+      stats_accounts: stats.accounts.total,
+      stats_pending: stats.accounts.pendingApproval,
+    };
+    for (const [id, value] of Object.entries(values)) {
+      document.getElementById(id).textContent = value;
+    }
+    overview.hidden = false;
+  } catch (error) {
+    console.error('Error loading statistics: ', error);
+  }
+}
@@ -1,3 +1,7 @@
+// Base URL of the record API. The Go server sets window.API_BASE when it
+// serves these pages; opened from disk they talk to the local service.
+var RECORD_API = (typeof window.API_BASE === 'string' ? window.API_BASE : 'http://localhost:5002') + '/api/v1';
+
 /*
 
     router.HandleFunc("/api/v1/records/all", ListAllRecordsHandler).Methods("GET")
@@ -45,10 +49,19 @@ function createCapstone() {
     console.log(companyContact);
     console.log(projDesc);
 
-    const curl = `http://localhost:5002/api/v1/records`;
+    const curl = `${RECORD_API}/records`;
     console.log(curl);
 
     request.open("POST", curl);
+    request.setRequestHeader('Authorization', 'Bearer ' + localStorage.getItem('token'));
+    request.onload = function() {
+        if (request.status === 201) {
+            alert("Capstone record is created.");
+            form.reset();
+        } else {
+            alert("Capstone record was not created.\n" + describeError(request.responseText));
+        }
+    };
 
     request.send(JSON.stringify ({
         "name": name,
@@ -60,16 +73,58 @@ function createCapstone() {
         "companyContact": companyContact,
         "projDesc": projDesc,
     }));
+}
 
-    console.log("Request Status: ", request.status);
-    alert("Capstone record is created.")
-    form.reset();
-
+// Import capstone records from a CSV file. A dry run only reports which
+// rows would be rejected.
+function importCapstones(dryRun) {
+    const file = document.getElementById('capstone_importFile').files[0];
+    if (!file) {
+        alert("Choose a CSV file first.");
+        return;
+    }
+    const atomic = document.getElementById('capstone_importAtomic').checked;
+    const url = `${RECORD_API}/records/import?dryRun=${dryRun}&atomic=${atomic}`;
+
+    file.text().then(csv => fetch(url, {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'text/csv',
+            'Authorization': 'Bearer ' + localStorage.getItem('token'),
+        },
+        body: csv,
+    })).then(response => response.json().then(body => {
+        // A report comes back on success and as the details of a rejected import
+        const report = response.ok ? body : body.details;
+        if (!report || !Array.isArray(report.rows)) {
+            alert("Capstone records were not imported.\n" + describeError(body));
+            return;
+        }
+        const problems = report.rows
+            .filter(row => row.error)
+            .map(row => `Line ${row.line}: ${row.error}`);
+        let summary;
+        if (dryRun) {
+            summary = `${report.valid} of ${report.total} rows can be imported.`;
+        } else if (response.ok) {
+            summary = `${report.created} capstone records were imported.`;
+        } else {
+            summary = "No capstone records were imported.";
+        }
+        alert([summary].concat(problems).join('\n'));
+    })).catch(error => {
+        console.error('Error:', error);
+        alert("Capstone records were not imported.");
+    });
 }
 
-function listCapstones() {
-    const url = `http://localhost:5002/api/v1/records/all`;
-    fetch(url)
+// listCapstones fills the record table, following nextCursor until every
+// page has been appended
+function listCapstones(cursor) {
+    const url = `${RECORD_API}/records/all` + (cursor ? `?cursor=${encodeURIComponent(cursor)}` : '');
+    fetch(url, {
+        headers: { 'Authorization': 'Bearer ' + localStorage.getItem('token') },
+    })
         .then(response => {
             if(!response.ok) {
                 throw new Error(`HTTP error! Status: ${response.status}`);
@@ -81,9 +136,11 @@ function listCapstones() {
 
             var tableBody = document.getElementById('allcapstone');
 
-            tableBody.innerHTML = '';
+            if (!cursor) {
+                tableBody.innerHTML = '';
+            }
 
-            data.forEach(record => {
+            data.items.forEach(record => {
                 var row = tableBody.insertRow();
                 //Edit the Javascript Function !!!!
                 row.innerHTML = `   <th scope="row">${record.recordId}</th>
@@ -101,6 +158,10 @@ function listCapstones() {
                                     </td>
                                 `;
             });
+
+            if (data.nextCursor) {
+                listCapstones(data.nextCursor);
+            }
         })
         .catch(error => console.error('Error fetching record details: ', error))
 }
@@ -109,7 +170,7 @@ function queryCapstone() {
     var request = new XMLHttpRequest();
     const form = document.getElementById('querycapstone');
 
-    const curl = 'http://localhost:5001/api/v1/records/search';
+    const curl = `${RECORD_API}/records/search`;
 
     //HTML VALUE 
     const queryacadYr = form.elements['query_acadYr'].value;
@@ -121,3 +182,37 @@ function queryCapstone() {
 
     
 }
+
+// Download the records matching the query form as a file the browser saves
+function exportCapstones(format) {
+    const form = document.getElementById('querycapstone');
+    const params = new URLSearchParams({ format: format });
+    const acadYr = form.elements['query_acadYr'].value.trim();
+    const keyword = form.elements['query_keyword'].value.trim();
+    if (acadYr) {
+        params.set('acadYr', acadYr);
+    }
+    if (keyword) {
+        params.set('q', keyword);
+    }
+
+    fetch(`${RECORD_API}/records/export?${params}`, { headers: authHeaders() })
+        .then(response => {
+            if (!response.ok) {
+                return response.text().then(text => { throw new Error(describeError(text)); });
+            }
+            const disposition = response.headers.get('Content-Disposition') || '';
+            const match = disposition.match(/filename="([^"]+)"/);
+            return response.blob().then(blob => {
+                const link = document.createElement('a');
+                link.href = URL.createObjectURL(blob);
+                link.download = match ? match[1] : `records.${format}`;
+                link.click();
+                URL.revokeObjectURL(link.href);
+            });
+        })
+        .catch(error => {
+            console.error('Error exporting records: ', error);
+            alert("Capstone records were not exported.\n" + error.message);
+        });
+}
@@ -0,0 +1,8 @@
+// Package static embeds the frontend pages, scripts and styles so the
+// console binary can serve them without the source tree.
+package static
+
+import "embed"
+
+//go:embed css js templates
+var Files embed.FS